@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var addressesCmd = &cobra.Command{
+	Use:   "addresses <lines_table>",
+	Short: "Interpolate address points along lines with address ranges",
+	Long: `Create a point for every house number in the address ranges of a line table,
+e.g. TIGER/Line edges, as a point table for geocoding.
+
+Name the columns holding the range of each side with --left and --right:
+either two columns with the numbers at the first and last point of the line
+(LFROMADD,LTOADD) or one column holding both (e.g. "100-198"). House numbers
+are read up to the first non-digit, so 123A counts as 123. When both ends of
+a range are odd or both even, it holds every other number.
+
+The numbers are spaced evenly along the line and moved --offset to their
+side. --offset takes a unit (10m) for lon/lat tables, where lengths are
+measured on the sphere, or a bare number in the units of a projected CRS.
+
+Each point carries the columns of its line plus house_number and
+address_side (left or right). Ranges that can't be parsed, or span more
+than 10000 numbers, are skipped and counted.`,
+	Example: `  xyzduck addresses edges --db tiger --left LFROMADD,LTOADD --right RFROMADD,RTOADD --offset 10m --out address_points
+  xyzduck addresses streets --db city --right house_range --offset 5 --out address_points`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAddresses,
+}
+
+var (
+	addressesDBFlag     string
+	addressesOutFlag    string
+	addressesLeftFlag   string
+	addressesRightFlag  string
+	addressesOffsetFlag string
+)
+
+func init() {
+	addressesCmd.Flags().StringVar(&addressesDBFlag, "db", "", "Target database file (required)")
+	addressesCmd.Flags().StringVar(&addressesOutFlag, "out", "", "Output table name, optionally schema-qualified (required)")
+	addressesCmd.Flags().StringVar(&addressesLeftFlag, "left", "", "Columns of the left side's range: from,to or a single range column")
+	addressesCmd.Flags().StringVar(&addressesRightFlag, "right", "", "Columns of the right side's range: from,to or a single range column")
+	addressesCmd.Flags().StringVar(&addressesOffsetFlag, "offset", "0m", "Distance points are moved off the line, e.g. 10m or CRS units")
+	addressesCmd.MarkFlagRequired("db")
+	addressesCmd.MarkFlagRequired("out")
+
+	rootCmd.AddCommand(addressesCmd)
+}
+
+func runAddresses(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	if addressesLeftFlag == "" && addressesRightFlag == "" {
+		return fmt.Errorf("at least one of --left and --right is required")
+	}
+	offset, err := database.ParseDistance(addressesOffsetFlag)
+	if err != nil {
+		return err
+	}
+
+	var sides []database.AddressSide
+	for _, flag := range []struct{ side, value string }{{"left", addressesLeftFlag}, {"right", addressesRightFlag}} {
+		if flag.value == "" {
+			continue
+		}
+		side, err := database.ParseAddressSide(flag.side, flag.value)
+		if err != nil {
+			return err
+		}
+		sides = append(sides, side)
+	}
+
+	dbPath, unlock, err := prepareOutputTable(addressesDBFlag, srcTable, addressesOutFlag)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	columns, err := database.GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return fmt.Errorf("failed to get table schema: %w", err)
+	}
+	for _, col := range columns {
+		for _, added := range database.AddressColumns {
+			if strings.EqualFold(col.Name, added) {
+				return fmt.Errorf("table %s already has a %s column", srcTable, col.Name)
+			}
+		}
+	}
+
+	fmt.Printf("Interpolating addresses along '%s'...\n", srcTable)
+	result, err := database.InterpolateAddresses(dbPath, srcTable, addressesOutFlag, database.AddressOptions{
+		Sides:  sides,
+		Offset: offset.Value,
+		Planar: !offset.Meters,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to interpolate addresses: %w", err)
+	}
+
+	fmt.Printf("✓ Created %d address points from %d ranges of %d lines in table '%s'\n",
+		result.Points, result.Ranges-result.Invalid, result.Lines, addressesOutFlag)
+	if result.Invalid > 0 {
+		fmt.Printf("! %d ranges could not be parsed or were too wide and were skipped\n", result.Invalid)
+	}
+	return nil
+}
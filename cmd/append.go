@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var (
+	appendDBFlag   string
+	appendFromFlag string
+	appendToFlag   string
+	appendMapFlag  []string
+)
+
+var appendCmd = &cobra.Command{
+	Use:   "append",
+	Short: "Append the rows of one table to another",
+	Long: `Copy every row of --from into the existing table --to.
+
+Columns are matched by name. Use --map source=target (repeatable) to copy a
+source column into a differently named target column. Values are checked
+against the target column types before anything is written, so an
+incompatible mapping fails without copying a partial table. Target columns
+with no source are left NULL.`,
+	Example: `  xyzduck append --db geodata --from cities_2023 --to cities
+  xyzduck append --db geodata --from import_7 --to roads --map road_name=name --map lanes=lane_count`,
+	Args: cobra.NoArgs,
+	RunE: runAppend,
+}
+
+func init() {
+	appendCmd.Flags().StringVar(&appendDBFlag, "db", "", "Target database file (required)")
+	appendCmd.Flags().StringVar(&appendFromFlag, "from", "", "Table to copy rows from (required)")
+	appendCmd.Flags().StringVar(&appendToFlag, "to", "", "Table to append rows to (required)")
+	appendCmd.Flags().StringArrayVar(&appendMapFlag, "map", nil, "Copy a source column into a target column, as source=target (repeatable)")
+	appendCmd.MarkFlagRequired("db")
+	appendCmd.MarkFlagRequired("from")
+	appendCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(appendCmd)
+}
+
+func runAppend(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(appendDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	mapping := make(map[string]string)
+	for _, m := range appendMapFlag {
+		source, target, ok := strings.Cut(m, "=")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" {
+			return fmt.Errorf("invalid --map %q (expected source=target)", m)
+		}
+		mapping[source] = target
+	}
+
+	fmt.Printf("Appending '%s' to '%s'...\n", appendFromFlag, appendToFlag)
+
+	result, err := database.AppendTable(dbPath, appendFromFlag, appendToFlag, mapping)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Copied %d rows into '%s'\n", result.Rows, appendToFlag)
+
+	fmt.Println("\nColumns:")
+	for _, m := range result.Mapped {
+		if m.Source.Name == m.Target.Name {
+			fmt.Printf("  %s (%s)\n", m.Target.Name, m.Target.Type)
+		} else {
+			fmt.Printf("  %s (%s) <- %s (%s)\n", m.Target.Name, m.Target.Type, m.Source.Name, m.Source.Type)
+		}
+	}
+	if len(result.Defaulted) > 0 {
+		fmt.Printf("! Left NULL in '%s': %s\n", appendToFlag, strings.Join(result.Defaulted, ", "))
+	}
+	if len(result.Unmapped) > 0 {
+		fmt.Printf("! Not copied from '%s': %s\n", appendFromFlag, strings.Join(result.Unmapped, ", "))
+	}
+
+	notifyChange("append", dbPath, appendToFlag, result.Rows)
+
+	return nil
+}
@@ -0,0 +1,464 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/query"
+)
+
+var browseDBFlag string
+
+var browseCmd = &cobra.Command{
+	Use:   "browse",
+	Short: "Browse the tables of a database interactively",
+	Long: `Open a table browser on a database.
+
+The first screen lists the tables with their row counts and columns. Enter
+opens a table a page of rows at a time: ↑/↓ select a row, PgUp/PgDn (or p/n)
+change page, Home/End jump to the first or last page and ←/→ scroll wide
+tables. Enter on a row shows all of its values, with geometries as full WKT;
+←/→ there step to the previous or next row.
+
+Esc goes back a screen and q quits.`,
+	Example: `  xyzduck browse --db geodata`,
+	Args:    cobra.NoArgs,
+	RunE:    runBrowse,
+}
+
+func init() {
+	browseCmd.Flags().StringVar(&browseDBFlag, "db", "", "Database file (required)")
+	browseCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(browseCmd)
+}
+
+func runBrowse(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(browseDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return err
+	}
+
+	tables, err := query.Tables(db)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		fmt.Println("No tables in database")
+		return nil
+	}
+
+	p := tea.NewProgram(newBrowseModel(db, dbPath, tables), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("error running browser: %w", err)
+	}
+	return nil
+}
+
+// browseScreen is what the browser shows
+type browseScreen int
+
+const (
+	browseTables browseScreen = iota
+	browseRows
+	browseRecord
+)
+
+// browseCellWidth caps the width of a cell in the rows screen
+const browseCellWidth = 40
+
+// browsePageMsg carries a page of rows read in the background
+type browsePageMsg struct {
+	offset int64
+	result *query.Result
+	// selectLast moves the cursor to the last row of the page, when paging
+	// up past the first row
+	selectLast bool
+	err        error
+}
+
+// TUI Model for the table browser
+type browseModel struct {
+	db     *sql.DB
+	dbPath string
+	tables []query.TableInfo
+	screen browseScreen
+	width  int
+	ready  bool
+	view   viewport.Model
+
+	// cursor is the selected table
+	cursor int
+
+	page    *query.Result
+	offset  int64
+	row     int
+	loading bool
+	err     error
+}
+
+func newBrowseModel(db *sql.DB, dbPath string, tables []query.TableInfo) browseModel {
+	return browseModel{db: db, dbPath: dbPath, tables: tables}
+}
+
+func (m browseModel) Init() tea.Cmd {
+	return nil
+}
+
+// pageSize is the number of rows that fit below the header of the rows screen
+func (m browseModel) pageSize() int64 {
+	return int64(max(m.view.Height-2, 1))
+}
+
+// table returns the selected table
+func (m browseModel) table() query.TableInfo {
+	return m.tables[m.cursor]
+}
+
+// loadPage reads a page of the selected table in the background
+func (m browseModel) loadPage(offset int64, selectLast bool) (browseModel, tea.Cmd) {
+	m.loading = true
+	db, table, limit := m.db, m.table().Name, m.pageSize()
+	return m, func() tea.Msg {
+		result, err := query.Page(db, table, limit, offset)
+		return browsePageMsg{offset: offset, result: result, selectLast: selectLast, err: err}
+	}
+}
+
+func (m browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the title, status and help lines
+		m.width = msg.Width
+		height := max(msg.Height-4, 3)
+		if !m.ready {
+			m.view = viewport.New(msg.Width, height)
+			m.view.SetHorizontalStep(8)
+			m.ready = true
+		} else {
+			m.view.Width, m.view.Height = msg.Width, height
+		}
+		m.render()
+		return m, nil
+
+	case browsePageMsg:
+		m.loading = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.page, m.offset = msg.result, msg.offset
+			m.row = 0
+			if msg.selectLast {
+				m.row = max(len(m.page.Rows)-1, 0)
+			}
+		}
+		m.render()
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" || msg.String() == "q" {
+			return m, tea.Quit
+		}
+		if m.loading {
+			return m, nil
+		}
+		switch m.screen {
+		case browseTables:
+			return m.updateTables(msg)
+		case browseRows:
+			return m.updateRows(msg)
+		case browseRecord:
+			return m.updateRecord(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m browseModel) updateTables(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.tables)-1 {
+			m.cursor++
+		}
+	case "enter":
+		m.screen = browseRows
+		m.page = nil
+		m.err = nil
+		m.view.SetXOffset(0)
+		m.render()
+		return m.loadPage(0, false)
+	}
+	m.render()
+	return m, nil
+}
+
+func (m browseModel) updateRows(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.page == nil {
+		if msg.String() == "esc" || msg.String() == "backspace" {
+			m.screen = browseTables
+			m.err = nil
+			m.render()
+		}
+		return m, nil
+	}
+
+	size, total := m.pageSize(), m.table().Rows
+	lastPage := max((total-1)/size*size, 0)
+
+	switch msg.String() {
+	case "esc", "backspace":
+		m.screen = browseTables
+		m.render()
+		return m, nil
+	case "up", "k":
+		if m.row > 0 {
+			m.row--
+		} else if m.offset > 0 {
+			return m.loadPage(max(m.offset-size, 0), true)
+		}
+	case "down", "j":
+		if m.row < len(m.page.Rows)-1 {
+			m.row++
+		} else if m.offset+size < total {
+			return m.loadPage(m.offset+size, false)
+		}
+	case "pgdown", "n", " ":
+		if m.offset+size < total {
+			return m.loadPage(m.offset+size, false)
+		}
+	case "pgup", "p":
+		if m.offset > 0 {
+			return m.loadPage(max(m.offset-size, 0), false)
+		}
+	case "home", "g":
+		return m.loadPage(0, false)
+	case "end", "G":
+		return m.loadPage(lastPage, false)
+	case "left", "h":
+		m.view.ScrollLeft(8)
+		return m, nil
+	case "right", "l":
+		m.view.ScrollRight(8)
+		return m, nil
+	case "enter":
+		if len(m.page.Rows) > 0 {
+			m.screen = browseRecord
+			m.view.SetXOffset(0)
+			m.render()
+			m.view.GotoTop()
+		}
+		return m, nil
+	}
+	m.render()
+	return m, nil
+}
+
+func (m browseModel) updateRecord(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc", "backspace":
+		m.screen = browseRows
+		m.render()
+		m.view.GotoTop()
+		return m, nil
+	case "left", "h":
+		if m.row > 0 {
+			m.row--
+		} else if m.offset > 0 {
+			return m.loadPage(max(m.offset-m.pageSize(), 0), true)
+		}
+		m.render()
+		m.view.GotoTop()
+		return m, nil
+	case "right", "l":
+		if m.row < len(m.page.Rows)-1 {
+			m.row++
+		} else if m.offset+m.pageSize() < m.table().Rows {
+			return m.loadPage(m.offset+m.pageSize(), false)
+		}
+		m.render()
+		m.view.GotoTop()
+		return m, nil
+	}
+
+	m.view, cmd = m.view.Update(msg)
+	return m, cmd
+}
+
+// render fills the viewport with the current screen
+func (m *browseModel) render() {
+	if !m.ready {
+		return
+	}
+	switch m.screen {
+	case browseTables:
+		m.view.SetContent(m.renderTables())
+		// Keep the selected table in sight
+		if m.cursor < m.view.YOffset {
+			m.view.SetYOffset(m.cursor)
+		} else if m.cursor >= m.view.YOffset+m.view.Height-1 {
+			m.view.SetYOffset(m.cursor - m.view.Height + 2)
+		}
+	case browseRows:
+		m.view.SetContent(m.renderRows())
+	case browseRecord:
+		m.view.SetContent(m.renderRecord())
+	}
+}
+
+func (m browseModel) renderTables() string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TABLE\tROWS\tCOLUMNS")
+	for i, t := range m.tables {
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		columns := make([]string, len(t.Columns))
+		for j, col := range t.Columns {
+			columns[j] = col.Name + " " + col.Type
+		}
+		fmt.Fprintf(tw, "%s%s\t%d\t%s\n", cursor, t.Name, t.Rows, strings.Join(columns, ", "))
+	}
+	tw.Flush()
+
+	// Cut the column lists at the screen edge rather than scrolling sideways
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = truncate(line, m.width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m browseModel) renderRows() string {
+	if m.err != nil {
+		return fmt.Sprintf("Error: %v", m.err)
+	}
+	if m.page == nil {
+		return "Loading..."
+	}
+
+	// Shorten long values, geometries especially, to keep rows on screen
+	short := &query.Result{Columns: m.page.Columns, Types: m.page.Types, Geometry: m.page.Geometry}
+	for _, row := range m.page.Rows {
+		cells := make([]any, len(row))
+		for i, v := range row {
+			if v != nil {
+				v = truncate(query.Text(v, m.page.Types[i]), browseCellWidth)
+			}
+			cells[i] = v
+		}
+		short.Rows = append(short.Rows, cells)
+	}
+
+	var b strings.Builder
+	if err := query.WriteTable(&b, short); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		cursor := "  "
+		if i-2 == m.row {
+			cursor = "> "
+		}
+		lines[i] = cursor + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m browseModel) renderRecord() string {
+	row := m.page.Rows[m.row]
+	width := max(m.width-4, 20)
+
+	var b strings.Builder
+	for i, name := range m.page.Columns {
+		fmt.Fprintf(&b, "%s (%s)\n", name, m.page.Types[i])
+		value := "NULL"
+		if row[i] != nil {
+			value = query.Text(row[i], m.page.Types[i])
+		}
+		for _, line := range strings.Split(value, "\n") {
+			runes := []rune(line)
+			for len(runes) > width {
+				fmt.Fprintf(&b, "    %s\n", string(runes[:width]))
+				runes = runes[width:]
+			}
+			fmt.Fprintf(&b, "    %s\n", string(runes))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (m browseModel) View() string {
+	if !m.ready {
+		return "\nStarting...\n"
+	}
+
+	var title, status, help string
+	switch m.screen {
+	case browseTables:
+		title = m.dbPath
+		status = fmt.Sprintf("%d tables", len(m.tables))
+		help = "(↑/↓ select, enter to open, q to quit)"
+	case browseRows:
+		t := m.table()
+		title = t.Name
+		switch {
+		case m.loading:
+			status = "Loading..."
+		case m.page != nil && len(m.page.Rows) > 0:
+			status = fmt.Sprintf("Rows %d-%d of %d", m.offset+1, m.offset+int64(len(m.page.Rows)), t.Rows)
+		default:
+			status = "No rows"
+		}
+		help = "(↑/↓ select, pgup/pgdn page, ←/→ scroll, enter to inspect, esc back, q to quit)"
+	case browseRecord:
+		t := m.table()
+		title = t.Name
+		status = fmt.Sprintf("Row %d of %d", m.offset+int64(m.row)+1, t.Rows)
+		if m.loading {
+			status = "Loading..."
+		}
+		help = "(↑/↓ scroll, ←/→ previous/next row, esc back, q to quit)"
+	}
+
+	return title + "\n" + m.view.View() + "\n\n" + status + "\n" + truncate(help, m.width)
+}
+
+// truncate shortens s to at most width runes, marking the cut with an ellipsis
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
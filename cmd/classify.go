@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/classify"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var classifyCmd = &cobra.Command{
+	Use:   "classify <table> <column>",
+	Short: "Compute class breaks of a numeric column for choropleth maps",
+	Long: `Split the values of a numeric column into classes and store the class
+breaks and colours in the database, for colouring maps by the column.
+
+Methods:
+  quantile        about the same number of features in every class (default)
+  equal-interval  classes of equal width between the minimum and maximum
+  natural-breaks  groups of similar values (Jenks); large columns are
+                  computed from an evenly spaced sample of their values
+
+Classes are coloured along --ramp: one of blues, greens, reds, oranges,
+purples or viridis, or your own comma-separated hex colours from low to
+high. Breaks that coincide, e.g. in columns with few distinct values, are
+merged, so there may be fewer classes than asked for.
+
+The breaks are stored in the xyzduck_classes table, replacing earlier ones
+of the column. 'xyzduck serve --choropleth <column>' colours its preview map
+and raster tiles by them, and --style writes a MapLibre style for the
+tileset at --tiles-url.`,
+	Example: `  xyzduck classify tracts median_income --db census
+  xyzduck classify tracts density --db census --method natural-breaks --classes 7 --ramp viridis
+  xyzduck classify parcels value --db city --ramp '#ffffcc,#fd8d3c,#800026' --style parcels-style.json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runClassify,
+}
+
+var (
+	classifyDBFlag       string
+	classifyMethodFlag   string
+	classifyClassesFlag  int
+	classifyRampFlag     string
+	classifyStyleFlag    string
+	classifyTilesURLFlag string
+)
+
+func init() {
+	classifyCmd.Flags().StringVar(&classifyDBFlag, "db", "", "Database file (required)")
+	classifyCmd.Flags().StringVar(&classifyMethodFlag, "method", "quantile", "How to place breaks: quantile, equal-interval, natural-breaks")
+	classifyCmd.Flags().IntVar(&classifyClassesFlag, "classes", 5, "Number of classes (2 to 12)")
+	classifyCmd.Flags().StringVar(&classifyRampFlag, "ramp", "blues", "Colour ramp name, or comma-separated hex colours")
+	classifyCmd.Flags().StringVar(&classifyStyleFlag, "style", "", "Write a MapLibre style JSON file for the classes")
+	classifyCmd.Flags().StringVar(&classifyTilesURLFlag, "tiles-url", "http://localhost:8080/tiles.json", "TileJSON URL of the tileset the style draws")
+	classifyCmd.MarkFlagRequired("db")
+
+	rootCmd.AddCommand(classifyCmd)
+}
+
+func runClassify(cmd *cobra.Command, args []string) error {
+	table, column := args[0], args[1]
+
+	method, err := classify.ParseMethod(classifyMethodFlag)
+	if err != nil {
+		return err
+	}
+	if classifyClassesFlag < 2 || classifyClassesFlag > 12 {
+		return fmt.Errorf("invalid number of classes %d (expected 2 to 12)", classifyClassesFlag)
+	}
+
+	dbPath := database.EnsureDuckDBExtension(classifyDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	schema, err := database.GetTableSchema(dbPath, table)
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 {
+		return fmt.Errorf("table %s not found", table)
+	}
+	found := false
+	for _, col := range schema {
+		if strings.EqualFold(col.Name, column) {
+			if !classify.Numeric(col.Type) {
+				return fmt.Errorf("column %s is %s, not numeric", col.Name, col.Type)
+			}
+			column, found = col.Name, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("column %s not found in table %s", column, table)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fmt.Printf("Classifying '%s' of '%s' by %s...\n", column, table, method)
+	c, err := classify.Classify(db, table, column, classify.Options{
+		Method:  method,
+		Classes: classifyClassesFlag,
+		Ramp:    classifyRampFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to classify: %w", err)
+	}
+	if err := classify.Store(db, c); err != nil {
+		return err
+	}
+
+	if c.Classes() < classifyClassesFlag {
+		fmt.Printf("Note: breaks coincide, so there are %d classes instead of %d\n", c.Classes(), classifyClassesFlag)
+	}
+	for i := 0; i < c.Classes(); i++ {
+		closing := ")"
+		if i == c.Classes()-1 {
+			closing = "]"
+		}
+		fmt.Printf("  %s  [%.6g, %.6g%s  %d\n", c.Colors[i], c.Bounds[i], c.Bounds[i+1], closing, c.Counts[i])
+	}
+
+	if classifyStyleFlag != "" {
+		_, layer := database.SplitTableName(table)
+		data, err := json.MarshalIndent(c.Style(classifyTilesURLFlag, layer), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode style: %w", err)
+		}
+		if err := os.WriteFile(classifyStyleFlag, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write style: %w", err)
+		}
+		fmt.Printf("✓ Wrote style to %s\n", classifyStyleFlag)
+	}
+
+	fmt.Printf("✓ Stored %d classes of '%s' in %s\n", c.Classes(), column, classify.ClassesTable)
+	return nil
+}
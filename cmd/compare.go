@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/compare"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <table_a> <table_b>",
+	Short: "Score two versions or providers of a dataset against each other",
+	Long: `Compare two tables holding the same kind of features, e.g. last month's and
+this month's release, or the same layer from two providers, and report:
+
+  - feature counts and the difference between them
+  - NULL, empty and invalid geometries, and the share of valid ones
+  - attribute completeness: the share of features with a value in each
+    column (blank text counts as missing), side by side
+  - positional offset: each feature of table_a is matched to the nearest
+    feature of table_b whose centroid is within --match-distance, with the
+    match rate and the mean, median, 95th percentile and max offset
+
+table_b is in --db too, unless --other-db names its database. Both tables
+need a GEOMETRY column; the first one is compared.
+
+The scorecard is printed as text, or written as JSON or a standalone HTML
+page with --format, or to --output (format from its extension).
+
+--match-distance takes a unit (10m, 0.5km) for lon/lat tables, where
+distances are measured on the sphere, or a bare number in the units of a
+projected CRS.`,
+	Example: `  xyzduck compare roads_2024 roads_2025 --db geodata
+  xyzduck compare pois pois --db provider_a --other-db provider_b --match-distance 25m --output scorecard.html
+  xyzduck compare parcels parcels_new --db cadastre --match-distance 0.5 --format json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompare,
+}
+
+var (
+	compareDBFlag       string
+	compareOtherDBFlag  string
+	compareDistanceFlag string
+	compareFormatFlag   string
+	compareOutputFlag   string
+)
+
+func init() {
+	compareCmd.Flags().StringVar(&compareDBFlag, "db", "", "Database file (required)")
+	compareCmd.Flags().StringVar(&compareOtherDBFlag, "other-db", "", "Database file holding table_b (default: --db)")
+	compareCmd.Flags().StringVar(&compareDistanceFlag, "match-distance", "10m", "Farthest apart matching features may be, e.g. 10m, 0.5km or CRS units")
+	compareCmd.Flags().StringVar(&compareFormatFlag, "format", "", "Output format: text, json or html (default: from --output, else text)")
+	compareCmd.Flags().StringVarP(&compareOutputFlag, "output", "o", "", "Write the scorecard to a file instead of stdout")
+	compareCmd.MarkFlagRequired("db")
+
+	rootCmd.AddCommand(compareCmd)
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	tableA, tableB := args[0], args[1]
+
+	distance, err := database.ParseDistance(compareDistanceFlag)
+	if err != nil {
+		return err
+	}
+	format := compare.FormatForPath(compareOutputFlag)
+	if compareFormatFlag != "" {
+		if format, err = compare.ParseFormat(compareFormatFlag); err != nil {
+			return err
+		}
+	}
+
+	dbPath := database.EnsureDuckDBExtension(compareDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+	var otherPath string
+	if compareOtherDBFlag != "" {
+		otherPath = database.EnsureDuckDBExtension(compareOtherDBFlag)
+		if !database.FileExists(otherPath) {
+			return fmt.Errorf("database not found: %s", otherPath)
+		}
+		if sameFile(dbPath, otherPath) {
+			otherPath = ""
+		}
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if otherPath != "" {
+		unlockOther, err := lockDatabase(otherPath)
+		if err != nil {
+			return err
+		}
+		defer unlockOther()
+	}
+
+	card, err := compare.Compare(dbPath, tableA, tableB, compare.Options{
+		OtherDB:       otherPath,
+		MatchDistance: distance,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compare tables: %w", err)
+	}
+
+	out := os.Stdout
+	if compareOutputFlag != "" {
+		out, err = os.Create(compareOutputFlag)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer out.Close()
+	}
+
+	if err := compare.Write(out, card, format); err != nil {
+		return fmt.Errorf("failed to write scorecard: %w", err)
+	}
+
+	if compareOutputFlag != "" {
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write scorecard: %w", err)
+		}
+		fmt.Printf("✓ Wrote scorecard of '%s' vs '%s' to %s\n", tableA, tableB, compareOutputFlag)
+	}
+	return nil
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
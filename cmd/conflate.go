@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/conflate"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var conflateCmd = &cobra.Command{
+	Use:   "conflate <reference> <candidate>",
+	Short: "Match features across two datasets and merge them",
+	Long: `Match the features of a candidate table, e.g. a new provider's POIs, to those
+of a reference table and merge the two into --out.
+
+Every pair of features within --distance is scored from 0 to 1 by how close
+they are and, with --attributes, how similar the named columns are
+(Jaro-Winkler, ignoring case). A reference and candidate that are each
+other's best pair are a match, unless:
+
+  - their attributes are less similar than --min-similarity: a conflict
+  - either has another pair within --margin of the score: ambiguous
+
+Matches become one feature, with the geometry and values of the --prefer
+side and gaps filled from the other. Features without a match are copied,
+and conflicting and ambiguous references are kept as they are, leaving out
+the candidates they were paired with. A conflate_status column records each
+feature's status: match, conflict, ambiguous, reference-only or
+candidate-only.
+
+The conflicting and ambiguous pairs are written side by side, with their
+distance, scores, attributes and both geometries, to --review (default:
+<out>_review), to go through with 'xyzduck browse'.
+
+--distance takes a unit (25m, 0.1km) for lon/lat tables, where distances
+are measured on the sphere, or a bare number in the units of a projected
+CRS. --attributes takes columns both tables have, or reference=candidate
+pairs for columns named differently.`,
+	Example: `  xyzduck conflate pois osm_pois --db city --out pois_merged --distance 25m --attributes name
+  xyzduck conflate addresses vendor_addresses --db city --out addresses_merged --distance 10m --attributes housenumber=number,street --prefer candidate
+  xyzduck conflate parcels parcels_new --db cadastre --out parcels_merged --distance 0.5 --review parcels_to_check`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConflate,
+}
+
+var (
+	conflateDBFlag            string
+	conflateOutFlag           string
+	conflateReviewFlag        string
+	conflateDistanceFlag      string
+	conflateAttributesFlag    []string
+	conflateMinSimilarityFlag float64
+	conflateMarginFlag        float64
+	conflatePreferFlag        string
+)
+
+func init() {
+	conflateCmd.Flags().StringVar(&conflateDBFlag, "db", "", "Target database file (required)")
+	conflateCmd.Flags().StringVar(&conflateOutFlag, "out", "", "Merged table name, optionally schema-qualified (required)")
+	conflateCmd.Flags().StringVar(&conflateReviewFlag, "review", "", "Table for conflicting and ambiguous pairs (default: <out>_review)")
+	conflateCmd.Flags().StringVar(&conflateDistanceFlag, "distance", "", "Farthest apart matching features may be, e.g. 25m, 0.1km or CRS units (required)")
+	conflateCmd.Flags().StringSliceVar(&conflateAttributesFlag, "attributes", nil, "Columns to compare, as column or reference=candidate (comma-separated)")
+	conflateCmd.Flags().Float64Var(&conflateMinSimilarityFlag, "min-similarity", 0.8, "Attribute similarity (0-1) below which a match is a conflict")
+	conflateCmd.Flags().Float64Var(&conflateMarginFlag, "margin", 0.1, "Score (0-1) by which a match must beat its runner-up")
+	conflateCmd.Flags().StringVar(&conflatePreferFlag, "prefer", "reference", "Side matched features take geometry and values from: reference or candidate")
+	conflateCmd.MarkFlagRequired("db")
+	conflateCmd.MarkFlagRequired("out")
+	conflateCmd.MarkFlagRequired("distance")
+
+	rootCmd.AddCommand(conflateCmd)
+}
+
+func runConflate(cmd *cobra.Command, args []string) error {
+	refTable, candTable := args[0], args[1]
+
+	distance, err := database.ParseDistance(conflateDistanceFlag)
+	if err != nil {
+		return err
+	}
+	attributes, err := conflate.ParseAttributes(conflateAttributesFlag)
+	if err != nil {
+		return err
+	}
+	if conflateMinSimilarityFlag < 0 || conflateMinSimilarityFlag > 1 {
+		return fmt.Errorf("--min-similarity must be between 0 and 1")
+	}
+	if conflateMarginFlag < 0 || conflateMarginFlag > 1 {
+		return fmt.Errorf("--margin must be between 0 and 1")
+	}
+	var preferCandidate bool
+	switch conflatePreferFlag {
+	case "reference":
+	case "candidate":
+		preferCandidate = true
+	default:
+		return fmt.Errorf("invalid --prefer %q (use reference or candidate)", conflatePreferFlag)
+	}
+
+	reviewTable := conflateReviewFlag
+	if reviewTable == "" {
+		reviewTable = conflateOutFlag + "_review"
+	}
+
+	dbPath, unlock, err := prepareOutputTable(conflateDBFlag, refTable, conflateOutFlag)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := database.TableExists(dbPath, candTable)
+	if err != nil {
+		return fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("table not found: %s", candTable)
+	}
+	exists, err = database.TableExists(dbPath, reviewTable)
+	if err != nil {
+		return fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("output table already exists: %s", reviewTable)
+	}
+
+	fmt.Printf("Conflating '%s' into '%s' within %s...\n", candTable, refTable, distance)
+	result, err := conflate.Conflate(dbPath, refTable, candTable, conflateOutFlag, reviewTable, conflate.Options{
+		Distance:        distance,
+		Attributes:      attributes,
+		MinSimilarity:   conflateMinSimilarityFlag,
+		Margin:          conflateMarginFlag,
+		PreferCandidate: preferCandidate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to conflate tables: %w", err)
+	}
+
+	fmt.Printf("✓ Merged %d features into table '%s'\n", result.Rows, conflateOutFlag)
+	fmt.Printf("  Matches: %d, reference only: %d, candidate only: %d\n", result.Matches, result.ReferenceOnly, result.CandidateOnly)
+	if result.Conflicts > 0 || result.Ambiguous > 0 {
+		fmt.Printf("! %d conflicting and %d ambiguous features: %d pairs to review in table '%s'\n",
+			result.Conflicts, result.Ambiguous, result.Review, reviewTable)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var (
+	convertFromFlag      string
+	convertToFlag        string
+	convertOverwriteFlag bool
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <input> <output>",
+	Short: "Convert a file from one geospatial format to another",
+	Long: `Convert between geospatial file formats without creating a database.
+The file is read into an in-memory DuckDB and written straight back out.
+
+Formats are inferred from the file extensions; use --from and --to when an
+extension is missing or ambiguous. Supported formats: ` + strings.Join(database.FormatNames(), ", ") + `.`,
+	Example: `  xyzduck convert cities.geojson cities.parquet
+  xyzduck convert tracks.kml tracks.fgb
+  xyzduck convert export.txt roads.gpkg --from geojsonseq`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConvert,
+}
+
+func init() {
+	convertCmd.Flags().StringVar(&convertFromFlag, "from", "", "Input format (default: from the input extension)")
+	convertCmd.Flags().StringVar(&convertToFlag, "to", "", "Output format (default: from the output extension)")
+	convertCmd.Flags().BoolVar(&convertOverwriteFlag, "overwrite", false, "Replace the output file if it exists")
+	rootCmd.AddCommand(convertCmd)
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	input, output := args[0], args[1]
+
+	fmt.Printf("Converting %s to %s...\n", input, output)
+
+	count, err := database.Convert(input, output, database.ConvertOptions{
+		From:      convertFromFlag,
+		To:        convertToFlag,
+		Overwrite: convertOverwriteFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Wrote %d features to %s\n", count, output)
+	return nil
+}
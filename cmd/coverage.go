@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/tiles"
+)
+
+// maxGapTiles caps the empty tiles --gaps writes, since the extent of
+// spread-out data holds millions of tiles at high zoom levels
+const maxGapTiles = 1000000
+
+var (
+	coverageDBFlag        string
+	coverageZoomFlag      int
+	coverageOutFlag       string
+	coverageGapsFlag      bool
+	coverageTopFlag       int
+	coverageOverwriteFlag bool
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage <table>",
+	Short: "Report which tiles of a zoom level hold data",
+	Long: `Count a table's features per tile of the XYZ grid at one zoom level, to plan
+tiling jobs and spot gaps in the data.
+
+The report gives the tiles holding data against the tiles covering the
+data's bounding box (the rest are gaps), the spread of features per tile,
+and the densest tiles with their features per square kilometre. Features
+spanning several tiles count in each tile their geometry touches.
+
+--out writes the tiles as a GeoJSON FeatureCollection of tile polygons with
+their z/x/y, feature count and density, to view over the data in any GIS.
+--gaps adds the empty tiles within the bounding box with a count of 0.`,
+	Example: `  xyzduck coverage roads --db geodata --zoom 12
+  xyzduck coverage parcels --db geodata --zoom 14 --out coverage.geojson --gaps`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCoverage,
+}
+
+func init() {
+	coverageCmd.Flags().StringVar(&coverageDBFlag, "db", "", "Source database file (required)")
+	coverageCmd.Flags().IntVar(&coverageZoomFlag, "zoom", 12, "Zoom level of the tile grid")
+	coverageCmd.Flags().StringVar(&coverageOutFlag, "out", "", "Write the tiles to this GeoJSON file")
+	coverageCmd.Flags().BoolVar(&coverageGapsFlag, "gaps", false, "Include the empty tiles within the data's extent in --out")
+	coverageCmd.Flags().IntVar(&coverageTopFlag, "top", 10, "Number of densest tiles to list")
+	coverageCmd.Flags().BoolVar(&coverageOverwriteFlag, "overwrite", false, "Replace the --out file if it exists")
+	coverageCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(coverageCmd)
+}
+
+func runCoverage(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	if coverageGapsFlag && coverageOutFlag == "" {
+		return fmt.Errorf("--gaps needs --out")
+	}
+	if coverageOutFlag != "" && database.FileExists(coverageOutFlag) && !coverageOverwriteFlag {
+		return fmt.Errorf("output file %s already exists (use --overwrite to replace it)", coverageOutFlag)
+	}
+
+	dbPath := database.EnsureDuckDBExtension(coverageDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("table not found: %s", tableName)
+	}
+
+	source, err := tiles.NewSource(dbPath, tableName, "")
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	cov, err := source.Coverage(coverageZoomFlag)
+	if err != nil {
+		return err
+	}
+	printCoverage(tableName, cov)
+
+	if coverageOutFlag == "" {
+		return nil
+	}
+	var gaps []tiles.Tile
+	if coverageGapsFlag {
+		if cov.Extent > maxGapTiles {
+			return fmt.Errorf("the data's extent spans %d tiles at zoom %d, too many to write the gaps; use a lower --zoom", cov.Extent, cov.Zoom)
+		}
+		gaps = cov.Gaps()
+	}
+
+	f, err := os.Create(coverageOutFlag)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := cov.WriteGeoJSON(f, gaps); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write coverage: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write coverage: %w", err)
+	}
+	fmt.Printf("\n✓ Wrote %d tiles to %s\n", len(cov.Tiles)+len(gaps), coverageOutFlag)
+	return nil
+}
+
+func printCoverage(tableName string, cov tiles.Coverage) {
+	fmt.Printf("Table:       %s\n", tableName)
+	fmt.Printf("Zoom:        %d\n", cov.Zoom)
+	if len(cov.Tiles) == 0 {
+		fmt.Println("Tiles:       none (no geometries)")
+		return
+	}
+	b := cov.Bounds
+	fmt.Printf("Bounds:      %.6f, %.6f, %.6f, %.6f\n", b[0], b[1], b[2], b[3])
+
+	filled := int64(len(cov.Tiles))
+	fmt.Printf("Tiles:       %d with data of %d in the extent (%.1f%%), %d gaps\n",
+		filled, cov.Extent, float64(filled)/float64(cov.Extent)*100, cov.Extent-filled)
+
+	counts := make([]int64, len(cov.Tiles))
+	var total int64
+	for i, t := range cov.Tiles {
+		counts[i] = t.Features
+		total += t.Features
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+	fmt.Printf("Per tile:    min %d, median %d, mean %.1f, max %d\n",
+		counts[0], counts[len(counts)/2], float64(total)/float64(len(counts)), counts[len(counts)-1])
+
+	if coverageTopFlag <= 0 {
+		return
+	}
+	fmt.Printf("\n  %-16s %10s %14s\n", "tile", "features", "per km²")
+	for _, t := range cov.Densest(coverageTopFlag) {
+		fmt.Printf("  %-16s %10d %14.2f\n", t.String(), t.Features, t.Density())
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/compressed"
+	"org.xyzmaps.xyzduck/src/geojson"
+	"org.xyzmaps.xyzduck/src/osm"
+)
+
+// loadExtensions are the files a directory load picks up without --pattern
+var loadExtensions = []string{".geojson", ".json", ".geojsonl", ".geojsons", ".ndjson", ".jsonl", ".topojson"}
+
+// directoryFile is the outcome of loading one file of a directory
+type directoryFile struct {
+	Path   string
+	Tables []loadedTable
+	Err    error
+}
+
+// runDirectoryLoad loads every matching file of a directory, and with
+// --recursive of its subdirectories, then summarizes the outcome per file. A
+// file that fails doesn't stop the others.
+func runDirectoryLoad(dbPath, dir string, opts geojson.LoadOptions) error {
+	if emitSQLFlag {
+		return fmt.Errorf("--emit-sql only applies to a single file")
+	}
+	if patternFlag != "" {
+		if _, err := filepath.Match(patternFlag, ""); err != nil {
+			return fmt.Errorf("invalid --pattern %q: %w", patternFlag, err)
+		}
+	}
+
+	paths, err := directoryInputs(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no files to load in %s", dir)
+	}
+
+	var files []directoryFile
+	for i, path := range paths {
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Printf("[%d/%d] %s\n", i+1, len(paths), rel)
+		tables, err := loadInput(dbPath, path, path, directoryTableName(rel), opts)
+		if err != nil {
+			fmt.Printf("! %v\n", err)
+		}
+		fmt.Println()
+		files = append(files, directoryFile{Path: rel, Tables: tables, Err: err})
+	}
+
+	failed := printDirectorySummary(dir, files)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to load", failed, len(files))
+	}
+	return nil
+}
+
+// directoryInputs lists the files of dir to load, in lexical order. Hidden
+// files and directories are skipped.
+func directoryInputs(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if !recursiveFlag {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchesLoadPattern(d.Name()) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return paths, nil
+}
+
+// matchesLoadPattern reports whether a file is picked up by a directory load:
+// its name matches --pattern, or without one it is a format load reads
+func matchesLoadPattern(name string) bool {
+	if patternFlag != "" {
+		matched, _ := filepath.Match(patternFlag, name)
+		return matched
+	}
+	if osm.IsPBF(name) || isTableSource(name) || compressed.Ext(name) == ".zip" {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(compressed.Trim(name)))
+	for _, e := range loadExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// directoryTableName derives a table name from a file's path relative to the
+// loaded directory, e.g. roads/2024/main.geojson becomes roads_2024_main
+func directoryTableName(rel string) string {
+	base := filepath.Base(compressed.Trim(rel))
+	if osm.IsPBF(base) {
+		base = osm.BaseName(base)
+	} else {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	parts := strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/")
+	if parts[0] == "." {
+		parts = nil
+	}
+	parts = append(parts, base)
+
+	// Dots would be read as a schema separator
+	name := strings.ReplaceAll(strings.Join(parts, "_"), ".", "_")
+	return cleanTableName(name)
+}
+
+// printDirectorySummary lists what each file of a directory load did and
+// returns the number of files that failed
+func printDirectorySummary(dir string, files []directoryFile) int {
+	failed := 0
+	for _, f := range files {
+		if f.Err != nil {
+			failed++
+		}
+	}
+
+	fmt.Printf("Loaded %d of %d files from %s:\n", len(files)-failed, len(files), dir)
+	for _, f := range files {
+		if f.Err != nil {
+			fmt.Printf("  ✗ %s: %v\n", f.Path, f.Err)
+			continue
+		}
+		var tables []string
+		for i, t := range f.Tables {
+			unit := ""
+			if i == 0 {
+				unit = " features"
+			}
+			tables = append(tables, fmt.Sprintf("%d%s into '%s'", t.Rows, unit, t.Table))
+		}
+		fmt.Printf("  ✓ %s: %s\n", f.Path, strings.Join(tables, ", "))
+	}
+	return failed
+}
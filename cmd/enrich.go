@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var enrichCmd = &cobra.Command{
+	Use:   "enrich",
+	Short: "Add derived attributes to a table",
+	Long:  `Enrich an existing table with attributes derived from reference data.`,
+}
+
+var (
+	enrichDBFlag      string
+	tzZonesFlag       string
+	tzZoneColumnFlag  string
+	tzColumnFlag      string
+	tzTimestampFlag   string
+	tzLocalColumnFlag string
+)
+
+var enrichTimezoneCmd = &cobra.Command{
+	Use:   "timezone <table>",
+	Short: "Assign an IANA timezone to each feature",
+	Long: `Look up the IANA timezone for every feature in a table by intersecting it
+with a timezone boundaries table, storing the zone name in a new column.
+
+The boundaries table defaults to 'timezones' as created by
+'xyzduck init --template world-admin'. Any polygon table with an IANA zone
+name column works, e.g. timezone-boundary-builder data loaded with
+'xyzduck load' and --zone-column tzid.
+
+With --timestamp, a UTC timestamp column is also converted to local time in
+the feature's zone.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnrichTimezone,
+}
+
+var (
+	stableColumnFlag      string
+	stableKeysFlag        []string
+	stableGridFlag        float64
+	stableReferenceFlag   string
+	stableReferenceIDFlag string
+	stableToleranceFlag   float64
+)
+
+var enrichStableIDCmd = &cobra.Command{
+	Use:   "stable-id <table>",
+	Short: "Assign content-based identifiers that survive reloads",
+	Long: `Compute a stable identifier for every feature so the same entity can be
+recognized across releases of a dataset, storing it in a new column.
+
+By default the identifier is the MD5 of the feature's geometry, snapped to
+--grid-size and normalized so vertex order and tiny numeric noise don't
+matter, together with the --keys attributes. Features with identical
+geometry and keys share an identifier; the number of such duplicates is
+reported.
+
+With --reference, identifiers are matched instead of computed, in the spirit
+of Overture's GERS ids: each feature takes the id of the reference feature
+whose centroid is closest, among those within --tolerance (in CRS units,
+degrees for WGS84) and with equal --keys. Unmatched features are left NULL.`,
+	Example: `  xyzduck enrich stable-id buildings --db geodata --keys height
+  xyzduck enrich stable-id places --db geodata --reference overture_places --reference-id id --keys name`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnrichStableID,
+}
+
+func init() {
+	enrichCmd.PersistentFlags().StringVar(&enrichDBFlag, "db", "", "Target database file (required)")
+	enrichCmd.MarkPersistentFlagRequired("db")
+
+	enrichTimezoneCmd.Flags().StringVar(&tzZonesFlag, "zones", "timezones", "Table with timezone boundary polygons")
+	enrichTimezoneCmd.Flags().StringVar(&tzZoneColumnFlag, "zone-column", "tz_name1st", "Column in the zones table holding the IANA zone name")
+	enrichTimezoneCmd.Flags().StringVar(&tzColumnFlag, "column", "timezone", "Column to store the assigned timezone in")
+	enrichTimezoneCmd.Flags().StringVar(&tzTimestampFlag, "timestamp", "", "UTC timestamp column to convert to local time")
+	enrichTimezoneCmd.Flags().StringVar(&tzLocalColumnFlag, "local-column", "", "Column for the local time (default: <timestamp>_local)")
+
+	enrichStableIDCmd.Flags().StringVar(&stableColumnFlag, "column", "stable_id", "Column to store the identifier in")
+	enrichStableIDCmd.Flags().StringSliceVar(&stableKeysFlag, "keys", nil, "Key attributes to hash with the geometry, or to require equal when matching")
+	enrichStableIDCmd.Flags().Float64Var(&stableGridFlag, "grid-size", 1e-7, "Snap coordinates to this grid before hashing")
+	enrichStableIDCmd.Flags().StringVar(&stableReferenceFlag, "reference", "", "Table of reference features to take identifiers from")
+	enrichStableIDCmd.Flags().StringVar(&stableReferenceIDFlag, "reference-id", "id", "Identifier column in the reference table")
+	enrichStableIDCmd.Flags().Float64Var(&stableToleranceFlag, "tolerance", 0.0001, "Maximum distance to a reference feature")
+
+	enrichCmd.AddCommand(enrichTimezoneCmd)
+	enrichCmd.AddCommand(enrichStableIDCmd)
+	rootCmd.AddCommand(enrichCmd)
+}
+
+func runEnrichTimezone(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath := database.EnsureDuckDBExtension(enrichDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Both tables must exist before we start altering anything
+	for _, t := range []string{tableName, tzZonesFlag} {
+		exists, err := database.TableExists(dbPath, t)
+		if err != nil {
+			return fmt.Errorf("failed to check if table exists: %w", err)
+		}
+		if !exists {
+			if t == tzZonesFlag {
+				return fmt.Errorf("timezone table not found: %s\nHint: Run 'xyzduck init %s --template world-admin' or load timezone boundaries first", t, enrichDBFlag)
+			}
+			return fmt.Errorf("table not found: %s", t)
+		}
+	}
+
+	localColumn := tzLocalColumnFlag
+	if tzTimestampFlag != "" && localColumn == "" {
+		localColumn = tzTimestampFlag + "_local"
+	}
+
+	fmt.Printf("Assigning timezones to '%s' from '%s'...\n", tableName, tzZonesFlag)
+	matched, err := database.EnrichTimezone(dbPath, tableName, database.TimezoneOptions{
+		ZonesTable:      tzZonesFlag,
+		ZoneColumn:      tzZoneColumnFlag,
+		TargetColumn:    tzColumnFlag,
+		TimestampColumn: tzTimestampFlag,
+		LocalColumn:     localColumn,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enrich timezones: %w", err)
+	}
+
+	fmt.Printf("✓ %d features assigned a timezone in column '%s'\n", matched, tzColumnFlag)
+	if tzTimestampFlag != "" {
+		fmt.Printf("✓ Local time written to column '%s'\n", localColumn)
+	}
+
+	return nil
+}
+
+func runEnrichStableID(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath := database.EnsureDuckDBExtension(enrichDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tables := []string{tableName}
+	if stableReferenceFlag != "" {
+		tables = append(tables, stableReferenceFlag)
+	}
+	for _, t := range tables {
+		exists, err := database.TableExists(dbPath, t)
+		if err != nil {
+			return fmt.Errorf("failed to check if table exists: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("table not found: %s", t)
+		}
+	}
+
+	if stableReferenceFlag != "" {
+		fmt.Printf("Matching '%s' against '%s'...\n", tableName, stableReferenceFlag)
+	} else {
+		fmt.Printf("Hashing '%s'...\n", tableName)
+	}
+
+	result, err := database.AssignStableIDs(dbPath, tableName, database.StableIDOptions{
+		TargetColumn:      stableColumnFlag,
+		KeyColumns:        stableKeysFlag,
+		GridSize:          stableGridFlag,
+		ReferenceTable:    stableReferenceFlag,
+		ReferenceIDColumn: stableReferenceIDFlag,
+		Tolerance:         stableToleranceFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ %d of %d features assigned an id in column '%s'\n", result.Assigned, result.Rows, stableColumnFlag)
+	if len(stableKeysFlag) > 0 {
+		fmt.Printf("  Keys: %s\n", strings.Join(stableKeysFlag, ", "))
+	}
+	if result.Duplicates > 0 {
+		fmt.Printf("! %d features share an id with another feature\n", result.Duplicates)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/ensure"
+)
+
+var (
+	ensureConfigFlag string
+	ensureDBFlag     string
+	ensureDryRunFlag bool
+)
+
+var ensureCmd = &cobra.Command{
+	Use:   "ensure",
+	Short: "Converge a database to a declared layout",
+	Long: `Bring a database to the layout declared in a state file, creating whatever is
+missing, and report each action taken. Running it again changes nothing, so
+it can run on every deploy, from CI or from infrastructure-as-code tooling.
+
+  db: geodata.duckdb
+  extensions: [spatial, h3]
+  schemas: [staging]
+  tables:
+    - file: schemas/roads.schema.yaml   # from 'xyzduck schema export'
+      table: staging.roads              # default: the file's table
+  views:
+    - name: staging.major_roads
+      sql: SELECT * FROM staging.roads WHERE class = 'major'
+  indexes:
+    - name: roads_class_idx
+      table: staging.roads
+      columns: [class]
+
+Convergence is additive: missing extensions are installed, missing schemas,
+tables, views and indexes created, and missing table columns added. Views
+whose query changed are replaced. Nothing is ever dropped, and column type
+or index differences are only reported. The database is created when it
+doesn't exist.
+
+All changes except extension installs run in one transaction, so a failure
+leaves the database unchanged. Use --dry-run to see the actions without
+applying them.`,
+	Example: `  xyzduck ensure --config state.yaml
+  xyzduck ensure --config state.yaml --db staging.duckdb --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runEnsure,
+}
+
+func init() {
+	ensureCmd.Flags().StringVar(&ensureConfigFlag, "config", "", "State file declaring the database layout (required)")
+	ensureCmd.Flags().StringVar(&ensureDBFlag, "db", "", "Database file (overrides the state file)")
+	ensureCmd.Flags().BoolVar(&ensureDryRunFlag, "dry-run", false, "Show the actions without applying them")
+	ensureCmd.MarkFlagRequired("config")
+	rootCmd.AddCommand(ensureCmd)
+}
+
+func runEnsure(cmd *cobra.Command, args []string) error {
+	state, err := ensure.LoadState(ensureConfigFlag)
+	if err != nil {
+		return err
+	}
+	if ensureDBFlag != "" {
+		state.DB = ensureDBFlag
+	}
+	if state.DB == "" {
+		return fmt.Errorf("no database given (use --db or set db in the state file)")
+	}
+
+	dbPath := database.EnsureDuckDBExtension(state.DB)
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists := database.FileExists(dbPath)
+	plan, err := ensure.NewPlan(dbPath, state)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		fmt.Printf("  + create database %s\n", dbPath)
+	}
+	for _, a := range plan.Actions {
+		fmt.Printf("  %s %s\n", a.Op, a.Description)
+	}
+	for _, w := range plan.Warnings {
+		fmt.Printf("  ! %s\n", w)
+	}
+
+	changes := len(plan.Actions)
+	if changes == 0 && exists {
+		fmt.Printf("✓ %s already matches %s (%d objects up to date)\n", dbPath, ensureConfigFlag, plan.UpToDate)
+		return nil
+	}
+	if ensureDryRunFlag {
+		fmt.Printf("Dry run: %d changes needed, %d objects up to date\n", changes, plan.UpToDate)
+		return nil
+	}
+
+	if !exists {
+		if err := database.InitSpatialExtension(dbPath); err != nil {
+			return err
+		}
+	}
+	if err := plan.Apply(dbPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Applied %d changes to %s (%d objects up to date)\n", changes, dbPath, plan.UpToDate)
+	return nil
+}
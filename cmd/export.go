@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var (
+	exportDBFlag        string
+	exportTableFlag     string
+	exportOutFlag       string
+	exportFormatFlag    string
+	exportSRSFlag       string
+	exportOverwriteFlag bool
+	exportWhereFlag     string
+	exportLimitFlag     int
+	exportMeasureFlag   []string
+	exportUnitsFlag     string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a table to a geospatial file",
+	Long: `Write a table to a file, e.g. GeoJSON, GeoParquet or a GeoPackage layer.
+
+The format is inferred from the --out extension; use --format when it is
+missing or ambiguous. Supported formats: ` + strings.Join(database.FormatNames(), ", ") + `.
+
+Parquet output of a table with GEOMETRY columns is GeoParquet: geometries
+are stored as WKB and the "geo" file metadata records the encoding, the
+geometry types, the bounding box and the CRS (--srs, default EPSG:4326).
+
+GeoPackages are written through GDAL, which registers the layer in
+gpkg_contents and gpkg_geometry_columns. The layer's CRS is --srs (default
+EPSG:4326, the CRS xyzduck loads into) and its geometry type is taken from
+the data when every row has the same type. The table must have exactly one
+GEOMETRY column.
+
+GeoJSON (geojson) and newline-delimited GeoJSONSeq (geojsonseq) output turn
+the GEOMETRY column back into feature geometries and every other column into
+properties, so loaded data can round-trip out of DuckDB.
+
+--where exports only the rows matching a SQL condition and --limit caps the
+number of rows; both apply to every format.
+
+--measure adds measurement columns computed on the WGS84 spheroid, whatever
+CRS the geometries are stored in (--srs): area for polygons, length for
+lines and perimeter for the rings of polygons. They are given in --units
+(m, km, ft, mi or nmi; areas in their square) and named after the measure
+and unit, e.g. area_km2, length_mi or perimeter_m.
+
+--out may be an s3://bucket/key URL for Parquet and CSV output, written
+through DuckDB's httpfs extension. Credentials, region and endpoint are
+resolved as for load: --aws-profile, AWS_ACCESS_KEY_ID and
+AWS_SECRET_ACCESS_KEY, or the AWS_PROFILE or default profile, with
+--s3-region and --s3-endpoint.`,
+	Example: `  xyzduck export --db data.duckdb --table roads --out roads.parquet
+  xyzduck export --db geodata --table cities --out cities.gpkg
+  xyzduck export --db geodata --table parcels --out parcels.gpkg --srs EPSG:27700 --overwrite
+  xyzduck export --db geodata --table cities --out big-cities.geojson --where "population > 1000000"
+  xyzduck export --db geodata --table roads --out sample.geojsonl --format geojsonseq --limit 100
+  xyzduck export --db geodata --table parcels --out parcels.parquet --measure area,perimeter --units km
+  xyzduck export --db geodata --table roads --out s3://my-bucket/roads.parquet --aws-profile prod`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportDBFlag, "db", "", "Source database file (required)")
+	exportCmd.Flags().StringVar(&exportTableFlag, "table", "", "Table to export (required)")
+	exportCmd.Flags().StringVar(&exportOutFlag, "out", "", "Output file (required)")
+	exportCmd.Flags().StringVar(&exportFormatFlag, "format", "", "Output format (default: from the --out extension)")
+	exportCmd.Flags().StringVar(&exportSRSFlag, "srs", "EPSG:4326", "CRS recorded in the output's metadata")
+	exportCmd.Flags().StringVar(&exportWhereFlag, "where", "", "Export only rows matching this SQL condition")
+	exportCmd.Flags().IntVar(&exportLimitFlag, "limit", 0, "Export at most this many rows (0 for all)")
+	exportCmd.Flags().StringSliceVar(&exportMeasureFlag, "measure", nil, "Add geodesic measurement columns: area, length, perimeter")
+	exportCmd.Flags().StringVar(&exportUnitsFlag, "units", "m", "Unit of --measure columns: m, km, ft, mi, nmi")
+	exportCmd.Flags().BoolVar(&exportOverwriteFlag, "overwrite", false, "Replace the output file if it exists")
+	addS3Flags(exportCmd)
+	exportCmd.MarkFlagRequired("db")
+	exportCmd.MarkFlagRequired("table")
+	exportCmd.MarkFlagRequired("out")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if exportLimitFlag < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+
+	measures, err := database.ParseMeasures(exportMeasureFlag)
+	if err != nil {
+		return err
+	}
+	unit, err := database.ParseUnit(exportUnitsFlag)
+	if err != nil {
+		return err
+	}
+
+	dbPath := database.EnsureDuckDBExtension(exportDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var format database.Format
+	if exportFormatFlag != "" {
+		format, err = database.LookupFormat(exportFormatFlag)
+	} else {
+		format, err = database.FormatForPath(exportOutFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	if database.IsS3(exportOutFlag) {
+		if _, err := configureS3(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Exporting '%s' to %s...\n", exportTableFlag, exportOutFlag)
+
+	count, err := database.ExportTable(dbPath, exportTableFlag, exportOutFlag, format, database.ExportOptions{
+		SRS:       exportSRSFlag,
+		Overwrite: exportOverwriteFlag,
+		Where:     exportWhereFlag,
+		Limit:     exportLimitFlag,
+		Measures:  measures,
+		Unit:      unit,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Exported %d rows to %s\n", count, exportOutFlag)
+	return nil
+}
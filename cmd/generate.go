@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate geometries from tabular data",
+	Long:  `Create new geometry layers from coordinates or ordered points in existing tables.`,
+}
+
+var (
+	generateDBFlag  string
+	generateOutFlag string
+	gcOriginFlag    []string
+	gcDestFlag      []string
+	gcSegmentsFlag  int
+	trackByFlag     string
+	trackOrderFlag  string
+)
+
+var generateGreatCircleCmd = &cobra.Command{
+	Use:   "greatcircle <table>",
+	Short: "Build great-circle lines between origin and destination columns",
+	Long: `Create a line layer with one great-circle arc per row, connecting the
+origin and destination lon/lat columns. All source columns are copied to the
+output table.
+
+Example:
+  xyzduck generate greatcircle flights --db air \
+    --origin src_lon,src_lat --dest dst_lon,dst_lat --out flight_arcs`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenerateGreatCircle,
+}
+
+var generateTracksCmd = &cobra.Command{
+	Use:   "tracks <points-table>",
+	Short: "Build track lines from ordered points grouped by an id",
+	Long: `Connect the points of each group, ordered by a column such as a timestamp,
+into a LINESTRING per group. The output holds the group id, the number of
+points and the first and last order values.
+
+Example:
+  xyzduck generate tracks ais_positions --db marine --by mmsi --order-by ts --out vessel_tracks`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenerateTracks,
+}
+
+func init() {
+	generateCmd.PersistentFlags().StringVar(&generateDBFlag, "db", "", "Target database file (required)")
+	generateCmd.MarkPersistentFlagRequired("db")
+	generateCmd.PersistentFlags().StringVar(&generateOutFlag, "out", "", "Output table name, optionally schema-qualified (required)")
+	generateCmd.MarkPersistentFlagRequired("out")
+
+	generateGreatCircleCmd.Flags().StringSliceVar(&gcOriginFlag, "origin", nil, "Origin lon,lat column names (required)")
+	generateGreatCircleCmd.Flags().StringSliceVar(&gcDestFlag, "dest", nil, "Destination lon,lat column names (required)")
+	generateGreatCircleCmd.Flags().IntVar(&gcSegmentsFlag, "segments", 64, "Number of segments per arc")
+	generateGreatCircleCmd.MarkFlagRequired("origin")
+	generateGreatCircleCmd.MarkFlagRequired("dest")
+
+	generateTracksCmd.Flags().StringVar(&trackByFlag, "by", "", "Column identifying each track (required)")
+	generateTracksCmd.Flags().StringVar(&trackOrderFlag, "order-by", "", "Column ordering points within a track (required)")
+	generateTracksCmd.MarkFlagRequired("by")
+	generateTracksCmd.MarkFlagRequired("order-by")
+
+	generateCmd.AddCommand(generateGreatCircleCmd)
+	generateCmd.AddCommand(generateTracksCmd)
+	rootCmd.AddCommand(generateCmd)
+}
+
+// prepareGenerate locks the database and validates the tables shared by the
+// generators. The caller must call unlock when done.
+func prepareGenerate(srcTable string) (dbPath string, unlock func(), err error) {
+	return prepareOutputTable(generateDBFlag, srcTable, generateOutFlag)
+}
+
+// prepareOutputTable locks the database, checks srcTable exists and that
+// outTable doesn't yet, for commands deriving a new table from another. The
+// caller must call unlock when done.
+func prepareOutputTable(dbFlag, srcTable, outTable string) (dbPath string, unlock func(), err error) {
+	dbPath = database.EnsureDuckDBExtension(dbFlag)
+	if !database.FileExists(dbPath) {
+		return "", nil, fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	release, err := lockDatabase(dbPath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	exists, err := database.TableExists(dbPath, srcTable)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return "", nil, fmt.Errorf("table not found: %s", srcTable)
+	}
+
+	exists, err = database.TableExists(dbPath, outTable)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if exists {
+		return "", nil, fmt.Errorf("output table already exists: %s", outTable)
+	}
+
+	return dbPath, release, nil
+}
+
+func runGenerateGreatCircle(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	if len(gcOriginFlag) != 2 || len(gcDestFlag) != 2 {
+		return fmt.Errorf("--origin and --dest each take two columns: lon,lat")
+	}
+
+	dbPath, unlock, err := prepareGenerate(srcTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Generating great-circle arcs from '%s'...\n", srcTable)
+	result, err := database.GenerateGreatCircles(dbPath, srcTable, generateOutFlag, database.GreatCircleOptions{
+		OriginLon: gcOriginFlag[0],
+		OriginLat: gcOriginFlag[1],
+		DestLon:   gcDestFlag[0],
+		DestLat:   gcDestFlag[1],
+		Segments:  gcSegmentsFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate great circles: %w", err)
+	}
+
+	fmt.Printf("✓ Created %d arcs in table '%s'\n", result.Arcs, generateOutFlag)
+	if result.Antipodal > 0 {
+		fmt.Printf("! Skipped %d rows with antipodal origin and destination: no single great circle joins them\n", result.Antipodal)
+	}
+	return nil
+}
+
+func runGenerateTracks(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	dbPath, unlock, err := prepareGenerate(srcTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Building tracks from '%s'...\n", srcTable)
+	count, err := database.GenerateTracks(dbPath, srcTable, generateOutFlag, database.TrackOptions{
+		GroupBy: trackByFlag,
+		OrderBy: trackOrderFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate tracks: %w", err)
+	}
+
+	fmt.Printf("✓ Created %d tracks in table '%s'\n", count, generateOutFlag)
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/spf13/cobra"
 	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/geojson"
+	"org.xyzmaps.xyzduck/src/templates"
 )
 
 var initCmd = &cobra.Command{
@@ -15,12 +17,24 @@ var initCmd = &cobra.Command{
 	Short: "Initialize a DuckDB database with spatial extension",
 	Long: `Create a new DuckDB database file or open an existing one and ensure
 the spatial extension is installed and loaded. If no filename is provided,
-an interactive prompt will ask for the database name.`,
+an interactive prompt will ask for the database name.
+
+Use --template to seed the database with ready-made layers so there is
+something to query immediately:
+
+  world-admin   countries, admin1, timezones and graticule (Natural Earth, downloaded)
+  graticule     a 10° graticule generated locally
+
+Layers whose table already exists are skipped. Downloads are cached (see
+XYZDUCK_CACHE_DIR), resumed if interrupted, and reused on later runs.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runInit,
 }
 
+var templateFlag string
+
 func init() {
+	initCmd.Flags().StringVar(&templateFlag, "template", "", "Seed the database from a template ("+strings.Join(templates.Names(), ", ")+")")
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -44,6 +58,15 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("filename cannot be empty")
 	}
 
+	// Resolve the template up front so a typo fails before anything is created
+	var tmpl templates.Template
+	if templateFlag != "" {
+		tmpl, err = templates.Get(templateFlag)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Ensure .duckdb extension
 	filename = database.EnsureDuckDBExtension(filename)
 
@@ -55,6 +78,12 @@ func runInit(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Creating new database: %s\n", filename)
 	}
 
+	unlock, err := lockDatabase(filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Create or open the database
 	if err := database.CreateOrOpenDatabase(filename); err != nil {
 		return fmt.Errorf("failed to create/open database: %w", err)
@@ -66,10 +95,55 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize spatial extension: %w", err)
 	}
 
+	// Load seed layers from the template
+	if templateFlag != "" {
+		if err := seedFromTemplate(filename, tmpl); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("\n✓ Database ready with spatial extension at: %s\n", filename)
 	return nil
 }
 
+// seedFromTemplate loads each of the template's layers that isn't already present
+func seedFromTemplate(filename string, tmpl templates.Template) error {
+	fmt.Printf("Seeding from template '%s'...\n", tmpl.Name)
+
+	for _, layer := range tmpl.Layers {
+		exists, err := database.TableExists(filename, layer.Table)
+		if err != nil {
+			return fmt.Errorf("failed to check if table exists: %w", err)
+		}
+		if exists {
+			fmt.Printf("  - %s already exists, skipping\n", layer.Table)
+			continue
+		}
+
+		if layer.Remote() {
+			fmt.Printf("  Downloading %s...\n", layer.Description)
+		} else {
+			fmt.Printf("  Generating %s...\n", layer.Description)
+		}
+
+		path, cleanup, err := layer.Fetch()
+		if err != nil {
+			return err
+		}
+
+		result, err := geojson.LoadGeoJSON(filename, path, layer.Table, geojson.LoadOptions{})
+		cleanup()
+		if err != nil {
+			return fmt.Errorf("failed to load template layer %s: %w", layer.Table, err)
+		}
+		reportCreatedTable(layer.Table, result)
+
+		fmt.Printf("  ✓ %s: %d features\n", layer.Table, result.RowCount)
+	}
+
+	return nil
+}
+
 // TUI Model for filename input
 type filenameModel struct {
 	textInput textinput.Model
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/lineage"
+)
+
+var lineageDBFlag string
+
+var lineageCmd = &cobra.Command{
+	Use:   "lineage <table>",
+	Short: "Show which source columns fed each column of a derived table",
+	Long: `Show the column lineage of a table created with CREATE TABLE/VIEW ... AS
+SELECT in 'xyzduck query' or the 'xyzduck sql' shell: for each of its columns,
+the columns of the tables it was computed from, through joins, subqueries,
+common table expressions and unions.
+
+The lineage is worked out from the statement when it runs and stored in
+xyzduck_lineage; re-creating the table replaces it. Source tables that are
+derived themselves are pointed out, so the chain can be followed back.`,
+	Example: `  xyzduck query --db geodata "CREATE TABLE district_stats AS SELECT d.name, sum(p.area) AS area FROM districts d JOIN parcels p ON ST_Within(p.geom, d.geom) GROUP BY d.name"
+  xyzduck lineage district_stats --db geodata`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLineage,
+}
+
+func init() {
+	lineageCmd.Flags().StringVar(&lineageDBFlag, "db", "", "Database file (required)")
+	lineageCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(lineageCmd)
+}
+
+func runLineage(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+	dbPath := database.EnsureDuckDBExtension(lineageDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	entry, err := lineage.Lookup(db, tableName)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("no lineage recorded for %s (it is recorded when CREATE TABLE ... AS SELECT runs in 'xyzduck query' or 'xyzduck sql')", tableName)
+	}
+
+	fmt.Printf("Lineage of '%s' (recorded %s)\n\n", entry.Table, entry.RecordedAt.Format("2006-01-02 15:04 UTC"))
+	width := 0
+	for _, col := range entry.Columns {
+		width = max(width, len(col.Name))
+	}
+	for _, col := range entry.Columns {
+		sources := "(no source columns)"
+		if len(col.Sources) > 0 {
+			names := make([]string, len(col.Sources))
+			for i, s := range col.Sources {
+				names[i] = s.String()
+			}
+			sources = "← " + strings.Join(names, ", ")
+		}
+		fmt.Printf("  %-*s  %s\n", width, col.Name, sources)
+	}
+
+	if tables := entry.Tables(); len(tables) > 0 {
+		fmt.Println("\nSource tables:")
+		for _, table := range tables {
+			upstream, err := lineage.Lookup(db, table)
+			if err != nil {
+				return err
+			}
+			if upstream != nil {
+				fmt.Printf("  %s (derived, see 'xyzduck lineage %s')\n", table, table)
+			} else {
+				fmt.Printf("  %s\n", table)
+			}
+		}
+	}
+
+	fmt.Printf("\nStatement:\n  %s\n", strings.ReplaceAll(entry.Statement, "\n", "\n  "))
+	return nil
+}
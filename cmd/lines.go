@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var linesCmd = &cobra.Command{
+	Use:   "lines",
+	Short: "Measure and fix the direction of lines",
+	Long:  `Compute bearings of line features and correct the direction they were digitized in.`,
+}
+
+var (
+	linesDBFlag       string
+	bearingColumnFlag string
+	bearingEndsFlag   bool
+	bearingPlanarFlag bool
+	reverseWhereFlag  string
+	orientGridFlag    float64
+	orientFlagFlag    string
+	orientFixFlag     bool
+)
+
+var linesBearingCmd = &cobra.Command{
+	Use:   "bearing <table>",
+	Short: "Store the azimuth of each line",
+	Long: `Store the azimuth from the first to the last point of every LINESTRING, in
+degrees clockwise from north (0 to 360), in a new column. With --ends the
+azimuths of the first and last segment are stored as well, in
+<column>_start and <column>_end.
+
+Azimuths are initial great-circle bearings between lon/lat positions; use
+--planar for tables in a projected CRS. Other geometry types, and lines that
+end where they start, are left NULL.`,
+	Example: `  xyzduck lines bearing roads --db geodata
+  xyzduck lines bearing pipes --db utilities --column heading --ends --planar`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLinesBearing,
+}
+
+var linesReverseCmd = &cobra.Command{
+	Use:   "reverse <table>",
+	Short: "Reverse the direction of lines",
+	Long: `Reverse the vertex order of the geometries in a table, or of the rows
+matching --where, a SQL condition on the table's columns.`,
+	Example: `  xyzduck lines reverse rivers --db hydro --where "flow_dir = 'upstream'"`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runLinesReverse,
+}
+
+var linesOrientCmd = &cobra.Command{
+	Use:   "orient <table>",
+	Short: "Find lines digitized against the direction of their neighbours",
+	Long: `Check that connected lines in a network run the same way, as routing graphs
+built from the table expect.
+
+Lines meeting end to end, where no third line joins, form a chain. Each
+chain should be digitized in one direction: the direction most of its lines
+follow wins, and lines pointing the other way are reported. Endpoints are
+matched after snapping them to --grid-size (in CRS units, degrees for WGS84).
+
+--flag-column stores true for those lines and false for the others, and
+--fix reverses them.`,
+	Example: `  xyzduck lines orient streams --db hydro
+  xyzduck lines orient streams --db hydro --flag-column reversed --fix`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLinesOrient,
+}
+
+func init() {
+	linesCmd.PersistentFlags().StringVar(&linesDBFlag, "db", "", "Target database file (required)")
+	linesCmd.MarkPersistentFlagRequired("db")
+
+	linesBearingCmd.Flags().StringVar(&bearingColumnFlag, "column", "bearing", "Column to store the azimuth in")
+	linesBearingCmd.Flags().BoolVar(&bearingEndsFlag, "ends", false, "Also store the azimuth of the first and last segment")
+	linesBearingCmd.Flags().BoolVar(&bearingPlanarFlag, "planar", false, "Measure on the plane of a projected CRS")
+
+	linesReverseCmd.Flags().StringVar(&reverseWhereFlag, "where", "", "Only reverse rows matching this SQL condition")
+
+	linesOrientCmd.Flags().Float64Var(&orientGridFlag, "grid-size", 1e-7, "Snap endpoints to this grid before matching them")
+	linesOrientCmd.Flags().StringVar(&orientFlagFlag, "flag-column", "", "Column to mark lines digitized against their chain in")
+	linesOrientCmd.Flags().BoolVar(&orientFixFlag, "fix", false, "Reverse lines digitized against their chain")
+
+	linesCmd.AddCommand(linesBearingCmd)
+	linesCmd.AddCommand(linesReverseCmd)
+	linesCmd.AddCommand(linesOrientCmd)
+	rootCmd.AddCommand(linesCmd)
+}
+
+// prepareLines locks the database and checks the table exists. The caller
+// must call unlock when done.
+func prepareLines(tableName string) (dbPath string, unlock func(), err error) {
+	dbPath = database.EnsureDuckDBExtension(linesDBFlag)
+	if !database.FileExists(dbPath) {
+		return "", nil, fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	release, err := lockDatabase(dbPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		release()
+		return "", nil, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		release()
+		return "", nil, fmt.Errorf("table not found: %s", tableName)
+	}
+
+	return dbPath, release, nil
+}
+
+func runLinesBearing(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath, unlock, err := prepareLines(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Computing bearings of '%s'...\n", tableName)
+	count, err := database.LineBearings(dbPath, tableName, database.BearingOptions{
+		Column: bearingColumnFlag,
+		Ends:   bearingEndsFlag,
+		Planar: bearingPlanarFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compute bearings: %w", err)
+	}
+
+	fmt.Printf("✓ %d lines given a bearing in column '%s'\n", count, bearingColumnFlag)
+	if bearingEndsFlag {
+		fmt.Printf("✓ Segment bearings written to '%s_start' and '%s_end'\n", bearingColumnFlag, bearingColumnFlag)
+	}
+	return nil
+}
+
+func runLinesReverse(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath, unlock, err := prepareLines(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	count, err := database.ReverseLines(dbPath, tableName, reverseWhereFlag)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Reversed %d geometries in '%s'\n", count, tableName)
+	return nil
+}
+
+func runLinesOrient(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath, unlock, err := prepareLines(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Checking the direction of lines in '%s'...\n", tableName)
+	result, err := database.OrientLines(dbPath, tableName, database.OrientOptions{
+		GridSize:   orientGridFlag,
+		FlagColumn: orientFlagFlag,
+		Fix:        orientFixFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check line direction: %w", err)
+	}
+
+	fmt.Printf("✓ %d lines in %d chains\n", result.Lines, result.Chains)
+	if result.Inconsistent == 0 {
+		fmt.Println("✓ Every chain runs in one direction")
+		return nil
+	}
+	fmt.Printf("! %d lines are digitized against their chain\n", result.Inconsistent)
+	if orientFlagFlag != "" {
+		fmt.Printf("✓ Marked them in column '%s'\n", orientFlagFlag)
+	}
+	if orientFixFlag {
+		fmt.Println("✓ Reversed them")
+	}
+	return nil
+}
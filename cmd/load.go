@@ -2,27 +2,299 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/compressed"
 	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/gdal"
 	"org.xyzmaps.xyzduck/src/geojson"
+	"org.xyzmaps.xyzduck/src/osm"
+	"org.xyzmaps.xyzduck/src/schema"
+	"org.xyzmaps.xyzduck/src/telemetry"
+	"org.xyzmaps.xyzduck/src/topojson"
 )
 
 var (
-	dbFlag    string
-	tableFlag string
+	dbFlag            string
+	tableFlag         string
+	duplicateKeysFlag string
+	verboseFlag       bool
+	nullValuesFlag    []string
+	emptyAsNullFlag   bool
+	schemaFileFlag    string
+	tablePrefixFlag   string
+	tableSuffixFlag   string
+	tableSchemaFlag   string
+	onCollisionFlag   string
+	emitSQLFlag       bool
+	streamFlag        bool
+	noProgressFlag    bool
+	formatFlag        string
+	generateIDFlag    string
+	idFlag            string
+	idColumnFlag      string
+	sourceCRSFlag     string
+	layerFlag         string
+	strictFlag        bool
+	quarantineFlag    bool
+	lonFlag           string
+	latFlag           string
+	wktFlag           string
+	wkbFlag           string
+	keepCRSFlag       bool
+	objectFlag        string
+	osmTagsFlag       []string
+	mappingFlag       string
+	inferSampleFlag   int
+	nestedFlag        string
+	schemaModeFlag    string
+	typesFlag         string
+	keepStringsFlag   bool
+	recursiveFlag     bool
+	patternFlag       string
+	cacheFlag         bool
+	validateFlag      string
+	onErrorFlag       string
+	errorsFileFlag    string
+	dryRunFlag        bool
+	bboxFlag          string
+	includePropsFlag  []string
+	excludePropsFlag  []string
 )
 
 var loadCmd = &cobra.Command{
-	Use:   "load <geojson-file>",
-	Short: "Load GeoJSON file into DuckDB database",
+	Use:   "load <file|directory|url|->",
+	Short: "Load a GeoJSON, TopoJSON, shapefile, GeoPackage, FlatGeobuf, Parquet, CSV or OSM PBF file into DuckDB database",
 	Long: `Load a GeoJSON file into a DuckDB table with automatic schema inference.
 
+Give - as the file to read GeoJSON from stdin, e.g. piped from curl, jq or
+ogr2ogr; --table is then required.
+
+Both FeatureCollections and newline-delimited GeoJSON (GeoJSONL / NDJSON,
+one Feature per line) are accepted. The format is detected from the file
+extension (.geojsonl, .geojsons, .ndjson, .jsonl) or its contents; use
+--format to override.
+
+ESRI shapefiles (.shp with .shx and .dbf next to it) are read through the
+spatial extension. Field names are sanitized like property keys, and the
+CRS is taken from the .prj file (or --source-crs); geometries are
+reprojected to WGS84 (EPSG:4326) unless --keep-crs is given. The GeoJSON
+property options below don't apply to shapefiles.
+
+GeoPackages (.gpkg) and FlatGeobuf files (.fgb) are read the same way,
+taking the CRS from the layer's metadata. A GeoPackage with several layers
+needs --layer to pick one.
+
+(Geo)Parquet files (.parquet, .geoparquet) are read with DuckDB's Parquet
+reader. The geometry comes from the GeoParquet geometry column, or a WKB
+column named geometry or geom, and is taken as WGS84 unless --source-crs
+says otherwise.
+
+CSV and TSV files become point tables with --lon and --lat naming the
+coordinate columns (lon/lng/longitude/x and lat/latitude/y are found
+automatically), or carry geometries as text in a --wkt or hex --wkb column.
+The geometry source columns aren't stored separately. Coordinates are taken
+as WGS84 unless --source-crs says otherwise.
+
+TopoJSON topologies (.topojson, or .json with "type": "Topology") have their
+arcs decoded and geometries rebuilt per object. Every named object is loaded
+into its own table, named after the object (or <table>_<object> with
+--table); --object loads just one, into --table if given. Point, line and
+polygon geometries are supported, with or without quantization.
+
+OpenStreetMap extracts (.osm.pbf) are read with ST_ReadOSM into three
+tables: <name>_nodes (tagged nodes as points), <name>_ways (tagged ways as
+lines, or polygons when closed and tagged as an area such as building=*) and
+<name>_relations (multipolygon and boundary relations assembled from their
+member ways). Every table keeps osm_id and all tags as JSON; --tags copies
+chosen tags into columns of their own (e.g. --tags name,highway,addr:street=street).
+The base name comes from --table or the filename, and the tables must not
+exist yet.
+
 The table name is derived from the GeoJSON filename by default, but can be
-overridden with the --table flag. If the table already exists, features will
-be appended to it.`,
+overridden with the --table flag. Names may be schema-qualified
+(staging.roads); the schema is created if needed. If a table given with
+--table already exists, features will be appended to it.
+
+--schema-mode decides what happens when the features don't match the
+columns of a table they are appended to: cast (default) drops properties the
+table has no column for and casts values to the column types, merge adds the
+missing columns first (ALTER TABLE), and strict rejects the load, listing
+the missing columns and mismatched types.
+
+Derived names can be shaped with --table-prefix, --table-suffix and
+--table-schema (e.g. staging.roads). When a derived name collides with an
+existing table, --on-collision decides what happens: rename (default, loads
+into roads_2, roads_3, ... with a warning), append, or error.
+
+Property keys are sanitized into column names. When two keys end up on the
+same column (e.g. "Name" and "name", or a key repeated in the raw JSON), the
+--duplicate-keys policy decides the outcome: first (keep the first value),
+last (keep the last value), suffix (add name_2, name_3, ...) or error.
+
+Use --empty-as-null and --null-values to store empty strings and sentinel
+values such as "N/A" or -9999 as NULL. A schema file (--schema-file) can
+override both per column:
+
+  columns:
+    - name: population
+      null_values: ["-9999"]
+    - name: comment
+      empty_as_null: false
+
+The schema file can also declare validation rules per column: required,
+pattern (a regular expression), min/max and enum. Values are checked after
+the null rules are applied, and violations are counted per column and rule.
+By default they are only reported; with --strict the load is rejected
+without changing the table when any rule is broken more than the column's
+max_violations (default 0) times:
+
+  columns:
+    - name: iso_code
+      validate:
+        required: true
+        pattern: "^[A-Z]{3}$"
+    - name: population
+      validate:
+        min: 0
+        max_violations: 5
+
+Column types are inferred from the values. Strings holding ISO-8601 dates
+and timestamps or UUIDs become DATE, TIMESTAMP (TIMESTAMPTZ with a zone
+designator) and UUID columns unless --keep-strings is set.
+
+--types overrides the inferred types of the columns a load creates, e.g.
+--types "population:BIGINT,updated_at:TIMESTAMP"; a schema file's type
+entries do the same, with --types taking precedence.
+
+--emit-sql prints the fully quoted statements the load would run, without
+changing the database. Properties are normalized into a temporary GeoJSON
+file first; that file is kept so the printed SQL can be run elsewhere.
+
+When appending from a terminal and some properties have no column in the
+table, an interactive screen asks where each should go: into a new column,
+into an existing column the file doesn't fill, or nowhere. The choices can be
+saved to a mapping file and reused with --mapping, which also applies them
+without a terminal:
+
+  properties:
+    - property: NAME
+      column: name
+    - property: pop_2020
+      column: pop_2020
+      type: BIGINT
+    - property: internal_ref
+      skip: true
+
+Without a terminal or --mapping, such properties are dropped.
+
+A new table gets a column for every property key found in the file. A
+property holding different kinds of values gets the type that fits them
+all: BIGINT and DOUBLE values make a DOUBLE column, anything else mixed a
+VARCHAR column. --infer-sample limits this to the first N features, which
+saves the extra pass over the file that --stream otherwise makes.
+
+Object and array properties are stored as JSON text in VARCHAR columns.
+--nested flatten spreads the members of objects over dotted columns instead
+(address.city, address.geo.lat, ...), keeping arrays as text, and
+--nested json stores them in DuckDB JSON columns.
+
+--generate-id adds a surrogate key to every feature, stored in --id-column
+(default id): sequence numbers rows continuing from the table's current
+maximum, uuid assigns random UUIDs, and hash-of-geometry uses the MD5 of the
+geometry's WKB so identical shapes get identical keys. When appending, the
+table must already have the id column.
+
+--id gives a table the load creates a primary key, so later updates and
+joins have a stable identifier: --id feature promotes each feature's "id"
+member into --id-column, --id <property> (or property:<name>) makes that
+property's column the key, and --id uuid or --id sequence generate one like
+--generate-id. Features without a key are errors (see --on-error), as are
+duplicate keys. When appending, the table must already have the column.
+
+--quarantine keeps bad features out of the table without failing the load:
+features that break a validation rule, have a property that can't be cast to
+its column's type, or have an unknown geometry type are written to
+<table>_quarantine with their raw properties, raw geometry, the reason and
+the source file, so they can be fixed and loaded again.
+
+--validate checks every geometry with ST_IsValid as it is loaded: skip drops
+features with invalid geometries, repair fixes them with ST_MakeValid, and
+fail rejects the load on the first batch that has one. The number of
+geometries dropped or repaired is reported at the end.
+
+--on-error decides what happens to a feature that can't be read, such as
+malformed JSON, properties that aren't an object or a geometry with broken
+coordinates: fail (default) stops the load, skip leaves the feature out, and
+log also writes it with the reason and source file to --errors-file
+(errors.geojsonl), one feature per line. The number skipped is reported at
+the end. GeoJSONL input is then read strictly one feature per line, so a
+broken line doesn't take the rest of the file with it.
+
+--bbox minx,miny,maxx,maxy loads only the features whose geometry
+intersects the box, given in the file's coordinates. Features are tested as
+they are read, before their properties are decoded, so extracting a city
+from a country-sized file costs little more than reading it. The number left
+out is reported at the end.
+
+--include-props keeps only the properties it names as columns and
+--exclude-props leaves the ones it names out, so a few attributes can be
+picked from a wide file or huge free-text fields dropped. Both take
+comma-separated property names or globs (addr*, *_raw), matched without
+regard to case; with --nested flatten, naming an object selects all of its
+dotted columns. Together, --exclude-props applies to what --include-props
+kept. Quarantined features still carry their full raw properties.
+
+Features are inserted in batches of 10,000 through DuckDB's appender, with
+geometries converted to WKB. --stream also decodes the file incrementally,
+so multi-gigabyte files load in constant memory. It can't be combined with
+--emit-sql.
+
+--dry-run reads the file without touching the database and prints the table
+it would load into, the number of features, their geometry types and
+bounding box, the columns of the table and the SQL --emit-sql would print.
+
+Given a directory, load reads every file in it in a format it knows, or
+whose name matches --pattern (e.g. "*.geojson"); --recursive descends into
+subdirectories, skipping hidden ones. Each file goes into a table named
+after its path relative to the directory, so roads/2024/main.geojson loads
+into roads_2024_main (shaped by the naming flags above), or every file into
+--table. A file that fails doesn't stop the others; a summary per file is
+printed at the end.
+
+Compressed input is decompressed as it is read, without extracting it to
+disk: GeoJSON, GeoJSONL and TopoJSON files ending in .gz or .zst (which needs
+the zstd command), and CSVs ending in .gz or .zst. A .zip archive is loaded
+through the one GeoJSON, TopoJSON, shapefile, GeoPackage or FlatGeobuf file
+in it, into a table named after the archive; when there are several, pick one
+as /vsizip/archive.zip/file.
+
+Given an HTTP(S) URL, load reads the file from there, naming the table after
+the URL's filename. Parquet and FlatGeobuf files are read in place, fetching
+only the parts needed; other formats are downloaded first, resuming an
+interrupted transfer. --cache keeps downloads in the download cache
+(XYZDUCK_CACHE_DIR) and reuses them while the server reports them unchanged;
+Parquet and FlatGeobuf files are then downloaded too. Proxies are taken from
+HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+
+s3://bucket/key URLs are read the same way, Parquet and FlatGeobuf in place
+and other formats downloaded. Credentials come from --aws-profile, else
+AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (with AWS_SESSION_TOKEN), else
+the AWS_PROFILE or default profile in ~/.aws/credentials; without any, public
+buckets are read anonymously. --s3-region and --s3-endpoint (for MinIO and
+other S3-compatible stores) override AWS_REGION and AWS_ENDPOINT_URL.
+
+GeoJSON loads report their progress: the share of the file read, features
+written per second and the time left. On a terminal this is a progress bar
+redrawn in place, otherwise a line every 10 seconds; --no-progress turns it
+off.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runLoad,
 }
@@ -30,16 +302,172 @@ be appended to it.`,
 func init() {
 	loadCmd.Flags().StringVar(&dbFlag, "db", "", "Target database file (required)")
 	loadCmd.MarkFlagRequired("db")
-	loadCmd.Flags().StringVar(&tableFlag, "table", "", "Table name (default: derived from filename)")
+	loadCmd.Flags().StringVar(&tableFlag, "table", "", "Table name, optionally schema-qualified (default: derived from filename)")
+	loadCmd.Flags().StringVar(&tablePrefixFlag, "table-prefix", "", "Prefix for derived table names")
+	loadCmd.Flags().StringVar(&tableSuffixFlag, "table-suffix", "", "Suffix for derived table names")
+	loadCmd.Flags().StringVar(&tableSchemaFlag, "table-schema", "", "Schema for derived table names (created if needed)")
+	loadCmd.Flags().StringVar(&onCollisionFlag, "on-collision", "rename", "When a derived table name exists: rename, append, error")
+	loadCmd.Flags().StringVar(&duplicateKeysFlag, "duplicate-keys", "first", "Policy for colliding property keys: first, last, suffix, error")
+	loadCmd.Flags().BoolVar(&emptyAsNullFlag, "empty-as-null", false, "Store empty string properties as NULL")
+	loadCmd.Flags().StringSliceVar(&nullValuesFlag, "null-values", nil, "Property values to store as NULL (e.g. null,N/A,-9999)")
+	loadCmd.Flags().StringVar(&schemaFileFlag, "schema-file", "", "YAML schema file with per-column load settings")
+	loadCmd.Flags().BoolVar(&strictFlag, "strict", false, "Reject the load when schema file validation rules are broken more than allowed")
+	loadCmd.Flags().BoolVar(&quarantineFlag, "quarantine", false, "Divert features that fail validation or type casts into <table>_quarantine")
+	loadCmd.Flags().StringVar(&mappingFlag, "mapping", "", "YAML file routing properties onto the columns of an existing table")
+	loadCmd.Flags().IntVar(&inferSampleFlag, "infer-sample", 0, "Infer a new table's columns from the first N features (default: all)")
+	loadCmd.Flags().StringVar(&nestedFlag, "nested", "", "Store object and array properties as: flatten (dotted columns), json (JSON columns)")
+	loadCmd.Flags().StringVar(&typesFlag, "types", "", "Column types overriding the inferred ones (e.g. population:BIGINT,updated_at:TIMESTAMP)")
+	loadCmd.Flags().BoolVar(&keepStringsFlag, "keep-strings", false, "Store date, timestamp and UUID strings as VARCHAR instead of detecting their type")
+	loadCmd.Flags().StringVar(&schemaModeFlag, "schema-mode", "cast", "When appending features that don't match the table: merge, strict, cast")
+	loadCmd.Flags().BoolVar(&emitSQLFlag, "emit-sql", false, "Print the SQL that would be executed instead of running it")
+	loadCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Print the inferred schema, a summary of the features and the SQL without touching the database")
+	loadCmd.Flags().StringVar(&generateIDFlag, "generate-id", "", "Generate a surrogate key per feature: sequence, uuid, hash-of-geometry")
+	loadCmd.Flags().StringVar(&idFlag, "id", "", "Primary key: feature (the feature id member), uuid, sequence or a property name")
+	loadCmd.Flags().StringVar(&idColumnFlag, "id-column", "id", "Column that stores generated or feature ids")
+	loadCmd.Flags().StringVar(&formatFlag, "format", "auto", "Input format: auto, geojson, geojsonl")
+	loadCmd.Flags().StringVar(&lonFlag, "lon", "", "CSV column holding longitudes (or x coordinates)")
+	loadCmd.Flags().StringVar(&latFlag, "lat", "", "CSV column holding latitudes (or y coordinates)")
+	loadCmd.Flags().StringVar(&wktFlag, "wkt", "", "CSV column holding geometries as WKT")
+	loadCmd.Flags().StringVar(&wkbFlag, "wkb", "", "CSV column holding geometries as hex-encoded WKB")
+	loadCmd.Flags().StringVar(&layerFlag, "layer", "", "Layer to load from a multi-layer source such as a GeoPackage")
+	loadCmd.Flags().StringVar(&objectFlag, "object", "", "TopoJSON object to load (default: every object, one table each)")
+	loadCmd.Flags().StringSliceVar(&osmTagsFlag, "tags", nil, "OSM tags to store in their own columns, as key or key=column")
+	loadCmd.Flags().StringVar(&sourceCRSFlag, "source-crs", "", "Source CRS, overriding the .prj or layer metadata (e.g. EPSG:27700)")
+	loadCmd.Flags().BoolVar(&keepCRSFlag, "keep-crs", false, "Store shapefile/GeoPackage geometries in their source CRS instead of WGS84")
+	loadCmd.Flags().BoolVar(&streamFlag, "stream", false, "Decode and insert features in batches to keep memory use flat on large files")
+	loadCmd.Flags().BoolVarP(&verboseFlag, "verbose", "v", false, "Show per-column type coercion details")
+	loadCmd.Flags().BoolVar(&noProgressFlag, "no-progress", false, "Don't report progress while loading")
+	loadCmd.Flags().BoolVar(&recursiveFlag, "recursive", false, "Load the files of subdirectories too, when loading a directory")
+	loadCmd.Flags().StringVar(&patternFlag, "pattern", "", "Glob the names of files to load from a directory must match (e.g. \"*.geojson\")")
+	loadCmd.Flags().StringVar(&validateFlag, "validate", "", "Check geometries with ST_IsValid and skip, repair or fail on invalid ones")
+	loadCmd.Flags().StringVar(&onErrorFlag, "on-error", "fail", "What to do with features that can't be read: fail, skip, log")
+	loadCmd.Flags().StringVar(&errorsFileFlag, "errors-file", "errors.geojsonl", "GeoJSONL file --on-error log writes skipped features to")
+	loadCmd.Flags().StringVar(&bboxFlag, "bbox", "", "Only load features intersecting minx,miny,maxx,maxy")
+	loadCmd.Flags().StringSliceVar(&includePropsFlag, "include-props", nil, "Only turn these properties into columns (names or globs, e.g. name,addr*)")
+	loadCmd.Flags().StringSliceVar(&excludePropsFlag, "exclude-props", nil, "Leave these properties out of the table (names or globs, e.g. description,raw_*)")
+	loadCmd.Flags().BoolVar(&cacheFlag, "cache", false, "Keep files loaded from URLs in the download cache and reuse them while unchanged")
+	addS3Flags(loadCmd)
 	rootCmd.AddCommand(loadCmd)
 }
 
 func runLoad(cmd *cobra.Command, args []string) error {
 	geojsonPath := args[0]
 
-	// Validate GeoJSON file exists
-	if !database.FileExists(geojsonPath) {
-		return fmt.Errorf("GeoJSON file not found: %s", geojsonPath)
+	duplicatePolicy, err := geojson.ParseDuplicatePolicy(duplicateKeysFlag)
+	if err != nil {
+		return err
+	}
+
+	var schemaFile *schema.File
+	if schemaFileFlag != "" {
+		if schemaFile, err = schema.Load(schemaFileFlag); err != nil {
+			return err
+		}
+	}
+	nullPolicy := buildNullPolicy(schemaFile)
+	validation, err := buildValidationPolicy(schemaFile)
+	if err != nil {
+		return err
+	}
+
+	format, err := geojson.ParseFormat(formatFlag)
+	if err != nil {
+		return err
+	}
+
+	idMode, err := geojson.ParseIDMode(generateIDFlag)
+	if err != nil {
+		return err
+	}
+	ids := geojson.IDOptions{Mode: idMode, Column: idColumnFlag}
+	if idFlag != "" {
+		if generateIDFlag != "" {
+			return fmt.Errorf("--id and --generate-id can't be combined")
+		}
+		if ids, err = geojson.ParseIDFlag(idFlag, idColumnFlag); err != nil {
+			return err
+		}
+		if ids.Mode == geojson.IDProperty && cmd.Flags().Changed("id-column") {
+			return fmt.Errorf("--id-column doesn't apply to --id <property>, whose column is the key")
+		}
+	}
+
+	nestedPolicy, err := geojson.ParseNestedPolicy(nestedFlag)
+	if err != nil {
+		return err
+	}
+
+	schemaMode, err := geojson.ParseSchemaMode(schemaModeFlag)
+	if err != nil {
+		return err
+	}
+
+	types, err := buildTypeOverrides(schemaFile)
+	if err != nil {
+		return err
+	}
+
+	if dryRunFlag && (streamFlag || quarantineFlag || validateFlag != "") {
+		return fmt.Errorf("--dry-run can't be combined with --stream, --quarantine or --validate")
+	}
+	// A dry run prints the statements --emit-sql would, after a summary
+	emitSQLFlag = emitSQLFlag || dryRunFlag
+
+	if streamFlag && emitSQLFlag {
+		return fmt.Errorf("--stream and --emit-sql can't be combined")
+	}
+	if quarantineFlag && emitSQLFlag {
+		return fmt.Errorf("--quarantine and --emit-sql can't be combined")
+	}
+	geometryCheck, err := database.ParseGeometryCheck(validateFlag)
+	if err != nil {
+		return err
+	}
+	if geometryCheck != database.GeometryCheckOff && emitSQLFlag {
+		return fmt.Errorf("--validate and --emit-sql can't be combined")
+	}
+	errorPolicy, err := geojson.ParseErrorPolicy(onErrorFlag)
+	if err != nil {
+		return err
+	}
+	var bbox *geojson.BBox
+	if bboxFlag != "" {
+		if bbox, err = geojson.ParseBBox(bboxFlag); err != nil {
+			return err
+		}
+	}
+	properties, err := geojson.ParsePropertySelection(includePropsFlag, excludePropsFlag)
+	if err != nil {
+		return err
+	}
+	if inferSampleFlag < 0 {
+		return fmt.Errorf("--infer-sample must not be negative")
+	}
+
+	// Validate input file exists
+	fromStdin := geojsonPath == stdinInput
+	fromURL := database.IsRemote(geojsonPath)
+	isDir := false
+	if fromStdin {
+		if tableFlag == "" {
+			return fmt.Errorf("--table is required when loading from stdin")
+		}
+	} else if compressed.IsZipMember(geojsonPath) {
+		if !compressed.Exists(geojsonPath) {
+			return fmt.Errorf("input file not found: %s", geojsonPath)
+		}
+	} else if !fromURL {
+		info, err := os.Stat(geojsonPath)
+		if err != nil {
+			return fmt.Errorf("input file not found: %s", geojsonPath)
+		}
+		isDir = info.IsDir()
+	}
+	if !isDir && (recursiveFlag || patternFlag != "") {
+		return fmt.Errorf("--recursive and --pattern only apply to directories")
+	}
+	if cacheFlag && (!fromURL || database.IsS3(geojsonPath)) {
+		return fmt.Errorf("--cache only applies to HTTP(S) URLs")
 	}
 
 	// Ensure database has .duckdb extension
@@ -50,39 +478,499 @@ func runLoad(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("database not found: %s\nHint: Run 'xyzduck init %s' to create it", dbPath, dbFlag)
 	}
 
+	source, name := geojsonPath, ""
+	if fromStdin {
+		if geojsonPath, err = spoolStdin(); err != nil {
+			return err
+		}
+		defer os.Remove(geojsonPath)
+		source = "stdin"
+	}
+	if fromURL {
+		input, err := openURL(geojsonPath)
+		if err != nil {
+			return err
+		}
+		defer input.Cleanup()
+		geojsonPath, name = input.Path, input.Name
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	opts := geojson.LoadOptions{
+		DuplicatePolicy: duplicatePolicy,
+		NullPolicy:      nullPolicy,
+		Validation:      validation,
+		EmitSQL:         emitSQLFlag,
+		IDs:             ids,
+		Format:          format,
+		Stream:          streamFlag,
+		Quarantine:      quarantineFlag,
+		InferSample:     inferSampleFlag,
+		Nested:          nestedPolicy,
+		SchemaMode:      schemaMode,
+		Types:           types,
+		KeepStrings:     keepStringsFlag,
+		GeometryCheck:   geometryCheck,
+		OnError:         errorPolicy,
+		BBox:            bbox,
+		Properties:      properties,
+	}
+	if errorPolicy == geojson.OnErrorLog {
+		opts.ErrorLog = &geojson.ErrorLog{Path: errorsFileFlag}
+	}
+
+	if isDir {
+		err = runDirectoryLoad(dbPath, geojsonPath, opts)
+	} else {
+		_, err = loadInput(dbPath, geojsonPath, source, name, opts)
+	}
+	if opts.ErrorLog != nil {
+		if closeErr := opts.ErrorLog.Close(); err == nil {
+			err = closeErr
+		}
+		if opts.ErrorLog.Count > 0 {
+			fmt.Printf("! %d skipped features written to %s\n", opts.ErrorLog.Count, opts.ErrorLog.Path)
+		}
+	}
+	return err
+}
+
+// stdinInput is the input argument that reads features from stdin
+const stdinInput = "-"
+
+// spoolStdin copies stdin into a temporary file, since the features are read
+// more than once: to infer the schema, then to load them
+func spoolStdin() (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("nothing to read: pipe GeoJSON into 'xyzduck load -'")
+	}
+
+	tmp, err := os.CreateTemp("", "xyzduck-stdin-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, os.Stdin); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// loadedTable is a table a load wrote to, and how many rows it added
+type loadedTable struct {
+	Table string
+	Rows  int64
+}
+
+// loadInput loads one input file in whichever format it is, calling it source
+// in messages. Tables are named after name, or the filename when it's empty,
+// unless --table is given.
+func loadInput(dbPath, geojsonPath, source, name string, opts geojson.LoadOptions) (loaded []loadedTable, err error) {
+	end := startStep("load "+filepath.Base(source), telemetry.String("input", source))
+	defer func() {
+		var rows int64
+		for _, t := range loaded {
+			rows += t.Rows
+		}
+		end(err, telemetry.Int64("rows", rows))
+	}()
+
+	// A zip archive is loaded through the one file in it load can stream out,
+	// naming the table after the archive
+	if compressed.Ext(geojsonPath) == ".zip" {
+		if name == "" {
+			name = cleanTableName(strings.TrimSuffix(filepath.Base(geojsonPath), filepath.Ext(geojsonPath)))
+		}
+		if geojsonPath, err = compressed.ZipMember(geojsonPath, isZipLoadable); err != nil {
+			return nil, err
+		}
+	}
+
+	if mappingFlag != "" && (osm.IsPBF(geojsonPath) || topojson.IsTopoJSON(geojsonPath) || isTableSource(geojsonPath)) {
+		return nil, fmt.Errorf("--mapping only applies to GeoJSON input")
+	}
+	if opts.SchemaMode != geojson.SchemaCast && (osm.IsPBF(geojsonPath) || isTableSource(geojsonPath)) {
+		return nil, fmt.Errorf("--schema-mode only applies to GeoJSON and TopoJSON input")
+	}
+	if typesFlag != "" && (osm.IsPBF(geojsonPath) || isTableSource(geojsonPath)) {
+		return nil, fmt.Errorf("--types only applies to GeoJSON and TopoJSON input")
+	}
+
+	// OSM extracts are split into node, way and relation tables
+	if osm.IsPBF(geojsonPath) {
+		if emitSQLFlag || streamFlag || quarantineFlag || validateFlag != "" || opts.OnError != geojson.OnErrorFail || opts.BBox != nil || opts.Properties != nil || opts.IDs.PrimaryKey {
+			return nil, fmt.Errorf("--emit-sql, --stream, --quarantine, --validate, --on-error, --bbox, --include-props, --exclude-props and --id only apply to GeoJSON input")
+		}
+		if name == "" {
+			name = osm.BaseName(geojsonPath)
+		}
+		return runOSMLoad(dbPath, geojsonPath, name)
+	}
+	if len(osmTagsFlag) > 0 {
+		return nil, fmt.Errorf("--tags only applies to OSM PBF input")
+	}
+
+	// TopoJSON objects are expanded to GeoJSON and loaded one table each
+	if topojson.IsTopoJSON(geojsonPath) {
+		if emitSQLFlag {
+			return nil, fmt.Errorf("--emit-sql doesn't apply to TopoJSON input")
+		}
+		if layerFlag != "" || lonFlag != "" || latFlag != "" || wktFlag != "" || wkbFlag != "" {
+			return nil, fmt.Errorf("--layer, --lon, --lat, --wkt and --wkb don't apply to TopoJSON input")
+		}
+		opts.Format = geojson.FormatGeoJSON
+		return runTopoJSONLoad(dbPath, geojsonPath, opts)
+	}
+	if objectFlag != "" {
+		return nil, fmt.Errorf("--object only applies to TopoJSON input")
+	}
+
 	// Determine table name
 	tableName := tableFlag
 	if tableName == "" {
-		// Derive from filename
-		base := filepath.Base(geojsonPath)
-		tableName = strings.TrimSuffix(base, filepath.Ext(base))
-		// Clean up table name (replace invalid characters)
-		tableName = strings.ReplaceAll(tableName, "-", "_")
-		tableName = strings.ReplaceAll(tableName, " ", "_")
+		if name == "" {
+			tableName, err = deriveTableName(dbPath, geojsonPath)
+		} else {
+			tableName, err = resolveTableName(dbPath, name)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Check if table exists
 	tableExists, err := database.TableExists(dbPath, tableName)
 	if err != nil {
-		return fmt.Errorf("failed to check if table exists: %w", err)
+		return nil, fmt.Errorf("failed to check if table exists: %w", err)
 	}
 
+	// Shapefiles, GeoPackages, FlatGeobuf, Parquet and CSVs are read through
+	// DuckDB's readers
+	if isTableSource(geojsonPath) {
+		if emitSQLFlag || streamFlag || quarantineFlag || validateFlag != "" || opts.OnError != geojson.OnErrorFail || opts.BBox != nil || opts.Properties != nil || opts.IDs.PrimaryKey {
+			return nil, fmt.Errorf("--emit-sql, --stream, --quarantine, --validate, --on-error, --bbox, --include-props, --exclude-props and --id only apply to GeoJSON input")
+		}
+		rows, err := runGDALLoad(dbPath, geojsonPath, filepath.Base(source), tableName, tableExists)
+		if err != nil {
+			return nil, err
+		}
+		return []loadedTable{{Table: tableName, Rows: rows}}, nil
+	}
+	if layerFlag != "" {
+		return nil, fmt.Errorf("--layer only applies to multi-layer sources such as GeoPackages")
+	}
+	if lonFlag != "" || latFlag != "" || wktFlag != "" || wkbFlag != "" {
+		return nil, fmt.Errorf("--lon, --lat, --wkt and --wkb only apply to CSV input")
+	}
+
+	// Route properties onto the columns of an existing table
+	if mappingFlag != "" {
+		if opts.Mapping, err = geojson.LoadMapping(mappingFlag); err != nil {
+			return nil, err
+		}
+	} else if tableExists && !emitSQLFlag && opts.SchemaMode == geojson.SchemaCast && isTerminal() {
+		if opts.Mapping, err = promptForMapping(dbPath, tableName, geojsonPath, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// Print the statements without running them
+	if emitSQLFlag {
+		result, err := geojson.LoadGeoJSON(dbPath, geojsonPath, tableName, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build load SQL: %w", err)
+		}
+		if dryRunFlag {
+			printDryRun(dbPath, tableName, tableExists, result)
+		}
+		fmt.Printf("-- Load %s into %s (%s)\n", source, tableName, dbPath)
+		for _, stmt := range result.SQL {
+			fmt.Printf("%s;\n\n", stmt)
+		}
+		return nil, nil
+	}
+
+	rows, err := loadGeoJSONFile(dbPath, geojsonPath, filepath.Base(source), tableName, tableExists, opts)
+	if err != nil {
+		return nil, err
+	}
+	return []loadedTable{{Table: tableName, Rows: rows}}, nil
+}
+
+// loadGeoJSONFile loads a GeoJSON file into a table and reports the outcome,
+// naming the input as label. It returns the number of features loaded.
+func loadGeoJSONFile(dbPath, geojsonPath, label, tableName string, tableExists bool, opts geojson.LoadOptions) (int64, error) {
 	if tableExists {
 		fmt.Printf("Appending to existing table '%s' in %s...\n", tableName, dbPath)
 	} else {
-		fmt.Printf("Loading %s into %s...\n", filepath.Base(geojsonPath), dbPath)
+		fmt.Printf("Loading %s into %s...\n", label, dbPath)
 	}
 
 	// Load the GeoJSON file
-	rowCount, err := geojson.LoadGeoJSON(dbPath, geojsonPath, tableName)
+	stopProgress := func() {}
+	if !noProgressFlag {
+		opts.Progress = &geojson.Progress{}
+		stopProgress = startProgress(opts.Progress)
+	}
+	result, err := geojson.LoadGeoJSON(dbPath, geojsonPath, tableName, opts)
+	stopProgress()
 	if err != nil {
-		return fmt.Errorf("failed to load GeoJSON: %w", err)
+		return 0, fmt.Errorf("failed to load GeoJSON: %w", err)
 	}
 
 	// Display success message
-	fmt.Printf("✓ Loaded %d features into table '%s'\n", rowCount, tableName)
+	reportCreatedTable(tableName, result)
+	fmt.Printf("✓ Loaded %d features into table '%s'\n", result.RowCount, tableName)
+
+	// Report property key collisions
+	if len(result.Duplicates) > 0 {
+		var dupes []string
+		for col, count := range result.Duplicates {
+			dupes = append(dupes, fmt.Sprintf("%s (%d)", col, count))
+		}
+		sort.Strings(dupes)
+		fmt.Printf("! Duplicate property keys resolved with policy '%s': %s\n", opts.DuplicatePolicy, strings.Join(dupes, ", "))
+	}
+
+	// Report values replaced by the null policy
+	if len(result.Nulled) > 0 {
+		var nulled []string
+		for col, count := range result.Nulled {
+			nulled = append(nulled, fmt.Sprintf("%s (%d)", col, count))
+		}
+		sort.Strings(nulled)
+		fmt.Printf("✓ Stored as NULL: %s\n", strings.Join(nulled, ", "))
+	}
+
+	// Report values that broke the schema file's validation rules
+	reportViolations(result.Violations)
+	if result.Quarantined > 0 {
+		fmt.Printf("! %d features quarantined in '%s'\n", result.Quarantined, geojson.QuarantineTable(tableName))
+	}
+
+	// Report features --bbox left out
+	if result.Outside > 0 {
+		fmt.Printf("✓ %d features outside the bounding box left out\n", result.Outside)
+	}
+
+	// Report features --on-error left out
+	if result.Skipped > 0 {
+		fmt.Printf("! %d features that couldn't be read skipped\n", result.Skipped)
+	}
+
+	// Report geometries the --validate check dropped or repaired
+	if result.Geometries.Dropped > 0 {
+		fmt.Printf("! %d features with invalid geometries skipped\n", result.Geometries.Dropped)
+	}
+	if result.Geometries.Repaired > 0 {
+		fmt.Printf("✓ %d invalid geometries repaired with ST_MakeValid\n", result.Geometries.Repaired)
+	}
+
+	// Report values that were converted to fit the column types
+	reportCoercions(result.Coercions)
+
+	notifyChange("load", dbPath, tableName, int64(result.RowCount))
 
 	// Show table schema
+	printTableSchema(dbPath, tableName)
+	return int64(result.RowCount), nil
+}
+
+// printDryRun describes what a load would do: the table it would write to,
+// the features it read and the columns the table would have
+func printDryRun(dbPath, tableName string, tableExists bool, result geojson.LoadResult) {
+	fmt.Printf("Dry run: nothing is written to %s\n\n", dbPath)
+	if tableExists {
+		fmt.Printf("Table: %s (exists; features would be appended)\n", tableName)
+	} else {
+		fmt.Printf("Table: %s (would be created)\n", tableName)
+	}
+
+	summary := result.Summary
+	fmt.Printf("Features: %d\n", summary.Features)
+	if result.Outside > 0 {
+		fmt.Printf("Outside the bounding box: %d features\n", result.Outside)
+	}
+	if result.Skipped > 0 {
+		fmt.Printf("Skipped: %d features that couldn't be read\n", result.Skipped)
+	}
+	var types []string
+	for t, count := range summary.GeometryTypes {
+		types = append(types, fmt.Sprintf("%s (%d)", t, count))
+	}
+	sort.Strings(types)
+	fmt.Printf("Geometry types: %s\n", strings.Join(types, ", "))
+	if b := summary.BBox; b != nil {
+		fmt.Printf("BBox: %g, %g, %g, %g\n", b[0], b[1], b[2], b[3])
+	}
+
+	var colNames []string
+	for _, col := range result.Columns {
+		colNames = append(colNames, fmt.Sprintf("%s (%s)", col.Name, col.Type))
+	}
+	fmt.Printf("Columns: %s\n\n", strings.Join(colNames, ", "))
+}
+
+// reportCreatedTable tells whether a GeoJSON load created its table
+func reportCreatedTable(tableName string, result geojson.LoadResult) {
+	if result.CreatedColumns > 0 {
+		fmt.Printf("✓ Table '%s' created with %d columns\n", tableName, result.CreatedColumns)
+	}
+}
+
+// runTopoJSONLoad loads the objects of a TopoJSON topology: the one named by
+// --object, or every object into its own table
+func runTopoJSONLoad(dbPath, inputPath string, opts geojson.LoadOptions) ([]loadedTable, error) {
+	topo, err := topojson.Read(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := topo.ObjectNames()
+	if objectFlag != "" {
+		objects = []string{objectFlag}
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("topology has no objects: %s", inputPath)
+	}
+
+	var loaded []loadedTable
+	for _, name := range objects {
+		path, cleanup, err := topo.ExtractObject(name)
+		if err != nil {
+			return loaded, err
+		}
+
+		// --table names the only table, or prefixes one table per object
+		var tableName string
+		switch {
+		case tableFlag != "" && len(objects) == 1:
+			tableName = tableFlag
+		case tableFlag != "":
+			tableName = tableFlag + "_" + cleanTableName(name)
+		default:
+			tableName, err = resolveTableName(dbPath, cleanTableName(name))
+		}
+		if err != nil {
+			cleanup()
+			return loaded, err
+		}
+
+		tableExists, err := database.TableExists(dbPath, tableName)
+		if err != nil {
+			cleanup()
+			return loaded, fmt.Errorf("failed to check if table exists: %w", err)
+		}
+
+		label := fmt.Sprintf("object '%s' of %s", name, filepath.Base(inputPath))
+		rows, err := loadGeoJSONFile(dbPath, path, label, tableName, tableExists, opts)
+		cleanup()
+		if err != nil {
+			return loaded, fmt.Errorf("object '%s': %w", name, err)
+		}
+		loaded = append(loaded, loadedTable{Table: tableName, Rows: rows})
+		fmt.Println()
+	}
+	return loaded, nil
+}
+
+// runGDALLoad loads a shapefile, GeoPackage layer, FlatGeobuf, Parquet or CSV
+// file and reports the outcome, naming the input as label. It returns the
+// number of features loaded.
+func runGDALLoad(dbPath, inputPath, label, tableName string, tableExists bool) (int64, error) {
+	if tableExists {
+		fmt.Printf("Appending to existing table '%s' in %s...\n", tableName, dbPath)
+	} else {
+		fmt.Printf("Loading %s into %s...\n", label, dbPath)
+	}
+
+	var result gdal.Result
+	var err error
+	if gdal.IsCSV(inputPath) {
+		if layerFlag != "" {
+			return 0, fmt.Errorf("--layer only applies to multi-layer sources such as GeoPackages")
+		}
+		result, err = gdal.LoadCSV(dbPath, inputPath, tableName, gdal.CSVOptions{
+			Lon:       lonFlag,
+			Lat:       latFlag,
+			WKT:       wktFlag,
+			WKB:       wkbFlag,
+			SourceCRS: sourceCRSFlag,
+			KeepCRS:   keepCRSFlag,
+		})
+	} else if gdal.IsParquet(inputPath) {
+		if layerFlag != "" || lonFlag != "" || latFlag != "" || wktFlag != "" || wkbFlag != "" {
+			return 0, fmt.Errorf("--layer, --lon, --lat, --wkt and --wkb don't apply to Parquet input")
+		}
+		result, err = gdal.LoadParquet(dbPath, inputPath, tableName, gdal.Options{
+			SourceCRS: sourceCRSFlag,
+			KeepCRS:   keepCRSFlag,
+		})
+	} else {
+		if lonFlag != "" || latFlag != "" || wktFlag != "" || wkbFlag != "" {
+			return 0, fmt.Errorf("--lon, --lat, --wkt and --wkb only apply to CSV input")
+		}
+		result, err = gdal.Load(dbPath, inputPath, tableName, gdal.Options{
+			Layer:     layerFlag,
+			SourceCRS: sourceCRSFlag,
+			KeepCRS:   keepCRSFlag,
+		})
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load %s: %w", label, err)
+	}
+
+	fmt.Printf("✓ Loaded %d features into table '%s'\n", result.RowCount, tableName)
+
+	switch {
+	case result.SourceCRS == "":
+		fmt.Println("! No CRS found (.prj missing); geometries stored as-is. Use --source-crs to reproject")
+	case result.Reprojected:
+		fmt.Printf("✓ Reprojected from %s to %s\n", crsLabel(result.SourceCRS), gdal.WGS84)
+	default:
+		fmt.Printf("✓ CRS: %s\n", crsLabel(result.SourceCRS))
+	}
+
+	if len(result.Renamed) > 0 {
+		var renamed []string
+		for field, column := range result.Renamed {
+			renamed = append(renamed, fmt.Sprintf("%s -> %s", field, column))
+		}
+		sort.Strings(renamed)
+		fmt.Printf("✓ Renamed fields: %s\n", strings.Join(renamed, ", "))
+	}
+
+	notifyChange("load", dbPath, tableName, result.RowCount)
+
+	printTableSchema(dbPath, tableName)
+	return result.RowCount, nil
+}
+
+// isTableSource reports whether a file is loaded by one of DuckDB's readers
+// rather than the GeoJSON loader
+func isTableSource(path string) bool {
+	return gdal.Supported(path) || gdal.IsCSV(path) || gdal.IsParquet(path)
+}
+
+// isZipLoadable reports whether a file in a zip archive is in a format load
+// can read out of the archive: GeoJSON, TopoJSON or one GDAL reads
+func isZipLoadable(name string) bool {
+	return gdal.Supported(name) || slices.Contains(loadExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// printTableSchema shows the columns of a freshly loaded table
+func printTableSchema(dbPath, tableName string) {
 	schema, err := database.GetTableSchema(dbPath, tableName)
 	if err == nil && len(schema) > 0 {
 		var colNames []string
@@ -91,6 +979,239 @@ func runLoad(cmd *cobra.Command, args []string) error {
 		}
 		fmt.Printf("\nTable: %s\nColumns: %s\n", tableName, strings.Join(colNames, ", "))
 	}
+}
+
+// crsLabel shortens a WKT CRS to its name for display
+func crsLabel(crs string) string {
+	if i := strings.Index(crs, `"`); i >= 0 && !strings.HasPrefix(crs, "EPSG:") {
+		if j := strings.Index(crs[i+1:], `"`); j >= 0 {
+			return crs[i+1 : i+1+j]
+		}
+	}
+	return crs
+}
+
+// deriveTableName builds a table name from the input filename, applying the
+// prefix/suffix/schema flags and the --on-collision policy
+func deriveTableName(dbPath, inputPath string) (string, error) {
+	base := filepath.Base(compressed.Trim(inputPath))
+	return resolveTableName(dbPath, cleanTableName(strings.TrimSuffix(base, filepath.Ext(base))))
+}
+
+// runOSMLoad loads an OSM extract into node, way and relation tables named
+// after name, unless --table is given
+func runOSMLoad(dbPath, inputPath, name string) ([]loadedTable, error) {
+	tags, err := osm.ParseTagColumns(osmTagsFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	base := tableFlag
+	if base == "" {
+		base = tablePrefixFlag + cleanTableName(name) + tableSuffixFlag
+		if tableSchemaFlag != "" {
+			base = tableSchemaFlag + "." + base
+		}
+	}
+
+	nodes, ways, relations := osm.Tables(base)
+	for _, table := range []string{nodes, ways, relations} {
+		exists, err := database.TableExists(dbPath, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if table exists: %w", err)
+		}
+		if exists {
+			return nil, fmt.Errorf("table '%s' already exists (use --table to choose another base name)", table)
+		}
+	}
+
+	fmt.Printf("Loading %s into %s...\n", filepath.Base(inputPath), dbPath)
+	result, err := osm.Load(dbPath, inputPath, base, osm.Options{Tags: tags})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", filepath.Base(inputPath), err)
+	}
 
-	return nil
+	fmt.Printf("✓ Loaded %d nodes into table '%s'\n", result.Nodes, nodes)
+	fmt.Printf("✓ Loaded %d ways into table '%s'\n", result.Ways, ways)
+	fmt.Printf("✓ Loaded %d relations into table '%s'\n", result.Relations, relations)
+
+	notifyChange("load", dbPath, nodes, result.Nodes)
+	notifyChange("load", dbPath, ways, result.Ways)
+	notifyChange("load", dbPath, relations, result.Relations)
+	return []loadedTable{
+		{Table: nodes, Rows: result.Nodes},
+		{Table: ways, Rows: result.Ways},
+		{Table: relations, Rows: result.Relations},
+	}, nil
+}
+
+// cleanTableName replaces characters that don't belong in a table name
+func cleanTableName(name string) string {
+	name = strings.ReplaceAll(name, "-", "_")
+	return strings.ReplaceAll(name, " ", "_")
+}
+
+// resolveTableName applies the prefix/suffix/schema flags and the
+// --on-collision policy to a derived name
+func resolveTableName(dbPath, name string) (string, error) {
+	name = tablePrefixFlag + name + tableSuffixFlag
+
+	if tableSchemaFlag != "" {
+		name = tableSchemaFlag + "." + name
+	}
+
+	exists, err := database.TableExists(dbPath, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return name, nil
+	}
+
+	switch onCollisionFlag {
+	case "append":
+		return name, nil
+	case "error":
+		return "", fmt.Errorf("table '%s' already exists (use --table to append to it explicitly)", name)
+	case "rename":
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s_%d", name, n)
+			exists, err := database.TableExists(dbPath, candidate)
+			if err != nil {
+				return "", fmt.Errorf("failed to check if table exists: %w", err)
+			}
+			if !exists {
+				fmt.Printf("! Table '%s' already exists, loading into '%s' instead (use --on-collision append to add to it)\n", name, candidate)
+				return candidate, nil
+			}
+		}
+	default:
+		return "", fmt.Errorf("invalid --on-collision value %q (expected rename, append or error)", onCollisionFlag)
+	}
+}
+
+// buildTypeOverrides combines the column types from the schema file with
+// --types, which wins
+func buildTypeOverrides(file *schema.File) (geojson.TypeOverrides, error) {
+	types, err := geojson.ParseTypeOverrides(typesFlag)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return types, nil
+	}
+	for _, col := range file.Columns {
+		if _, ok := types.Lookup(col.Name); !ok && col.Type != "" {
+			types[col.Name] = strings.ToUpper(col.Type)
+		}
+	}
+	return types, nil
+}
+
+// buildNullPolicy combines the null flags with per-column overrides from the schema file
+func buildNullPolicy(file *schema.File) geojson.NullPolicy {
+	policy := geojson.NullPolicy{
+		Default: geojson.NullRule{EmptyAsNull: emptyAsNullFlag, Values: nullValuesFlag},
+		Columns: make(map[string]geojson.NullRule),
+	}
+
+	if file == nil {
+		return policy
+	}
+
+	for _, col := range file.Columns {
+		if col.NullValues == nil && col.EmptyAsNull == nil {
+			continue
+		}
+		rule := policy.Default
+		if col.NullValues != nil {
+			rule.Values = col.NullValues
+		}
+		if col.EmptyAsNull != nil {
+			rule.EmptyAsNull = *col.EmptyAsNull
+		}
+		policy.Columns[strings.ToLower(col.Name)] = rule
+	}
+
+	return policy
+}
+
+// buildValidationPolicy collects the validate rules from the schema file
+func buildValidationPolicy(file *schema.File) (geojson.ValidationPolicy, error) {
+	policy := geojson.ValidationPolicy{
+		Columns: make(map[string]geojson.ValidationRule),
+		Strict:  strictFlag,
+	}
+
+	if file == nil {
+		return policy, nil
+	}
+
+	for _, col := range file.Columns {
+		if col.Validate == nil {
+			continue
+		}
+		v := col.Validate
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			return policy, fmt.Errorf("schema file column %s: min %g is greater than max %g", col.Name, *v.Min, *v.Max)
+		}
+		rule := geojson.ValidationRule{
+			Required:      v.Required,
+			Min:           v.Min,
+			Max:           v.Max,
+			Enum:          v.Enum,
+			MaxViolations: v.MaxViolations,
+		}
+		if v.Pattern != "" {
+			re, err := regexp.Compile(v.Pattern)
+			if err != nil {
+				return policy, fmt.Errorf("schema file column %s: invalid pattern: %w", col.Name, err)
+			}
+			rule.Pattern = re
+		}
+		policy.Columns[strings.ToLower(col.Name)] = rule
+	}
+
+	return policy, nil
+}
+
+// reportViolations lists the failed validation rules with their counts
+func reportViolations(violations map[string]map[string]int) {
+	var failed []string
+	for col, rules := range violations {
+		for rule, count := range rules {
+			failed = append(failed, fmt.Sprintf("%s %s (%d)", col, rule, count))
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	sort.Strings(failed)
+	fmt.Printf("! Validation failures: %s\n", strings.Join(failed, ", "))
+}
+
+// reportCoercions summarizes type coercions, listing every column when --verbose is set
+func reportCoercions(coercions map[string]geojson.CoercionStats) {
+	total := 0
+	var names []string
+	for name, stats := range coercions {
+		total += stats.Total()
+		names = append(names, name)
+	}
+	if total == 0 {
+		return
+	}
+
+	if !verboseFlag {
+		fmt.Printf("! %d values in %d columns were coerced to fit column types (use --verbose for details)\n", total, len(names))
+		return
+	}
+
+	sort.Strings(names)
+	fmt.Printf("\nType coercions:\n")
+	fmt.Printf("  %-24s %14s %14s %10s %7s\n", "column", "string→number", "number→string", "truncated", "other")
+	for _, name := range names {
+		s := coercions[name]
+		fmt.Printf("  %-24s %14d %14d %10d %7d\n", name, s.StringToNumber, s.NumberToString, s.Truncation, s.Other)
+	}
 }
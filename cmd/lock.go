@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+
+	"org.xyzmaps.xyzduck/src/lock"
+	"org.xyzmaps.xyzduck/src/telemetry"
+)
+
+// lockDatabase waits for other xyzduck processes to finish with a database,
+// since DuckDB lets only one process open it, and returns the unlock function
+func lockDatabase(dbPath string) (func(), error) {
+	end := startStep("wait for lock", telemetry.String("db.path", dbPath))
+	l, err := lock.Acquire(dbPath, lockTimeoutFlag, func(holder string) {
+		fmt.Printf("Waiting for %s to finish with %s...\n", holder, dbPath)
+	})
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	return l.Release, nil
+}
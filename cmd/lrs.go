@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var lrsCmd = &cobra.Command{
+	Use:   "lrs",
+	Short: "Place events along routes by measure (linear referencing)",
+	Long: `Linear referencing for road asset, pipeline and rail data: events recorded as
+a route id and a measure along it are turned into geometries, and points are
+turned into measures.
+
+Routes are the LINESTRINGs of the --routes table, identified by --route-id.
+A route may be split over several lines. Measures run from 0 at the first
+point of each line to its length, in meters on the sphere for lon/lat data
+(or CRS units with --planar). When the lines carry their own measures, such
+as kilometer posts at either end, name those columns with --route-start and
+--route-end and measures are interpolated between them.
+
+Events refer to their route by a column with the same name as --route-id,
+or the one named by --event-route-id.`,
+}
+
+var (
+	lrsDBFlag           string
+	lrsOutFlag          string
+	lrsRoutesFlag       string
+	lrsRouteIDFlag      string
+	lrsEventRouteIDFlag string
+	lrsRouteStartFlag   string
+	lrsRouteEndFlag     string
+	lrsPlanarFlag       bool
+	lrsMeasureFlag      string
+	lrsMeasureColFlag   string
+	lrsFromFlag         string
+	lrsToFlag           string
+	lrsMatchRouteFlag   bool
+)
+
+var lrsLocateCmd = &cobra.Command{
+	Use:   "locate <events_table>",
+	Short: "Create points at the measures of point events",
+	Long: `Copy an events table into --out with a point geometry at the --measure of each
+event along its route. Events whose route is missing or whose measure falls
+outside it get a NULL geometry.`,
+	Example: `  xyzduck lrs locate signs --db roads --routes roads --route-id road_id --measure chainage --out sign_points
+  xyzduck lrs locate crashes --db roads --routes sections --route-id road_id --route-start from_km --route-end to_km --measure km --out crash_points`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLRSLocate,
+}
+
+var lrsSegmentCmd = &cobra.Command{
+	Use:   "segment <events_table>",
+	Short: "Cut routes between the measures of line events",
+	Long: `Copy an events table into --out with the part of its route between the --from
+and --to measures of each event (dynamic segmentation), e.g. pavement
+condition or speed limit stretches. When --from is greater than --to the
+line runs against the route. Events whose route is missing or whose measures
+fall outside it get a NULL geometry.`,
+	Example: `  xyzduck lrs segment pavement --db roads --routes roads --route-id road_id --from start_m --to end_m --out pavement_lines`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runLRSSegment,
+}
+
+var lrsProjectCmd = &cobra.Command{
+	Use:   "project <points_table>",
+	Short: "Measure points along their nearest route",
+	Long: `Copy a point table into --out with the measure of the closest position on the
+nearest route in --measure (default measure) and the distance from the point
+to the route in route_offset, in meters or CRS units with --planar. The
+route's id is added as well.
+
+With --match-route each point is only measured along the route its own
+route id column names, which is then not added again.`,
+	Example: `  xyzduck lrs project inspections --db pipes --routes mains --route-id main_id --out inspection_measures
+  xyzduck lrs project hydrants --db pipes --routes mains --route-id main_id --match-route --out hydrant_measures`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLRSProject,
+}
+
+func init() {
+	lrsCmd.PersistentFlags().StringVar(&lrsDBFlag, "db", "", "Target database file (required)")
+	lrsCmd.PersistentFlags().StringVar(&lrsOutFlag, "out", "", "Output table name, optionally schema-qualified (required)")
+	lrsCmd.PersistentFlags().StringVar(&lrsRoutesFlag, "routes", "", "Table of route lines (required)")
+	lrsCmd.PersistentFlags().StringVar(&lrsRouteIDFlag, "route-id", "", "Column identifying each route (required)")
+	lrsCmd.PersistentFlags().StringVar(&lrsEventRouteIDFlag, "event-route-id", "", "Column naming the route of each event (default: --route-id)")
+	lrsCmd.PersistentFlags().StringVar(&lrsRouteStartFlag, "route-start", "", "Route column with the measure at the first point of each line")
+	lrsCmd.PersistentFlags().StringVar(&lrsRouteEndFlag, "route-end", "", "Route column with the measure at the last point of each line")
+	lrsCmd.PersistentFlags().BoolVar(&lrsPlanarFlag, "planar", false, "Measure in CRS units instead of meters on the sphere")
+	lrsCmd.MarkPersistentFlagRequired("db")
+	lrsCmd.MarkPersistentFlagRequired("out")
+	lrsCmd.MarkPersistentFlagRequired("routes")
+	lrsCmd.MarkPersistentFlagRequired("route-id")
+
+	lrsLocateCmd.Flags().StringVar(&lrsMeasureFlag, "measure", "", "Column holding each event's measure (required)")
+	lrsLocateCmd.MarkFlagRequired("measure")
+
+	lrsSegmentCmd.Flags().StringVar(&lrsFromFlag, "from", "", "Column holding the measure each event starts at (required)")
+	lrsSegmentCmd.Flags().StringVar(&lrsToFlag, "to", "", "Column holding the measure each event ends at (required)")
+	lrsSegmentCmd.MarkFlagRequired("from")
+	lrsSegmentCmd.MarkFlagRequired("to")
+
+	lrsProjectCmd.Flags().StringVar(&lrsMeasureColFlag, "measure", "measure", "Column to store the measure in")
+	lrsProjectCmd.Flags().BoolVar(&lrsMatchRouteFlag, "match-route", false, "Only measure points along the route their route id names")
+
+	lrsCmd.AddCommand(lrsLocateCmd)
+	lrsCmd.AddCommand(lrsSegmentCmd)
+	lrsCmd.AddCommand(lrsProjectCmd)
+	rootCmd.AddCommand(lrsCmd)
+}
+
+// prepareLRS locks the database and checks the events and routes tables. The
+// caller must call unlock when done.
+func prepareLRS(srcTable string) (dbPath string, unlock func(), err error) {
+	if (lrsRouteStartFlag == "") != (lrsRouteEndFlag == "") {
+		return "", nil, fmt.Errorf("--route-start and --route-end must be given together")
+	}
+
+	dbPath, unlock, err = prepareOutputTable(lrsDBFlag, srcTable, lrsOutFlag)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exists, err := database.TableExists(dbPath, lrsRoutesFlag)
+	if err != nil {
+		unlock()
+		return "", nil, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		unlock()
+		return "", nil, fmt.Errorf("table not found: %s", lrsRoutesFlag)
+	}
+
+	return dbPath, unlock, nil
+}
+
+// routeOptions collects the route flags shared by the subcommands
+func routeOptions() database.RouteOptions {
+	return database.RouteOptions{
+		Table:         lrsRoutesFlag,
+		IDColumn:      lrsRouteIDFlag,
+		EventIDColumn: lrsEventRouteIDFlag,
+		StartColumn:   lrsRouteStartFlag,
+		EndColumn:     lrsRouteEndFlag,
+		Planar:        lrsPlanarFlag,
+	}
+}
+
+// reportEvents prints how many events were placed
+func reportEvents(result database.LRSResult, what string) {
+	fmt.Printf("✓ Placed %d of %d %s in table '%s'\n", result.Located, result.Rows, what, lrsOutFlag)
+	if missed := result.Rows - result.Located; missed > 0 {
+		fmt.Printf("! %d had no route or a measure outside it and have no geometry\n", missed)
+	}
+}
+
+func runLRSLocate(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	dbPath, unlock, err := prepareLRS(srcTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Locating events of '%s' along '%s'...\n", srcTable, lrsRoutesFlag)
+	result, err := database.LocateEvents(dbPath, srcTable, lrsOutFlag, database.EventOptions{
+		Routes:  routeOptions(),
+		Measure: lrsMeasureFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to locate events: %w", err)
+	}
+
+	reportEvents(result, "point events")
+	return nil
+}
+
+func runLRSSegment(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	dbPath, unlock, err := prepareLRS(srcTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Cutting '%s' at the measures of '%s'...\n", lrsRoutesFlag, srcTable)
+	result, err := database.SegmentEvents(dbPath, srcTable, lrsOutFlag, database.EventOptions{
+		Routes: routeOptions(),
+		From:   lrsFromFlag,
+		To:     lrsToFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to segment routes: %w", err)
+	}
+
+	reportEvents(result, "line events")
+	return nil
+}
+
+func runLRSProject(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	dbPath, unlock, err := prepareLRS(srcTable)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The added columns must not clash with the point table's
+	columns, err := database.GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return fmt.Errorf("failed to get table schema: %w", err)
+	}
+	added := []string{lrsMeasureColFlag, "route_offset"}
+	if !lrsMatchRouteFlag {
+		added = append(added, lrsRouteIDFlag)
+	}
+	for _, col := range columns {
+		for _, name := range added {
+			if strings.EqualFold(col.Name, name) {
+				return fmt.Errorf("table %s already has a %s column", srcTable, col.Name)
+			}
+		}
+	}
+
+	fmt.Printf("Measuring '%s' along '%s'...\n", srcTable, lrsRoutesFlag)
+	result, err := database.ProjectPoints(dbPath, srcTable, lrsOutFlag, database.ProjectOptions{
+		Routes:        routeOptions(),
+		MatchRoute:    lrsMatchRouteFlag,
+		MeasureColumn: lrsMeasureColFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to project points: %w", err)
+	}
+
+	fmt.Printf("✓ Measured %d of %d points in table '%s'\n", result.Located, result.Rows, lrsOutFlag)
+	if missed := result.Rows - result.Located; missed > 0 {
+		fmt.Printf("! %d points had no route to measure along\n", missed)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/macros"
+)
+
+var macrosCmd = &cobra.Command{
+	Use:   "macros",
+	Short: "Install a library of helpful SQL macros into a database",
+	Long: `Manage xyzduck's library of SQL macros: bounding box builders, lon/lat to
+Web Mercator tile math, quadkeys and casts that return NULL instead of
+failing. Once installed they are stored in the database, so 'xyzduck query',
+the 'xyzduck sql' shell and any other DuckDB client can call them.`,
+}
+
+var (
+	macrosDBFlag    string
+	macrosForceFlag bool
+)
+
+var macrosInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Create or upgrade the macros in a database",
+	Long: `Create every macro of the library, replacing those of an earlier version.
+Macros an earlier version installed that the library no longer has are
+dropped. The installed macros and their version are recorded in
+xyzduck_macros, so running install again after upgrading xyzduck upgrades
+them.
+
+A database holding macros from a newer xyzduck is left alone unless --force
+is given.`,
+	Example: `  xyzduck macros install --db geodata
+  xyzduck query --db geodata "SELECT xyz_quadkey(12, 2200, 1343)"`,
+	Args: cobra.NoArgs,
+	RunE: runMacrosInstall,
+}
+
+var macrosListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the macros of the library",
+	Long: `List the macros of the library with their parameters and what they do.
+With --db, also tell which version is installed in the database.`,
+	Args: cobra.NoArgs,
+	RunE: runMacrosList,
+}
+
+func init() {
+	macrosInstallCmd.Flags().StringVar(&macrosDBFlag, "db", "", "Target database file (required)")
+	macrosInstallCmd.Flags().BoolVar(&macrosForceFlag, "force", false, "Install even over macros of a newer version")
+	macrosInstallCmd.MarkFlagRequired("db")
+
+	macrosListCmd.Flags().StringVar(&macrosDBFlag, "db", "", "Database to check the installed version of")
+
+	macrosCmd.AddCommand(macrosInstallCmd)
+	macrosCmd.AddCommand(macrosListCmd)
+	rootCmd.AddCommand(macrosCmd)
+}
+
+func runMacrosInstall(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(macrosDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := macros.Install(db, macrosForceFlag)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Previous == 0:
+		fmt.Printf("✓ Installed %d macros (version %d) in %s\n", result.Installed, macros.Version, dbPath)
+	case result.Previous == macros.Version:
+		fmt.Printf("✓ Reinstalled %d macros (version %d) in %s\n", result.Installed, macros.Version, dbPath)
+	default:
+		fmt.Printf("✓ Upgraded macros from version %d to %d in %s (%d installed)\n", result.Previous, macros.Version, dbPath, result.Installed)
+	}
+	if len(result.Dropped) > 0 {
+		fmt.Printf("✓ Dropped macros no longer in the library: %s\n", strings.Join(result.Dropped, ", "))
+	}
+	return nil
+}
+
+func runMacrosList(cmd *cobra.Command, args []string) error {
+	if macrosDBFlag != "" {
+		dbPath := database.EnsureDuckDBExtension(macrosDBFlag)
+		if !database.FileExists(dbPath) {
+			return fmt.Errorf("database not found: %s", dbPath)
+		}
+		db, err := database.Open(dbPath)
+		if err != nil {
+			return err
+		}
+		installed, err := macros.Installed(db)
+		db.Close()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case installed == 0:
+			fmt.Printf("Not installed in %s (run 'xyzduck macros install --db %s')\n\n", dbPath, macrosDBFlag)
+		case installed < macros.Version:
+			fmt.Printf("Version %d installed in %s; run 'xyzduck macros install' to upgrade to %d\n\n", installed, dbPath, macros.Version)
+		default:
+			fmt.Printf("Version %d installed in %s\n\n", installed, dbPath)
+		}
+	}
+
+	fmt.Printf("Macros (version %d):\n", macros.Version)
+	for _, m := range macros.Library {
+		fmt.Printf("  %s(%s)\n      %s\n", m.Name, m.Signature, m.Description)
+	}
+	return nil
+}
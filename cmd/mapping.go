@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"org.xyzmaps.xyzduck/src/geojson"
+)
+
+// isTerminal reports whether stdin and stdout are both attached to a terminal
+func isTerminal() bool {
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// promptForMapping compares a file with the table it is appended to and, when
+// some properties have no column, lets the user decide where they go. It
+// returns nil when every property already has a column.
+func promptForMapping(dbPath, tableName, geojsonPath string, opts geojson.LoadOptions) (*geojson.Mapping, error) {
+	diff, err := geojson.DiffSchema(dbPath, tableName, geojsonPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	suggested := geojson.SuggestMapping(diff)
+	if len(suggested.Properties) == 0 {
+		return nil, nil
+	}
+
+	p := tea.NewProgram(newMappingModel(tableName, diff, suggested))
+	finalModel, err := p.Run()
+	if err != nil {
+		return nil, fmt.Errorf("error running prompt: %w", err)
+	}
+
+	m := finalModel.(mappingModel)
+	if m.cancelled {
+		return nil, fmt.Errorf("cancelled by user")
+	}
+
+	mapping := m.mapping()
+	if path := strings.TrimSpace(m.saveInput.Value()); path != "" {
+		if err := mapping.Save(path); err != nil {
+			return nil, err
+		}
+		fmt.Printf("✓ Saved mapping to %s (reuse it with --mapping %s)\n", path, path)
+	}
+	return mapping, nil
+}
+
+// mappingRow is one property without a column and the choices for it
+type mappingRow struct {
+	property string
+	fileType string
+	values   int
+	// choice indexes the model's targets: 0 is a new column, 1 skips the
+	// property and the rest are existing columns
+	choice int
+}
+
+// TUI Model for mapping properties onto columns
+type mappingModel struct {
+	table   string
+	rows    []mappingRow
+	types   []string
+	targets []string
+	cursor  int
+	saving  bool
+	err     error
+
+	saveInput textinput.Model
+	submitted bool
+	cancelled bool
+}
+
+func newMappingModel(tableName string, diff geojson.SchemaDiff, suggested *geojson.Mapping) mappingModel {
+	m := mappingModel{table: tableName}
+
+	// Properties can go to columns the file doesn't fill itself
+	m.targets = []string{"", ""}
+	for _, c := range diff.Columns {
+		if c.Kind == geojson.DiffTableOnly {
+			m.targets = append(m.targets, c.Name)
+		}
+	}
+
+	for _, e := range suggested.Properties {
+		row := mappingRow{property: e.Property}
+		for _, c := range diff.Columns {
+			if c.Name == e.Property && c.Kind == geojson.DiffFileOnly {
+				row.fileType, row.values = c.FileType, c.Values
+			}
+		}
+		// Preselect a column differing only in case, e.g. NAME -> name
+		for i, target := range m.targets[2:] {
+			if strings.EqualFold(target, e.Property) {
+				row.choice = i + 2
+			}
+		}
+		m.rows = append(m.rows, row)
+		m.types = append(m.types, e.Type)
+	}
+
+	ti := textinput.New()
+	ti.Placeholder = tableName + ".mapping.yaml"
+	ti.CharLimit = 256
+	ti.Width = 50
+	m.saveInput = ti
+	return m
+}
+
+func (m mappingModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m mappingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if keyMsg.Type == tea.KeyCtrlC {
+		m.cancelled = true
+		return m, tea.Quit
+	}
+
+	if m.saving {
+		switch keyMsg.Type {
+		case tea.KeyEnter:
+			m.submitted = true
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.saving = false
+			m.saveInput.Blur()
+			return m, nil
+		}
+		m.saveInput, cmd = m.saveInput.Update(msg)
+		return m, cmd
+	}
+
+	switch keyMsg.String() {
+	case "esc", "q":
+		m.cancelled = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.rows)-1 {
+			m.cursor++
+		}
+	case "right", "l", " ", "tab":
+		m.rows[m.cursor].choice = (m.rows[m.cursor].choice + 1) % len(m.targets)
+		m.err = nil
+	case "left", "h", "shift+tab":
+		m.rows[m.cursor].choice = (m.rows[m.cursor].choice + len(m.targets) - 1) % len(m.targets)
+		m.err = nil
+	case "enter":
+		if m.err = m.validate(); m.err != nil {
+			return m, nil
+		}
+		m.saving = true
+		return m, m.saveInput.Focus()
+	}
+	return m, nil
+}
+
+// validate checks that no column receives two properties
+func (m mappingModel) validate() error {
+	used := make(map[int]string)
+	for _, row := range m.rows {
+		if row.choice < 2 {
+			continue
+		}
+		if other, ok := used[row.choice]; ok {
+			return fmt.Errorf("%s and %s are both mapped to column %s", other, row.property, m.targets[row.choice])
+		}
+		used[row.choice] = row.property
+	}
+	return nil
+}
+
+// describe renders the choice made for a row
+func (m mappingModel) describe(i int) string {
+	row := m.rows[i]
+	switch row.choice {
+	case 0:
+		return fmt.Sprintf("new column %s (%s)", row.property, m.types[i])
+	case 1:
+		return "skip"
+	default:
+		return "column " + m.targets[row.choice]
+	}
+}
+
+// mapping returns the choices as a mapping
+func (m mappingModel) mapping() *geojson.Mapping {
+	mapping := &geojson.Mapping{}
+	for i, row := range m.rows {
+		e := geojson.MappingEntry{Property: row.property}
+		switch row.choice {
+		case 0:
+			e.Column, e.Type = row.property, m.types[i]
+		case 1:
+			e.Skip = true
+		default:
+			e.Column = m.targets[row.choice]
+		}
+		mapping.Properties = append(mapping.Properties, e)
+	}
+	return mapping
+}
+
+func (m mappingModel) View() string {
+	if m.submitted || m.cancelled {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nThese properties have no column in '%s'. Where should they go?\n\n", m.table)
+
+	labels := make([]string, len(m.rows))
+	width := 0
+	for i, row := range m.rows {
+		labels[i] = fmt.Sprintf("%s (%s, %d values)", row.property, row.fileType, row.values)
+		width = max(width, len(labels[i]))
+	}
+	for i := range m.rows {
+		cursor := "  "
+		if i == m.cursor && !m.saving {
+			cursor = "> "
+		}
+		s += fmt.Sprintf("%s%-*s -> %s\n", cursor, width, labels[i], m.describe(i))
+	}
+	s += "\n"
+
+	if m.err != nil {
+		s += fmt.Sprintf("Error: %s\n\n", m.err)
+	}
+
+	if m.saving {
+		s += "Save this mapping for reuse? Enter a file name, or leave empty to skip:\n\n"
+		s += m.saveInput.View() + "\n\n"
+		s += "(enter to continue, esc to go back)\n"
+		return s
+	}
+
+	s += "(↑/↓ select, ←/→ change, enter to continue, esc to cancel)\n"
+	return s
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var (
+	mergeDBFlag           string
+	mergeIntoFlag         string
+	mergeSourceColumnFlag bool
+	mergeReplaceFlag      bool
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <table-pattern>",
+	Short: "Merge tables matching a pattern into one table",
+	Long: `Union every table whose name matches a shell-style pattern (e.g. 'roads_*')
+into a new table. Columns are matched by name, so tables with different
+property sets can be merged; columns a table lacks are NULL for its rows.
+Columns with the same name must have compatible types.
+
+Tables outside the main schema match as schema.table (e.g. 'staging.*').
+With --add-source-column each row records the table it came from in a
+source_table column.`,
+	Example: `  xyzduck merge 'roads_*' --db geodata --into roads_all --add-source-column`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runMerge,
+}
+
+func init() {
+	mergeCmd.Flags().StringVar(&mergeDBFlag, "db", "", "Target database file (required)")
+	mergeCmd.Flags().StringVar(&mergeIntoFlag, "into", "", "Table to create from the merged rows (required)")
+	mergeCmd.Flags().BoolVar(&mergeSourceColumnFlag, "add-source-column", false, "Record each row's source table in a source_table column")
+	mergeCmd.Flags().BoolVar(&mergeReplaceFlag, "replace", false, "Overwrite the --into table if it exists")
+	mergeCmd.MarkFlagRequired("db")
+	mergeCmd.MarkFlagRequired("into")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(mergeDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	result, err := database.MergeTables(dbPath, args[0], mergeIntoFlag, database.MergeOptions{
+		AddSourceColumn: mergeSourceColumnFlag,
+		Replace:         mergeReplaceFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Merged %d tables (%d rows) into '%s'\n", len(result.Tables), result.Rows, mergeIntoFlag)
+	fmt.Printf("  %s\n", strings.Join(result.Tables, ", "))
+
+	notifyChange("merge", dbPath, mergeIntoFlag, result.Rows)
+
+	return nil
+}
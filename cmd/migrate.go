@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+var (
+	migrateOutFlag     string
+	migrateDuckDBFlag  string
+	migrateReplaceFlag bool
+)
+
+var migrateStorageCmd = &cobra.Command{
+	Use:   "migrate-storage <database>",
+	Short: "Convert a database written by another DuckDB release to the current format",
+	Long: `Copy a database into a new file in the storage format of the DuckDB bundled
+with xyzduck, e.g. after xyzduck reports a file was created with an older
+DuckDB it can no longer read.
+
+Files the bundled DuckDB can still read are copied directly. Older files are
+exported with a DuckDB command line binary of a release that can read them
+(the one on PATH, or --duckdb) and imported into the new file. Geometry columns
+only survive the export when that binary has the spatial extension.
+
+The new file is written next to the original as <name>_migrated.duckdb unless
+--out is given. --replace puts it in place of the original, which is kept as
+<name>.duckdb.bak.`,
+	Example: `  xyzduck migrate-storage parcels.duckdb
+  xyzduck migrate-storage parcels.duckdb --duckdb ~/bin/duckdb-0.9.2 --replace`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMigrateStorage,
+}
+
+func init() {
+	migrateStorageCmd.Flags().StringVar(&migrateOutFlag, "out", "", "New database file (default <name>_migrated.duckdb)")
+	migrateStorageCmd.Flags().StringVar(&migrateDuckDBFlag, "duckdb", "", "DuckDB command line binary that can read the database (default duckdb on PATH)")
+	migrateStorageCmd.Flags().BoolVar(&migrateReplaceFlag, "replace", false, "Replace the original, keeping it as <name>.duckdb.bak")
+	rootCmd.AddCommand(migrateStorageCmd)
+}
+
+func runMigrateStorage(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(args[0])
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	outPath := database.EnsureDuckDBExtension(strings.TrimSuffix(dbPath, ".duckdb") + "_migrated")
+	if migrateOutFlag != "" {
+		outPath = database.EnsureDuckDBExtension(migrateOutFlag)
+	}
+	if database.FileExists(outPath) {
+		return fmt.Errorf("output file already exists: %s", outPath)
+	}
+	backupPath := dbPath + ".bak"
+	if migrateReplaceFlag && database.FileExists(backupPath) {
+		return fmt.Errorf("backup file already exists: %s", backupPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	header, err := database.ReadStorageHeader(dbPath)
+	if err != nil {
+		return err
+	}
+	createdWith := ""
+	if header.LibraryVersion != "" {
+		createdWith = ", created with DuckDB " + header.LibraryVersion
+	}
+	fmt.Printf("%s: storage version %d%s\n", dbPath, header.Version, createdWith)
+
+	err = database.CheckStorage(dbPath)
+	var versionErr *database.StorageVersionError
+	switch {
+	case err == nil:
+		fmt.Println("Copying with the bundled DuckDB...")
+		err = database.MigrateStorage(dbPath, outPath)
+	case errors.As(err, &versionErr) && !versionErr.Newer:
+		fmt.Printf("The bundled DuckDB can't read it; it was created with %s\n", versionErr.CreatedWith)
+		err = migrateWithCLI(dbPath, outPath, versionErr)
+	default:
+		return err
+	}
+	if err != nil {
+		removeDatabaseFile(outPath)
+		return err
+	}
+
+	if migrateReplaceFlag {
+		if err := os.Rename(dbPath, backupPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", dbPath, err)
+		}
+		if err := os.Rename(outPath, dbPath); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dbPath, err)
+		}
+		fmt.Printf("✓ Migrated %s (the original is kept as %s)\n", dbPath, backupPath)
+		return nil
+	}
+	fmt.Printf("✓ Migrated %s to %s\n", dbPath, outPath)
+	return nil
+}
+
+// migrateWithCLI exports a database the bundled DuckDB can't read with a
+// DuckDB command line binary and imports the export into outPath
+func migrateWithCLI(dbPath, outPath string, versionErr *database.StorageVersionError) error {
+	cli := migrateDuckDBFlag
+	if cli == "" {
+		found, err := exec.LookPath("duckdb")
+		if err != nil {
+			return fmt.Errorf("no duckdb binary found on PATH: install the DuckDB command line binary of the release that created %s (%s) and pass it with --duckdb", dbPath, versionErr.CreatedWith)
+		}
+		cli = found
+	}
+
+	cliVersion, err := database.CLIVersion(cli)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exporting with %s (DuckDB %s)...\n", cli, cliVersion)
+
+	dir, err := os.MkdirTemp("", "xyzduck-migrate-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	spatial, err := database.ExportWithCLI(cli, dbPath, dir, !offline.Enabled())
+	if err != nil {
+		return err
+	}
+	if !spatial {
+		fmt.Printf("! %s can't load the spatial extension; geometry columns may fail to import\n", cli)
+	}
+
+	fmt.Printf("Importing into %s...\n", outPath)
+	return database.ImportExport(dir, outPath)
+}
+
+// removeDatabaseFile deletes a partially written database and its WAL
+func removeDatabaseFile(path string) {
+	os.Remove(path)
+	os.Remove(path + ".wal")
+}
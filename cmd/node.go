@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var nodeCmd = &cobra.Command{
+	Use:   "node <table>",
+	Short: "Split lines where they intersect",
+	Long: `Copy the lines of a table into --out, split at every point where they cross
+or touch, so that lines only meet at their endpoints. Overlapping stretches
+are kept once. This prepares surveyed boundary lines for polygonize and
+networks for routing.
+
+The output table has an id and a geom column; attributes are not carried
+over.`,
+	Example: `  xyzduck node boundaries --db cadastre --out boundaries_noded`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runNode,
+}
+
+var (
+	nodeDBFlag  string
+	nodeOutFlag string
+)
+
+func init() {
+	nodeCmd.Flags().StringVar(&nodeDBFlag, "db", "", "Target database file (required)")
+	nodeCmd.Flags().StringVar(&nodeOutFlag, "out", "", "Output table name, optionally schema-qualified (required)")
+	nodeCmd.MarkFlagRequired("db")
+	nodeCmd.MarkFlagRequired("out")
+
+	rootCmd.AddCommand(nodeCmd)
+}
+
+func runNode(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	dbPath, unlock, err := prepareOutputTable(nodeDBFlag, srcTable, nodeOutFlag)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Noding lines of '%s'...\n", srcTable)
+	count, err := database.NodeLines(dbPath, srcTable, nodeOutFlag)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Created %d lines in table '%s'\n", count, nodeOutFlag)
+	return nil
+}
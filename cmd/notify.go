@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/notify"
+	"org.xyzmaps.xyzduck/src/telemetry"
+)
+
+// notifyConfig lists where change events go; nil when notifications are off
+var notifyConfig *notify.Config
+
+// loadNotifyConfig reads the --notify-config file, or XYZDUCK_NOTIFY_CONFIG
+func loadNotifyConfig() error {
+	path := notifyFlag
+	if path == "" {
+		path = os.Getenv("XYZDUCK_NOTIFY_CONFIG")
+	}
+	if path == "" {
+		return nil
+	}
+
+	config, err := notify.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	notifyConfig = config
+	return nil
+}
+
+// notifyChange publishes a change event to the targets configured for the
+// table. The change has already been made, so failures are only reported.
+// The change is also noted in the usage history.
+func notifyChange(event, dbPath, tableName string, rows int64) {
+	recordChange(dbPath, tableName, rows)
+	if notifyConfig == nil {
+		return
+	}
+	targets := notifyConfig.For(tableName)
+	if len(targets) == 0 {
+		return
+	}
+
+	bbox, err := database.TableBBox(dbPath, tableName)
+	if err != nil {
+		fmt.Printf("! Change event for '%s' sent without a bbox: %v\n", tableName, err)
+	}
+
+	e := notify.Event{
+		Event:    event,
+		Database: dbPath,
+		Table:    tableName,
+		Rows:     rows,
+		BBox:     bbox,
+		Time:     time.Now().UTC(),
+	}
+	for _, t := range targets {
+		end := startStep("publish change event", telemetry.String("notify.target", t.Name()))
+		err := notify.Publish(t, e)
+		end(err)
+		if err != nil {
+			fmt.Printf("! Failed to publish change event to %s: %v\n", t.Name(), err)
+			continue
+		}
+		fmt.Printf("✓ Published change event to %s\n", t.Name())
+	}
+}
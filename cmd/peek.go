@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/geojson"
+)
+
+var (
+	peekCountFlag int
+	peekTailFlag  bool
+)
+
+var peekCmd = &cobra.Command{
+	Use:   "peek <geojson-file>",
+	Short: "Preview the first or last features of a GeoJSON or GeoJSONL file",
+	Long: `Stream a GeoJSON file and print the properties and geometry type of its
+first N features (or last N with --tail) without loading anything.
+
+The file is read incrementally, so peeking at the head of a multi-gigabyte
+file returns immediately. --tail has to read the whole file but only keeps
+N features in memory.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPeek,
+}
+
+func init() {
+	peekCmd.Flags().IntVarP(&peekCountFlag, "count", "n", 5, "Number of features to show")
+	peekCmd.Flags().BoolVar(&peekTailFlag, "tail", false, "Show the last features instead of the first")
+	rootCmd.AddCommand(peekCmd)
+}
+
+func runPeek(cmd *cobra.Command, args []string) error {
+	path := args[0]
+
+	if peekCountFlag < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+
+	// Keep a ring of the last N features for --tail, or stop after N for head
+	type indexed struct {
+		n       int
+		feature geojson.Feature
+	}
+	var window []indexed
+	total := 0
+
+	err := geojson.StreamFile(path, geojson.FormatAuto, func(feat geojson.Feature) error {
+		total++
+		if !peekTailFlag {
+			window = append(window, indexed{total, feat})
+			if len(window) == peekCountFlag {
+				return geojson.ErrStop
+			}
+			return nil
+		}
+
+		window = append(window, indexed{total, feat})
+		if len(window) > peekCountFlag {
+			window = window[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range window {
+		if err := printFeature(item.n, item.feature); err != nil {
+			return err
+		}
+	}
+
+	if peekTailFlag {
+		fmt.Printf("(%d features in file)\n", total)
+	}
+
+	return nil
+}
+
+// printFeature writes a feature's geometry type and properties in file order
+func printFeature(n int, f geojson.Feature) error {
+	fmt.Printf("Feature %d (%s)\n", n, geojson.GeometryType(f.Geometry))
+
+	props, err := f.DecodeProperties()
+	if err != nil {
+		return fmt.Errorf("feature %d: %w", n, err)
+	}
+
+	for _, p := range props {
+		var value string
+		switch v := p.Value.(type) {
+		case string:
+			value = v
+		default:
+			data, _ := json.Marshal(v)
+			value = string(data)
+		}
+		fmt.Printf("  %s: %s\n", p.Key, value)
+	}
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var polygonizeCmd = &cobra.Command{
+	Use:   "polygonize <table>",
+	Short: "Build polygons from the areas enclosed by lines",
+	Long: `Create one polygon in --out for every area enclosed by the lines of a table,
+e.g. parcels or land use from surveyed boundary lines. Neighbouring polygons
+share their edges, so together they cover the enclosed area without gaps or
+overlaps.
+
+Lines must be noded, meeting only at their endpoints, or the areas they cross
+are missed. Run 'xyzduck node' first or pass --node. Dangling lines that
+enclose nothing are ignored.
+
+The output table has an id and a geom column.`,
+	Example: `  xyzduck polygonize boundaries_noded --db cadastre --out parcels
+  xyzduck polygonize boundaries --db cadastre --out parcels --node`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPolygonize,
+}
+
+var (
+	polygonizeDBFlag   string
+	polygonizeOutFlag  string
+	polygonizeNodeFlag bool
+)
+
+func init() {
+	polygonizeCmd.Flags().StringVar(&polygonizeDBFlag, "db", "", "Target database file (required)")
+	polygonizeCmd.Flags().StringVar(&polygonizeOutFlag, "out", "", "Output table name, optionally schema-qualified (required)")
+	polygonizeCmd.Flags().BoolVar(&polygonizeNodeFlag, "node", false, "Split lines at their intersections first")
+	polygonizeCmd.MarkFlagRequired("db")
+	polygonizeCmd.MarkFlagRequired("out")
+
+	rootCmd.AddCommand(polygonizeCmd)
+}
+
+func runPolygonize(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	dbPath, unlock, err := prepareOutputTable(polygonizeDBFlag, srcTable, polygonizeOutFlag)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fmt.Printf("Building polygons from '%s'...\n", srcTable)
+	count, err := database.Polygonize(dbPath, srcTable, polygonizeOutFlag, database.PolygonizeOptions{
+		Node: polygonizeNodeFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Created %d polygons in table '%s'\n", count, polygonizeOutFlag)
+	if count == 0 {
+		fmt.Println("! The lines enclose no areas; are they noded?")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/geojson"
+)
+
+const (
+	// progressWidth is the number of cells in the progress bar
+	progressWidth = 24
+	// progressLogInterval is how often progress is printed when stdout isn't
+	// a terminal, e.g. in CI logs
+	progressLogInterval = 10 * time.Second
+)
+
+// startProgress reports a load's progress until the returned function is
+// called: a progress bar redrawn in place on a terminal, otherwise a line
+// every progressLogInterval
+func startProgress(p *geojson.Progress) func() {
+	interactive := stdoutIsTerminal()
+	interval := progressLogInterval
+	if interactive {
+		interval = 200 * time.Millisecond
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				if interactive {
+					fmt.Print("\r\033[K")
+				}
+				return
+			case <-ticker.C:
+				s := p.Snapshot()
+				if s.Phase == "" {
+					continue
+				}
+				if interactive {
+					fmt.Print("\r\033[K" + progressLine(s, true))
+				} else {
+					fmt.Println(progressLine(s, false))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// progressLine describes the state of a load: the phase, how far it has got,
+// its throughput and the estimated time left
+func progressLine(s geojson.ProgressSnapshot, bar bool) string {
+	fraction := s.Fraction()
+	elapsed := time.Since(s.Started)
+
+	parts := []string{fmt.Sprintf("%-7s", s.Phase)}
+	if bar {
+		filled := int(fraction * progressWidth)
+		parts = append(parts, "["+strings.Repeat("█", filled)+strings.Repeat("░", progressWidth-filled)+"]")
+	}
+	parts = append(parts, fmt.Sprintf("%3.0f%%", fraction*100))
+
+	switch s.Phase {
+	case geojson.PhaseWriting:
+		parts = append(parts, fmt.Sprintf("%d/%d features", s.Written, s.Features), rate(s.Written, elapsed, "features"))
+	case geojson.PhaseLoading:
+		parts = append(parts, fmt.Sprintf("%s/%s", formatBytes(s.BytesRead), formatBytes(s.TotalBytes)), fmt.Sprintf("%d features", s.Written), rate(s.Written, elapsed, "features"))
+	default:
+		parts = append(parts, fmt.Sprintf("%s/%s", formatBytes(s.BytesRead), formatBytes(s.TotalBytes)), fmt.Sprintf("%s/s", formatBytes(int64(float64(s.BytesRead)/max(elapsed.Seconds(), 0.001)))))
+	}
+
+	if fraction > 0 && fraction < 1 && elapsed > time.Second {
+		left := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		parts = append(parts, "ETA "+left.Round(time.Second).String())
+	}
+	return "  " + strings.Join(parts, "  ")
+}
+
+// rate formats how many of something were done per second
+func rate(n int64, elapsed time.Duration, unit string) string {
+	return fmt.Sprintf("%.0f %s/s", float64(n)/max(elapsed.Seconds(), 0.001), unit)
+}
+
+// stdoutIsTerminal reports whether output goes to a terminal, where lines can
+// be redrawn
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
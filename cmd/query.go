@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/lineage"
+	"org.xyzmaps.xyzduck/src/query"
+	"org.xyzmaps.xyzduck/src/telemetry"
+)
+
+var (
+	queryDBFlag     string
+	queryFormatFlag string
+	queryOutputFlag string
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <sql>",
+	Short: "Run SQL against a database and print the results",
+	Long: `Run a SQL statement against a database with the spatial extension loaded.
+
+Results are printed as an aligned table by default; --format picks csv,
+json (an array of objects) or geojson (a FeatureCollection, which needs a
+GEOMETRY column in the result). --output writes to a file instead of stdout
+and, without --format, takes the format from its extension.
+
+GEOMETRY values are shown as WKT in table and CSV output and as GeoJSON
+geometries in JSON output. Statements that return no rows, such as CREATE
+or UPDATE, report how many rows they changed. CREATE TABLE/VIEW ... AS SELECT
+records which source columns fed each new column; see 'xyzduck lineage'.
+
+Pass - as the SQL to read it from stdin.`,
+	Example: `  xyzduck query --db geodata "SELECT name, population FROM cities ORDER BY population DESC LIMIT 10"
+  xyzduck query --db geodata "SELECT * FROM roads WHERE class = 'motorway'" --output motorways.geojson
+  xyzduck query --db geodata --format csv "SELECT * FROM cities" > cities.csv
+  xyzduck query --db geodata - < report.sql`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQuery,
+}
+
+func init() {
+	queryCmd.Flags().StringVar(&queryDBFlag, "db", "", "Database file (required)")
+	queryCmd.Flags().StringVar(&queryFormatFlag, "format", "", "Output format: table, csv, json or geojson (default: from --output, else table)")
+	queryCmd.Flags().StringVarP(&queryOutputFlag, "output", "o", "", "Write the results to this file instead of stdout")
+	queryCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(queryCmd)
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	sql := args[0]
+	if sql == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read SQL from stdin: %w", err)
+		}
+		sql = string(data)
+	}
+
+	currentRun.Query = sql
+
+	format := query.FormatForPath(queryOutputFlag)
+	if queryFormatFlag != "" {
+		var err error
+		if format, err = query.ParseFormat(queryFormatFlag); err != nil {
+			return err
+		}
+	}
+
+	dbPath := database.EnsureDuckDBExtension(queryDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	end := startStep("run query")
+	result, err := query.Run(dbPath, sql, format.GeometryEncoding())
+	end(err, telemetry.Int64("rows", int64(len(result.Rows))))
+	if err != nil {
+		return err
+	}
+	if result.Statement {
+		fmt.Printf("✓ %d rows affected\n", result.RowsAffected)
+		// The statement already ran, so failing to record its lineage is
+		// only a warning
+		if entry, err := lineage.RecordFile(dbPath, sql); err != nil {
+			fmt.Printf("! Failed to record lineage: %v\n", err)
+		} else if entry != nil {
+			fmt.Printf("✓ Recorded lineage of '%s' (see 'xyzduck lineage %s')\n", entry.Table, entry.Table)
+		}
+		return nil
+	}
+	if format == query.FormatGeoJSON && !result.HasGeometry() {
+		return fmt.Errorf("GeoJSON output needs a GEOMETRY column in the result")
+	}
+
+	out := os.Stdout
+	if queryOutputFlag != "" {
+		out, err = os.Create(queryOutputFlag)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer out.Close()
+	}
+
+	if err := query.Write(out, result, format); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+
+	if queryOutputFlag != "" {
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+		fmt.Printf("✓ Wrote %d rows to %s\n", len(result.Rows), queryOutputFlag)
+	} else if format == query.FormatTable {
+		if len(result.Rows) == 1 {
+			fmt.Println("(1 row)")
+		} else {
+			fmt.Printf("(%d rows)\n", len(result.Rows))
+		}
+	}
+	return nil
+}
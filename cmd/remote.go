@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/aws"
+	"org.xyzmaps.xyzduck/src/compressed"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/download"
+	"org.xyzmaps.xyzduck/src/gdal"
+	"org.xyzmaps.xyzduck/src/telemetry"
+)
+
+// remoteInput is a URL given to load: either read in place, or downloaded to
+// Path first
+type remoteInput struct {
+	// Path is what the loaders read: the URL itself or the downloaded file
+	Path string
+	// Name is the table name derived from the URL's filename, empty when it
+	// has none
+	Name string
+	// Cleanup removes a download that isn't kept in the cache
+	Cleanup func()
+}
+
+// openURL prepares a URL for loading. Parquet and FlatGeobuf files are read in
+// place, fetching only the byte ranges needed, unless --cache is set; other
+// formats are downloaded, into the download cache with --cache so unchanged
+// files aren't fetched again. s3:// URLs are accessed with the credentials
+// configureS3 resolves. Proxies are taken from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY.
+func openURL(rawURL string) (remoteInput, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return remoteInput{}, fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+	input := remoteInput{Path: rawURL, Cleanup: func() {}}
+	var s3 database.S3Config
+	if database.IsS3(rawURL) {
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return remoteInput{}, fmt.Errorf("invalid S3 URL %s: expected s3://bucket/key", rawURL)
+		}
+		if s3, err = configureS3(); err != nil {
+			return remoteInput{}, err
+		}
+	}
+
+	filename := path.Base(u.Path)
+	ext := ""
+	if filename != "/" && filename != "." {
+		input.Name = directoryTableName(filename)
+		ext = path.Ext(filename)
+		// Keep compound extensions whole, since .pbf, .gz and .zst alone
+		// don't say what's inside
+		if inner := path.Ext(strings.TrimSuffix(filename, ext)); strings.EqualFold(inner, ".osm") || compressed.Trim(filename) != filename {
+			ext = inner + ext
+		}
+	}
+	if input.Name == "" && tableFlag == "" {
+		return remoteInput{}, fmt.Errorf("can't derive a table name from %s; use --table", rawURL)
+	}
+
+	// The loaders tell the format from the path, which must end in the
+	// extension to be read in place
+	streamable := gdal.IsParquet(filename) || strings.EqualFold(ext, ".fgb")
+	if streamable && !cacheFlag && strings.HasSuffix(rawURL, filename) {
+		fmt.Printf("Reading %s in place...\n", rawURL)
+		return input, nil
+	}
+
+	if database.IsS3(rawURL) {
+		return downloadS3(u, s3, input)
+	}
+
+	opts := download.Options{Retries: 3, Extension: ext}
+	if !cacheFlag {
+		dir, err := os.MkdirTemp("", "xyzduck-download-*")
+		if err != nil {
+			return remoteInput{}, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		opts.CacheDir = dir
+		input.Cleanup = func() { os.RemoveAll(dir) }
+	}
+
+	fmt.Printf("Downloading %s...\n", rawURL)
+	end := startStep("download", telemetry.String("url", rawURL))
+	input.Path, err = download.Fetch(rawURL, opts)
+	end(err)
+	if err != nil {
+		input.Cleanup()
+		return remoteInput{}, err
+	}
+	if cacheFlag {
+		fmt.Printf("✓ Cached as %s\n", input.Path)
+	} else if info, err := os.Stat(input.Path); err == nil {
+		fmt.Printf("✓ Downloaded %s\n", formatBytes(info.Size()))
+	}
+	return input, nil
+}
+
+// downloadS3 downloads an object to a temporary file
+func downloadS3(u *url.URL, config database.S3Config, input remoteInput) (remoteInput, error) {
+	bucket := &aws.Bucket{Name: u.Host, Region: config.Region, Endpoint: config.Endpoint, Creds: config.Creds}
+
+	fmt.Printf("Downloading %s...\n", u)
+	end := startStep("download", telemetry.String("s3.uri", u.String()))
+	file, err := bucket.Download(strings.TrimPrefix(u.Path, "/"))
+	end(err)
+	if err != nil {
+		return remoteInput{}, err
+	}
+	input.Path = file
+	input.Cleanup = func() { os.Remove(file) }
+	if info, err := os.Stat(input.Path); err == nil {
+		fmt.Printf("✓ Downloaded %s\n", formatBytes(info.Size()))
+	}
+	return input, nil
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/aws"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/report"
+	"org.xyzmaps.xyzduck/src/telemetry"
+)
+
+var (
+	reportConfigFlag string
+	reportDBFlag     string
+	reportOutputFlag string
+	reportOnceFlag   bool
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render query results as HTML or Markdown reports, on a schedule",
+	Long: `Run the named queries of a config file and render their results as an HTML
+or Markdown report, with a map of each result's geometries.
+
+  title: Weekly data quality
+  db: geodata.duckdb
+  schedule: weekly
+  output: reports/quality-{date}.html
+  queries:
+    - name: Invalid parcels
+      description: Parcels whose geometry fails ST_IsValid
+      sql: SELECT id, owner, geom FROM parcels WHERE NOT ST_IsValid(geom)
+      limit: 100
+    - name: Roads per class
+      sql: SELECT class, count(*) AS roads FROM roads GROUP BY class ORDER BY 2 DESC
+
+Each query becomes a section: a table of its first 'limit' rows (default 50)
+without the geometry columns, and a map drawing the first geometry column of
+every row. Set 'map: false' on a query to leave its map out.
+
+The schedule is once (the default), hourly, daily, weekly or a duration such
+as 30m; the command keeps running and writes a report at each interval until
+stopped. {date} and {time} in the output are replaced by when the report ran.
+
+The format is html or markdown, set with 'format' or taken from the output's
+extension. HTML reports embed their maps; Markdown reports link to PNG files
+written next to them. An s3:// output uploads the report instead. The
+database is only locked while the queries run.`,
+	Example: `  xyzduck report --config quality.yaml --once
+  xyzduck report --config quality.yaml --output s3://team-reports/quality-{date}.md`,
+	Args: cobra.NoArgs,
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportConfigFlag, "config", "", "Report config file with the queries (required)")
+	reportCmd.Flags().StringVar(&reportDBFlag, "db", "", "Database file (overrides the config)")
+	reportCmd.Flags().StringVarP(&reportOutputFlag, "output", "o", "", "Report file or s3:// URL (overrides the config)")
+	reportCmd.Flags().BoolVar(&reportOnceFlag, "once", false, "Write one report and exit, ignoring the schedule")
+	addS3Flags(reportCmd)
+	reportCmd.MarkFlagRequired("config")
+	rootCmd.AddCommand(reportCmd)
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	config, err := report.LoadConfig(reportConfigFlag)
+	if err != nil {
+		return err
+	}
+	if reportDBFlag != "" {
+		config.DB = reportDBFlag
+	}
+	if reportOutputFlag != "" {
+		config.Output = reportOutputFlag
+	}
+	if config.DB == "" {
+		return fmt.Errorf("no database given (use --db or set db in the config)")
+	}
+	if config.Output == "" {
+		return fmt.Errorf("no output given (use --output or set output in the config)")
+	}
+
+	dbPath := database.EnsureDuckDBExtension(config.DB)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	interval, _ := report.ParseSchedule(config.Schedule)
+	if reportOnceFlag {
+		interval = 0
+	}
+	if interval == 0 {
+		return writeReport(dbPath, config)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		// A failed report is retried at the next interval
+		if err := writeReport(dbPath, config); err != nil {
+			fmt.Printf("! %v\n", err)
+		}
+		fmt.Printf("Next report at %s (Ctrl+C to stop)\n", time.Now().Add(interval).Format("2006-01-02 15:04"))
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped")
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// writeReport runs the queries and writes or uploads the rendered report
+func writeReport(dbPath string, config *report.Config) error {
+	r, err := runReportQueries(dbPath, config)
+	if err != nil {
+		return err
+	}
+
+	target := config.OutputPath(r.GeneratedAt)
+	format := config.OutputFormat()
+	out, err := report.Render(r, format, path.Base(target))
+	if err != nil {
+		return err
+	}
+
+	if database.IsS3(target) {
+		err = uploadReport(target, format, out)
+	} else {
+		err = saveReport(target, out)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Wrote report '%s' (%d queries) to %s\n", r.Title, len(r.Sections), target)
+	return nil
+}
+
+// runReportQueries holds the database lock only while the queries run
+func runReportQueries(dbPath string, config *report.Config) (*report.Report, error) {
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := database.LoadSpatial(db); err != nil {
+		return nil, err
+	}
+
+	end := startStep("report", telemetry.String("db.path", dbPath))
+	r, err := report.Run(db, config, dbPath)
+	end(err)
+	return r, err
+}
+
+// saveReport writes the document and its assets next to each other
+func saveReport(target string, out *report.Output) error {
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, name := range assetNames(out) {
+		if err := os.WriteFile(filepath.Join(dir, name), out.Assets[name], 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := os.WriteFile(target, out.Document, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// uploadReport uploads the document and its assets under the same prefix
+func uploadReport(target string, format report.Format, out *report.Output) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("invalid S3 URL %q (expected s3://bucket/key)", target)
+	}
+	config, err := configureS3()
+	if err != nil {
+		return err
+	}
+	bucket := &aws.Bucket{Name: u.Host, Region: config.Region, Endpoint: config.Endpoint, Creds: config.Creds}
+	key := strings.TrimPrefix(u.Path, "/")
+
+	end := startStep("upload", telemetry.String("s3.uri", target))
+	defer func() { end(err) }()
+	for _, name := range assetNames(out) {
+		if err = bucket.Upload(path.Join(path.Dir(key), name), out.Assets[name], "image/png"); err != nil {
+			return err
+		}
+	}
+	err = bucket.Upload(key, out.Document, format.ContentType())
+	return err
+}
+
+// assetNames lists the assets of a report in a stable order
+func assetNames(out *report.Output) []string {
+	names := make([]string, 0, len(out.Assets))
+	for name := range out.Assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
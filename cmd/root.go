@@ -3,8 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/offline"
 	"org.xyzmaps.xyzduck/src/version"
 )
 
@@ -18,10 +20,27 @@ var rootCmd = &cobra.Command{
 	},
 }
 
-var versionFlag bool
+var (
+	versionFlag     bool
+	offlineFlag     bool
+	lockTimeoutFlag time.Duration
+	notifyFlag      string
+	timingsFlag     bool
+)
 
 func init() {
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Print version information")
+	rootCmd.PersistentFlags().BoolVar(&offlineFlag, "offline", false, "Disable all network access (also XYZDUCK_OFFLINE=1)")
+	rootCmd.PersistentFlags().StringVar(&notifyFlag, "notify-config", "", "YAML file listing NATS/Kafka targets for change events (also XYZDUCK_NOTIFY_CONFIG)")
+	rootCmd.PersistentFlags().DurationVar(&lockTimeoutFlag, "lock-timeout", 10*time.Minute, "How long to wait for other xyzduck processes using the same database")
+	rootCmd.PersistentFlags().BoolVar(&timingsFlag, "timings", false, "Print how long the command and its steps took, on stderr")
+
+	// Offline mode, change notifications and tracing apply to every subcommand
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		offline.Set(offlineFlag)
+		startTracing(cmd)
+		return loadNotifyConfig()
+	}
 
 	// Handle version flag
 	rootCmd.PreRun = func(cmd *cobra.Command, args []string) {
@@ -34,8 +53,14 @@ func init() {
 
 // Execute runs the root command
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	start := time.Now()
+	cmd, err := rootCmd.ExecuteC()
+	recordUsage(cmd, start, err)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+	}
+	finishTracing(err)
+	if err != nil {
 		os.Exit(1)
 	}
 }
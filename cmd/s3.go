@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/aws"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var (
+	s3RegionFlag   string
+	s3EndpointFlag string
+	awsProfileFlag string
+)
+
+// addS3Flags adds the flags configuring access to s3:// URLs
+func addS3Flags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s3RegionFlag, "s3-region", "", "Region of s3:// buckets (default: AWS_REGION, the profile's, or us-east-1)")
+	cmd.Flags().StringVar(&s3EndpointFlag, "s3-endpoint", "", "URL of an S3-compatible store, e.g. http://localhost:9000 (also AWS_ENDPOINT_URL)")
+	cmd.Flags().StringVar(&awsProfileFlag, "aws-profile", "", "AWS profile to read S3 credentials from (also AWS_PROFILE)")
+}
+
+// configureS3 resolves the credentials, region and endpoint for s3:// URLs
+// from the flags, the environment and the AWS shared files
+func configureS3() (database.S3Config, error) {
+	creds, region, err := aws.ResolveCredentials(awsProfileFlag)
+	if err != nil {
+		return database.S3Config{}, err
+	}
+
+	config := database.S3Config{Region: s3RegionFlag, Endpoint: s3EndpointFlag, Creds: creds}
+	for _, r := range []string{os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), region, "us-east-1"} {
+		if config.Region == "" {
+			config.Region = r
+		}
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	database.SetS3(config)
+	return config, nil
+}
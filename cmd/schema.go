@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/geojson"
+	"org.xyzmaps.xyzduck/src/schema"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Inspect table schemas",
+	Long:  `Inspect table schemas and compare them with input files.`,
+}
+
+var (
+	schemaDBFlag            string
+	schemaDuplicateKeysFlag string
+	schemaOutFlag           string
+	schemaTableFlag         string
+)
+
+var schemaDiffCmd = &cobra.Command{
+	Use:   "diff <table> <file>",
+	Short: "Compare a table's columns with a GeoJSON file before appending",
+	Long: `Show how the properties of a GeoJSON file line up with the columns of an
+existing table, to predict what 'xyzduck load <file> --table <table>' would do
+before running it.
+
+Property keys are resolved to column names as the load resolves them. Each
+column is marked:
+
+  =  present in both, values fit the column type
+  ~  present in both, but values would be coerced or can't be cast
+  -  only in the table; it would be NULL for the new rows
+  +  only in the file; the values would be dropped
+
+The command exits with an error when a value can't be cast to its column
+type, since the load would fail.`,
+	Example: `  xyzduck schema diff cities more-cities.geojson --db geodata`,
+	Args:    cobra.ExactArgs(2),
+	RunE:    runSchemaDiff,
+}
+
+var schemaExportCmd = &cobra.Command{
+	Use:   "export <table>",
+	Short: "Write a table's columns to a schema file",
+	Long: `Write the name and type of every column of a table to a YAML schema file,
+so the table structure can be kept under version control and recreated with
+'xyzduck schema apply'. The file is the same one load's --schema-file reads,
+so per-column load settings can be added to it.
+
+Without -o the schema is printed to stdout.`,
+	Example: `  xyzduck schema export roads --db geodata -o roads.schema.yaml`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runSchemaExport,
+}
+
+var schemaApplyCmd = &cobra.Command{
+	Use:   "apply <schema-file>",
+	Short: "Create or extend a table from a schema file",
+	Long: `Create the table described by a schema file, or add the columns it's missing
+if the table already exists. Existing columns are never changed or dropped;
+columns whose type differs from the file are reported.
+
+The table name comes from the file's table key, or --table.`,
+	Example: `  xyzduck schema apply roads.schema.yaml --db fresh
+  xyzduck schema apply roads.schema.yaml --db geodata --table staging.roads`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSchemaApply,
+}
+
+func init() {
+	schemaCmd.PersistentFlags().StringVar(&schemaDBFlag, "db", "", "Database file (required)")
+	schemaCmd.MarkPersistentFlagRequired("db")
+
+	schemaDiffCmd.Flags().StringVar(&schemaDuplicateKeysFlag, "duplicate-keys", "first", "Policy for colliding property keys: first, last, suffix, error")
+
+	schemaExportCmd.Flags().StringVarP(&schemaOutFlag, "output", "o", "", "Schema file to write (default: stdout)")
+	schemaApplyCmd.Flags().StringVar(&schemaTableFlag, "table", "", "Table to create (default: the file's table)")
+
+	schemaCmd.AddCommand(schemaDiffCmd)
+	schemaCmd.AddCommand(schemaExportCmd)
+	schemaCmd.AddCommand(schemaApplyCmd)
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func runSchemaDiff(cmd *cobra.Command, args []string) error {
+	tableName, path := args[0], args[1]
+
+	dbPath := database.EnsureDuckDBExtension(schemaDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !database.FileExists(path) {
+		return fmt.Errorf("input file not found: %s", path)
+	}
+
+	duplicatePolicy, err := geojson.ParseDuplicatePolicy(schemaDuplicateKeysFlag)
+	if err != nil {
+		return err
+	}
+
+	diff, err := geojson.DiffSchema(dbPath, tableName, path, geojson.LoadOptions{DuplicatePolicy: duplicatePolicy})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Table '%s' vs %s (%d features)\n\n", tableName, path, diff.Features)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var added, missing, changed int
+	for _, c := range diff.Columns {
+		switch c.Kind {
+		case geojson.DiffSame:
+			fmt.Fprintf(w, "  = %s\t%s\n", c.Name, c.TableType)
+		case geojson.DiffType:
+			changed++
+			note := fmt.Sprintf("%d of %d values coerced", c.Coerced, c.Values)
+			if c.Unfit > 0 {
+				note = fmt.Sprintf("%d of %d values can't be cast", c.Unfit, c.Values)
+			}
+			fmt.Fprintf(w, "  ~ %s\t%s <- %s\t%s\n", c.Name, c.TableType, c.FileType, note)
+		case geojson.DiffTableOnly:
+			missing++
+			fmt.Fprintf(w, "  - %s\t%s\tnot in file, NULL for new rows\n", c.Name, c.TableType)
+		case geojson.DiffFileOnly:
+			added++
+			fmt.Fprintf(w, "  + %s\t%s\tnot in table, %d values dropped\n", c.Name, c.FileType, c.Values)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d only in file, %d only in table, %d type mismatches\n", added, missing, changed)
+	if diff.WouldFail() {
+		return fmt.Errorf("loading %s into '%s' would fail: some values can't be cast to their column type", path, tableName)
+	}
+	return nil
+}
+
+func runSchemaExport(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath := database.EnsureDuckDBExtension(schemaDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	columns, err := database.GetTableSchema(dbPath, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to get table schema: %w", err)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("table not found: %s", tableName)
+	}
+
+	soft, err := database.HasSoftDelete(dbPath, tableName)
+	if err != nil {
+		return err
+	}
+	file := schema.File{Table: tableName, SoftDelete: soft}
+	for _, col := range columns {
+		// Soft-delete mode brings its own column
+		if soft && col.Name == database.SoftDeleteColumn {
+			continue
+		}
+		file.Columns = append(file.Columns, schema.ColumnSpec{Name: col.Name, Type: col.Type})
+	}
+	data, err := file.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if schemaOutFlag == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(schemaOutFlag, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema file: %w", err)
+	}
+	fmt.Printf("✓ Wrote %d columns of '%s' to %s\n", len(file.Columns), tableName, schemaOutFlag)
+	return nil
+}
+
+func runSchemaApply(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(schemaDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s\nHint: Run 'xyzduck init %s' to create it", dbPath, schemaDBFlag)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := schema.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	tableName := schemaTableFlag
+	if tableName == "" {
+		tableName = file.Table
+	}
+	if tableName == "" {
+		return fmt.Errorf("schema file has no table name; use --table")
+	}
+
+	var columns []database.Column
+	for _, col := range file.Columns {
+		if col.Type == "" {
+			return fmt.Errorf("schema file column %s has no type", col.Name)
+		}
+		columns = append(columns, database.Column{Name: col.Name, Type: col.Type})
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("schema file has no columns")
+	}
+
+	result, err := database.ApplyColumns(dbPath, tableName, columns)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Created:
+		fmt.Printf("✓ Created table '%s' with %d columns\n", tableName, len(columns))
+	case len(result.Added) > 0:
+		fmt.Printf("✓ Added columns to '%s': %s\n", tableName, strings.Join(result.Added, ", "))
+	default:
+		fmt.Printf("✓ Table '%s' already has every column\n", tableName)
+	}
+	for _, m := range result.Mismatched {
+		fmt.Printf("! Column %s is %s in the table but %s in the schema file (left unchanged)\n", m.Target.Name, m.Target.Type, m.Source.Type)
+	}
+
+	// Soft-delete mode is only ever turned on here; turning it off purges
+	// deleted rows, which 'soft-delete disable' asks for explicitly
+	if file.SoftDelete {
+		enabled, err := database.EnableSoftDelete(dbPath, tableName, false)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			fmt.Printf("✓ Enabled soft delete on '%s'\n", tableName)
+		}
+	}
+
+	if result.Created || len(result.Added) > 0 {
+		notifyChange("apply", dbPath, tableName, 0)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/auth"
+	"org.xyzmaps.xyzduck/src/classify"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/features"
+	"org.xyzmaps.xyzduck/src/server"
+	"org.xyzmaps.xyzduck/src/thumbnail"
+	"org.xyzmaps.xyzduck/src/tiles"
+)
+
+var (
+	serveDBFlag         string
+	serveTableFlag      string
+	serveAddrFlag       string
+	serveLayerFlag      string
+	serveMinZoomFlag    int
+	serveMaxZoomFlag    int
+	serveRasterSizeFlag int
+	serveFillFlag       string
+	serveStrokeFlag     string
+	serveChoroplethFlag string
+	serveAuthFlag       string
+	serveEditFlag       bool
+	serveEditKeyFlag    string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve vector and raster tiles from a table over HTTP",
+	Long: `Start an HTTP server that generates Mapbox Vector Tiles from a table on the
+fly, for map clients such as MapLibre, OpenLayers or QGIS.
+
+Endpoints:
+  /                            a preview map of the table
+  /tiles.json                  TileJSON describing the tileset
+  /tiles/{z}/{x}/{y}.mvt       a vector tile (204 No Content when empty)
+  /raster/{z}/{x}/{y}.png      the same tile drawn as an image
+
+With --edit also:
+  GET  /features/{id}          a feature with its full geometry, as GeoJSON
+  POST /features/edits         inserts, updates and deletes in one transaction
+
+Tiles are encoded like 'xyzduck tiles' writes them: one layer named after
+the table (or --layer) with the non-geometry columns as properties.
+
+Raster tiles are for clients that only show images, such as Leaflet tile
+layers or web map services. Polygons are filled with --fill and outlined,
+lines and points drawn, in --stroke; colours are hex, #rrggbb or #rrggbbaa.
+
+--choropleth colours features by the class breaks 'xyzduck classify' stored
+for a column, in the preview map and raster tiles. Raster polygons keep the
+opacity of --fill; features without a value are drawn as usual.
+
+--auth-config (or XYZDUCK_AUTH_CONFIG) names a YAML file requiring every
+request to authenticate, with an API key or a JWT from an OpenID Connect
+provider, sent as "Authorization: Bearer <credential>", an X-API-Key header
+or an access_token query parameter (open the preview map as
+/?access_token=...). Roles come with each API key, or from a token claim
+mapped through role_map; roles lists those allowed in:
+
+  api_keys:
+    - name: dashboards
+      key_env: DASHBOARD_KEY
+      roles: [viewer]
+  oidc:
+    issuer: https://login.example.com/realms/corp
+    audience: xyzduck
+    roles_claim: groups
+    role_map:
+      gis-team: [viewer]
+  roles: [viewer]
+
+--edit turns the preview map into an editor: draw points, lines and
+polygons, click a feature to change its attributes, reshape or delete it.
+Changes go through the features API, which takes a batch such as
+
+  {"insert": [{"type": "Feature", "geometry": {...}, "properties": {...}}],
+   "update": [{"id": 42, "properties": {"status": "closed"}}],
+   "delete": [7, 8]}
+
+and makes all of it or, when one edit fails, none of it. Features are
+identified by the table's primary key, or the column --edit-key names;
+inserted features without a key get the next integer or a random UUID.
+Updates only change the geometry and properties they give. Deletes in a
+soft-delete table stamp the row, so 'xyzduck restore-rows' can undo them.
+With --auth-config, only callers holding one of the roles in edit_roles may
+edit; --edit refuses to start when it lists none.
+
+The server keeps the database open, so other xyzduck commands on the same
+database wait until it stops (Ctrl+C).`,
+	Example: `  xyzduck serve --db geodata --table roads
+  xyzduck serve --db geodata --table staging.parcels --addr :9000 --min-zoom 10
+  xyzduck serve --db geodata --table parcels --fill '#22c55e80' --stroke '#15803d' --raster-size 512
+  xyzduck serve --db census --table tracts --choropleth median_income
+  xyzduck serve --db geodata --table roads --addr :8080 --auth-config auth.yaml
+  xyzduck serve --db geodata --table parcels --edit`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveDBFlag, "db", "", "Database file (required)")
+	serveCmd.Flags().StringVar(&serveTableFlag, "table", "", "Table to serve (required)")
+	serveCmd.Flags().StringVar(&serveAddrFlag, "addr", "localhost:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveLayerFlag, "layer", "", "Layer name in the tiles (default: the table name)")
+	serveCmd.Flags().IntVar(&serveMinZoomFlag, "min-zoom", 0, "Lowest zoom level served")
+	serveCmd.Flags().IntVar(&serveMaxZoomFlag, "max-zoom", 22, "Highest zoom level served")
+	serveCmd.Flags().IntVar(&serveRasterSizeFlag, "raster-size", 256, "Width and height of raster tiles in pixels")
+	serveCmd.Flags().StringVar(&serveFillFlag, "fill", "#3b82f660", "Polygon fill colour in raster tiles")
+	serveCmd.Flags().StringVar(&serveStrokeFlag, "stroke", "#1d4ed8", "Line, outline and point colour in raster tiles")
+	serveCmd.Flags().StringVar(&serveChoroplethFlag, "choropleth", "", "Colour features by the stored classes of this column")
+	serveCmd.Flags().StringVar(&serveAuthFlag, "auth-config", "", "YAML file with the API keys and OIDC provider requests must authenticate with (also XYZDUCK_AUTH_CONFIG)")
+	serveCmd.Flags().BoolVar(&serveEditFlag, "edit", false, "Let the preview map and the features API edit the table")
+	serveCmd.Flags().StringVar(&serveEditKeyFlag, "edit-key", "", "Column identifying features for editing (default: the primary key)")
+	serveCmd.MarkFlagRequired("db")
+	serveCmd.MarkFlagRequired("table")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveMinZoomFlag < 0 || serveMaxZoomFlag > 24 || serveMinZoomFlag > serveMaxZoomFlag {
+		return fmt.Errorf("invalid zoom range %d-%d (zoom levels go from 0 to 24)", serveMinZoomFlag, serveMaxZoomFlag)
+	}
+	if serveRasterSizeFlag < 1 || serveRasterSizeFlag > 4096 {
+		return fmt.Errorf("invalid raster size %d (expected 1 to 4096 pixels)", serveRasterSizeFlag)
+	}
+	fill, err := thumbnail.ParseColor(serveFillFlag)
+	if err != nil {
+		return fmt.Errorf("--fill: %w", err)
+	}
+	stroke, err := thumbnail.ParseColor(serveStrokeFlag)
+	if err != nil {
+		return fmt.Errorf("--stroke: %w", err)
+	}
+
+	authenticator, err := loadAuth()
+	if err != nil {
+		return err
+	}
+	if serveEditFlag && authenticator != nil && len(authenticator.EditRoles) == 0 {
+		return fmt.Errorf("--edit with an auth config requires edit_roles, the roles allowed to edit")
+	}
+
+	dbPath := database.EnsureDuckDBExtension(serveDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var choropleth *classify.Classification
+	if serveChoroplethFlag != "" {
+		if choropleth, err = loadChoropleth(dbPath, serveTableFlag, serveChoroplethFlag); err != nil {
+			return err
+		}
+	}
+
+	source, err := tiles.NewSource(dbPath, serveTableFlag, serveLayerFlag)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	opts := server.Options{
+		MinZoom:     serveMinZoomFlag,
+		MaxZoom:     serveMaxZoomFlag,
+		RasterSize:  serveRasterSizeFlag,
+		RasterStyle: thumbnail.Style{Fill: fill, Stroke: stroke},
+		Choropleth:  choropleth,
+		Auth:        authenticator,
+	}
+	if serveEditFlag {
+		// Edits go through the source's connection, so tiles see them at once
+		if opts.Features, err = features.NewStore(source.DB(), serveTableFlag, serveEditKeyFlag); err != nil {
+			return err
+		}
+		opts.OnEdit = func(r features.Result) {
+			fmt.Printf("✓ Edited '%s': %d inserted, %d updated, %d deleted\n", serveTableFlag, len(r.Inserted), r.Updated, r.Deleted)
+		}
+		fmt.Printf("✓ Editing enabled, features keyed by %s\n", opts.Features.Key())
+	} else if serveEditKeyFlag != "" {
+		return fmt.Errorf("--edit-key only applies with --edit")
+	}
+
+	handler, err := server.New(source, opts)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", serveAddrFlag)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", serveAddrFlag, err)
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Serving '%s' at http://%s (Ctrl+C to stop)\n", serveTableFlag, listener.Addr())
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	fmt.Println("Stopped")
+	return nil
+}
+
+// loadAuth builds the authenticator of the --auth-config file, or
+// XYZDUCK_AUTH_CONFIG; nil means requests aren't authenticated
+func loadAuth() (*auth.Authenticator, error) {
+	path := serveAuthFlag
+	if path == "" {
+		path = os.Getenv("XYZDUCK_AUTH_CONFIG")
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	config, err := auth.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	authenticator, err := auth.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []string
+	for _, p := range authenticator.Providers {
+		providers = append(providers, p.Name())
+	}
+	fmt.Printf("✓ Requests must authenticate (%s)\n", strings.Join(providers, ", "))
+	return authenticator, nil
+}
+
+// loadChoropleth reads the stored classes of a column
+func loadChoropleth(dbPath, table, column string) (*classify.Classification, error) {
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	c, err := classify.Load(db, table, column)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
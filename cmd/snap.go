@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var snapCmd = &cobra.Command{
+	Use:   "snap <table>",
+	Short: "Move features onto nearby reference geometries",
+	Long: `Copy a table into --out, moving every feature onto the nearest geometry of
+the --to table within --tolerance, e.g. address points onto road edges.
+
+Points move to the closest point of the reference geometry; the vertices of
+lines and polygons snap to its vertices and edges. Features with no reference
+geometry within the tolerance are copied unchanged.
+
+The tolerance takes a unit (2m, 0.5km) for lon/lat tables, where distances
+are measured on the sphere, or is a plain number in CRS units for projected
+ones. The output table gets a snap_distance column with the distance each
+feature was from its reference geometry, NULL for those left in place, and
+with --ref-id a snap_ref column with that geometry's id. A displacement
+report is printed at the end.`,
+	Example: `  xyzduck snap addresses --db city --to roads --tolerance 2m --out addresses_snapped
+  xyzduck snap parcels --db cadastre --to boundaries --tolerance 0.05 --out parcels_snapped --ref-id boundary_id`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnap,
+}
+
+var (
+	snapDBFlag        string
+	snapToFlag        string
+	snapToleranceFlag string
+	snapOutFlag       string
+	snapRefIDFlag     string
+)
+
+func init() {
+	snapCmd.Flags().StringVar(&snapDBFlag, "db", "", "Target database file (required)")
+	snapCmd.Flags().StringVar(&snapToFlag, "to", "", "Reference table to snap onto (required)")
+	snapCmd.Flags().StringVar(&snapToleranceFlag, "tolerance", "", "Farthest a feature is moved, e.g. 2m, 0.5km or CRS units (required)")
+	snapCmd.Flags().StringVar(&snapOutFlag, "out", "", "Output table name, optionally schema-qualified (required)")
+	snapCmd.Flags().StringVar(&snapRefIDFlag, "ref-id", "", "Reference column to copy into snap_ref")
+	snapCmd.MarkFlagRequired("db")
+	snapCmd.MarkFlagRequired("to")
+	snapCmd.MarkFlagRequired("tolerance")
+	snapCmd.MarkFlagRequired("out")
+
+	rootCmd.AddCommand(snapCmd)
+}
+
+func runSnap(cmd *cobra.Command, args []string) error {
+	srcTable := args[0]
+
+	tolerance, err := database.ParseDistance(snapToleranceFlag)
+	if err != nil {
+		return err
+	}
+
+	dbPath, unlock, err := prepareOutputTable(snapDBFlag, srcTable, snapOutFlag)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := database.TableExists(dbPath, snapToFlag)
+	if err != nil {
+		return fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("table not found: %s", snapToFlag)
+	}
+
+	columns, err := database.GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return fmt.Errorf("failed to get table schema: %w", err)
+	}
+	for _, col := range columns {
+		for _, added := range database.SnapColumns {
+			if strings.EqualFold(col.Name, added) {
+				return fmt.Errorf("table %s already has a %s column", srcTable, col.Name)
+			}
+		}
+	}
+
+	fmt.Printf("Snapping '%s' to '%s' within %s...\n", srcTable, snapToFlag, tolerance)
+	result, err := database.SnapFeatures(dbPath, srcTable, snapOutFlag, database.SnapOptions{
+		ReferenceTable:    snapToFlag,
+		Tolerance:         tolerance,
+		ReferenceIDColumn: snapRefIDFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to snap features: %w", err)
+	}
+
+	fmt.Printf("✓ Snapped %d of %d features into table '%s'\n", result.Snapped, result.Rows, snapOutFlag)
+	if result.Snapped > 0 {
+		unit := ""
+		if tolerance.Meters {
+			unit = "m"
+		}
+		fmt.Printf("  Displacement: mean %.3g%s, median %.3g%s, max %.3g%s\n",
+			result.Mean, unit, result.Median, unit, result.Max, unit)
+	}
+	if unsnapped := result.Rows - result.Snapped; unsnapped > 0 {
+		fmt.Printf("! %d features had no reference geometry within %s and were left in place\n", unsnapped, tolerance)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var softDeleteCmd = &cobra.Command{
+	Use:   "soft-delete",
+	Short: "Keep deleted rows of a table restorable",
+	Long: `Put a table in soft-delete mode, or take it out of it.
+
+A soft-delete table has a deleted_at column. 'xyzduck delete-rows' stamps
+the rows it deletes with the time instead of removing them, 'xyzduck export',
+'xyzduck tiles' and 'xyzduck serve' leave stamped rows out, and
+'xyzduck restore-rows' brings them back. Queries and the SQL shell still see
+every row; filter on deleted_at IS NULL for the live ones.
+
+A schema file with soft_delete: true enables the mode when applied with
+'xyzduck schema apply'.`,
+}
+
+var (
+	softDeleteDBFlag    string
+	softDeleteAdoptFlag bool
+	deleteWhereFlag     string
+	deleteAllFlag       bool
+	restoreWhereFlag    string
+	restoreSinceFlag    time.Duration
+	restoreLastFlag     bool
+)
+
+var softDeleteEnableCmd = &cobra.Command{
+	Use:   "enable <table>",
+	Short: "Put a table in soft-delete mode",
+	Long: `Put a table in soft-delete mode, adding its deleted_at column.
+
+A table that already has a deleted_at column is refused: rows with a value in
+it would disappear from exports and tiles, and disabling the mode would purge
+them and drop the column. --adopt takes over an existing TIMESTAMP deleted_at
+column that holds deletion times.`,
+	Example: `  xyzduck soft-delete enable parcels --db geodata`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runSoftDeleteEnable,
+}
+
+var softDeleteDisableCmd = &cobra.Command{
+	Use:   "disable <table>",
+	Short: "Take a table out of soft-delete mode, purging its deleted rows",
+	Long: `Take a table out of soft-delete mode. Rows deleted while it was in the mode
+are removed for good, and so is the deleted_at column.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSoftDeleteDisable,
+}
+
+var deleteRowsCmd = &cobra.Command{
+	Use:   "delete-rows <table>",
+	Short: "Delete the rows of a table matching a condition",
+	Long: `Delete the rows of a table matching a SQL condition.
+
+Rows of a table in soft-delete mode are only stamped with the time in their
+deleted_at column, so a filter that turns out to be wrong can be undone with
+'xyzduck restore-rows'. Rows of other tables are removed for good.
+
+Either --where or --all must be given, so an empty condition can't delete a
+whole table by accident.`,
+	Example: `  xyzduck delete-rows parcels --db geodata --where "status = 'retired'"
+  xyzduck restore-rows parcels --db geodata --last`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDeleteRows,
+}
+
+var restoreRowsCmd = &cobra.Command{
+	Use:   "restore-rows <table>",
+	Short: "Undelete rows of a soft-delete table",
+	Long: `Bring back rows 'xyzduck delete-rows' deleted from a table in soft-delete
+mode. --last restores the rows of the most recent delete, --since those
+deleted within a duration and --where those matching a SQL condition; they
+combine, and with none of them every deleted row is restored.`,
+	Example: `  xyzduck restore-rows parcels --db geodata --last
+  xyzduck restore-rows parcels --db geodata --since 2h --where "district = 'north'"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestoreRows,
+}
+
+func init() {
+	softDeleteCmd.PersistentFlags().StringVar(&softDeleteDBFlag, "db", "", "Target database file (required)")
+	softDeleteCmd.MarkPersistentFlagRequired("db")
+	softDeleteEnableCmd.Flags().BoolVar(&softDeleteAdoptFlag, "adopt", false, "Take over an existing TIMESTAMP deleted_at column")
+	softDeleteCmd.AddCommand(softDeleteEnableCmd)
+	softDeleteCmd.AddCommand(softDeleteDisableCmd)
+	rootCmd.AddCommand(softDeleteCmd)
+
+	deleteRowsCmd.Flags().StringVar(&softDeleteDBFlag, "db", "", "Target database file (required)")
+	deleteRowsCmd.Flags().StringVar(&deleteWhereFlag, "where", "", "SQL condition selecting the rows to delete")
+	deleteRowsCmd.Flags().BoolVar(&deleteAllFlag, "all", false, "Delete every row")
+	deleteRowsCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(deleteRowsCmd)
+
+	restoreRowsCmd.Flags().StringVar(&softDeleteDBFlag, "db", "", "Target database file (required)")
+	restoreRowsCmd.Flags().StringVar(&restoreWhereFlag, "where", "", "SQL condition selecting the rows to restore")
+	restoreRowsCmd.Flags().DurationVar(&restoreSinceFlag, "since", 0, "Only restore rows deleted within this duration (e.g. 30m, 24h)")
+	restoreRowsCmd.Flags().BoolVar(&restoreLastFlag, "last", false, "Only restore the rows of the most recent delete")
+	restoreRowsCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(restoreRowsCmd)
+}
+
+// openSoftDeleteTable resolves the database, locks it and checks the table
+// exists, returning the database path and the unlock function
+func openSoftDeleteTable(tableName string) (string, func(), error) {
+	dbPath := database.EnsureDuckDBExtension(softDeleteDBFlag)
+	if !database.FileExists(dbPath) {
+		return "", nil, fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		unlock()
+		return "", nil, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		unlock()
+		return "", nil, fmt.Errorf("table not found: %s", tableName)
+	}
+	return dbPath, unlock, nil
+}
+
+func runSoftDeleteEnable(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+	dbPath, unlock, err := openSoftDeleteTable(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	enabled, err := database.EnableSoftDelete(dbPath, tableName, softDeleteAdoptFlag)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		fmt.Printf("✓ '%s' is already in soft-delete mode\n", tableName)
+		return nil
+	}
+	fmt.Printf("✓ Enabled soft delete on '%s'\n", tableName)
+	return nil
+}
+
+func runSoftDeleteDisable(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+	dbPath, unlock, err := openSoftDeleteTable(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	purged, err := database.DisableSoftDelete(dbPath, tableName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Disabled soft delete on '%s' (%d deleted rows purged)\n", tableName, purged)
+	return nil
+}
+
+func runDeleteRows(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+	if deleteWhereFlag == "" && !deleteAllFlag {
+		return fmt.Errorf("give --where to select the rows to delete, or --all to delete every row")
+	}
+	if deleteWhereFlag != "" && deleteAllFlag {
+		return fmt.Errorf("--where and --all can't be combined")
+	}
+
+	dbPath, unlock, err := openSoftDeleteTable(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	n, soft, err := database.DeleteRows(dbPath, tableName, deleteWhereFlag)
+	if err != nil {
+		return err
+	}
+	if soft {
+		fmt.Printf("✓ Deleted %d rows from '%s' (restore them with 'xyzduck restore-rows %s --last')\n", n, tableName, tableName)
+	} else {
+		fmt.Printf("✓ Deleted %d rows from '%s' permanently\n", n, tableName)
+	}
+
+	if n > 0 {
+		notifyChange("delete", dbPath, tableName, n)
+	}
+	return nil
+}
+
+func runRestoreRows(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+	if restoreSinceFlag < 0 {
+		return fmt.Errorf("--since must not be negative")
+	}
+
+	dbPath, unlock, err := openSoftDeleteTable(tableName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	opts := database.RestoreOptions{Where: restoreWhereFlag, Last: restoreLastFlag}
+	if restoreSinceFlag > 0 {
+		opts.Since = time.Now().Add(-restoreSinceFlag)
+	}
+	n, err := database.RestoreRows(dbPath, tableName, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Restored %d rows of '%s'\n", n, tableName)
+
+	if n > 0 {
+		notifyChange("restore", dbPath, tableName, n)
+	}
+	return nil
+}
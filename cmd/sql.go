@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/lineage"
+	"org.xyzmaps.xyzduck/src/query"
+	"org.xyzmaps.xyzduck/src/usage"
+)
+
+var sqlDBFlag string
+
+var sqlCmd = &cobra.Command{
+	Use:   "sql",
+	Short: "Open an interactive SQL shell on a database",
+	Long: `Open an interactive SQL shell on a database with the spatial extension loaded.
+
+Statements can span several lines and run when a line ends with ';'. Results
+are shown as a table that can be scrolled with PgUp/PgDn and, when wide,
+Shift+Left/Right. GEOMETRY values are shown as WKT. CREATE TABLE/VIEW ... AS
+SELECT records the new table's column lineage, shown by 'xyzduck lineage'.
+
+Up and Down on the first or last line step through earlier statements, and
+Ctrl+R searches them. The history is kept in sql_history in the xyzduck
+config directory (XYZDUCK_CONFIG_DIR, or the user config directory).
+
+Ctrl+C clears the input, and quits when it is empty, as do Ctrl+D and .quit.`,
+	Example: `  xyzduck sql --db geodata`,
+	Args:    cobra.NoArgs,
+	RunE:    runSQL,
+}
+
+func init() {
+	sqlCmd.Flags().StringVar(&sqlDBFlag, "db", "", "Database file (required)")
+	sqlCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(sqlCmd)
+}
+
+func runSQL(cmd *cobra.Command, args []string) error {
+	dbPath := database.EnsureDuckDBExtension(sqlDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// Temporary tables, settings and attached databases belong to a
+	// connection, so the whole session uses one
+	db.SetMaxOpenConns(1)
+
+	if err := database.LoadSpatial(db); err != nil {
+		return err
+	}
+
+	// Without a config directory the history lasts for the session only
+	var historyPath string
+	if dir, err := usage.ConfigDir(); err == nil {
+		historyPath = filepath.Join(dir, "sql_history")
+	}
+	history, err := query.LoadHistory(historyPath)
+	if err != nil {
+		return err
+	}
+
+	p := tea.NewProgram(newSQLModel(db, dbPath, history), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("error running SQL shell: %w", err)
+	}
+	return nil
+}
+
+// sqlResultMsg carries the rendered outcome of a statement
+type sqlResultMsg struct {
+	output string
+	status string
+}
+
+// TUI Model for the SQL shell
+type sqlModel struct {
+	db      *sql.DB
+	dbPath  string
+	input   textarea.Model
+	results viewport.Model
+	status  string
+	running bool
+	ready   bool
+
+	history *query.History
+	// histPos is the history entry shown in the input; Len() is the draft
+	histPos int
+	draft   string
+
+	searching bool
+	search    textinput.Model
+	// match is the history entry found by the search, -1 when none
+	match int
+}
+
+func newSQLModel(db *sql.DB, dbPath string, history *query.History) sqlModel {
+	ta := textarea.New()
+	ta.Placeholder = "SELECT ... ;"
+	ta.ShowLineNumbers = false
+	ta.SetHeight(5)
+	ta.SetPromptFunc(5, func(line int) string {
+		if line == 0 {
+			return "sql> "
+		}
+		return " ..> "
+	})
+	ta.Focus()
+
+	search := textinput.New()
+	search.Prompt = ""
+
+	return sqlModel{
+		db:      db,
+		dbPath:  dbPath,
+		input:   ta,
+		history: history,
+		histPos: history.Len(),
+		search:  search,
+		match:   -1,
+		status:  fmt.Sprintf("Connected to %s", dbPath),
+	}
+}
+
+func (m sqlModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m sqlModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the status line, input and help line
+		height := max(msg.Height-m.input.Height()-4, 1)
+		if !m.ready {
+			m.results = viewport.New(msg.Width, height)
+			m.results.SetHorizontalStep(8)
+			m.ready = true
+		} else {
+			m.results.Width = msg.Width
+			m.results.Height = height
+		}
+		m.input.SetWidth(msg.Width)
+		return m, nil
+
+	case sqlResultMsg:
+		m.running = false
+		m.status = msg.status
+		m.results.SetContent(msg.output)
+		m.results.GotoTop()
+		m.results.SetXOffset(0)
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+
+		switch msg.String() {
+		case "ctrl+c":
+			if m.input.Value() == "" {
+				return m, tea.Quit
+			}
+			m.input.Reset()
+			m.histPos = m.history.Len()
+			return m, nil
+
+		case "ctrl+d":
+			if m.input.Value() == "" {
+				return m, tea.Quit
+			}
+
+		case "enter":
+			stmt := strings.TrimSpace(m.input.Value())
+			if stmt == ".quit" || stmt == ".exit" {
+				return m, tea.Quit
+			}
+			if !strings.HasSuffix(stmt, ";") {
+				break
+			}
+			if m.running {
+				return m, nil
+			}
+			if err := m.history.Add(stmt); err != nil {
+				m.status = fmt.Sprintf("! %v", err)
+			}
+			m.input.Reset()
+			m.histPos = m.history.Len()
+			m.draft = ""
+			m.running = true
+			m.status = "Running..."
+			return m, runSQLStatement(m.db, stmt)
+
+		case "up":
+			if m.input.Line() == 0 && m.histPos > 0 {
+				if m.histPos == m.history.Len() {
+					m.draft = m.input.Value()
+				}
+				m.histPos--
+				m.input.SetValue(m.history.Entry(m.histPos))
+				return m, nil
+			}
+
+		case "down":
+			if m.input.Line() == m.input.LineCount()-1 && m.histPos < m.history.Len() {
+				m.histPos++
+				if m.histPos == m.history.Len() {
+					m.input.SetValue(m.draft)
+				} else {
+					m.input.SetValue(m.history.Entry(m.histPos))
+				}
+				return m, nil
+			}
+
+		case "ctrl+r":
+			m.searching = true
+			m.match = -1
+			m.search.Reset()
+			return m, m.search.Focus()
+
+		case "pgup":
+			m.results.PageUp()
+			return m, nil
+		case "pgdown":
+			m.results.PageDown()
+			return m, nil
+		case "shift+left":
+			m.results.ScrollLeft(8)
+			return m, nil
+		case "shift+right":
+			m.results.ScrollRight(8)
+			return m, nil
+		}
+	}
+
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// updateSearch handles keys during a Ctrl+R history search
+func (m sqlModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc", "ctrl+c", "ctrl+g":
+		m.searching = false
+		m.search.Blur()
+		return m, nil
+
+	case "enter":
+		if m.match >= 0 {
+			m.input.SetValue(m.history.Entry(m.match))
+			m.histPos = m.match
+		}
+		m.searching = false
+		m.search.Blur()
+		return m, nil
+
+	case "ctrl+r":
+		// Step to the next older match
+		if m.match > 0 {
+			if i := m.history.Search(m.search.Value(), m.match); i >= 0 {
+				m.match = i
+			}
+		}
+		return m, nil
+	}
+
+	m.search, cmd = m.search.Update(msg)
+	m.match = m.history.Search(m.search.Value(), m.history.Len())
+	return m, cmd
+}
+
+func (m sqlModel) View() string {
+	if !m.ready {
+		return "\nStarting...\n"
+	}
+
+	s := m.results.View() + "\n\n"
+	s += m.status + "\n"
+
+	if m.searching {
+		found := ""
+		if m.match >= 0 {
+			found = strings.Join(strings.Fields(m.history.Entry(m.match)), " ")
+		}
+		s += fmt.Sprintf("(reverse-i-search)`%s': %s\n", m.search.View(), found)
+		s += strings.Repeat("\n", m.input.Height()-1)
+		s += "(enter to use, ctrl+r for older, esc to cancel)"
+		return s
+	}
+
+	s += m.input.View() + "\n"
+	s += "(end with ; to run, ↑/↓ history, ctrl+r search, pgup/pgdn scroll, ctrl+d to quit)"
+	return s
+}
+
+// runSQLStatement runs a statement in the background and renders its result
+func runSQLStatement(db *sql.DB, stmt string) tea.Cmd {
+	return func() tea.Msg {
+		start := time.Now()
+		result, err := query.RunOn(db, stmt, query.GeometryWKT)
+		elapsed := formatDuration(time.Since(start))
+		if err != nil {
+			return sqlResultMsg{output: fmt.Sprintf("Error: %v", err), status: fmt.Sprintf("✗ Failed after %s", elapsed)}
+		}
+		if result.Statement {
+			status := fmt.Sprintf("✓ %d rows affected (%s)", result.RowsAffected, elapsed)
+			if _, err := lineage.Record(db, stmt); err != nil {
+				return sqlResultMsg{output: fmt.Sprintf("! Failed to record lineage: %v", err), status: status}
+			}
+			return sqlResultMsg{status: status}
+		}
+
+		var out strings.Builder
+		if err := query.WriteTable(&out, result); err != nil {
+			return sqlResultMsg{output: fmt.Sprintf("Error: %v", err), status: "✗ Failed to render the result"}
+		}
+		rows := fmt.Sprintf("%d rows", len(result.Rows))
+		if len(result.Rows) == 1 {
+			rows = "1 row"
+		}
+		return sqlResultMsg{output: out.String(), status: fmt.Sprintf("%s (%s)", rows, elapsed)}
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+var (
+	storageDBFlag      string
+	storageRewriteFlag bool
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage <table>",
+	Short: "Show compression and storage statistics for a table",
+	Long: `Report how a table is stored on disk: estimated compressed size, row-group
+layout, and each column's compression and share of the table's storage.
+
+Use --rewrite to rebuild the table so DuckDB recompresses every segment.
+This helps after many appends or updates; constraints and defaults on the
+table are not preserved. Freed blocks are reused by later writes but the
+.duckdb file itself does not shrink.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStorage,
+}
+
+func init() {
+	storageCmd.Flags().StringVar(&storageDBFlag, "db", "", "Target database file (required)")
+	storageCmd.MarkFlagRequired("db")
+	storageCmd.Flags().BoolVar(&storageRewriteFlag, "rewrite", false, "Rebuild the table to recompress it")
+	rootCmd.AddCommand(storageCmd)
+}
+
+func runStorage(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath := database.EnsureDuckDBExtension(storageDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("table not found: %s", tableName)
+	}
+
+	before, err := database.GetTableStorage(dbPath, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to read storage info: %w", err)
+	}
+
+	if !storageRewriteFlag {
+		printStorage(tableName, before)
+		return nil
+	}
+
+	fmt.Printf("Rewriting table '%s'...\n", tableName)
+	if err := database.RewriteTable(dbPath, tableName); err != nil {
+		return fmt.Errorf("failed to rewrite table: %w", err)
+	}
+
+	after, err := database.GetTableStorage(dbPath, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to read storage info: %w", err)
+	}
+
+	fmt.Printf("✓ Rewrote '%s': %s -> %s\n\n", tableName, formatBytes(before.Bytes), formatBytes(after.Bytes))
+	printStorage(tableName, after)
+	return nil
+}
+
+func printStorage(tableName string, stats database.TableStorage) {
+	fmt.Printf("Table:       %s\n", tableName)
+	fmt.Printf("Rows:        %d\n", stats.Rows)
+	fmt.Printf("Row groups:  %d (rows per group: min %d, max %d)\n", stats.RowGroups, stats.MinGroupRow, stats.MaxGroupRow)
+	fmt.Printf("Size:        %s (estimated, compressed)\n", formatBytes(stats.Bytes))
+	fmt.Printf("File size:   %s (whole database)\n\n", formatBytes(stats.FileBytes))
+
+	fmt.Printf("  %-24s %-28s %9s %10s %6s\n", "column", "compression", "segments", "size", "share")
+	for _, col := range stats.Columns {
+		share := 0.0
+		if stats.Bytes > 0 {
+			share = float64(col.Bytes) / float64(stats.Bytes) * 100
+		}
+		fmt.Printf("  %-24s %-28s %9d %10s %5.1f%%\n", col.Name, col.Compression, col.Segments, formatBytes(col.Bytes), share)
+	}
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/telemetry"
+	"org.xyzmaps.xyzduck/src/version"
+)
+
+// traceContext carries the span of the step the running command is in, which
+// the steps started next are nested in
+var traceContext = context.Background()
+
+// commandSpan times the running command
+var commandSpan *telemetry.Span
+
+// startTracing turns tracing on as the OTEL_* variables and --timings say
+// and starts the command's span. TRACEPARENT makes the command part of the
+// trace of whatever ran it, e.g. a pipeline.
+func startTracing(cmd *cobra.Command) {
+	config, err := telemetry.ConfigFromEnv(version.GetVersion())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "! Traces won't be exported: %v\n", err)
+	}
+	config.Timings = timingsFlag
+	if err := telemetry.Init(config); err != nil {
+		fmt.Fprintf(os.Stderr, "! Traces won't be exported: %v\n", err)
+	}
+
+	ctx := telemetry.WithTraceparent(context.Background(), os.Getenv("TRACEPARENT"))
+	traceContext, commandSpan = telemetry.Start(ctx, cmd.CommandPath(), telemetry.KindInternal,
+		telemetry.String("xyzduck.command", cmd.Name()))
+}
+
+// finishTracing ends the command's span, prints the --timings report and
+// sends the spans still waiting to be exported
+func finishTracing(err error) {
+	commandSpan.RecordError(err)
+	commandSpan.Finish()
+	if timingsFlag {
+		fmt.Fprintln(os.Stderr)
+		telemetry.WriteTimings(os.Stderr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	telemetry.Shutdown(ctx)
+}
+
+// startStep starts a span timing a step of the running command; steps
+// started before it ends are nested in it. Call the returned function with
+// the step's outcome when it's done.
+func startStep(name string, attrs ...telemetry.Attr) func(error, ...telemetry.Attr) {
+	parent := traceContext
+	ctx, span := telemetry.Start(parent, name, telemetry.KindInternal, attrs...)
+	traceContext = ctx
+	return func(err error, attrs ...telemetry.Attr) {
+		span.SetAttributes(attrs...)
+		span.RecordError(err)
+		span.Finish()
+		traceContext = parent
+	}
+}
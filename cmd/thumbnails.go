@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/thumbnail"
+)
+
+var (
+	thumbnailsDBFlag          string
+	thumbnailsOutFlag         string
+	thumbnailsSizeFlag        int
+	thumbnailsMaxFeaturesFlag int
+)
+
+var thumbnailsCmd = &cobra.Command{
+	Use:   "thumbnails [table...]",
+	Short: "Render preview thumbnails and catalog metadata for tables",
+	Long: `Draw a small PNG thumbnail of each table's geometries and a bbox preview
+showing where on the world the data lies, and record them with the table's
+row count, geometry types and extent in the xyzduck_catalog table, so
+dataset browsers can show previews without reading the data.
+
+Without arguments every table with a geometry column is cataloged and entries
+of tables that no longer exist are removed. Tables with more than
+--max-features features are drawn from a random sample.
+
+--out also writes <table>.png, <table>.bbox.png and a catalog.json with the
+metadata of every table to a directory.`,
+	Example: `  xyzduck thumbnails --db geodata
+  xyzduck thumbnails roads parcels --db geodata --size 512
+  xyzduck thumbnails --db geodata --out catalog/`,
+	RunE: runThumbnails,
+}
+
+func init() {
+	thumbnailsCmd.Flags().StringVar(&thumbnailsDBFlag, "db", "", "Database file (required)")
+	thumbnailsCmd.Flags().StringVar(&thumbnailsOutFlag, "out", "", "Also write the images and catalog.json to this directory")
+	thumbnailsCmd.Flags().IntVar(&thumbnailsSizeFlag, "size", 256, "Longer side of each thumbnail in pixels")
+	thumbnailsCmd.Flags().IntVar(&thumbnailsMaxFeaturesFlag, "max-features", 10000, "Most features drawn per table")
+	thumbnailsCmd.MarkFlagRequired("db")
+	rootCmd.AddCommand(thumbnailsCmd)
+}
+
+func runThumbnails(cmd *cobra.Command, args []string) error {
+	if thumbnailsSizeFlag < 16 || thumbnailsSizeFlag > 4096 {
+		return fmt.Errorf("--size must be between 16 and 4096")
+	}
+	if thumbnailsMaxFeaturesFlag < 1 {
+		return fmt.Errorf("--max-features must be at least 1")
+	}
+
+	dbPath := database.EnsureDuckDBExtension(thumbnailsDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tables := args
+	if len(tables) == 0 {
+		if tables, err = database.ListTables(dbPath); err != nil {
+			return err
+		}
+	}
+
+	// Find each table's geometry column before opening the database for drawing
+	var names, geomColumns []string
+	for _, table := range tables {
+		columns, err := database.GetTableSchema(dbPath, table)
+		if err != nil {
+			return fmt.Errorf("failed to get table schema: %w", err)
+		}
+		if len(columns) == 0 {
+			return fmt.Errorf("table not found: %s", table)
+		}
+
+		geomColumn := ""
+		for _, col := range columns {
+			if strings.HasPrefix(strings.ToUpper(col.Type), "GEOMETRY") {
+				geomColumn = col.Name
+				break
+			}
+		}
+		if geomColumn == "" {
+			if len(args) > 0 {
+				return fmt.Errorf("table %s has no geometry column", table)
+			}
+			continue
+		}
+		names = append(names, table)
+		geomColumns = append(geomColumns, geomColumn)
+	}
+	if len(names) == 0 {
+		fmt.Println("No tables with a geometry column")
+		return nil
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := database.LoadSpatial(db); err != nil {
+		return err
+	}
+
+	opts := thumbnail.Options{Size: thumbnailsSizeFlag, MaxFeatures: thumbnailsMaxFeaturesFlag}
+	var entries []thumbnail.Entry
+	for i, table := range names {
+		entry, err := thumbnail.Build(db, table, geomColumns[i], opts)
+		if err != nil {
+			return err
+		}
+		if entry.Thumbnail == nil {
+			fmt.Printf("  - %s: no geometries, cataloged without a thumbnail\n", table)
+		} else {
+			fmt.Printf("  ✓ %s: %d rows, %s\n", table, entry.Rows, strings.Join(entry.GeometryTypes, ", "))
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := thumbnail.Store(db, entries); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		removed, err := thumbnail.Prune(db, names)
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			fmt.Printf("✓ Removed %d catalog entries for tables that no longer exist\n", removed)
+		}
+	}
+	fmt.Printf("✓ Cataloged %d tables in '%s'\n", len(entries), thumbnail.CatalogTable)
+
+	if thumbnailsOutFlag != "" {
+		if err := thumbnail.Export(thumbnailsOutFlag, entries); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Wrote thumbnails and catalog.json to %s\n", thumbnailsOutFlag)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/tiles"
+)
+
+var (
+	tilesDBFlag        string
+	tilesOutFlag       string
+	tilesMinZoomFlag   int
+	tilesMaxZoomFlag   int
+	tilesLayerFlag     string
+	tilesOverwriteFlag bool
+)
+
+var tilesCmd = &cobra.Command{
+	Use:   "tiles <table>",
+	Short: "Generate Mapbox Vector Tiles from a table",
+	Long: `Cut a table into Mapbox Vector Tiles (MVT) for a range of zoom levels.
+
+Each tile holds one layer (named after the table, or --layer) with the
+features clipped to the tile plus a small buffer and simplified to the
+tile's resolution; every non-geometry column becomes a feature property.
+Tiles without features are not written.
+
+--out decides the output:
+  - a path ending in .mbtiles writes an MBTiles file (gzipped tiles, TMS
+    rows and vector_layers metadata, as Mapbox and MapLibre tools expect)
+  - a path ending in .pmtiles writes a PMTiles v3 archive, a single file
+    that map clients read with HTTP range requests, so it can be hosted on
+    S3 or any static file server without a tile server
+  - anything else is a directory of {z}/{x}/{y}.mvt files with a TileJSON
+    metadata.json
+
+Tile counts grow fourfold with each zoom level, so keep --max-zoom as low as
+the data needs.`,
+	Example: `  xyzduck tiles roads --db geodata --out roads.mbtiles --max-zoom 12
+  xyzduck tiles parcels --db geodata --out parcels.pmtiles --min-zoom 10 --max-zoom 16
+  xyzduck tiles cities --db geodata --out tiles/cities --min-zoom 2 --max-zoom 8 --layer places`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTiles,
+}
+
+func init() {
+	tilesCmd.Flags().StringVar(&tilesDBFlag, "db", "", "Source database file (required)")
+	tilesCmd.Flags().StringVar(&tilesOutFlag, "out", "", "Output directory, .mbtiles or .pmtiles file (required)")
+	tilesCmd.Flags().IntVar(&tilesMinZoomFlag, "min-zoom", 0, "Lowest zoom level to generate")
+	tilesCmd.Flags().IntVar(&tilesMaxZoomFlag, "max-zoom", 14, "Highest zoom level to generate")
+	tilesCmd.Flags().StringVar(&tilesLayerFlag, "layer", "", "Layer name in the tiles (default: the table name)")
+	tilesCmd.Flags().BoolVar(&tilesOverwriteFlag, "overwrite", false, "Replace the output if it exists")
+	tilesCmd.MarkFlagRequired("db")
+	tilesCmd.MarkFlagRequired("out")
+	rootCmd.AddCommand(tilesCmd)
+}
+
+func runTiles(cmd *cobra.Command, args []string) error {
+	tableName := args[0]
+
+	dbPath := database.EnsureDuckDBExtension(tilesDBFlag)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	if _, err := os.Stat(tilesOutFlag); err == nil {
+		if !tilesOverwriteFlag {
+			return fmt.Errorf("output %s already exists (use --overwrite to replace it)", tilesOutFlag)
+		}
+		if err := os.RemoveAll(tilesOutFlag); err != nil {
+			return fmt.Errorf("failed to remove existing output: %w", err)
+		}
+	}
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to check if table exists: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("table not found: %s", tableName)
+	}
+
+	sink, err := newTileSink(tilesOutFlag)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Generating tiles for '%s' (zoom %d-%d)...\n", tableName, tilesMinZoomFlag, tilesMaxZoomFlag)
+
+	stats, err := tiles.Generate(dbPath, tableName, tiles.Options{
+		MinZoom: tilesMinZoomFlag,
+		MaxZoom: tilesMaxZoomFlag,
+		Layer:   tilesLayerFlag,
+	}, sink)
+	if err != nil {
+		// Don't leave a partial tileset behind
+		os.RemoveAll(tilesOutFlag)
+		return err
+	}
+
+	fmt.Printf("✓ Wrote %d tiles (%d features, %s) to %s\n", stats.Tiles, stats.Features, formatBytes(stats.Bytes), tilesOutFlag)
+	return nil
+}
+
+// newTileSink picks the tile output from the --out path
+func newTileSink(out string) (tiles.Sink, error) {
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".mbtiles":
+		return tiles.NewMBTilesSink(out)
+	case ".pmtiles":
+		return tiles.NewPMTilesSink(out)
+	default:
+		return &tiles.DirSink{Dir: out}, nil
+	}
+}
@@ -2,54 +2,127 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/blang/semver"
 	"github.com/rhysd/go-github-selfupdate/selfupdate"
 	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/compat"
+	"org.xyzmaps.xyzduck/src/offline"
 	"org.xyzmaps.xyzduck/src/version"
 )
 
+// releaseRepo is the GitHub repository releases are published to
+const releaseRepo = "xyzmaps/xyzduck"
+
 var (
-	dryRun bool
-	yes    bool
+	dryRun            bool
+	yes               bool
+	updateChannelFlag string
+	updateVersionFlag string
+	updateCheckFlag   bool
 )
 
 var updateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update xyzduck to the latest version",
-	Long: `Check for the latest release on GitHub and update the binary in-place.
-The update is verified using checksums from the release.`,
+	Use:     "self-update",
+	Aliases: []string{"update"},
+	Short:   "Update xyzduck to the latest version",
+	Long: `Check for a release on GitHub and update the binary in-place.
+
+--channel picks the releases to follow: stable (the default) or beta, which
+includes pre-releases. --version installs a specific release instead, e.g. to
+pin a version or roll back.
+
+Every download is verified against the SHA-256 checksum published with the
+release before the binary is replaced.
+
+--check reports known incompatibilities of this binary, its bundled DuckDB
+library or the installed spatial extension, from the list maintained in the
+xyzduck repository, and exits with an error when one applies.`,
+	Example: `  xyzduck self-update
+  xyzduck self-update --channel beta --dry-run
+  xyzduck self-update --version v0.9.2 --yes
+  xyzduck self-update --check`,
+	Args: cobra.NoArgs,
 	RunE: runUpdate,
 }
 
 func init() {
 	updateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Check for updates without applying them")
 	updateCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	updateCmd.Flags().StringVar(&updateChannelFlag, "channel", "stable", "Release channel: stable or beta")
+	updateCmd.Flags().StringVar(&updateVersionFlag, "version", "", "Install this release instead of the latest, e.g. v1.2.0")
+	updateCmd.Flags().BoolVar(&updateCheckFlag, "check", false, "Report known incompatibilities of this installation")
 	rootCmd.AddCommand(updateCmd)
 }
 
 func runUpdate(cmd *cobra.Command, args []string) error {
+	if updateCheckFlag {
+		return runCompatCheck()
+	}
+	if updateChannelFlag != "stable" && updateChannelFlag != "beta" {
+		return fmt.Errorf("invalid channel %q (must be stable or beta)", updateChannelFlag)
+	}
+	if err := offline.Check("checking for updates"); err != nil {
+		return err
+	}
+
 	currentVersion := version.GetVersion()
 	fmt.Printf("Current version: %s\n", currentVersion)
 
-	// Configure selfupdate
-	latest, found, err := selfupdate.DetectLatest("xyzmaps/xyzduck")
+	// Only releases with a checksum file are found, and their download is
+	// verified against it
+	updater, err := selfupdate.NewUpdater(selfupdate.Config{Validator: &selfupdate.SHA2Validator{}})
 	if err != nil {
-		return fmt.Errorf("error checking for updates: %w", err)
+		return fmt.Errorf("error setting up the updater: %w", err)
 	}
 
+	target := updateVersionFlag
+	if target != "" && !strings.HasPrefix(target, "v") {
+		target = "v" + target
+	}
+	if target == "" && updateChannelFlag == "beta" {
+		// The updater skips pre-releases unless asked for one by tag
+		target, err = latestReleaseTag()
+		if err != nil {
+			return err
+		}
+	}
+
+	latest, found, err := updater.DetectVersion(releaseRepo, target)
+	if err != nil {
+		return fmt.Errorf("error checking for updates: %w", err)
+	}
 	if !found {
-		return fmt.Errorf("no releases found for xyzmaps/xyzduck")
+		if target != "" {
+			return fmt.Errorf("release %s not found for this platform", target)
+		}
+		return fmt.Errorf("no releases found for %s", releaseRepo)
 	}
 
-	fmt.Printf("Latest version:  %s\n", latest.Version)
+	if updateVersionFlag != "" {
+		fmt.Printf("Target version:  %s\n", latest.Version)
+	} else {
+		fmt.Printf("Latest version:  %s\n", latest.Version)
+	}
 
-	// Check if we're already on the latest version
-	if currentVersion == latest.Version.String() {
-		fmt.Println("\nAlready up to date!")
-		return nil
+	// Check if we're already on the requested version. Without --version
+	// only a newer release is offered, so a pre-release isn't "updated" to
+	// the older stable one; development builds have no version to compare.
+	if current, err := semver.ParseTolerant(currentVersion); err == nil {
+		if latest.Version.Equals(current) {
+			fmt.Println("\nAlready up to date!")
+			return nil
+		}
+		if updateVersionFlag == "" && !latest.Version.GT(current) {
+			fmt.Printf("\nAlready up to date (%s is newer than the latest %s release)\n", currentVersion, updateChannelFlag)
+			return nil
+		}
 	}
 
 	// Dry run - just show what would happen
@@ -82,7 +155,7 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not locate executable: %w", err)
 	}
 
-	if err := selfupdate.UpdateTo(latest.AssetURL, exe); err != nil {
+	if err := updater.UpdateTo(latest, exe); err != nil {
 		return fmt.Errorf("error updating binary: %w", err)
 	}
 
@@ -91,3 +164,79 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// latestReleaseTag returns the tag of the newest published release,
+// including pre-releases
+func latestReleaseTag() (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get("https://api.github.com/repos/" + releaseRepo + "/releases?per_page=20")
+	if err != nil {
+		return "", fmt.Errorf("error checking for updates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error checking for updates: %s", resp.Status)
+	}
+
+	var releases []struct {
+		TagName string `json:"tag_name"`
+		Draft   bool   `json:"draft"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return "", fmt.Errorf("error reading releases: %w", err)
+	}
+	// Releases are listed newest first
+	for _, r := range releases {
+		if !r.Draft {
+			return r.TagName, nil
+		}
+	}
+	return "", fmt.Errorf("no releases found for %s", releaseRepo)
+}
+
+// runCompatCheck prints the installed versions and the known issues that
+// affect them
+func runCompatCheck() error {
+	inst, err := compat.Detect()
+	if err != nil {
+		return err
+	}
+
+	spatial := inst.Spatial
+	if spatial == "" {
+		spatial = "not installed"
+	}
+	fmt.Printf("xyzduck:  %s\n", inst.Xyzduck)
+	fmt.Printf("DuckDB:   %s (%s)\n", inst.DuckDB, inst.Platform)
+	fmt.Printf("Spatial:  %s\n\n", spatial)
+
+	if err := offline.Check("fetching known issues"); err != nil {
+		return err
+	}
+	issues, err := compat.FetchIssues(compat.IssuesURL)
+	if err != nil {
+		return err
+	}
+
+	var affecting []compat.Issue
+	for _, issue := range issues {
+		if issue.Affects(inst) {
+			affecting = append(affecting, issue)
+		}
+	}
+	if len(affecting) == 0 {
+		fmt.Println("✓ No known incompatibilities")
+		return nil
+	}
+
+	for _, issue := range affecting {
+		fmt.Printf("! [%s] %s\n", issue.Component, issue.Summary)
+		if issue.Fix != "" {
+			fmt.Printf("  Fix: %s\n", issue.Fix)
+		}
+		if issue.URL != "" {
+			fmt.Printf("  See: %s\n", issue.URL)
+		}
+	}
+	return fmt.Errorf("%d known issues affect this installation", len(affecting))
+}
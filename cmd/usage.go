@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/usage"
+)
+
+var (
+	usageEnableFlag  bool
+	usageDisableFlag bool
+	usageDaysFlag    int
+	usageTopFlag     int
+)
+
+// currentRun collects what the running command did for the usage history
+var currentRun usage.Record
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Summarize your local command history",
+	Long: `Report which commands you run most, the biggest loads and the slowest
+queries, to help tune a workflow.
+
+Recording is off until you run 'xyzduck usage --enable'. The history is kept
+in usage.jsonl in the xyzduck config directory (XYZDUCK_CONFIG_DIR, or the
+user config directory) and is never sent anywhere. It includes the SQL passed
+to 'xyzduck query'. --disable stops recording and deletes the history.`,
+	Example: `  xyzduck usage --enable
+  xyzduck usage
+  xyzduck usage --days 7 --top 5`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().BoolVar(&usageEnableFlag, "enable", false, "Start recording command history")
+	usageCmd.Flags().BoolVar(&usageDisableFlag, "disable", false, "Stop recording and delete the history")
+	usageCmd.Flags().IntVar(&usageDaysFlag, "days", 0, "Only report the last N days (default: all history)")
+	usageCmd.Flags().IntVar(&usageTopFlag, "top", 10, "Number of entries to show per section")
+	usageCmd.MarkFlagsMutuallyExclusive("enable", "disable")
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	path, err := usage.HistoryPath()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case usageEnableFlag:
+		if err := usage.Enable(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Recording command history in %s\n", path)
+		return nil
+	case usageDisableFlag:
+		if err := usage.Disable(); err != nil {
+			return err
+		}
+		fmt.Println("✓ Stopped recording and deleted the command history")
+		return nil
+	}
+
+	if !usage.Enabled() {
+		fmt.Println("Command history is not being recorded. Run 'xyzduck usage --enable' to start.")
+		return nil
+	}
+
+	records, err := usage.Read()
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if usageDaysFlag > 0 {
+		since = time.Now().AddDate(0, 0, -usageDaysFlag)
+	}
+	summary := usage.Summarize(records, since, usageTopFlag)
+	if summary.Runs == 0 {
+		fmt.Println("No commands recorded yet")
+		return nil
+	}
+
+	fmt.Printf("%d runs since %s\n\n", summary.Runs, summary.First.Local().Format("2006-01-02"))
+
+	fmt.Println("Most used commands:")
+	fmt.Printf("  %-20s %6s %7s %12s %12s\n", "command", "runs", "failed", "total", "average")
+	for _, c := range summary.Commands {
+		avg := c.Total / time.Duration(c.Runs)
+		fmt.Printf("  %-20s %6d %7d %12s %12s\n", c.Command, c.Runs, c.Failures, formatDuration(c.Total), formatDuration(avg))
+	}
+
+	if len(summary.Loads) > 0 {
+		fmt.Println("\nBiggest loads:")
+		fmt.Printf("  %12s %-10s %10s %-16s  %s\n", "rows", "command", "time", "date", "table")
+		for _, r := range summary.Loads {
+			fmt.Printf("  %12d %-10s %10s %-16s  %s\n", r.Rows, r.Command, formatDuration(r.Duration), r.Time.Local().Format("2006-01-02 15:04"), r.Table)
+		}
+	}
+
+	if len(summary.Queries) > 0 {
+		fmt.Println("\nSlowest queries:")
+		fmt.Printf("  %10s %-16s  %s\n", "time", "date", "query")
+		for _, r := range summary.Queries {
+			fmt.Printf("  %10s %-16s  %s\n", formatDuration(r.Duration), r.Time.Local().Format("2006-01-02 15:04"), shortenSQL(r.Query, 80))
+		}
+	}
+	return nil
+}
+
+// recordUsage appends the finished command to the usage history, if
+// recording is enabled. The history is best effort and never fails a command.
+func recordUsage(cmd *cobra.Command, start time.Time, err error) {
+	if cmd == nil || cmd == rootCmd || cmd == usageCmd || !cmd.Runnable() {
+		return
+	}
+	currentRun.Time = start.UTC()
+	currentRun.Command = strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	currentRun.Duration = time.Since(start)
+	currentRun.Failed = err != nil
+	usage.Append(currentRun)
+}
+
+// recordChange notes rows a command changed for the usage history
+func recordChange(dbPath, tableName string, rows int64) {
+	currentRun.Database = dbPath
+	if currentRun.Table == "" {
+		currentRun.Table = tableName
+	} else if !strings.Contains(", "+currentRun.Table+", ", ", "+tableName+", ") {
+		currentRun.Table += ", " + tableName
+	}
+	currentRun.Rows += rows
+}
+
+// formatDuration rounds a duration for display
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
+
+// shortenSQL puts a statement on one line and cuts it to n characters
+func shortenSQL(sql string, n int) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if r := []rune(sql); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return sql
+}
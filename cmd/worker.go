@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"org.xyzmaps.xyzduck/src/aws"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/gdal"
+	"org.xyzmaps.xyzduck/src/geojson"
+	"org.xyzmaps.xyzduck/src/telemetry"
+	"org.xyzmaps.xyzduck/src/worker"
+)
+
+var (
+	workerConfigFlag string
+	workerQueueFlag  string
+	workerDBFlag     string
+	workerRegionFlag string
+	workerOnceFlag   bool
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Run long-lived ingestion workers",
+}
+
+var workerS3Cmd = &cobra.Command{
+	Use:   "s3",
+	Short: "Load new S3 objects announced on an SQS queue",
+	Long: `Consume S3 object-created notifications from an SQS queue and load each new
+object into the table its key prefix maps to.
+
+The config file lists the database and the prefix-to-table mappings; the
+first matching mapping wins and {name} in a table name is replaced by the
+object's file name without its extension:
+
+  db: geodata.duckdb
+  queue: https://sqs.eu-west-1.amazonaws.com/123456789012/ingest
+  mappings:
+    - prefix: incoming/roads/
+      pattern: "*.geojson"
+      table: roads
+    - bucket: partner-drops
+      prefix: parcels/
+      table: staging.{name}
+
+Notifications may come straight from S3, through SNS or from EventBridge.
+GeoJSON, newline-delimited GeoJSON, GeoPackage and CSV objects are loaded,
+appending to existing tables. A message is deleted once every object in it
+is loaded (or matches no mapping); when a load fails the message is left on
+the queue, so SQS retries it and eventually moves it to the dead-letter
+queue if one is configured.
+
+Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN. The database is only locked while an object is loaded, so
+other xyzduck commands can use it in between.`,
+	Example: `  xyzduck worker s3 --config ingest.yaml
+  xyzduck worker s3 --config ingest.yaml --queue https://sqs.eu-west-1.amazonaws.com/123456789012/ingest --once`,
+	Args: cobra.NoArgs,
+	RunE: runWorkerS3,
+}
+
+func init() {
+	workerS3Cmd.Flags().StringVar(&workerConfigFlag, "config", "", "Worker config file with the database and prefix mappings (required)")
+	workerS3Cmd.Flags().StringVar(&workerQueueFlag, "queue", "", "SQS queue URL (overrides the config)")
+	workerS3Cmd.Flags().StringVar(&workerDBFlag, "db", "", "Target database file (overrides the config)")
+	workerS3Cmd.Flags().StringVar(&workerRegionFlag, "region", "", "AWS region (default: from the queue URL)")
+	workerS3Cmd.Flags().BoolVar(&workerOnceFlag, "once", false, "Process the messages waiting on the queue and exit")
+	workerS3Cmd.MarkFlagRequired("config")
+
+	workerCmd.AddCommand(workerS3Cmd)
+	rootCmd.AddCommand(workerCmd)
+}
+
+func runWorkerS3(cmd *cobra.Command, args []string) error {
+	config, err := worker.LoadConfig(workerConfigFlag)
+	if err != nil {
+		return err
+	}
+	if workerQueueFlag != "" {
+		config.Queue = workerQueueFlag
+	}
+	if workerDBFlag != "" {
+		config.DB = workerDBFlag
+	}
+	if workerRegionFlag != "" {
+		config.Region = workerRegionFlag
+	}
+	if config.Queue == "" {
+		return fmt.Errorf("no queue given (use --queue or set queue in the config)")
+	}
+	if config.DB == "" {
+		return fmt.Errorf("no database given (use --db or set db in the config)")
+	}
+
+	dbPath := database.EnsureDuckDBExtension(config.DB)
+	if !database.FileExists(dbPath) {
+		return fmt.Errorf("database not found: %s", dbPath)
+	}
+
+	creds, err := aws.EnvCredentials()
+	if err != nil {
+		return err
+	}
+	queue, err := aws.NewQueue(config.Queue, config.Region, creds)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Waiting for objects on %s (Ctrl+C to stop)...\n", config.Queue)
+	for {
+		messages, err := queue.Receive(ctx, 10, 20*time.Second)
+		if ctx.Err() != nil {
+			fmt.Println("Stopped")
+			return nil
+		}
+		if err != nil {
+			// Keep polling through transient failures
+			fmt.Printf("! %v\n", err)
+			if workerOnceFlag {
+				return err
+			}
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if len(messages) == 0 && workerOnceFlag {
+			return nil
+		}
+
+		for _, m := range messages {
+			if err := handleMessage(dbPath, config, queue, m); err != nil {
+				fmt.Printf("! Message %s left on the queue: %v\n", m.MessageID, err)
+			}
+		}
+	}
+}
+
+// handleMessage loads the objects announced in a message and deletes it once
+// they are all loaded
+func handleMessage(dbPath string, config *worker.Config, queue *aws.Queue, m aws.Message) error {
+	objects, err := worker.CreatedObjects(m.Body)
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, obj := range objects {
+		table, ok := config.Route(obj.Bucket, obj.Key)
+		if !ok {
+			fmt.Printf("- Skipping s3://%s/%s (no mapping)\n", obj.Bucket, obj.Key)
+			continue
+		}
+		if obj.Region == "" {
+			// Buckets usually share the queue's region
+			obj.Region = queue.Region
+		}
+		if err := ingestObject(dbPath, config, queue.Creds, obj, table); err != nil {
+			failed = append(failed, fmt.Errorf("s3://%s/%s: %w", obj.Bucket, obj.Key, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Join(failed...)
+	}
+
+	if err := queue.Delete(m); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	return nil
+}
+
+// ingestObject downloads an object and loads it into table
+func ingestObject(dbPath string, config *worker.Config, creds aws.Credentials, obj worker.Object, table string) error {
+	bucket := &aws.Bucket{Name: obj.Bucket, Region: obj.Region, Endpoint: config.Endpoint, Creds: creds}
+
+	fmt.Printf("Loading s3://%s/%s into '%s'...\n", obj.Bucket, obj.Key, table)
+	end := startStep("download", telemetry.String("s3.uri", fmt.Sprintf("s3://%s/%s", obj.Bucket, obj.Key)))
+	path, err := bucket.Download(obj.Key)
+	end(err)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	end = startStep("load", telemetry.String("table", table))
+	rows, err := loadObject(dbPath, path, table)
+	end(err, telemetry.Int64("rows", rows))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Loaded %d features into table '%s'\n", rows, table)
+
+	notifyChange("load", dbPath, table, rows)
+	return nil
+}
+
+// loadObject loads a downloaded object with the loader for its format
+func loadObject(dbPath, path, table string) (int64, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case gdal.IsCSV(path):
+		result, err := gdal.LoadCSV(dbPath, path, table, gdal.CSVOptions{})
+		return result.RowCount, err
+	case ext == ".gpkg":
+		result, err := gdal.Load(dbPath, path, table, gdal.Options{})
+		return result.RowCount, err
+	case ext == ".geojson" || ext == ".json" || ext == ".geojsonl" || ext == ".geojsons" || ext == ".ndjson" || ext == ".jsonl":
+		result, err := geojson.LoadGeoJSON(dbPath, path, table, geojson.LoadOptions{DuplicatePolicy: geojson.DuplicateFirstWins})
+		if err == nil {
+			reportCreatedTable(table, result)
+		}
+		return int64(result.RowCount), err
+	default:
+		return 0, fmt.Errorf("unsupported object type %q", ext)
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// APIKeys accepts static keys, each with a name and roles
+type APIKeys struct {
+	keys []apiKey
+}
+
+// apiKey is a key by the SHA-256 digest it is compared with
+type apiKey struct {
+	name   string
+	digest [32]byte
+	roles  []string
+}
+
+// NewAPIKeys builds the API key provider from its config entries
+func NewAPIKeys(entries []APIKeyConfig) (*APIKeys, error) {
+	p := &APIKeys{}
+	for i, e := range entries {
+		name := e.Name
+		if name == "" {
+			name = fmt.Sprintf("key %d", i+1)
+		}
+
+		k := apiKey{name: name, roles: e.Roles}
+		switch {
+		case e.SHA256 != "":
+			digest, err := hex.DecodeString(e.SHA256)
+			if err != nil || len(digest) != 32 {
+				return nil, fmt.Errorf("api key %s: sha256 must be 64 hex digits", name)
+			}
+			copy(k.digest[:], digest)
+		case e.KeyEnv != "":
+			key := os.Getenv(e.KeyEnv)
+			if key == "" {
+				return nil, fmt.Errorf("api key %s: %s is not set", name, e.KeyEnv)
+			}
+			k.digest = sha256.Sum256([]byte(key))
+		case e.Key != "":
+			k.digest = sha256.Sum256([]byte(e.Key))
+		default:
+			return nil, fmt.Errorf("api key %s: one of key, key_env or sha256 is required", name)
+		}
+		p.keys = append(p.keys, k)
+	}
+	return p, nil
+}
+
+func (p *APIKeys) Name() string {
+	return "api key"
+}
+
+// Authenticate looks the credential up among the keys. Credentials matching
+// none, e.g. JWTs, are left to the other providers.
+func (p *APIKeys) Authenticate(ctx context.Context, credential string) (Identity, error) {
+	digest := sha256.Sum256([]byte(credential))
+	for _, k := range p.keys {
+		if subtle.ConstantTimeCompare(digest[:], k.digest[:]) == 1 {
+			return Identity{Subject: k.name, Roles: k.roles}, nil
+		}
+	}
+	return Identity{}, ErrUnrecognized
+}
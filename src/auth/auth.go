@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Identity is who a request was authenticated as
+type Identity struct {
+	// Subject is the API key's name or the token's sub claim
+	Subject string
+	// Provider names the provider that accepted the credential
+	Provider string
+	Roles    []string
+}
+
+// HasRole reports whether the identity holds one of roles
+func (id Identity) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(id.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider checks one kind of credential, e.g. API keys or tokens of an OIDC
+// issuer
+type Provider interface {
+	Name() string
+	// Authenticate checks a credential taken from a request. It returns
+	// ErrUnrecognized when the credential isn't of the provider's kind, so
+	// the next provider gets to try it.
+	Authenticate(ctx context.Context, credential string) (Identity, error)
+}
+
+// ErrUnrecognized is returned by providers for credentials they don't handle
+var ErrUnrecognized = errors.New("credential not recognized")
+
+// Error is a failed authentication, with the HTTP status to answer it with
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Authenticator authenticates requests with a chain of providers and lets in
+// the callers holding one of the required roles
+type Authenticator struct {
+	Providers []Provider
+	// Roles are the roles a caller needs one of; empty lets in every
+	// authenticated caller
+	Roles []string
+	// EditRoles are the roles a caller needs one of to edit; empty lets
+	// nobody edit, so read-only keys don't gain write access when editing
+	// is turned on
+	EditRoles []string
+}
+
+// CanEdit reports whether an authenticated caller may edit
+func (a *Authenticator) CanEdit(id Identity) bool {
+	return id.HasRole(a.EditRoles...)
+}
+
+// Check authenticates a request. The credential is read from an
+// "Authorization: Bearer" header, an X-API-Key header or, for clients that
+// can't set headers, an access_token query parameter. A failure is an *Error.
+func (a *Authenticator) Check(r *http.Request) (Identity, error) {
+	credential := Credential(r)
+	if credential == "" {
+		return Identity{}, &Error{Status: http.StatusUnauthorized, Message: "authentication required"}
+	}
+
+	for _, p := range a.Providers {
+		id, err := p.Authenticate(r.Context(), credential)
+		if errors.Is(err, ErrUnrecognized) {
+			continue
+		}
+		if err != nil {
+			return Identity{}, &Error{Status: http.StatusUnauthorized, Message: fmt.Sprintf("%s: %v", p.Name(), err)}
+		}
+		id.Provider = p.Name()
+		if len(a.Roles) > 0 && !id.HasRole(a.Roles...) {
+			return id, &Error{Status: http.StatusForbidden, Message: fmt.Sprintf("%s lacks a required role (%s)", id.Subject, strings.Join(a.Roles, ", "))}
+		}
+		return id, nil
+	}
+	return Identity{}, &Error{Status: http.StatusUnauthorized, Message: "invalid credential"}
+}
+
+// Credential returns the credential a request carries, or ""
+func Credential(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// Challenge sets the WWW-Authenticate header a 401 answer needs
+func Challenge(w http.ResponseWriter, err *Error) {
+	if err.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="xyzduck"`)
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config declares how serve mode authenticates requests
+type Config struct {
+	APIKeys []APIKeyConfig `yaml:"api_keys,omitempty"`
+	OIDC    *OIDCConfig    `yaml:"oidc,omitempty"`
+	// Roles are the roles a caller needs one of; empty lets in every
+	// authenticated caller
+	Roles []string `yaml:"roles,omitempty"`
+	// EditRoles are the roles a caller needs one of to edit features when
+	// editing is on; empty lets nobody edit
+	EditRoles []string `yaml:"edit_roles,omitempty"`
+}
+
+// APIKeyConfig is one static key. The key is given as is, in an environment
+// variable, or as the hex SHA-256 digest so the file holds no secret.
+type APIKeyConfig struct {
+	Name   string   `yaml:"name"`
+	Key    string   `yaml:"key,omitempty"`
+	KeyEnv string   `yaml:"key_env,omitempty"`
+	SHA256 string   `yaml:"sha256,omitempty"`
+	Roles  []string `yaml:"roles,omitempty"`
+}
+
+// OIDCConfig accepts JWTs issued by an OpenID Connect provider
+type OIDCConfig struct {
+	// Issuer is the iss tokens must carry; its discovery document names the
+	// signing keys unless JWKSURL is set
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	JWKSURL  string `yaml:"jwks_url,omitempty"`
+	// RolesClaim is the claim holding the caller's roles or groups, a dotted
+	// path for nested claims such as realm_access.roles (default roles)
+	RolesClaim string `yaml:"roles_claim,omitempty"`
+	// RoleMap maps values of the roles claim to roles; without it the values
+	// are the roles
+	RoleMap map[string][]string `yaml:"role_map,omitempty"`
+	// Claims are claims tokens must carry with exactly these values
+	Claims map[string]string `yaml:"claims,omitempty"`
+}
+
+// LoadConfig reads and checks an auth config file
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	if len(c.APIKeys) == 0 && c.OIDC == nil {
+		return nil, fmt.Errorf("auth config: no api_keys or oidc provider configured")
+	}
+	if c.OIDC != nil {
+		if c.OIDC.Issuer == "" {
+			return nil, fmt.Errorf("auth config: oidc: issuer is required")
+		}
+		if c.OIDC.Audience == "" {
+			return nil, fmt.Errorf("auth config: oidc: audience is required")
+		}
+	}
+	return &c, nil
+}
+
+// New builds the authenticator a config declares. OIDC signing keys are
+// fetched right away, so a wrong issuer fails at startup.
+func New(c *Config) (*Authenticator, error) {
+	a := &Authenticator{Roles: c.Roles, EditRoles: c.EditRoles}
+	if len(c.APIKeys) > 0 {
+		keys, err := NewAPIKeys(c.APIKeys)
+		if err != nil {
+			return nil, fmt.Errorf("auth config: %w", err)
+		}
+		a.Providers = append(a.Providers, keys)
+	}
+	if c.OIDC != nil {
+		oidc, err := NewOIDC(*c.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		a.Providers = append(a.Providers, oidc)
+	}
+	return a, nil
+}
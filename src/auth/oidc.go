@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+const (
+	// clockSkew is how far exp and nbf may be off from the local clock
+	clockSkew = time.Minute
+	// keysMaxAge refetches the signing keys after this long, to pick up
+	// rotated keys
+	keysMaxAge = time.Hour
+	// keysMinAge limits refetching for tokens signed with an unknown key
+	keysMinAge = time.Minute
+)
+
+// OIDC accepts JWTs signed by an OpenID Connect issuer's keys
+type OIDC struct {
+	config  OIDCConfig
+	client  *http.Client
+	jwksURL string
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewOIDC builds the OIDC provider, finding the issuer's signing keys through
+// its discovery document unless the config names them
+func NewOIDC(config OIDCConfig) (*OIDC, error) {
+	if err := offline.Check("validating tokens of " + config.Issuer); err != nil {
+		return nil, err
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+
+	p := &OIDC{config: config, client: &http.Client{Timeout: 10 * time.Second}, jwksURL: config.JWKSURL}
+	if p.jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := p.getJSON(strings.TrimSuffix(config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", config.Issuer, err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("OIDC issuer %s publishes no jwks_uri", config.Issuer)
+		}
+		p.jwksURL = discovery.JWKSURI
+	}
+
+	if err := p.refreshKeys(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *OIDC) Name() string {
+	return "oidc"
+}
+
+// Authenticate verifies a JWT's signature, issuer, audience, lifetime and
+// required claims, and maps its roles claim to roles
+func (p *OIDC) Authenticate(ctx context.Context, credential string) (Identity, error) {
+	parts := strings.Split(credential, ".")
+	if len(parts) != 3 {
+		return Identity{}, ErrUnrecognized
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("malformed token header")
+	}
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, fmt.Errorf("malformed token claims")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("malformed token signature")
+	}
+
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Identity{}, err
+	}
+	if err := p.checkClaims(claims, time.Now()); err != nil {
+		return Identity{}, err
+	}
+
+	id := Identity{Roles: p.roles(claims)}
+	id.Subject, _ = claims["sub"].(string)
+	return id, nil
+}
+
+// checkClaims checks the registered claims and the configured ones
+func (p *OIDC) checkClaims(claims map[string]any, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != p.config.Issuer {
+		return fmt.Errorf("token issued by %q, not %q", iss, p.config.Issuer)
+	}
+	if !hasAudience(claims["aud"], p.config.Audience) {
+		return fmt.Errorf("token is not meant for audience %q", p.config.Audience)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not valid yet")
+	}
+	for name, want := range p.config.Claims {
+		if got, ok := claims[name]; !ok || fmt.Sprint(got) != want {
+			return fmt.Errorf("token claim %s is not %q", name, want)
+		}
+	}
+	return nil
+}
+
+// hasAudience reports whether an aud claim, a string or a list, names audience
+func hasAudience(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// roles reads the roles claim, following dotted paths into nested claims,
+// and maps its values through the role map
+func (p *OIDC) roles(claims map[string]any) []string {
+	var value any = claims
+	for _, name := range strings.Split(p.config.RolesClaim, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = obj[name]
+	}
+
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	if p.config.RoleMap == nil {
+		return values
+	}
+
+	var roles []string
+	for _, v := range values {
+		roles = append(roles, p.config.RoleMap[v]...)
+	}
+	return roles
+}
+
+// key returns the signing key with the id, refetching the keys once they're
+// old or when a token names a key not seen yet, e.g. after a rotation
+func (p *OIDC) key(kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.lookup(kid)
+	age := time.Since(p.fetched)
+	p.mu.Unlock()
+	if ok && age < keysMaxAge {
+		return key, nil
+	}
+
+	if age >= keysMinAge {
+		if err := p.refreshKeys(); err != nil && !ok {
+			return nil, err
+		}
+		p.mu.Lock()
+		key, ok = p.lookup(kid)
+		p.mu.Unlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("token signed with unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup finds a key by id; a token without one matches the only key
+func (p *OIDC) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// refreshKeys fetches the issuer's JSON Web Key Set
+func (p *OIDC) refreshKeys() error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(p.jwksURL, &set); err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of other types are skipped rather than failing the set
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no usable signing keys at %s", p.jwksURL)
+	}
+
+	p.mu.Lock()
+	p.keys, p.fetched = keys, time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// getJSON fetches and decodes a JSON document
+func (p *OIDC) getJSON(url string, v any) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwk is an RSA or EC public key in a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, errN := decodeInt(k.N)
+		e, errE := decodeInt(k.E)
+		if errN != nil || errE != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("malformed RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := decodeInt(k.X)
+		y, errY := decodeInt(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("malformed EC key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature checks a JWS signature with the RS* or ES* algorithms
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	invalid := errors.New("invalid token signature")
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' || rsa.VerifyPKCS1v15(k, hash, digest, signature) != nil {
+			return invalid
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return invalid
+		}
+	default:
+		return invalid
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeInt decodes a base64url big-endian integer
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+// testKeys are signing keys generated once for all tests
+var testKeys = struct {
+	rsa, rsa2 *rsa.PrivateKey
+	ec        *ecdsa.PrivateKey
+}{
+	rsa:  mustRSAKey(),
+	rsa2: mustRSAKey(),
+	ec:   mustECKey(),
+}
+
+func mustRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// testProvider returns a provider holding the public keys by id, as if they
+// had just been fetched, so no test reaches the network
+func testProvider(keys map[string]crypto.PublicKey) *OIDC {
+	return &OIDC{
+		config:  OIDCConfig{Issuer: testIssuer, Audience: "xyzduck", RolesClaim: "roles"},
+		jwksURL: "http://127.0.0.1:0/jwks",
+		keys:    keys,
+		fetched: time.Now(),
+	}
+}
+
+// sign builds a JWT with the header and claims, signed with key by alg; a
+// nil key leaves the signature empty
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	if key == nil {
+		return signed + "."
+	}
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// validClaims returns claims that pass every check, for tests to spoil
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   testIssuer,
+		"aud":   "xyzduck",
+		"sub":   "alice",
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Minute).Unix(),
+		"roles": []string{"viewer"},
+	}
+}
+
+func TestAuthenticateSignature(t *testing.T) {
+	oneKey := map[string]crypto.PublicKey{"rsa": &testKeys.rsa.PublicKey}
+	allKeys := map[string]crypto.PublicKey{
+		"rsa":  &testKeys.rsa.PublicKey,
+		"rsa2": &testKeys.rsa2.PublicKey,
+		"ec":   &testKeys.ec.PublicKey,
+	}
+
+	tests := []struct {
+		name    string
+		keys    map[string]crypto.PublicKey
+		token   func(t *testing.T) string
+		wantErr string
+	}{
+		{
+			name:  "RS256",
+			keys:  allKeys,
+			token: func(t *testing.T) string { return sign(t, "RS256", "rsa", testKeys.rsa, validClaims()) },
+		},
+		{
+			name:  "ES256",
+			keys:  allKeys,
+			token: func(t *testing.T) string { return sign(t, "ES256", "ec", testKeys.ec, validClaims()) },
+		},
+		{
+			name:    "RS256 header with an EC key",
+			keys:    allKeys,
+			token:   func(t *testing.T) string { return sign(t, "RS256", "ec", testKeys.ec, validClaims()) },
+			wantErr: "invalid token signature",
+		},
+		{
+			name:    "ES256 header with an RSA key",
+			keys:    allKeys,
+			token:   func(t *testing.T) string { return sign(t, "ES256", "rsa", testKeys.rsa, validClaims()) },
+			wantErr: "invalid token signature",
+		},
+		{
+			name:    "alg none",
+			keys:    oneKey,
+			token:   func(t *testing.T) string { return sign(t, "none", "rsa", nil, validClaims()) },
+			wantErr: `unsupported token algorithm "none"`,
+		},
+		{
+			name:    "HS256 with the public key as secret",
+			keys:    oneKey,
+			token:   func(t *testing.T) string { return sign(t, "HS256", "rsa", nil, validClaims()) },
+			wantErr: `unsupported token algorithm "HS256"`,
+		},
+		{
+			name:    "signed by another key",
+			keys:    allKeys,
+			token:   func(t *testing.T) string { return sign(t, "RS256", "rsa", testKeys.rsa2, validClaims()) },
+			wantErr: "invalid token signature",
+		},
+		{
+			name:    "tampered claims",
+			keys:    allKeys,
+			wantErr: "invalid token signature",
+			token: func(t *testing.T) string {
+				parts := strings.Split(sign(t, "RS256", "rsa", testKeys.rsa, validClaims()), ".")
+				claims := validClaims()
+				claims["roles"] = []string{"admin"}
+				parts[1] = encodeSegment(t, claims)
+				return strings.Join(parts, ".")
+			},
+		},
+		{
+			name:    "wrong kid",
+			keys:    allKeys,
+			token:   func(t *testing.T) string { return sign(t, "RS256", "other", testKeys.rsa, validClaims()) },
+			wantErr: `unknown key "other"`,
+		},
+		{
+			name:  "no kid with a single key",
+			keys:  oneKey,
+			token: func(t *testing.T) string { return sign(t, "RS256", "", testKeys.rsa, validClaims()) },
+		},
+		{
+			name:    "no kid with several keys",
+			keys:    allKeys,
+			token:   func(t *testing.T) string { return sign(t, "RS256", "", testKeys.rsa, validClaims()) },
+			wantErr: `unknown key ""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProvider(tt.keys)
+			id, err := p.Authenticate(context.Background(), tt.token(t))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Authenticate() error = %v", err)
+				}
+				if id.Subject != "alice" || !slices.Equal(id.Roles, []string{"viewer"}) {
+					t.Errorf("Authenticate() = %+v, want alice with role viewer", id)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticateUnrecognized(t *testing.T) {
+	p := testProvider(map[string]crypto.PublicKey{"rsa": &testKeys.rsa.PublicKey})
+	for _, credential := range []string{"plain-api-key", "a.b", "a.b.c.d"} {
+		if _, err := p.Authenticate(context.Background(), credential); err != ErrUnrecognized {
+			t.Errorf("Authenticate(%q) error = %v, want ErrUnrecognized", credential, err)
+		}
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	unix := func(d time.Duration) float64 { return float64(now.Add(d).Unix()) }
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		config  func(c *OIDCConfig)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			claims: map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(time.Hour)},
+		},
+		{
+			name:   "expired within clock skew",
+			claims: map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(-clockSkew / 2)},
+		},
+		{
+			name:    "expired beyond clock skew",
+			claims:  map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(-2 * clockSkew)},
+			wantErr: "token expired",
+		},
+		{
+			name:    "no expiry",
+			claims:  map[string]any{"iss": testIssuer, "aud": "xyzduck"},
+			wantErr: "token has no expiry",
+		},
+		{
+			name:   "not valid yet within clock skew",
+			claims: map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(time.Hour), "nbf": unix(clockSkew / 2)},
+		},
+		{
+			name:    "not valid yet beyond clock skew",
+			claims:  map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(time.Hour), "nbf": unix(2 * clockSkew)},
+			wantErr: "token not valid yet",
+		},
+		{
+			name:   "aud list naming the audience",
+			claims: map[string]any{"iss": testIssuer, "aud": []any{"other", "xyzduck"}, "exp": unix(time.Hour)},
+		},
+		{
+			name:    "aud list without the audience",
+			claims:  map[string]any{"iss": testIssuer, "aud": []any{"other", "another"}, "exp": unix(time.Hour)},
+			wantErr: `not meant for audience "xyzduck"`,
+		},
+		{
+			name:    "wrong aud",
+			claims:  map[string]any{"iss": testIssuer, "aud": "other", "exp": unix(time.Hour)},
+			wantErr: `not meant for audience "xyzduck"`,
+		},
+		{
+			name:    "wrong iss",
+			claims:  map[string]any{"iss": "https://evil.example.com", "aud": "xyzduck", "exp": unix(time.Hour)},
+			wantErr: `token issued by "https://evil.example.com"`,
+		},
+		{
+			name:    "missing iss",
+			claims:  map[string]any{"aud": "xyzduck", "exp": unix(time.Hour)},
+			wantErr: `token issued by ""`,
+		},
+		{
+			name:   "required claim",
+			claims: map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(time.Hour), "tid": "acme"},
+			config: func(c *OIDCConfig) { c.Claims = map[string]string{"tid": "acme"} },
+		},
+		{
+			name:    "required claim differs",
+			claims:  map[string]any{"iss": testIssuer, "aud": "xyzduck", "exp": unix(time.Hour), "tid": "other"},
+			config:  func(c *OIDCConfig) { c.Claims = map[string]string{"tid": "acme"} },
+			wantErr: `token claim tid is not "acme"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProvider(nil)
+			if tt.config != nil {
+				tt.config(&p.config)
+			}
+			err := p.checkClaims(tt.claims, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkClaims() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkClaims() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoles(t *testing.T) {
+	nested := map[string]any{
+		"realm_access": map[string]any{"roles": []any{"gis-editor", "gis-viewer", 42}},
+		"scope":        "read write",
+	}
+
+	tests := []struct {
+		name    string
+		claim   string
+		roleMap map[string][]string
+		want    []string
+	}{
+		{
+			name:  "dotted claim",
+			claim: "realm_access.roles",
+			want:  []string{"gis-editor", "gis-viewer"},
+		},
+		{
+			name:    "dotted claim through the role map",
+			claim:   "realm_access.roles",
+			roleMap: map[string][]string{"gis-editor": {"editor", "viewer"}, "gis-viewer": {"viewer"}},
+			want:    []string{"editor", "viewer", "viewer"},
+		},
+		{
+			name:    "unmapped values are dropped",
+			claim:   "realm_access.roles",
+			roleMap: map[string][]string{"gis-viewer": {"viewer"}},
+			want:    []string{"viewer"},
+		},
+		{
+			name:  "space-separated string",
+			claim: "scope",
+			want:  []string{"read", "write"},
+		},
+		{
+			name:  "path through a non-object",
+			claim: "scope.roles",
+		},
+		{
+			name:  "missing claim",
+			claim: "groups",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProvider(nil)
+			p.config.RolesClaim = tt.claim
+			p.config.RoleMap = tt.roleMap
+			if got := p.roles(nested); !slices.Equal(got, tt.want) {
+				t.Errorf("roles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
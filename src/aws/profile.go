@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProfileCredentials reads a profile's keys from the shared credentials file
+// (~/.aws/credentials or AWS_SHARED_CREDENTIALS_FILE) and its region from the
+// config file (~/.aws/config or AWS_CONFIG_FILE)
+func ProfileCredentials(profile string) (Credentials, string, error) {
+	credsFile := sharedFile("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+	sections, err := readINI(credsFile)
+	if err != nil {
+		return Credentials{}, "", fmt.Errorf("failed to read AWS credentials: %w", err)
+	}
+	section, ok := sections[profile]
+	if !ok {
+		return Credentials{}, "", fmt.Errorf("AWS profile %q not found in %s", profile, credsFile)
+	}
+	creds := Credentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, "", fmt.Errorf("AWS profile %q has no aws_access_key_id and aws_secret_access_key", profile)
+	}
+
+	// The config file names profiles "profile <name>", except the default
+	region := section["region"]
+	if config, err := readINI(sharedFile("AWS_CONFIG_FILE", "config")); err == nil {
+		name := "profile " + profile
+		if profile == "default" {
+			name = profile
+		}
+		if r := config[name]["region"]; r != "" {
+			region = r
+		}
+	}
+	return creds, region, nil
+}
+
+// ResolveCredentials finds the credentials for S3 access: the named profile,
+// otherwise AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, otherwise the
+// AWS_PROFILE or default profile. The region is the profile's, if any. Empty
+// credentials, with no error, mean anonymous access.
+func ResolveCredentials(profile string) (Credentials, string, error) {
+	if profile != "" {
+		return ProfileCredentials(profile)
+	}
+	if creds, err := EnvCredentials(); err == nil {
+		return creds, "", nil
+	}
+	if profile = os.Getenv("AWS_PROFILE"); profile != "" {
+		return ProfileCredentials(profile)
+	}
+	if creds, region, err := ProfileCredentials("default"); err == nil {
+		return creds, region, nil
+	}
+	return Credentials{}, "", nil
+}
+
+// sharedFile returns the path of a shared AWS file, overridden by env
+func sharedFile(env, name string) string {
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".aws", name)
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// readINI parses an INI file into its sections' keys and values
+func readINI(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			current = make(map[string]string)
+			sections[name] = current
+		case current != nil:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				current[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return sections, scanner.Err()
+}
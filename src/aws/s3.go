@@ -0,0 +1,129 @@
+package aws
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+// emptyHash is the SHA-256 of an empty request body
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Bucket reads and writes objects in S3 or an S3-compatible store
+type Bucket struct {
+	Name   string
+	Region string
+	// Endpoint overrides the AWS endpoint, e.g. http://localhost:9000 for
+	// MinIO; objects are then addressed path-style
+	Endpoint string
+	// Creds are empty for anonymous access to public buckets
+	Creds  Credentials
+	Client *http.Client
+}
+
+// Download writes an object to a temporary file, keeping the key's extension
+// so the file's format can be recognized, and returns its path
+func (b *Bucket) Download(key string) (string, error) {
+	if err := offline.Check("downloading from S3"); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, b.objectURL(key), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if b.Creds.AccessKeyID != "" {
+		sign(req, emptyHash, "s3", b.Region, b.Creds, time.Now())
+	}
+
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download s3://%s/%s: %w", b.Name, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("failed to download s3://%s/%s: %s: %s", b.Name, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	tmp, err := os.CreateTemp("", "xyzduck-s3-*"+objectExt(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to download s3://%s/%s: %w", b.Name, key, err)
+	}
+	return tmp.Name(), nil
+}
+
+// Upload writes data to an object, replacing any existing one
+func (b *Bucket) Upload(key string, data []byte, contentType string) error {
+	if err := offline.Check("uploading to S3"); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, b.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if b.Creds.AccessKeyID != "" {
+		sign(req, hashHex(data), "s3", b.Region, b.Creds, time.Now())
+	}
+
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", b.Name, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to upload s3://%s/%s: %s: %s", b.Name, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// objectURL addresses an object virtual-hosted style on AWS, or path-style
+// for custom endpoints and bucket names with dots (which break TLS hostnames)
+func (b *Bucket) objectURL(key string) string {
+	escaped := (&url.URL{Path: "/" + key}).EscapedPath()
+	switch {
+	case b.Endpoint != "":
+		return strings.TrimSuffix(b.Endpoint, "/") + "/" + b.Name + escaped
+	case strings.Contains(b.Name, "."):
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s%s", b.Region, b.Name, escaped)
+	default:
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", b.Name, b.Region, escaped)
+	}
+}
+
+// objectExt returns the extension of a key, including compound ones like .osm.pbf
+func objectExt(key string) string {
+	base := path.Base(key)
+	ext := path.Ext(base)
+	if inner := path.Ext(strings.TrimSuffix(base, ext)); strings.EqualFold(inner, ".osm") {
+		return inner + ext
+	}
+	return ext
+}
@@ -0,0 +1,147 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+// Message is a message received from an SQS queue
+type Message struct {
+	MessageID     string `json:"MessageId"`
+	ReceiptHandle string `json:"ReceiptHandle"`
+	Body          string `json:"Body"`
+}
+
+// Queue is an SQS queue, addressed by its URL
+type Queue struct {
+	URL    string
+	Region string
+	Creds  Credentials
+	Client *http.Client
+}
+
+// NewQueue returns a client for the queue at queueURL. The region is taken
+// from the URL (sqs.<region>.amazonaws.com) unless region is given.
+func NewQueue(queueURL, region string, creds Credentials) (*Queue, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid queue URL %q", queueURL)
+	}
+	if region == "" {
+		region = regionFromHost(u.Hostname())
+	}
+	if region == "" {
+		return nil, fmt.Errorf("can't tell the region of %s (use --region)", queueURL)
+	}
+	return &Queue{
+		URL:    queueURL,
+		Region: region,
+		Creds:  creds,
+		// Long polls wait up to 20 seconds for messages
+		Client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// Receive long-polls the queue for up to max messages
+func (q *Queue) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	var out struct {
+		Messages []Message `json:"Messages"`
+	}
+	err := q.call(ctx, "ReceiveMessage", map[string]any{
+		"QueueUrl":            q.URL,
+		"MaxNumberOfMessages": max,
+		"WaitTimeSeconds":     int(wait.Seconds()),
+	}, &out)
+	return out.Messages, err
+}
+
+// Delete removes a processed message from the queue
+func (q *Queue) Delete(m Message) error {
+	return q.call(context.Background(), "DeleteMessage", map[string]any{
+		"QueueUrl":      q.URL,
+		"ReceiptHandle": m.ReceiptHandle,
+	}, nil)
+}
+
+// call invokes an action with the SQS JSON protocol
+func (q *Queue) call(ctx context.Context, action string, input map[string]any, output any) error {
+	if err := offline.Check("reading an SQS queue"); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", action, err)
+	}
+
+	u, err := url.Parse(q.URL)
+	if err != nil {
+		return fmt.Errorf("invalid queue URL %q", q.URL)
+	}
+	endpoint := u.Scheme + "://" + u.Host + "/"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	sign(req, hashHex(body), "sqs", q.Region, q.Creds, time.Now())
+
+	resp, err := q.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("SQS %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read SQS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SQS %s returned %s: %s", action, resp.Status, awsError(data))
+	}
+	if output != nil {
+		if err := json.Unmarshal(data, output); err != nil {
+			return fmt.Errorf("failed to parse SQS response: %w", err)
+		}
+	}
+	return nil
+}
+
+// regionFromHost extracts the region from an AWS endpoint host such as
+// sqs.eu-west-1.amazonaws.com or s3.eu-west-1.amazonaws.com
+func regionFromHost(host string) string {
+	parts := strings.Split(host, ".")
+	for i, p := range parts {
+		if (p == "sqs" || p == "s3") && i+1 < len(parts) && parts[i+1] != "amazonaws" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// awsError extracts the message of an AWS error response
+func awsError(data []byte) string {
+	var e struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &e) == nil && (e.Type != "" || e.Message != "") {
+		return strings.TrimSpace(e.Type + " " + e.Message)
+	}
+	msg := strings.TrimSpace(string(data))
+	if len(msg) > 300 {
+		msg = msg[:300]
+	}
+	return msg
+}
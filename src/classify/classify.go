@@ -0,0 +1,246 @@
+package classify
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// Method decides where class breaks are placed
+type Method string
+
+const (
+	// Quantile puts about the same number of values in every class
+	Quantile Method = "quantile"
+	// EqualInterval splits the range of values into classes of equal width
+	EqualInterval Method = "equal-interval"
+	// NaturalBreaks minimizes the variance within classes (Jenks)
+	NaturalBreaks Method = "natural-breaks"
+)
+
+// ParseMethod parses the --method flag
+func ParseMethod(s string) (Method, error) {
+	switch Method(s) {
+	case Quantile, EqualInterval, NaturalBreaks:
+		return Method(s), nil
+	}
+	return "", fmt.Errorf("invalid method %q (expected quantile, equal-interval or natural-breaks)", s)
+}
+
+// Numeric reports whether a column of a DuckDB type can be classified
+func Numeric(columnType string) bool {
+	switch columnType {
+	case "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT",
+		"UTINYINT", "USMALLINT", "UINTEGER", "UBIGINT", "UHUGEINT",
+		"FLOAT", "DOUBLE", "REAL":
+		return true
+	}
+	return strings.HasPrefix(columnType, "DECIMAL")
+}
+
+// maxJenksValues caps the values natural breaks are computed from, as the
+// computation grows with their square. Larger columns are thinned to evenly
+// spaced values of the sorted column.
+const maxJenksValues = 2000
+
+// Options controls a classification
+type Options struct {
+	Method  Method
+	Classes int
+	// Ramp names a colour ramp or lists hex colours, see Ramp
+	Ramp string
+}
+
+// Classification is the class breaks of a numeric column with a colour per
+// class
+type Classification struct {
+	Table  string
+	Column string
+	Method Method
+	// Bounds holds the minimum, the breaks between classes and the maximum.
+	// Class i runs from Bounds[i] up to, but not including, Bounds[i+1]; the
+	// last class includes the maximum, and may hold only it.
+	Bounds []float64
+	// Colors are the classes' colours as #rrggbb
+	Colors []string
+	// Counts are the number of values in each class
+	Counts    []int64
+	UpdatedAt time.Time
+}
+
+// Classes returns the number of classes
+func (c Classification) Classes() int {
+	return len(c.Bounds) - 1
+}
+
+// Class returns the class a value falls in. Values outside the bounds go to
+// the first or last class.
+func (c Classification) Class(v float64) int {
+	breaks := c.Bounds[1 : len(c.Bounds)-1]
+	return sort.Search(len(breaks), func(i int) bool { return breaks[i] > v })
+}
+
+// Classify reads the non-NULL values of a numeric column and computes its
+// class breaks
+func Classify(db *sql.DB, table, column string, opts Options) (Classification, error) {
+	c := Classification{Table: tableKey(table), Column: column, Method: opts.Method, UpdatedAt: time.Now().UTC()}
+	if opts.Classes < 2 {
+		return c, fmt.Errorf("classes must be at least 2")
+	}
+
+	query := fmt.Sprintf("SELECT CAST(%s AS DOUBLE) AS v FROM %s WHERE %s IS NOT NULL ORDER BY v",
+		database.QuoteIdentifier(column), database.QuoteTableName(table), database.QuoteIdentifier(column))
+	rows, err := db.Query(query)
+	if err != nil {
+		return c, fmt.Errorf("failed to read values of %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	var values []float64
+	for rows.Next() {
+		var v float64
+		if err := rows.Scan(&v); err != nil {
+			return c, fmt.Errorf("failed to scan value: %w", err)
+		}
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			values = append(values, v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return c, fmt.Errorf("error iterating values: %w", err)
+	}
+	if len(values) == 0 {
+		return c, fmt.Errorf("column %s has no values to classify", column)
+	}
+
+	c.Bounds = Breaks(values, opts.Classes, opts.Method)
+	c.Counts = make([]int64, c.Classes())
+	for _, v := range values {
+		c.Counts[c.Class(v)]++
+	}
+	if c.Colors, err = Ramp(opts.Ramp, c.Classes()); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// Breaks returns the bounds of classes of sorted values: the minimum, the
+// breaks between classes and the maximum. Breaks that coincide are merged,
+// so there may be fewer classes than asked for.
+func Breaks(sorted []float64, classes int, method Method) []float64 {
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+
+	var breaks []float64
+	switch method {
+	case EqualInterval:
+		for i := 1; i < classes; i++ {
+			breaks = append(breaks, lo+(hi-lo)*float64(i)/float64(classes))
+		}
+	case NaturalBreaks:
+		breaks = jenks(thin(sorted, maxJenksValues), classes)
+	default:
+		for i := 1; i < classes; i++ {
+			breaks = append(breaks, quantile(sorted, float64(i)/float64(classes)))
+		}
+	}
+
+	// A break at the maximum leaves a last class of only the maximum
+	bounds := []float64{lo}
+	for _, b := range breaks {
+		if b > bounds[len(bounds)-1] && b <= hi {
+			bounds = append(bounds, b)
+		}
+	}
+	return append(bounds, hi)
+}
+
+// quantile interpolates the q-th quantile of sorted values
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
+// thin keeps at most n evenly spaced values, including the first and last
+func thin(sorted []float64, n int) []float64 {
+	if len(sorted) <= n {
+		return sorted
+	}
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = sorted[i*(len(sorted)-1)/(n-1)]
+	}
+	return out
+}
+
+// jenks finds the breaks of the Fisher-Jenks natural breaks of sorted
+// values: the smallest value of every class but the first
+func jenks(sorted []float64, classes int) []float64 {
+	n := len(sorted)
+	classes = min(classes, n)
+
+	// first[i][j] is the 1-based index of the first value of class j in the
+	// best split of the first i values into j classes; cost[i][j] is that
+	// split's sum of squared deviations
+	first := make([][]int, n+1)
+	cost := make([][]float64, n+1)
+	for i := range first {
+		first[i] = make([]int, classes+1)
+		cost[i] = make([]float64, classes+1)
+		for j := 1; j <= classes && i > 1; j++ {
+			cost[i][j] = math.Inf(1)
+		}
+	}
+	for j := 1; j <= classes; j++ {
+		first[1][j] = 1
+	}
+
+	for i := 2; i <= n; i++ {
+		var sum, sumSq, variance float64
+		for m := 1; m <= i; m++ {
+			start := i - m + 1
+			v := sorted[start-1]
+			sum += v
+			sumSq += v * v
+			variance = sumSq - sum*sum/float64(m)
+			if start == 1 {
+				continue
+			}
+			for j := 2; j <= classes; j++ {
+				if c := variance + cost[start-1][j-1]; c <= cost[i][j] {
+					first[i][j] = start
+					cost[i][j] = c
+				}
+			}
+		}
+		first[i][1] = 1
+		cost[i][1] = variance
+	}
+
+	breaks := make([]float64, classes-1)
+	end := n
+	for j := classes; j >= 2; j-- {
+		start := first[end][j]
+		breaks[j-2] = sorted[start-1]
+		end = start - 1
+	}
+	return breaks
+}
+
+// tableKey names a table the same way however it was written: main-schema
+// tables without their schema
+func tableKey(table string) string {
+	schema, name := database.SplitTableName(table)
+	if strings.EqualFold(schema, "main") {
+		return name
+	}
+	return schema + "." + name
+}
@@ -0,0 +1,79 @@
+package classify
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/thumbnail"
+)
+
+// ramps are sequential colour schemes running from low to high values
+var ramps = map[string][]string{
+	"blues":   {"#f7fbff", "#6baed6", "#08306b"},
+	"greens":  {"#f7fcf5", "#74c476", "#00441b"},
+	"reds":    {"#fff5f0", "#fb6a4a", "#67000d"},
+	"oranges": {"#fff5eb", "#fd8d3c", "#7f2704"},
+	"purples": {"#fcfbfd", "#9e9ac8", "#3f007d"},
+	"viridis": {"#440154", "#3b528b", "#21918c", "#5ec962", "#fde725"},
+}
+
+// RampNames lists the named colour ramps
+func RampNames() []string {
+	var names []string
+	for name := range ramps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Ramp returns n colours evenly spaced along a ramp: a name from RampNames,
+// or two or more comma-separated hex colours to blend between
+func Ramp(ramp string, n int) ([]string, error) {
+	stops, ok := ramps[ramp]
+	if !ok {
+		stops = strings.Split(ramp, ",")
+		if len(stops) < 2 {
+			return nil, fmt.Errorf("unknown colour ramp %q (expected one of %s, or hex colours such as #ffffcc,#800026)", ramp, strings.Join(RampNames(), ", "))
+		}
+	}
+
+	anchors := make([]color.RGBA, len(stops))
+	for i, s := range stops {
+		c, err := thumbnail.ParseColor(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		anchors[i] = c
+	}
+
+	colors := make([]string, n)
+	for i := range colors {
+		// A single class takes the middle of the ramp
+		t := 0.5
+		if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+		colors[i] = hex(blend(anchors, t))
+	}
+	return colors, nil
+}
+
+// blend interpolates between the anchors, t running from 0 to 1
+func blend(anchors []color.RGBA, t float64) color.RGBA {
+	pos := t * float64(len(anchors)-1)
+	i := min(int(pos), len(anchors)-2)
+	f := pos - float64(i)
+	a, b := anchors[i], anchors[i+1]
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f))
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,75 @@
+package classify
+
+import (
+	"database/sql"
+	"fmt"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// ClassesTable holds the class breaks of classified columns
+const ClassesTable = "xyzduck_classes"
+
+// Store records a classification, replacing an earlier one of the same column
+func Store(db *sql.DB, c Classification) error {
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	table_name VARCHAR,
+	column_name VARCHAR,
+	method VARCHAR,
+	bounds DOUBLE[],
+	colors VARCHAR[],
+	counts BIGINT[],
+	updated_at TIMESTAMP,
+	PRIMARY KEY (table_name, column_name)
+)`, database.QuoteIdentifier(ClassesTable))
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create classes table: %w", err)
+	}
+
+	insertSQL := fmt.Sprintf("INSERT OR REPLACE INTO %s VALUES (?, ?, ?, ?, ?, ?, ?)", database.QuoteIdentifier(ClassesTable))
+	if _, err := db.Exec(insertSQL, c.Table, c.Column, string(c.Method), c.Bounds, c.Colors, c.Counts, c.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to store classes of %s: %w", c.Column, err)
+	}
+	return nil
+}
+
+// Load reads the stored classification of a column
+func Load(db *sql.DB, table, column string) (Classification, error) {
+	c := Classification{Table: tableKey(table), Column: column}
+
+	var exists bool
+	err := db.QueryRow("SELECT count(*) > 0 FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = 'main' AND table_name = ?", ClassesTable).Scan(&exists)
+	if err != nil {
+		return c, fmt.Errorf("failed to look up classes table: %w", err)
+	}
+	if !exists {
+		return c, fmt.Errorf("column %s of %s has not been classified (run xyzduck classify first)", column, table)
+	}
+
+	// Lists come back from the driver as []any
+	var method string
+	var bounds, colors, counts []any
+	query := fmt.Sprintf("SELECT method, bounds, colors, counts, updated_at FROM %s WHERE table_name = ? AND column_name = ?", database.QuoteIdentifier(ClassesTable))
+	err = db.QueryRow(query, c.Table, column).Scan(&method, &bounds, &colors, &counts, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return c, fmt.Errorf("column %s of %s has not been classified (run xyzduck classify first)", column, table)
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read classes of %s: %w", column, err)
+	}
+
+	c.Method = Method(method)
+	for _, v := range bounds {
+		c.Bounds = append(c.Bounds, v.(float64))
+	}
+	for _, v := range colors {
+		c.Colors = append(c.Colors, v.(string))
+	}
+	for _, v := range counts {
+		c.Counts = append(c.Counts, v.(int64))
+	}
+	if len(c.Bounds) < 2 || len(c.Colors) != c.Classes() {
+		return c, fmt.Errorf("stored classes of %s are incomplete", column)
+	}
+	return c, nil
+}
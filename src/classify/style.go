@@ -0,0 +1,45 @@
+package classify
+
+// NoDataColor is the colour of features without a value
+const NoDataColor = "#bdbdbd"
+
+// ColorExpression is a MapLibre expression that colours each feature by the
+// class of its value, and features without one in NoDataColor
+func (c Classification) ColorExpression() []any {
+	step := []any{"step", []any{"to-number", []any{"get", c.Column}}, c.Colors[0]}
+	for i := 1; i < c.Classes(); i++ {
+		step = append(step, c.Bounds[i], c.Colors[i])
+	}
+	return []any{"case", []any{"has", c.Column}, step, NoDataColor}
+}
+
+// Style returns a MapLibre style drawing a vector tileset as a choropleth.
+// tileJSON is the URL of the tileset's TileJSON and layer the name of the
+// layer in its tiles.
+func (c Classification) Style(tileJSON, layer string) map[string]any {
+	color := c.ColorExpression()
+	return map[string]any{
+		"version": 8,
+		"name":    c.Table + " by " + c.Column,
+		"sources": map[string]any{
+			"data": map[string]any{"type": "vector", "url": tileJSON},
+		},
+		"layers": []any{
+			map[string]any{
+				"id": "fill", "type": "fill", "source": "data", "source-layer": layer,
+				"filter": []any{"==", "$type", "Polygon"},
+				"paint":  map[string]any{"fill-color": color, "fill-opacity": 0.7},
+			},
+			map[string]any{
+				"id": "line", "type": "line", "source": "data", "source-layer": layer,
+				"filter": []any{"!=", "$type", "Point"},
+				"paint":  map[string]any{"line-color": color, "line-width": 1},
+			},
+			map[string]any{
+				"id": "point", "type": "circle", "source": "data", "source-layer": layer,
+				"filter": []any{"==", "$type", "Point"},
+				"paint":  map[string]any{"circle-color": color, "circle-radius": 4, "circle-stroke-color": "#fff", "circle-stroke-width": 1},
+			},
+		},
+	}
+}
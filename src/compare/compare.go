@@ -0,0 +1,332 @@
+package compare
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// otherAlias is the name the database holding the second table is attached as
+const otherAlias = "compare_other"
+
+// Options controls a comparison
+type Options struct {
+	// OtherDB, when set, is the database holding the second table
+	OtherDB string
+	// MatchDistance is the farthest apart matching features may be
+	MatchDistance database.Distance
+}
+
+// Scorecard compares two versions, or two providers, of a dataset
+type Scorecard struct {
+	A TableStats `json:"a"`
+	B TableStats `json:"b"`
+	// FeatureDifference is B's features less A's
+	FeatureDifference int64            `json:"feature_difference"`
+	Attributes        []AttributeStats `json:"attributes"`
+	Matching          MatchingStats    `json:"matching"`
+	GeneratedAt       time.Time        `json:"generated_at"`
+}
+
+// TableStats describes the features of one table
+type TableStats struct {
+	Database          string `json:"database"`
+	Table             string `json:"table"`
+	GeometryColumn    string `json:"geometry_column"`
+	Features          int64  `json:"features"`
+	NullGeometries    int64  `json:"null_geometries"`
+	EmptyGeometries   int64  `json:"empty_geometries"`
+	InvalidGeometries int64  `json:"invalid_geometries"`
+	// ValidRate is the share of non-NULL geometries that are valid
+	ValidRate     float64          `json:"valid_rate"`
+	GeometryTypes map[string]int64 `json:"geometry_types"`
+}
+
+// AttributeStats is the completeness of a column in either table: the share
+// of features with a value, blank text counting as none. A column missing
+// from a table has no completeness there.
+type AttributeStats struct {
+	Column        string   `json:"column"`
+	CompletenessA *float64 `json:"completeness_a"`
+	CompletenessB *float64 `json:"completeness_b"`
+}
+
+// MatchingStats pairs each feature of A with the nearest feature of B within
+// the match distance, measured between centroids
+type MatchingStats struct {
+	Distance string `json:"distance"`
+	// Unit is the unit of the offsets: meters, or CRS units
+	Unit string `json:"unit"`
+	// MatchedA is the features of A with a match in B
+	MatchedA   int64   `json:"matched_a"`
+	MatchRateA float64 `json:"match_rate_a"`
+	// MatchedB is the features of B that are the match of a feature of A
+	MatchedB   int64   `json:"matched_b"`
+	MatchRateB float64 `json:"match_rate_b"`
+	// The offsets between matched features, in the match distance's unit
+	MeanOffset   float64 `json:"mean_offset"`
+	MedianOffset float64 `json:"median_offset"`
+	P95Offset    float64 `json:"p95_offset"`
+	MaxOffset    float64 `json:"max_offset"`
+}
+
+// table is a table being compared
+type table struct {
+	stats   *TableStats
+	from    string
+	geom    string
+	columns []database.Column
+}
+
+// Compare builds the scorecard of tableA in dbPath against tableB, which is
+// in opts.OtherDB when set and dbPath otherwise
+func Compare(dbPath, tableA, tableB string, opts Options) (Scorecard, error) {
+	card := Scorecard{
+		A:           TableStats{Database: dbPath, Table: tableA},
+		B:           TableStats{Database: dbPath, Table: tableB},
+		GeneratedAt: time.Now().UTC(),
+	}
+	otherPath := dbPath
+	if opts.OtherDB != "" {
+		otherPath = opts.OtherDB
+		card.B.Database = opts.OtherDB
+	}
+
+	a, err := newTable(dbPath, tableA, &card.A, database.QuoteTableName(tableA))
+	if err != nil {
+		return card, err
+	}
+	fromB := database.QuoteTableName(tableB)
+	if opts.OtherDB != "" {
+		fromB = database.QuoteIdentifier(otherAlias) + "." + fromB
+	}
+	b, err := newTable(otherPath, tableB, &card.B, fromB)
+	if err != nil {
+		return card, err
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return card, err
+	}
+	defer db.Close()
+	if err := database.LoadSpatial(db); err != nil {
+		return card, err
+	}
+	if opts.OtherDB != "" {
+		attachSQL := fmt.Sprintf("ATTACH %s AS %s (READ_ONLY)", database.QuoteLiteral(opts.OtherDB), database.QuoteIdentifier(otherAlias))
+		if _, err := db.Exec(attachSQL); err != nil {
+			return card, fmt.Errorf("failed to attach %s: %w", opts.OtherDB, err)
+		}
+	}
+
+	for _, t := range []*table{a, b} {
+		if err := t.readStats(db); err != nil {
+			return card, err
+		}
+	}
+	card.FeatureDifference = card.B.Features - card.A.Features
+
+	if card.Attributes, err = attributes(db, a, b); err != nil {
+		return card, err
+	}
+	if card.Matching, err = match(db, a, b, opts.MatchDistance); err != nil {
+		return card, err
+	}
+	return card, nil
+}
+
+// newTable reads the columns of a table and finds its geometry column
+func newTable(dbPath, name string, stats *TableStats, from string) (*table, error) {
+	columns, err := database.GetTableSchema(dbPath, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found in %s", name, dbPath)
+	}
+
+	t := &table{stats: stats, from: from}
+	for _, col := range columns {
+		if strings.HasPrefix(strings.ToUpper(col.Type), "GEOMETRY") {
+			if t.geom == "" {
+				t.geom = col.Name
+			}
+			continue
+		}
+		t.columns = append(t.columns, col)
+	}
+	if t.geom == "" {
+		return nil, fmt.Errorf("table %s has no GEOMETRY column", name)
+	}
+	stats.GeometryColumn = t.geom
+	return t, nil
+}
+
+// readStats counts the table's features and checks their geometries
+func (t *table) readStats(db *sql.DB) error {
+	g := database.QuoteIdentifier(t.geom)
+	countSQL := fmt.Sprintf(`SELECT count(*),
+		count(*) FILTER (WHERE %[1]s IS NULL),
+		count(*) FILTER (WHERE ST_IsEmpty(%[1]s)),
+		count(*) FILTER (WHERE NOT ST_IsValid(%[1]s))
+		FROM %[2]s`, g, t.from)
+	s := t.stats
+	if err := db.QueryRow(countSQL).Scan(&s.Features, &s.NullGeometries, &s.EmptyGeometries, &s.InvalidGeometries); err != nil {
+		return fmt.Errorf("failed to check geometries of %s: %w", s.Table, err)
+	}
+	if present := s.Features - s.NullGeometries; present > 0 {
+		s.ValidRate = float64(present-s.InvalidGeometries) / float64(present)
+	}
+
+	typesSQL := fmt.Sprintf("SELECT ST_GeometryType(%s)::VARCHAR, count(*) FROM %s WHERE %s IS NOT NULL GROUP BY 1", g, t.from, g)
+	rows, err := db.Query(typesSQL)
+	if err != nil {
+		return fmt.Errorf("failed to count geometry types of %s: %w", s.Table, err)
+	}
+	defer rows.Close()
+
+	s.GeometryTypes = map[string]int64{}
+	for rows.Next() {
+		var geomType string
+		var count int64
+		if err := rows.Scan(&geomType, &count); err != nil {
+			return fmt.Errorf("failed to scan geometry type: %w", err)
+		}
+		s.GeometryTypes[geomType] = count
+	}
+	return rows.Err()
+}
+
+// completeness returns the share of the table's features with a value in
+// each of its columns, by lower-cased column name
+func (t *table) completeness(db *sql.DB) (map[string]float64, error) {
+	result := map[string]float64{}
+	if t.stats.Features == 0 {
+		for _, col := range t.columns {
+			result[strings.ToLower(col.Name)] = 0
+		}
+		return result, nil
+	}
+	if len(t.columns) == 0 {
+		return result, nil
+	}
+
+	counts := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		q := database.QuoteIdentifier(col.Name)
+		if col.Type == "VARCHAR" {
+			q = fmt.Sprintf("NULLIF(trim(%s), '')", q)
+		}
+		counts[i] = fmt.Sprintf("count(%s)", q)
+	}
+	values := make([]int64, len(t.columns))
+	ptrs := make([]any, len(values))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(counts, ", "), t.from)
+	if err := db.QueryRow(query).Scan(ptrs...); err != nil {
+		return nil, fmt.Errorf("failed to count values of %s: %w", t.stats.Table, err)
+	}
+
+	for i, col := range t.columns {
+		result[strings.ToLower(col.Name)] = float64(values[i]) / float64(t.stats.Features)
+	}
+	return result, nil
+}
+
+// attributes compares the completeness of the columns of both tables,
+// ordered by name
+func attributes(db *sql.DB, a, b *table) ([]AttributeStats, error) {
+	inA, err := a.completeness(db)
+	if err != nil {
+		return nil, err
+	}
+	inB, err := b.completeness(db)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, col := range append(append([]database.Column{}, a.columns...), b.columns...) {
+		if _, ok := names[strings.ToLower(col.Name)]; !ok {
+			names[strings.ToLower(col.Name)] = col.Name
+		}
+	}
+
+	var result []AttributeStats
+	for key, name := range names {
+		attr := AttributeStats{Column: name}
+		if v, ok := inA[key]; ok {
+			attr.CompletenessA = &v
+		}
+		if v, ok := inB[key]; ok {
+			attr.CompletenessB = &v
+		}
+		result = append(result, attr)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Column < result[j].Column })
+	return result, nil
+}
+
+// match pairs each feature of a with the nearest feature of b whose centroid
+// is within the distance, and measures how far apart they are
+func match(db *sql.DB, a, b *table, distance database.Distance) (MatchingStats, error) {
+	stats := MatchingStats{Distance: distance.String(), Unit: "CRS units"}
+	if distance.Meters {
+		stats.Unit = "meters"
+	}
+
+	// With meters the data is lon/lat: candidates are found with the distance
+	// in degrees at the feature's latitude, then measured on the sphere
+	tolerance := fmt.Sprintf("%g", distance.Value)
+	search := tolerance
+	measure := "ST_Distance(a.c, b.c)"
+	if distance.Meters {
+		search = fmt.Sprintf("(%g / 111320.0 / greatest(cos(radians(ST_Y(a.c))), 0.01))", distance.Value)
+		measure = "ST_Distance_Sphere(a.c, b.c)"
+	}
+
+	centroids := "SELECT rowid AS rid, ST_Centroid(%[1]s) AS c FROM %[2]s WHERE %[1]s IS NOT NULL AND NOT ST_IsEmpty(%[1]s)"
+	query := fmt.Sprintf(`
+		WITH a AS (%s),
+		b AS (%s),
+		pairs AS (
+			SELECT a.rid AS a_rid, b.rid AS b_rid, %s AS d
+			FROM a JOIN b ON ST_DWithin(a.c, b.c, %s)
+		),
+		nearest AS (
+			SELECT a_rid, arg_min(b_rid, d) AS b_rid, min(d) AS d
+			FROM pairs
+			WHERE d <= %s
+			GROUP BY a_rid
+		)
+		SELECT count(*), count(DISTINCT b_rid), avg(d), median(d), quantile_cont(d, 0.95), max(d)
+		FROM nearest`,
+		fmt.Sprintf(centroids, database.QuoteIdentifier(a.geom), a.from),
+		fmt.Sprintf(centroids, database.QuoteIdentifier(b.geom), b.from),
+		measure, search, tolerance)
+
+	var mean, median, p95, maxOffset sql.NullFloat64
+	err := db.QueryRow(query).Scan(&stats.MatchedA, &stats.MatchedB, &mean, &median, &p95, &maxOffset)
+	if err != nil {
+		return stats, fmt.Errorf("failed to match features: %w", err)
+	}
+	stats.MeanOffset, stats.MedianOffset, stats.P95Offset, stats.MaxOffset = mean.Float64, median.Float64, p95.Float64, maxOffset.Float64
+	stats.MatchRateA = rate(stats.MatchedA, a.stats.Features)
+	stats.MatchRateB = rate(stats.MatchedB, b.stats.Features)
+	return stats, nil
+}
+
+// rate is part as a share of whole, 0 for an empty whole
+func rate(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
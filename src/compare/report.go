@@ -0,0 +1,187 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format is how a scorecard is written
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatHTML Format = "html"
+)
+
+// ParseFormat parses the --format flag
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatText, FormatJSON, FormatHTML:
+		return Format(strings.ToLower(name)), nil
+	}
+	return "", fmt.Errorf("unknown output format %q (use text, json or html)", name)
+}
+
+// FormatForPath picks the format from a file extension, falling back to text
+func FormatForPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".html", ".htm":
+		return FormatHTML
+	default:
+		return FormatText
+	}
+}
+
+// Write writes the scorecard in a format
+func Write(w io.Writer, card Scorecard, format Format) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(card)
+	case FormatHTML:
+		return reportPage.Execute(w, card)
+	default:
+		return writeText(w, card)
+	}
+}
+
+// writeText writes the scorecard as aligned plain text
+func writeText(w io.Writer, card Scorecard) error {
+	a, b := card.A, card.B
+	var sb strings.Builder
+	row := func(label, va, vb string) {
+		fmt.Fprintf(&sb, "  %-22s %18s %18s\n", label, va, vb)
+	}
+
+	row("", truncateName(a.Table), truncateName(b.Table))
+	row("Features", fmt.Sprint(a.Features), fmt.Sprint(b.Features))
+	row("NULL geometries", fmt.Sprint(a.NullGeometries), fmt.Sprint(b.NullGeometries))
+	row("Empty geometries", fmt.Sprint(a.EmptyGeometries), fmt.Sprint(b.EmptyGeometries))
+	row("Invalid geometries", fmt.Sprint(a.InvalidGeometries), fmt.Sprint(b.InvalidGeometries))
+	row("Valid rate", percent(a.ValidRate), percent(b.ValidRate))
+	for _, geomType := range geometryTypes(card) {
+		row(geomType, fmt.Sprint(a.GeometryTypes[geomType]), fmt.Sprint(b.GeometryTypes[geomType]))
+	}
+	fmt.Fprintf(&sb, "  Difference: %+d features\n", card.FeatureDifference)
+
+	if len(card.Attributes) > 0 {
+		sb.WriteString("\nAttribute completeness\n")
+		for _, attr := range card.Attributes {
+			row(truncateName(attr.Column), completeness(attr.CompletenessA), completeness(attr.CompletenessB))
+		}
+	}
+
+	m := card.Matching
+	fmt.Fprintf(&sb, "\nPositional offset (nearest match within %s)\n", m.Distance)
+	row("Matched", fmt.Sprintf("%d (%s)", m.MatchedA, percent(m.MatchRateA)), fmt.Sprintf("%d (%s)", m.MatchedB, percent(m.MatchRateB)))
+	if m.MatchedA > 0 {
+		fmt.Fprintf(&sb, "  Offset in %s: mean %.4g, median %.4g, 95th percentile %.4g, max %.4g\n", m.Unit, m.MeanOffset, m.MedianOffset, m.P95Offset, m.MaxOffset)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// geometryTypes lists the geometry types found in either table
+func geometryTypes(card Scorecard) []string {
+	seen := map[string]bool{}
+	var types []string
+	for _, counts := range []map[string]int64{card.A.GeometryTypes, card.B.GeometryTypes} {
+		for geomType := range counts {
+			if !seen[geomType] {
+				seen[geomType] = true
+				types = append(types, geomType)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// percent formats a share as a percentage
+func percent(v float64) string {
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+// completeness formats a column's completeness, "-" when it's missing
+func completeness(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return percent(*v)
+}
+
+// truncateName shortens a name to fit a text column
+func truncateName(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 18 {
+		return s
+	}
+	return string(runes[:17]) + "…"
+}
+
+// reportPage is the scorecard as a standalone HTML page
+var reportPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent":       percent,
+	"completeness":  completeness,
+	"geometryTypes": geometryTypes,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.A.Table}} vs {{.B.Table}} · xyzduck</title>
+<style>
+  body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 56em; color: #1e293b; }
+  table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+  th, td { padding: 0.35em 0.75em; border-bottom: 1px solid #e2e8f0; text-align: right; }
+  th:first-child, td:first-child { text-align: left; }
+  .muted { color: #64748b; }
+</style>
+</head>
+<body>
+<h1>{{.A.Table}} vs {{.B.Table}}</h1>
+<p class="muted">{{.A.Database}} · {{.B.Database}} · {{.GeneratedAt.Format "2006-01-02 15:04 UTC"}}</p>
+
+<h2>Features and geometries</h2>
+<table>
+<tr><th></th><th>{{.A.Table}}</th><th>{{.B.Table}}</th></tr>
+<tr><td>Features</td><td>{{.A.Features}}</td><td>{{.B.Features}} <span class="muted">({{printf "%+d" .FeatureDifference}})</span></td></tr>
+<tr><td>NULL geometries</td><td>{{.A.NullGeometries}}</td><td>{{.B.NullGeometries}}</td></tr>
+<tr><td>Empty geometries</td><td>{{.A.EmptyGeometries}}</td><td>{{.B.EmptyGeometries}}</td></tr>
+<tr><td>Invalid geometries</td><td>{{.A.InvalidGeometries}}</td><td>{{.B.InvalidGeometries}}</td></tr>
+<tr><td>Valid rate</td><td>{{percent .A.ValidRate}}</td><td>{{percent .B.ValidRate}}</td></tr>
+{{range geometryTypes .}}<tr><td>{{.}}</td><td>{{index $.A.GeometryTypes .}}</td><td>{{index $.B.GeometryTypes .}}</td></tr>
+{{end}}</table>
+
+{{with .Attributes}}<h2>Attribute completeness</h2>
+<table>
+<tr><th>Column</th><th>{{$.A.Table}}</th><th>{{$.B.Table}}</th></tr>
+{{range .}}<tr><td>{{.Column}}</td><td>{{completeness .CompletenessA}}</td><td>{{completeness .CompletenessB}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Matching}}<h2>Positional offset</h2>
+<p class="muted">Each feature of {{$.A.Table}} is matched to the nearest feature of {{$.B.Table}} whose centroid is within {{.Distance}}.</p>
+<table>
+<tr><th></th><th>{{$.A.Table}}</th><th>{{$.B.Table}}</th></tr>
+<tr><td>Matched</td><td>{{.MatchedA}} ({{percent .MatchRateA}})</td><td>{{.MatchedB}} ({{percent .MatchRateB}})</td></tr>
+</table>
+{{if .MatchedA}}<table>
+<tr><th>Offset</th><th>{{.Unit}}</th></tr>
+<tr><td>Mean</td><td>{{printf "%.4g" .MeanOffset}}</td></tr>
+<tr><td>Median</td><td>{{printf "%.4g" .MedianOffset}}</td></tr>
+<tr><td>95th percentile</td><td>{{printf "%.4g" .P95Offset}}</td></tr>
+<tr><td>Max</td><td>{{printf "%.4g" .MaxOffset}}</td></tr>
+</table>{{end}}
+{{end}}
+</body>
+</html>
+`))
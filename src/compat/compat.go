@@ -0,0 +1,113 @@
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/version"
+)
+
+// IssuesURL is the list of known incompatibilities, kept in the repository
+// so installed binaries learn about problems found after their release
+const IssuesURL = "https://raw.githubusercontent.com/xyzmaps/xyzduck/main/known-issues.json"
+
+// Installation describes the running binary and what it bundles
+type Installation struct {
+	Xyzduck  string
+	DuckDB   string
+	Platform string
+	// Spatial is the installed spatial extension's version, empty when it
+	// isn't installed
+	Spatial string
+}
+
+// Issue is a known problem with some versions of a component
+type Issue struct {
+	// Component is xyzduck, duckdb or spatial
+	Component string `json:"component"`
+	// Versions lists the affected versions
+	Versions []string `json:"versions"`
+	// Platforms restricts the issue to DuckDB platforms, e.g. osx_arm64
+	Platforms []string `json:"platforms,omitempty"`
+	Summary   string   `json:"summary"`
+	// Fix says how to get around the issue, e.g. the version fixing it
+	Fix string `json:"fix,omitempty"`
+	URL string `json:"url,omitempty"`
+}
+
+// Detect reports the versions of the running binary, its DuckDB library and
+// the installed spatial extension
+func Detect() (Installation, error) {
+	inst := Installation{Xyzduck: version.GetVersion()}
+
+	db, err := database.OpenInMemory()
+	if err != nil {
+		return inst, err
+	}
+	defer db.Close()
+
+	if err := db.QueryRow("SELECT library_version FROM pragma_version()").Scan(&inst.DuckDB); err != nil {
+		return inst, fmt.Errorf("failed to read DuckDB version: %w", err)
+	}
+	if err := db.QueryRow("SELECT platform FROM pragma_platform()").Scan(&inst.Platform); err != nil {
+		return inst, fmt.Errorf("failed to read DuckDB platform: %w", err)
+	}
+
+	var spatial *string
+	err = db.QueryRow("SELECT extension_version FROM duckdb_extensions() WHERE extension_name = 'spatial' AND installed").Scan(&spatial)
+	if err == nil && spatial != nil {
+		inst.Spatial = *spatial
+	}
+	return inst, nil
+}
+
+// FetchIssues downloads the list of known issues
+func FetchIssues(url string) ([]Issue, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch known issues: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch known issues: %s", resp.Status)
+	}
+
+	var list struct {
+		Issues []Issue `json:"issues"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse known issues: %w", err)
+	}
+	return list.Issues, nil
+}
+
+// Affects reports whether an issue applies to an installation
+func (i Issue) Affects(inst Installation) bool {
+	var installed string
+	switch strings.ToLower(i.Component) {
+	case "xyzduck":
+		installed = inst.Xyzduck
+	case "duckdb":
+		installed = inst.DuckDB
+	case "spatial":
+		installed = inst.Spatial
+	}
+	if installed == "" {
+		return false
+	}
+	if len(i.Platforms) > 0 && !slices.Contains(i.Platforms, inst.Platform) {
+		return false
+	}
+	for _, v := range i.Versions {
+		if strings.TrimPrefix(v, "v") == strings.TrimPrefix(installed, "v") {
+			return true
+		}
+	}
+	return false
+}
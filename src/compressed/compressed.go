@@ -0,0 +1,273 @@
+package compressed
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// zipPrefix starts the path of a file inside a zip archive, as GDAL names
+// them: /vsizip/<archive>/<member>
+const zipPrefix = "/vsizip/"
+
+// Ext returns the compression extension of a path, .gz, .zst or .zip, or ""
+func Ext(p string) string {
+	switch ext := strings.ToLower(filepath.Ext(p)); ext {
+	case ".gz", ".zst", ".zip":
+		return ext
+	}
+	return ""
+}
+
+// Trim removes a .gz or .zst extension, so roads.geojson.gz becomes
+// roads.geojson and the format can be told from what's left
+func Trim(p string) string {
+	switch Ext(p) {
+	case ".gz", ".zst":
+		return strings.TrimSuffix(p, filepath.Ext(p))
+	}
+	return p
+}
+
+// IsZipMember reports whether a path names a file inside a zip archive
+func IsZipMember(p string) bool {
+	return strings.HasPrefix(p, zipPrefix)
+}
+
+// Open opens a file for reading, decompressing .gz and .zst files and the
+// members of zip archives (see ZipMember) as they are read, without
+// extracting anything to disk. wrap, when not nil, is given the compressed
+// stream and its size, e.g. to count progress.
+func Open(p string, wrap func(r io.Reader, size int64) io.Reader) (io.ReadCloser, error) {
+	if wrap == nil {
+		wrap = func(r io.Reader, size int64) io.Reader { return r }
+	}
+	if IsZipMember(p) {
+		return openZipMember(p, wrap)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r := wrap(f, info.Size())
+
+	switch Ext(p) {
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(p), err)
+		}
+		return readCloser{gz, func() error { gz.Close(); return f.Close() }}, nil
+	case ".zst":
+		rc, err := zstdReader(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return readCloser{rc, func() error { rc.Close(); return f.Close() }}, nil
+	}
+	return readCloser{r, f.Close}, nil
+}
+
+// ReadFile reads a whole file the way Open does
+func ReadFile(p string) ([]byte, error) {
+	rc, err := Open(p, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Exists reports whether a file, or a member of a zip archive, exists
+func Exists(p string) bool {
+	if !IsZipMember(p) {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+	archive, member := splitZipPath(p)
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name == member {
+			return true
+		}
+	}
+	return false
+}
+
+// ZipMember picks the file to load from a zip archive: the only member
+// accept takes, e.g. a shapefile with its sidecars or a GeoJSON file. It
+// returns the member's path as /vsizip/<archive>/<member>, which GDAL reads
+// directly and Open streams.
+func ZipMember(archive string, accept func(name string) bool) (string, error) {
+	abs, err := filepath.Abs(archive)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve archive path: %w", err)
+	}
+	zr, err := zip.OpenReader(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", filepath.Base(archive), err)
+	}
+	defer zr.Close()
+
+	var members []string
+	for _, f := range zr.File {
+		// Skip directories and the resource forks macOS adds
+		base := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		if accept(base) {
+			members = append(members, f.Name)
+		}
+	}
+	sort.Strings(members)
+
+	switch len(members) {
+	case 0:
+		return "", fmt.Errorf("%s contains no file that can be loaded", filepath.Base(archive))
+	case 1:
+		return zipPrefix + abs + "/" + members[0], nil
+	}
+	return "", fmt.Errorf("%s contains several files that can be loaded (%s); load one of them as %s%s/<file>",
+		filepath.Base(archive), strings.Join(members, ", "), zipPrefix, archive)
+}
+
+// openZipMember streams a member of a zip archive, inflating it as it is
+// read and checking its CRC-32 at the end. Progress is counted in compressed
+// bytes, as they are read from the archive.
+func openZipMember(p string, wrap func(io.Reader, int64) io.Reader) (io.ReadCloser, error) {
+	archive, member := splitZipPath(p)
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if f.Name != member {
+			continue
+		}
+		if f.Method != zip.Store && f.Method != zip.Deflate {
+			zr.Close()
+			return nil, fmt.Errorf("%s is compressed with an unsupported method (%d)", member, f.Method)
+		}
+
+		size := int64(f.CompressedSize64)
+		zr.RegisterDecompressor(zip.Store, func(r io.Reader) io.ReadCloser {
+			return io.NopCloser(wrap(r, size))
+		})
+		zr.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
+			return flate.NewReader(wrap(r, size))
+		})
+		rc, err := f.Open()
+		if err != nil {
+			zr.Close()
+			return nil, err
+		}
+		return readCloser{rc, func() error { rc.Close(); return zr.Close() }}, nil
+	}
+	zr.Close()
+	return nil, fmt.Errorf("%s not found in %s: %w", member, filepath.Base(archive), os.ErrNotExist)
+}
+
+// splitZipPath splits /vsizip/<archive>/<member> at the archive's .zip. An
+// absolute archive path gives /vsizip//path, which cleaning the path turns
+// into /vsizip/path, so a relative archive that doesn't exist is taken as
+// absolute.
+func splitZipPath(p string) (archive, member string) {
+	rest := strings.TrimPrefix(p, zipPrefix)
+	i := strings.Index(strings.ToLower(rest), ".zip/")
+	if i < 0 {
+		return rest, ""
+	}
+	archive, member = rest[:i+len(".zip")], rest[i+len(".zip/"):]
+	if _, err := os.Stat(archive); err != nil && !filepath.IsAbs(archive) {
+		archive = string(filepath.Separator) + archive
+	}
+	return archive, member
+}
+
+// zstdReader decompresses a Zstandard stream with the zstd command, which
+// Go's standard library has no decoder for
+func zstdReader(r io.Reader) (io.ReadCloser, error) {
+	bin, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, fmt.Errorf("reading .zst files needs the zstd command; install it or decompress the file first")
+	}
+	cmd := exec.Command(bin, "-d", "-c", "-q")
+	cmd.Stdin = r
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	return &zstdStream{out: out, cmd: cmd, stderr: &stderr}, nil
+}
+
+// zstdStream is the output of a running zstd command
+type zstdStream struct {
+	out    io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *strings.Builder
+	done   bool
+}
+
+// Read reports zstd's own error once its output ends, e.g. for corrupt input
+func (z *zstdStream) Read(p []byte) (int, error) {
+	n, err := z.out.Read(p)
+	if errors.Is(err, io.EOF) && !z.done {
+		z.done = true
+		if werr := z.cmd.Wait(); werr != nil {
+			if msg := strings.TrimSpace(z.stderr.String()); msg != "" {
+				return n, errors.New(msg)
+			}
+			return n, fmt.Errorf("zstd failed: %w", werr)
+		}
+	}
+	return n, err
+}
+
+// Close stops zstd when the stream wasn't read to the end
+func (z *zstdStream) Close() error {
+	if z.done {
+		return nil
+	}
+	z.done = true
+	z.out.Close()
+	z.cmd.Process.Kill()
+	z.cmd.Wait()
+	return nil
+}
+
+// readCloser pairs a reader with what closes its underlying file
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
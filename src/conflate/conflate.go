@@ -0,0 +1,428 @@
+package conflate
+
+import (
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// Status classifies a feature, or a pair of features, after matching
+type Status string
+
+const (
+	// StatusMatch is a pair of features taken to be the same thing
+	StatusMatch Status = "match"
+	// StatusConflict is a pair that matches spatially but whose attributes
+	// disagree
+	StatusConflict Status = "conflict"
+	// StatusAmbiguous is a pair with a rival almost as good, so which
+	// features belong together needs a closer look
+	StatusAmbiguous Status = "ambiguous"
+	// StatusReferenceOnly is a reference feature without a match
+	StatusReferenceOnly Status = "reference-only"
+	// StatusCandidateOnly is a candidate feature without a match
+	StatusCandidateOnly Status = "candidate-only"
+)
+
+// StatusColumn records each merged feature's status
+const StatusColumn = "conflate_status"
+
+// Options configures a conflation
+type Options struct {
+	// Distance is the farthest apart matching features may be
+	Distance database.Distance
+	// Attributes pairs the reference and candidate columns compared for
+	// similarity
+	Attributes []AttributePair
+	// MinSimilarity is the attribute similarity, from 0 to 1, below which
+	// a spatial match is a conflict
+	MinSimilarity float64
+	// Margin is how much better, in score, a match must be than its
+	// runner-up not to be ambiguous
+	Margin float64
+	// PreferCandidate takes matched features' geometries and values from the
+	// candidate, and fills gaps from the reference, instead of the reverse
+	PreferCandidate bool
+}
+
+// AttributePair names a column of the reference table and the column of the
+// candidate table it is compared with
+type AttributePair struct {
+	Reference string
+	Candidate string
+}
+
+// ParseAttributes parses --attributes values: a column both tables have, or
+// reference_column=candidate_column
+func ParseAttributes(values []string) ([]AttributePair, error) {
+	var pairs []AttributePair
+	for _, v := range values {
+		ref, cand, found := strings.Cut(v, "=")
+		if !found {
+			cand = ref
+		}
+		ref, cand = strings.TrimSpace(ref), strings.TrimSpace(cand)
+		if ref == "" || cand == "" {
+			return nil, fmt.Errorf("invalid attribute %q (expected column or reference_column=candidate_column)", v)
+		}
+		pairs = append(pairs, AttributePair{Reference: ref, Candidate: cand})
+	}
+	return pairs, nil
+}
+
+// Result counts the features by status
+type Result struct {
+	Matches       int64
+	Conflicts     int64
+	Ambiguous     int64
+	ReferenceOnly int64
+	CandidateOnly int64
+	// Rows is the number of rows of the merged table
+	Rows int64
+	// Review is the number of pairs in the review table
+	Review int64
+}
+
+// table is one of the datasets being conflated
+type table struct {
+	name    string
+	geom    string
+	columns []database.Column
+}
+
+// Conflate matches the features of the candidate table to those of the
+// reference table by distance and attribute similarity, and creates two
+// tables: outTable, the reference and candidate features merged, and
+// reviewTable, the conflicting and ambiguous pairs side by side.
+//
+// Each pair of features within the distance is scored from 0 to 1: the
+// spatial score falls from 1 for touching features to 0 at the distance, and
+// with attributes the score is the mean of it and their average Jaro-Winkler
+// similarity. A reference and candidate that are each other's best pair are
+// a match, unless their attributes are less similar than MinSimilarity (a
+// conflict) or either has a runner-up within Margin of the score (ambiguous).
+//
+// Matches become one merged feature; unmatched features of either table are
+// copied. Conflicting and ambiguous references are copied as they are, and
+// the candidates they were paired with left out for review.
+func Conflate(dbPath, referenceTable, candidateTable, outTable, reviewTable string, opts Options) (Result, error) {
+	var result Result
+
+	ref, err := newTable(dbPath, referenceTable)
+	if err != nil {
+		return result, err
+	}
+	cand, err := newTable(dbPath, candidateTable)
+	if err != nil {
+		return result, err
+	}
+	for _, attr := range opts.Attributes {
+		if !ref.has(attr.Reference) {
+			return result, fmt.Errorf("column %s not found in %s", attr.Reference, referenceTable)
+		}
+		if !cand.has(attr.Candidate) {
+			return result, fmt.Errorf("column %s not found in %s", attr.Candidate, candidateTable)
+		}
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+	if err := database.LoadSpatial(db); err != nil {
+		return result, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{pairsSQL(ref, cand, opts), statusSQL(opts), pendingSQL(opts)} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return result, fmt.Errorf("failed to match features: %w", err)
+		}
+	}
+
+	for _, name := range []string{outTable, reviewTable} {
+		if err := database.EnsureSchema(tx, name); err != nil {
+			return result, err
+		}
+	}
+	if _, err := tx.Exec(reviewSQL(ref, cand, reviewTable, opts)); err != nil {
+		return result, fmt.Errorf("failed to create review table: %w", err)
+	}
+	if _, err := tx.Exec(mergeSQL(ref, cand, outTable, opts)); err != nil {
+		return result, fmt.Errorf("failed to create merged table: %w", err)
+	}
+
+	countSQL := fmt.Sprintf(`SELECT
+		count(*),
+		count(*) FILTER (WHERE %[1]s = '%[2]s'),
+		count(*) FILTER (WHERE %[1]s = '%[3]s'),
+		count(*) FILTER (WHERE %[1]s = '%[4]s'),
+		count(*) FILTER (WHERE %[1]s = '%[5]s'),
+		count(*) FILTER (WHERE %[1]s = '%[6]s')
+		FROM %[7]s`, database.QuoteIdentifier(StatusColumn), StatusMatch, StatusConflict, StatusAmbiguous,
+		StatusReferenceOnly, StatusCandidateOnly, database.QuoteTableName(outTable))
+	err = tx.QueryRow(countSQL).Scan(&result.Rows, &result.Matches, &result.Conflicts, &result.Ambiguous, &result.ReferenceOnly, &result.CandidateOnly)
+	if err != nil {
+		return result, fmt.Errorf("failed to count merged features: %w", err)
+	}
+
+	if err := tx.QueryRow("SELECT count(*) FROM conflate_pending").Scan(&result.Review); err != nil {
+		return result, fmt.Errorf("failed to count pairs for review: %w", err)
+	}
+
+	// The temporary tables would otherwise outlive the transaction
+	for _, stmt := range []string{"DROP TABLE conflate_pairs", "DROP TABLE conflate_status", "DROP TABLE conflate_pending"} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return result, fmt.Errorf("failed to drop temporary table: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
+
+// newTable reads a table's columns and finds its geometry column
+func newTable(dbPath, name string) (*table, error) {
+	columns, err := database.GetTableSchema(dbPath, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table not found: %s", name)
+	}
+
+	t := &table{name: name}
+	for _, col := range columns {
+		if strings.HasPrefix(strings.ToUpper(col.Type), "GEOMETRY") {
+			if t.geom == "" {
+				t.geom = col.Name
+			}
+			continue
+		}
+		t.columns = append(t.columns, col)
+	}
+	if t.geom == "" {
+		return nil, fmt.Errorf("table %s has no GEOMETRY column", name)
+	}
+	return t, nil
+}
+
+// has reports whether the table has a non-geometry column
+func (t *table) has(column string) bool {
+	return t.column(column) != nil
+}
+
+// column finds a non-geometry column by name, ignoring case
+func (t *table) column(name string) *database.Column {
+	for i, col := range t.columns {
+		if strings.EqualFold(col.Name, name) {
+			return &t.columns[i]
+		}
+	}
+	return nil
+}
+
+// pairsSQL creates conflate_pairs: every reference and candidate feature
+// within the distance, scored
+func pairsSQL(ref, cand *table, opts Options) string {
+	rg, cg := "r."+database.QuoteIdentifier(ref.geom), "c."+database.QuoteIdentifier(cand.geom)
+
+	// With meters the data is lon/lat: candidates are found with the distance
+	// in degrees at the reference's latitude, then measured on the sphere
+	// along the shortest line between the two geometries
+	tolerance := fmt.Sprintf("%g", opts.Distance.Value)
+	search := tolerance
+	distance := fmt.Sprintf("ST_Distance(%s, %s)", rg, cg)
+	if opts.Distance.Meters {
+		search = fmt.Sprintf("(%g / 111320.0 / greatest(cos(radians(ST_Y(ST_Centroid(%s)))), 0.01))", opts.Distance.Value, rg)
+		distance = fmt.Sprintf("ST_Distance_Sphere(ST_StartPoint(ST_ShortestLine(%[1]s, %[2]s)), ST_EndPoint(ST_ShortestLine(%[1]s, %[2]s)))", rg, cg)
+	}
+
+	// Text is compared case- and whitespace-insensitively; list_avg skips
+	// the NULLs of missing values
+	similarity := "NULL::DOUBLE"
+	if len(opts.Attributes) > 0 {
+		var sims []string
+		for _, attr := range opts.Attributes {
+			sims = append(sims, fmt.Sprintf("jaro_winkler_similarity(lower(trim(r.%s::VARCHAR)), lower(trim(c.%s::VARCHAR)))",
+				database.QuoteIdentifier(attr.Reference), database.QuoteIdentifier(attr.Candidate)))
+		}
+		similarity = fmt.Sprintf("list_avg([%s])", strings.Join(sims, ", "))
+	}
+
+	return fmt.Sprintf(`
+		CREATE TEMPORARY TABLE conflate_pairs AS
+		SELECT ref_rid, cand_rid, distance, spatial_score, attribute_score,
+			CASE WHEN attribute_score IS NULL THEN spatial_score ELSE (spatial_score + attribute_score) / 2 END AS score
+		FROM (
+			SELECT ref_rid, cand_rid, distance, attribute_score,
+				CASE WHEN %[1]s = 0 THEN 1.0 ELSE greatest(1 - distance / %[1]s, 0) END AS spatial_score
+			FROM (
+				SELECT r.rowid AS ref_rid, c.rowid AS cand_rid, %[2]s AS distance, %[3]s AS attribute_score
+				FROM %[4]s r JOIN %[5]s c ON ST_DWithin(%[6]s, %[7]s, %[8]s)
+			)
+			WHERE distance <= %[1]s
+		)`,
+		tolerance, distance, similarity,
+		database.QuoteTableName(ref.name), database.QuoteTableName(cand.name), rg, cg, search)
+}
+
+// statusSQL creates conflate_status: the pair each reference feature is
+// best matched to, and the status of that pair
+func statusSQL(opts Options) string {
+	return fmt.Sprintf(`
+		CREATE TEMPORARY TABLE conflate_status AS
+		WITH ranked AS (
+			SELECT *,
+				row_number() OVER (PARTITION BY ref_rid ORDER BY score DESC, distance, cand_rid) AS ref_rank,
+				row_number() OVER (PARTITION BY cand_rid ORDER BY score DESC, distance, ref_rid) AS cand_rank,
+				nth_value(score, 2) OVER (PARTITION BY ref_rid ORDER BY score DESC, distance, cand_rid
+					ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS ref_runner_up,
+				first_value(score) OVER (PARTITION BY cand_rid ORDER BY score DESC, distance, ref_rid) AS cand_best,
+				nth_value(score, 2) OVER (PARTITION BY cand_rid ORDER BY score DESC, distance, ref_rid
+					ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS cand_runner_up
+			FROM conflate_pairs
+		)
+		SELECT ref_rid, cand_rid, distance, spatial_score, attribute_score, score,
+			CASE
+				-- A reference whose best candidate is better taken by another
+				-- reference only needs review when it is a close second
+				WHEN cand_rank > 1 THEN '%[3]s'
+				WHEN score - coalesce(ref_runner_up, -1) < %[1]g OR score - coalesce(cand_runner_up, -1) < %[1]g THEN '%[3]s'
+				WHEN attribute_score < %[2]g THEN '%[4]s'
+				ELSE '%[5]s'
+			END AS status
+		FROM ranked
+		WHERE ref_rank = 1 AND (cand_rank = 1 OR cand_best - score < %[1]g)`,
+		opts.Margin, opts.MinSimilarity, StatusAmbiguous, StatusConflict, StatusMatch)
+}
+
+// pendingSQL creates conflate_pending: the pairs left for review. These are
+// conflicting pairs, and for ambiguous references every pair within the
+// margin of the best.
+func pendingSQL(opts Options) string {
+	return fmt.Sprintf(`
+		CREATE TEMPORARY TABLE conflate_pending AS
+		SELECT s.status, p.ref_rid, p.cand_rid, p.distance, p.spatial_score, p.attribute_score, p.score
+		FROM conflate_pairs p JOIN conflate_status s ON s.ref_rid = p.ref_rid
+		WHERE (s.status = '%[2]s' AND p.cand_rid = s.cand_rid)
+			OR (s.status = '%[3]s' AND s.score - p.score < %[1]g)`,
+		opts.Margin, StatusConflict, StatusAmbiguous)
+}
+
+// reviewSQL creates the review table: the pairs left for review with their
+// scores, compared attributes and both geometries
+func reviewSQL(ref, cand *table, reviewTable string, opts Options) string {
+	selects := []string{
+		"s.status", "s.ref_rid AS reference_rowid", "s.cand_rid AS candidate_rowid",
+		"s.distance", "s.spatial_score", "s.attribute_score", "s.score",
+	}
+	for _, attr := range opts.Attributes {
+		selects = append(selects,
+			fmt.Sprintf("r.%s AS %s", database.QuoteIdentifier(attr.Reference), database.QuoteIdentifier("reference_"+attr.Reference)),
+			fmt.Sprintf("c.%s AS %s", database.QuoteIdentifier(attr.Candidate), database.QuoteIdentifier("candidate_"+attr.Candidate)))
+	}
+	selects = append(selects,
+		fmt.Sprintf("r.%s AS reference_geom", database.QuoteIdentifier(ref.geom)),
+		fmt.Sprintf("c.%s AS candidate_geom", database.QuoteIdentifier(cand.geom)))
+
+	return fmt.Sprintf(`
+		CREATE TABLE %s AS
+		SELECT %s
+		FROM conflate_pending s
+		JOIN %s r ON r.rowid = s.ref_rid
+		JOIN %s c ON c.rowid = s.cand_rid
+		ORDER BY s.ref_rid, s.score DESC`,
+		database.QuoteTableName(reviewTable), strings.Join(selects, ", "),
+		database.QuoteTableName(ref.name), database.QuoteTableName(cand.name))
+}
+
+// mergeSQL creates the merged table. Its columns are the reference's, then
+// the candidate's that the reference lacks, then geom and the status.
+func mergeSQL(ref, cand *table, outTable string, opts Options) string {
+	// Columns both tables have take the reference's type
+	type mergedColumn struct {
+		name      string
+		colType   string
+		ref, cand string
+	}
+	var columns []mergedColumn
+	for _, col := range ref.columns {
+		mc := mergedColumn{name: col.Name, colType: col.Type, ref: "r." + database.QuoteIdentifier(col.Name), cand: "NULL"}
+		if c := cand.column(col.Name); c != nil {
+			mc.cand = "c." + database.QuoteIdentifier(c.Name)
+		}
+		columns = append(columns, mc)
+	}
+	for _, col := range cand.columns {
+		if !ref.has(col.Name) {
+			columns = append(columns, mergedColumn{name: col.Name, colType: col.Type, ref: "NULL", cand: "c." + database.QuoteIdentifier(col.Name)})
+		}
+	}
+
+	// Matches take the preferred feature's values, filling its gaps from the
+	// other; unmatched features keep their own
+	value := func(mc mergedColumn, from string) string {
+		var expr string
+		switch from {
+		case "match":
+			first, second := mc.ref, mc.cand
+			if opts.PreferCandidate {
+				first, second = second, first
+			}
+			expr = fmt.Sprintf("coalesce(TRY_CAST(%s AS %s), TRY_CAST(%s AS %s))", first, mc.colType, second, mc.colType)
+		case "reference":
+			expr = fmt.Sprintf("TRY_CAST(%s AS %s)", mc.ref, mc.colType)
+		default:
+			expr = fmt.Sprintf("TRY_CAST(%s AS %s)", mc.cand, mc.colType)
+		}
+		return expr + " AS " + database.QuoteIdentifier(mc.name)
+	}
+	selectFor := func(from, geom, status string) string {
+		var selects []string
+		for _, mc := range columns {
+			selects = append(selects, value(mc, from))
+		}
+		selects = append(selects, geom+" AS geom", status+" AS "+database.QuoteIdentifier(StatusColumn))
+		return strings.Join(selects, ", ")
+	}
+
+	rg, cg := "r."+database.QuoteIdentifier(ref.geom), "c."+database.QuoteIdentifier(cand.geom)
+	matchGeom := rg
+	if opts.PreferCandidate {
+		matchGeom = cg
+	}
+	refTable, candTable := database.QuoteTableName(ref.name), database.QuoteTableName(cand.name)
+
+	return fmt.Sprintf(`
+		CREATE TABLE %[1]s AS
+		SELECT %[2]s
+		FROM conflate_status s
+		JOIN %[5]s r ON r.rowid = s.ref_rid
+		JOIN %[6]s c ON c.rowid = s.cand_rid
+		WHERE s.status = '%[7]s'
+		UNION ALL
+		SELECT %[3]s
+		FROM %[5]s r
+		LEFT JOIN conflate_status s ON s.ref_rid = r.rowid AND s.status <> '%[7]s'
+		WHERE r.rowid NOT IN (SELECT ref_rid FROM conflate_status WHERE status = '%[7]s')
+		UNION ALL
+		SELECT %[4]s
+		FROM %[6]s c
+		WHERE c.rowid NOT IN (SELECT cand_rid FROM conflate_status WHERE status = '%[7]s')
+			AND c.rowid NOT IN (SELECT cand_rid FROM conflate_pending)`,
+		database.QuoteTableName(outTable),
+		selectFor("match", matchGeom, fmt.Sprintf("'%s'", StatusMatch)),
+		selectFor("reference", rg, fmt.Sprintf("coalesce(s.status, '%s')", StatusReferenceOnly)),
+		selectFor("candidate", cg, fmt.Sprintf("'%s'", StatusCandidateOnly)),
+		refTable, candTable, StatusMatch)
+}
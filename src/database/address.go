@@ -0,0 +1,337 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AddressColumns are added to the points interpolated along lines
+var AddressColumns = []string{"house_number", "address_side"}
+
+// maxAddressSpan is the most numbers one range is interpolated into; wider
+// ranges are taken to be malformed
+const maxAddressSpan = 10000
+
+// ParseHouseNumber parses the number a house number starts with, so "123",
+// "123A" and "123 1/2" all give 123
+func ParseHouseNumber(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:end], 10, 64)
+	return n, err == nil
+}
+
+// AddressRange is the house numbers along one side of a line, from its first
+// point to its last
+type AddressRange struct {
+	From int64
+	To   int64
+}
+
+// ParseAddressRange parses a range held in one attribute, "100-198" or
+// "100 - 198". A single house number is a range of one.
+func ParseAddressRange(s string) (AddressRange, bool) {
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		to = from
+	}
+	f, ok1 := ParseHouseNumber(from)
+	t, ok2 := ParseHouseNumber(to)
+	if !ok1 || !ok2 {
+		return AddressRange{}, false
+	}
+	return AddressRange{From: f, To: t}, true
+}
+
+// Numbers lists the house numbers of the range in order from its first point.
+// When both ends are odd or both even the range holds every other number, as
+// on streets numbered odd on one side and even on the other.
+func (r AddressRange) Numbers() []int64 {
+	step := int64(1)
+	if (r.From-r.To)%2 == 0 {
+		step = 2
+	}
+	if r.To < r.From {
+		step = -step
+	}
+	var numbers []int64
+	for n := r.From; (step > 0 && n <= r.To) || (step < 0 && n >= r.To); n += step {
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// span is how many numbers the range covers
+func (r AddressRange) span() int64 {
+	if r.To < r.From {
+		return r.From - r.To + 1
+	}
+	return r.To - r.From + 1
+}
+
+// fraction is how far along the line a number of the range lies
+func (r AddressRange) fraction(n int64) float64 {
+	if r.From == r.To {
+		return 0.5
+	}
+	return float64(n-r.From) / float64(r.To-r.From)
+}
+
+// AddressSide names the columns holding the address range of one side of the
+// lines
+type AddressSide struct {
+	// Side is "left" or "right", looking along the line
+	Side string
+	// From and To hold the numbers at the first and last point of the line,
+	// as in TIGER's LFROMADD and LTOADD
+	From string
+	To   string
+	// Range holds both ends in one column, e.g. "100-198", instead
+	Range string
+}
+
+// ParseAddressSide parses the columns of one side: "from_column,to_column"
+// or a single "range_column"
+func ParseAddressSide(side, value string) (AddressSide, error) {
+	parts := strings.Split(value, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+		if parts[i] == "" {
+			return AddressSide{}, fmt.Errorf("invalid %s address columns %q (expected from_column,to_column or range_column)", side, value)
+		}
+	}
+	switch len(parts) {
+	case 1:
+		return AddressSide{Side: side, Range: parts[0]}, nil
+	case 2:
+		return AddressSide{Side: side, From: parts[0], To: parts[1]}, nil
+	}
+	return AddressSide{}, fmt.Errorf("invalid %s address columns %q (expected from_column,to_column or range_column)", side, value)
+}
+
+// columns returns the side's columns in the order they are read
+func (s AddressSide) columns() []string {
+	if s.Range != "" {
+		return []string{s.Range}
+	}
+	return []string{s.From, s.To}
+}
+
+// parse reads the side's range from the values of its columns
+func (s AddressSide) parse(values []sql.NullString) (AddressRange, bool) {
+	if s.Range != "" {
+		return ParseAddressRange(values[0].String)
+	}
+	from, ok1 := ParseHouseNumber(values[0].String)
+	to, ok2 := ParseHouseNumber(values[1].String)
+	return AddressRange{From: from, To: to}, ok1 && ok2
+}
+
+// AddressOptions configures interpolating address points along lines
+type AddressOptions struct {
+	// Sides are the address ranges to interpolate, usually left and right
+	Sides []AddressSide
+	// Offset moves each point away from the line towards its side
+	Offset float64
+	// Planar measures in CRS units instead of meters on the sphere
+	Planar bool
+}
+
+// AddressResult summarizes interpolating addresses
+type AddressResult struct {
+	// Lines is the number of LINESTRINGs read
+	Lines int64
+	// Ranges is the number of sides with a range, Invalid the number whose
+	// range could not be parsed or spans more than maxAddressSpan numbers
+	Ranges  int64
+	Invalid int64
+	// Points is the number of address points created
+	Points int64
+}
+
+// addressPoint is an interpolated address of one line
+type addressPoint struct {
+	rowID  int64
+	number int64
+	side   string
+	wkt    string
+}
+
+// InterpolateAddresses creates outTable with a point for every house number
+// in the address ranges of the lines of srcTable, spaced evenly along the line
+// and moved Offset towards its side. Each point carries the columns of its
+// line plus house_number and address_side. Sides without a range are
+// skipped, and lines other than LINESTRINGs are ignored.
+func InterpolateAddresses(dbPath, srcTable, outTable string, opts AddressOptions) (AddressResult, error) {
+	var result AddressResult
+
+	columns, err := GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return result, err
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	err = withTempTables(db, func(tx *sql.Tx) error {
+		var rangeExprs []string
+		for _, side := range opts.Sides {
+			for _, col := range side.columns() {
+				rangeExprs = append(rangeExprs, fmt.Sprintf("CAST(%s AS VARCHAR)", QuoteIdentifier(col)))
+			}
+		}
+		rows, err := tx.Query(fmt.Sprintf(`
+			SELECT rowid, %s
+			FROM %s
+			WHERE geom IS NOT NULL AND ST_GeometryType(geom)::VARCHAR = 'LINESTRING' AND ST_NPoints(geom) >= 2
+		`, strings.Join(rangeExprs, ", "), QuoteTableName(srcTable)))
+		if err != nil {
+			return fmt.Errorf("failed to read address ranges: %w", err)
+		}
+
+		lines := make(map[int64]*route)
+		ranges := make(map[int64][]sql.NullString)
+		var order []int64
+		for rows.Next() {
+			r := &route{planar: opts.Planar}
+			values := make([]sql.NullString, len(rangeExprs))
+			dest := []any{&r.rowID}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan address ranges: %w", err)
+			}
+			lines[r.rowID] = r
+			ranges[r.rowID] = values
+			order = append(order, r.rowID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating rows: %w", err)
+		}
+		result.Lines = int64(len(order))
+
+		if err := readVertices(tx, srcTable, lines); err != nil {
+			return err
+		}
+
+		var points []addressPoint
+		for _, rowID := range order {
+			r, values := lines[rowID], ranges[rowID]
+			for _, side := range opts.Sides {
+				n := len(side.columns())
+				sideValues := values[:n]
+				values = values[n:]
+
+				blank := true
+				for _, v := range sideValues {
+					blank = blank && strings.TrimSpace(v.String) == ""
+				}
+				if blank {
+					continue
+				}
+				result.Ranges++
+
+				addresses, ok := side.parse(sideValues)
+				if !ok || addresses.span() > maxAddressSpan {
+					result.Invalid++
+					continue
+				}
+				for _, number := range addresses.Numbers() {
+					p := r.offsetPoint(addresses.fraction(number)*r.length(), opts.Offset, side.Side == "left")
+					points = append(points, addressPoint{
+						rowID:  rowID,
+						number: number,
+						side:   side.Side,
+						wkt:    fmt.Sprintf("POINT (%.7f %.7f)", p[0], p[1]),
+					})
+				}
+			}
+		}
+		result.Points = int64(len(points))
+
+		if _, err := tx.Exec("CREATE TEMPORARY TABLE temp_addresses (rid BIGINT, seq BIGINT, house_number BIGINT, address_side VARCHAR, wkt VARCHAR)"); err != nil {
+			return fmt.Errorf("failed to create temporary table: %w", err)
+		}
+		defer tx.Exec("DROP TABLE IF EXISTS temp_addresses")
+		stmt, err := tx.Prepare("INSERT INTO temp_addresses VALUES (?, ?, ?, ?, ?)")
+		if err != nil {
+			return fmt.Errorf("failed to prepare insert: %w", err)
+		}
+		defer stmt.Close()
+		for i, p := range points {
+			if _, err := stmt.Exec(p.rowID, i, p.number, p.side, p.wkt); err != nil {
+				return fmt.Errorf("failed to insert address point: %w", err)
+			}
+		}
+
+		if err := EnsureSchema(tx, outTable); err != nil {
+			return err
+		}
+
+		selectCols := sourceColumns("s", columns)
+		if selectCols != "" {
+			selectCols += ", "
+		}
+		createSQL := fmt.Sprintf(`
+			CREATE TABLE %s AS
+			SELECT %st.house_number, t.address_side, ST_GeomFromText(t.wkt) AS geom
+			FROM temp_addresses t JOIN %s s ON s.rowid = t.rid
+			ORDER BY t.seq
+		`, QuoteTableName(outTable), selectCols, QuoteTableName(srcTable))
+		if _, err := tx.Exec(createSQL); err != nil {
+			return fmt.Errorf("failed to create %s: %w", outTable, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// offsetPoint returns the point at length d from the start of the route,
+// moved offset to its left or right
+func (r *route) offsetPoint(d, offset float64, left bool) [2]float64 {
+	p, i := r.pointAt(d)
+	if offset == 0 || i < 0 {
+		return p
+	}
+
+	// Work on a plane in meters around the point: a degree of latitude is
+	// about 111320m, a degree of longitude that times cos(latitude)
+	unitX, unitY := 1.0, 1.0
+	if !r.planar {
+		unitY = 111320.0
+		unitX = unitY * math.Max(math.Cos(p[1]*math.Pi/180), 0.01)
+	}
+	a, b := r.points[i], r.points[i+1]
+	dx, dy := (b[0]-a[0])*unitX, (b[1]-a[1])*unitY
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return p
+	}
+
+	// The left of the direction of travel is the direction turned 90°
+	// counterclockwise
+	nx, ny := -dy/length, dx/length
+	if !left {
+		nx, ny = -nx, -ny
+	}
+	return [2]float64{p[0] + nx*offset/unitX, p[1] + ny*offset/unitY}
+}
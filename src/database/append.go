@@ -0,0 +1,182 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ColumnMapping pairs a source column with the target column it is copied into
+type ColumnMapping struct {
+	Source Column
+	Target Column
+}
+
+// AppendResult describes a completed append
+type AppendResult struct {
+	Rows   int64
+	Mapped []ColumnMapping
+	// Unmapped lists source columns that weren't copied
+	Unmapped []string
+	// Defaulted lists target columns left NULL (or their default)
+	Defaulted []string
+}
+
+// AppendTable copies every row of source into target. Columns are matched by
+// name (case-insensitively); mapping adds or overrides pairs as source -> target.
+// Values are checked against the target types before anything is written.
+func AppendTable(dbPath, source, target string, mapping map[string]string) (AppendResult, error) {
+	var result AppendResult
+
+	sourceCols, err := GetTableSchema(dbPath, source)
+	if err != nil {
+		return result, err
+	}
+	if len(sourceCols) == 0 {
+		return result, fmt.Errorf("table not found: %s", source)
+	}
+	targetCols, err := GetTableSchema(dbPath, target)
+	if err != nil {
+		return result, err
+	}
+	if len(targetCols) == 0 {
+		return result, fmt.Errorf("table not found: %s", target)
+	}
+
+	result.Mapped, err = mapColumns(sourceCols, targetCols, mapping)
+	if err != nil {
+		return result, err
+	}
+	if len(result.Mapped) == 0 {
+		return result, fmt.Errorf("no columns of %s map onto %s (use --map source=target)", source, target)
+	}
+
+	// Report columns in the target table's order
+	position := make(map[string]int)
+	for i, col := range targetCols {
+		position[col.Name] = i
+	}
+	sort.SliceStable(result.Mapped, func(i, j int) bool {
+		return position[result.Mapped[i].Target.Name] < position[result.Mapped[j].Target.Name]
+	})
+
+	usedSource := make(map[string]bool)
+	usedTarget := make(map[string]bool)
+	for _, m := range result.Mapped {
+		usedSource[m.Source.Name] = true
+		usedTarget[m.Target.Name] = true
+	}
+	for _, col := range sourceCols {
+		if !usedSource[col.Name] {
+			result.Unmapped = append(result.Unmapped, col.Name)
+		}
+	}
+	for _, col := range targetCols {
+		if !usedTarget[col.Name] {
+			result.Defaulted = append(result.Defaulted, col.Name)
+		}
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	// Count values that wouldn't survive the cast so nothing is half-copied
+	var problems []string
+	for _, m := range result.Mapped {
+		if strings.EqualFold(m.Source.Type, m.Target.Type) {
+			continue
+		}
+		src := QuoteIdentifier(m.Source.Name)
+		var failed int64
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NOT NULL AND TRY_CAST(%s AS %s) IS NULL",
+			QuoteTableName(source), src, src, m.Target.Type)
+		if err := db.QueryRow(query).Scan(&failed); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s) -> %s (%s): %v", m.Source.Name, m.Source.Type, m.Target.Name, m.Target.Type, err))
+			continue
+		}
+		if failed > 0 {
+			problems = append(problems, fmt.Sprintf("%s (%s) -> %s (%s): %d values can't be converted", m.Source.Name, m.Source.Type, m.Target.Name, m.Target.Type, failed))
+		}
+	}
+	if len(problems) > 0 {
+		return result, fmt.Errorf("incompatible column mapping:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	var targetList, selectList []string
+	for _, m := range result.Mapped {
+		targetList = append(targetList, QuoteIdentifier(m.Target.Name))
+		selectList = append(selectList, QuoteIdentifier(m.Source.Name))
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+		QuoteTableName(target), strings.Join(targetList, ", "), strings.Join(selectList, ", "), QuoteTableName(source))
+
+	res, err := db.Exec(insertSQL)
+	if err != nil {
+		return result, fmt.Errorf("failed to append rows: %w", err)
+	}
+	result.Rows, err = res.RowsAffected()
+	if err != nil {
+		return result, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return result, nil
+}
+
+// mapColumns pairs source and target columns, explicit mappings first and then
+// any remaining columns that share a name
+func mapColumns(sourceCols, targetCols []Column, mapping map[string]string) ([]ColumnMapping, error) {
+	find := func(cols []Column, name string) (Column, bool) {
+		for _, c := range cols {
+			if strings.EqualFold(c.Name, name) {
+				return c, true
+			}
+		}
+		return Column{}, false
+	}
+
+	var mapped []ColumnMapping
+	usedSource := make(map[string]bool)
+	usedTarget := make(map[string]bool)
+
+	// Sort for stable error messages
+	var keys []string
+	for k := range mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, srcName := range keys {
+		src, ok := find(sourceCols, srcName)
+		if !ok {
+			return nil, fmt.Errorf("source column not found: %s", srcName)
+		}
+		tgt, ok := find(targetCols, mapping[srcName])
+		if !ok {
+			return nil, fmt.Errorf("target column not found: %s", mapping[srcName])
+		}
+		if usedTarget[tgt.Name] {
+			return nil, fmt.Errorf("target column %s is mapped more than once", tgt.Name)
+		}
+		mapped = append(mapped, ColumnMapping{Source: src, Target: tgt})
+		usedSource[src.Name] = true
+		usedTarget[tgt.Name] = true
+	}
+
+	for _, src := range sourceCols {
+		if usedSource[src.Name] {
+			continue
+		}
+		tgt, ok := find(targetCols, src.Name)
+		if !ok || usedTarget[tgt.Name] {
+			continue
+		}
+		mapped = append(mapped, ColumnMapping{Source: src, Target: tgt})
+		usedSource[src.Name] = true
+		usedTarget[tgt.Name] = true
+	}
+
+	return mapped, nil
+}
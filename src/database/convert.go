@@ -0,0 +1,165 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format describes a file format DuckDB can read and write
+type Format struct {
+	Name       string
+	Extensions []string
+	// Driver is the GDAL driver used through ST_Read and COPY ... (FORMAT GDAL);
+	// empty for formats DuckDB handles natively
+	Driver string
+}
+
+var formats = []Format{
+	{Name: "geojson", Extensions: []string{".geojson", ".json"}, Driver: "GeoJSON"},
+	{Name: "geojsonseq", Extensions: []string{".geojsonl", ".geojsons", ".ndjson"}, Driver: "GeoJSONSeq"},
+	{Name: "parquet", Extensions: []string{".parquet", ".geoparquet"}},
+	{Name: "csv", Extensions: []string{".csv"}},
+	{Name: "gpkg", Extensions: []string{".gpkg"}, Driver: "GPKG"},
+	{Name: "fgb", Extensions: []string{".fgb"}, Driver: "FlatGeobuf"},
+	{Name: "kml", Extensions: []string{".kml"}, Driver: "KML"},
+	{Name: "shp", Extensions: []string{".shp"}, Driver: "ESRI Shapefile"},
+}
+
+// FormatNames returns the names of the supported formats in sorted order
+func FormatNames() []string {
+	var names []string
+	for _, f := range formats {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupFormat returns the format with the given name
+func LookupFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, f := range formats {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown format %q (supported: %s)", name, strings.Join(FormatNames(), ", "))
+}
+
+// FormatForPath infers a file's format from its extension
+func FormatForPath(path string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, nil
+			}
+		}
+	}
+	return Format{}, fmt.Errorf("can't infer the format of %s from its extension (supported: %s)", path, strings.Join(FormatNames(), ", "))
+}
+
+// ReadSQL returns a table expression that reads path in this format
+func (f Format) ReadSQL(path string) string {
+	switch f.Name {
+	case "parquet":
+		return fmt.Sprintf("read_parquet(%s)", QuoteLiteral(path))
+	case "csv":
+		return fmt.Sprintf("read_csv_auto(%s)", QuoteLiteral(path))
+	default:
+		return fmt.Sprintf("ST_Read(%s)", QuoteLiteral(path))
+	}
+}
+
+// CopySQL returns the statement that writes the result of query to path in
+// this format, with any extra COPY options appended
+func (f Format) CopySQL(query, path string, options ...string) string {
+	switch f.Name {
+	case "parquet":
+		options = append([]string{"FORMAT parquet"}, options...)
+	case "csv":
+		options = append([]string{"FORMAT csv", "HEADER"}, options...)
+	default:
+		options = append([]string{"FORMAT GDAL", "DRIVER " + QuoteLiteral(f.Driver)}, options...)
+	}
+	return fmt.Sprintf("COPY (%s) TO %s (%s)", query, QuoteLiteral(path), strings.Join(options, ", "))
+}
+
+// ConvertOptions controls a format-to-format conversion
+type ConvertOptions struct {
+	// From and To override the formats inferred from the file extensions
+	From string
+	To   string
+	// Overwrite replaces an existing output file
+	Overwrite bool
+}
+
+// Convert translates input into output through an in-memory database and
+// returns the number of features written
+func Convert(input, output string, opts ConvertOptions) (int64, error) {
+	from, err := resolveFormat(input, opts.From)
+	if err != nil {
+		return 0, err
+	}
+	to, err := resolveFormat(output, opts.To)
+	if err != nil {
+		return 0, err
+	}
+
+	absInput, err := filepath.Abs(input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve input path: %w", err)
+	}
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve output path: %w", err)
+	}
+
+	if !FileExists(absInput) {
+		return 0, fmt.Errorf("input file not found: %s", input)
+	}
+	if FileExists(absOutput) {
+		if !opts.Overwrite {
+			return 0, fmt.Errorf("output file %s already exists (use --overwrite to replace it)", output)
+		}
+		if err := os.Remove(absOutput); err != nil {
+			return 0, fmt.Errorf("failed to remove existing output: %w", err)
+		}
+	}
+
+	db, err := OpenInMemory()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := LoadSpatial(db); err != nil {
+		return 0, err
+	}
+
+	if _, err := db.Exec("CREATE TABLE features AS SELECT * FROM " + from.ReadSQL(absInput)); err != nil {
+		return 0, fmt.Errorf("failed to read %s as %s: %w", input, from.Name, err)
+	}
+
+	var count int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM features").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count features: %w", err)
+	}
+
+	if _, err := db.Exec(to.CopySQL("SELECT * FROM features", absOutput)); err != nil {
+		return 0, fmt.Errorf("failed to write %s as %s: %w", output, to.Name, err)
+	}
+
+	return count, nil
+}
+
+// resolveFormat uses the named format when given, otherwise the path's extension
+func resolveFormat(path, name string) (Format, error) {
+	if name != "" {
+		return LookupFormat(name)
+	}
+	return FormatForPath(path)
+}
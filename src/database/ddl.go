@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ApplyResult describes what ApplyColumns changed
+type ApplyResult struct {
+	Created bool
+	// Added lists columns added to an existing table
+	Added []string
+	// Mismatched lists existing columns whose type differs from the wanted one;
+	// they're left unchanged
+	Mismatched []ColumnMapping
+}
+
+// ApplyColumns makes a table match a column list: the table is created when it
+// doesn't exist, otherwise missing columns are added. Existing columns are
+// never altered or dropped.
+func ApplyColumns(dbPath, tableName string, columns []Column) (ApplyResult, error) {
+	var result ApplyResult
+
+	existing, err := GetTableSchema(dbPath, tableName)
+	if err != nil {
+		return result, err
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(existing) == 0 {
+		if err := EnsureSchema(tx, tableName); err != nil {
+			return result, err
+		}
+		var defs []string
+		for _, col := range columns {
+			defs = append(defs, QuoteIdentifier(col.Name)+" "+col.Type)
+		}
+		createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", QuoteTableName(tableName), strings.Join(defs, ", "))
+		if _, err := tx.Exec(createSQL); err != nil {
+			return result, fmt.Errorf("failed to create table: %w", err)
+		}
+		result.Created = true
+	} else {
+		current := make(map[string]Column, len(existing))
+		for _, col := range existing {
+			current[strings.ToLower(col.Name)] = col
+		}
+		for _, col := range columns {
+			have, ok := current[strings.ToLower(col.Name)]
+			if !ok {
+				addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", QuoteTableName(tableName), QuoteIdentifier(col.Name), col.Type)
+				if _, err := tx.Exec(addSQL); err != nil {
+					return result, fmt.Errorf("failed to add column %s: %w", col.Name, err)
+				}
+				result.Added = append(result.Added, col.Name)
+				continue
+			}
+			if !strings.EqualFold(have.Type, col.Type) {
+				result.Mismatched = append(result.Mismatched, ColumnMapping{Source: col, Target: have})
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return result, nil
+}
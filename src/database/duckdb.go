@@ -1,13 +1,16 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
-	_ "github.com/duckdb/duckdb-go/v2"
+	"github.com/duckdb/duckdb-go/v2"
+	"org.xyzmaps.xyzduck/src/offline"
 )
 
 // EnsureDuckDBExtension adds .duckdb extension if not present
@@ -24,18 +27,115 @@ func FileExists(filename string) bool {
 	return err == nil
 }
 
-// CreateOrOpenDatabase creates a new DuckDB database or opens an existing one
-func CreateOrOpenDatabase(filename string) error {
-	// Get absolute path for better error messages
+// attachedName is the catalog name used for databases that can't be opened via the DSN
+const attachedName = "xyzduck_db"
+
+// Open opens a DuckDB database file. In offline mode DuckDB is told not to
+// download extensions on demand, so only pre-seeded extensions can be loaded.
+// Callers are responsible for closing the returned connection.
+func Open(filename string) (*sql.DB, error) {
 	absPath, err := filepath.Abs(filename)
 	if err != nil {
-		return fmt.Errorf("failed to resolve absolute path: %w", err)
+		return nil, fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+
+	// DuckDB treats '?' as the start of connection options, even when attaching
+	if strings.Contains(absPath, "?") {
+		return nil, fmt.Errorf("database path %s must not contain '?'", absPath)
+	}
+
+	// The driver parses its DSN as a URL, which rejects a stray '%'. Such files
+	// are attached to an in-memory database instead.
+	if strings.Contains(absPath, "%") {
+		return open("", absPath)
+	}
+	db, err := open(absPath, "")
+	if err != nil {
+		return nil, storageError(filename, err)
+	}
+	return db, nil
+}
+
+// OpenInMemory opens a scratch in-memory database with the same settings as Open
+func OpenInMemory() (*sql.DB, error) {
+	return open("", "")
+}
+
+// open connects to dsn, attaching attachPath as the default catalog when set
+func open(dsn, attachPath string) (*sql.DB, error) {
+	connector, err := duckdb.NewConnector(dsn, func(execer driver.ExecerContext) error {
+		var statements []string
+		if offline.Enabled() {
+			statements = append(statements, "SET autoinstall_known_extensions = false")
+		}
+		if attachPath != "" {
+			statements = append(statements,
+				fmt.Sprintf("ATTACH IF NOT EXISTS %s AS %s", QuoteLiteral(attachPath), attachedName),
+				"USE "+attachedName,
+			)
+		}
+		for _, stmt := range statements {
+			if _, err := execer.ExecContext(context.Background(), stmt, nil); err != nil {
+				return storageError(attachPath, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return sql.OpenDB(connector), nil
+}
+
+// LoadSpatial loads the spatial extension on an open connection
+func LoadSpatial(db *sql.DB) error {
+	if _, err := db.Exec("LOAD spatial;"); err != nil {
+		return extensionLoadError(db, "spatial", err)
 	}
+	return nil
+}
+
+// LoadExtension installs and loads a DuckDB extension. Offline nothing is
+// downloaded: the extension must have been pre-seeded.
+func LoadExtension(db *sql.DB, name string) error {
+	if !offline.Enabled() {
+		if _, err := db.Exec("INSTALL " + QuoteIdentifier(name)); err != nil {
+			return fmt.Errorf("failed to install %s extension: %w", name, err)
+		}
+	}
+	if _, err := db.Exec("LOAD " + QuoteIdentifier(name)); err != nil {
+		return extensionLoadError(db, name, err)
+	}
+	return nil
+}
 
-	// Open/create the database
-	db, err := sql.Open("duckdb", absPath)
+// extensionLoadError wraps a failed LOAD of an extension. Offline, it explains
+// how to pre-seed the extension since it can't be downloaded.
+func extensionLoadError(db *sql.DB, name string, err error) error {
+	if !offline.Enabled() {
+		return fmt.Errorf("failed to load %s extension: %w", name, err)
+	}
+
+	var version, platform string
+	db.QueryRow("SELECT library_version FROM pragma_version()").Scan(&version)
+	db.QueryRow("SELECT platform FROM pragma_platform()").Scan(&platform)
+
+	return fmt.Errorf(`failed to load %[1]s extension in offline mode: %[2]w
+
+The %[1]s extension must be pre-seeded. On a machine with network access run
+  duckdb -c "INSTALL %[1]s"
+(or download https://extensions.duckdb.org/%[3]s/%[4]s/%[1]s.duckdb_extension.gz and
+gunzip it), then copy the file to
+  ~/.duckdb/extensions/%[3]s/%[4]s/%[1]s.duckdb_extension
+on this machine`, name, err, version, platform)
+}
+
+// CreateOrOpenDatabase creates a new DuckDB database or opens an existing one
+func CreateOrOpenDatabase(filename string) error {
+	db, err := Open(filename)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -49,31 +149,48 @@ func CreateOrOpenDatabase(filename string) error {
 
 // InitSpatialExtension installs and loads the spatial extension
 func InitSpatialExtension(filename string) error {
-	// Get absolute path
-	absPath, err := filepath.Abs(filename)
+	db, err := Open(filename)
 	if err != nil {
-		return fmt.Errorf("failed to resolve absolute path: %w", err)
+		return err
 	}
+	defer db.Close()
 
-	// Open the database
-	db, err := sql.Open("duckdb", absPath)
+	return LoadExtension(db, "spatial")
+}
+
+// openWithSpatial opens the database and loads the spatial extension.
+// Callers are responsible for closing the returned connection.
+func openWithSpatial(filename string) (*sql.DB, error) {
+	db, err := Open(filename)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-	defer db.Close()
 
-	// Install spatial extension
-	_, err = db.Exec("INSTALL spatial;")
-	if err != nil {
-		return fmt.Errorf("failed to install spatial extension: %w", err)
+	if err := LoadSpatial(db); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	// Load spatial extension
-	_, err = db.Exec("LOAD spatial;")
+	return db, nil
+}
+
+// withTempTables runs fn in a transaction, committing when it returns nil.
+// Temporary tables only live on the connection that created them, and the
+// transaction keeps all of fn's statements on one.
+func withTempTables(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to load spatial extension: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
 	return nil
 }
 
@@ -83,26 +200,49 @@ type Column struct {
 	Type string
 }
 
-// TableExists checks if a table exists in the database
-func TableExists(dbPath, tableName string) (bool, error) {
-	absPath, err := filepath.Abs(dbPath)
-	if err != nil {
-		return false, fmt.Errorf("failed to resolve absolute path: %w", err)
+// SplitTableName splits an optionally schema-qualified name ("staging.roads")
+// into its schema and table parts. Unqualified names belong to the main schema.
+func SplitTableName(name string) (schema, table string) {
+	if i := strings.Index(name, "."); i > 0 && i < len(name)-1 {
+		return name[:i], name[i+1:]
+	}
+	return "main", name
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// EnsureSchema creates the schema of a qualified table name if it doesn't exist yet
+func EnsureSchema(db Execer, tableName string) error {
+	schema, _ := SplitTableName(tableName)
+	if schema == "main" {
+		return nil
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", QuoteIdentifier(schema))); err != nil {
+		return fmt.Errorf("failed to create schema %s: %w", schema, err)
 	}
+	return nil
+}
 
-	db, err := sql.Open("duckdb", absPath)
+// TableExists checks if a table exists in the database
+func TableExists(dbPath, tableName string) (bool, error) {
+	db, err := Open(dbPath)
 	if err != nil {
-		return false, fmt.Errorf("failed to open database: %w", err)
+		return false, err
 	}
 	defer db.Close()
 
 	var exists bool
+	schema, table := SplitTableName(tableName)
 	query := `
 		SELECT COUNT(*) > 0
 		FROM information_schema.tables
-		WHERE table_name = ?
+		WHERE table_schema = ? AND table_name = ?
 	`
-	err = db.QueryRow(query, tableName).Scan(&exists)
+	err = db.QueryRow(query, schema, table).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check table existence: %w", err)
 	}
@@ -112,24 +252,20 @@ func TableExists(dbPath, tableName string) (bool, error) {
 
 // GetTableSchema returns the schema of a table
 func GetTableSchema(dbPath, tableName string) ([]Column, error) {
-	absPath, err := filepath.Abs(dbPath)
+	db, err := Open(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve absolute path: %w", err)
-	}
-
-	db, err := sql.Open("duckdb", absPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 	defer db.Close()
 
+	schema, table := SplitTableName(tableName)
 	query := `
 		SELECT column_name, data_type
 		FROM information_schema.columns
-		WHERE table_name = ?
+		WHERE table_schema = ? AND table_name = ?
 		ORDER BY ordinal_position
 	`
-	rows, err := db.Query(query, tableName)
+	rows, err := db.Query(query, schema, table)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query table schema: %w", err)
 	}
@@ -150,3 +286,39 @@ func GetTableSchema(dbPath, tableName string) ([]Column, error) {
 
 	return columns, nil
 }
+
+// TableBBox returns the extent of a table's first GEOMETRY column as minx,
+// miny, maxx, maxy, or nil when it has no geometry column or no geometries
+func TableBBox(dbPath, tableName string) ([]float64, error) {
+	columns, err := GetTableSchema(dbPath, tableName)
+	if err != nil {
+		return nil, err
+	}
+	var geomColumn string
+	for _, col := range columns {
+		if strings.HasPrefix(strings.ToUpper(col.Type), "GEOMETRY") {
+			geomColumn = col.Name
+			break
+		}
+	}
+	if geomColumn == "" {
+		return nil, nil
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	q := QuoteIdentifier(geomColumn)
+	var xmin, ymin, xmax, ymax sql.NullFloat64
+	bboxSQL := fmt.Sprintf("SELECT MIN(ST_XMin(%s)), MIN(ST_YMin(%s)), MAX(ST_XMax(%s)), MAX(ST_YMax(%s)) FROM %s", q, q, q, q, QuoteTableName(tableName))
+	if err := db.QueryRow(bboxSQL).Scan(&xmin, &ymin, &xmax, &ymax); err != nil {
+		return nil, fmt.Errorf("failed to compute bounding box: %w", err)
+	}
+	if !xmin.Valid {
+		return nil, nil
+	}
+	return []float64{xmin.Float64, ymin.Float64, xmax.Float64, ymax.Float64}, nil
+}
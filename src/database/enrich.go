@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+)
+
+// TimezoneOptions configures timezone enrichment
+type TimezoneOptions struct {
+	// ZonesTable holds timezone boundary polygons
+	ZonesTable string
+	// ZoneColumn is the column in ZonesTable with the IANA zone name
+	ZoneColumn string
+	// TargetColumn receives the IANA zone name
+	TargetColumn string
+	// TimestampColumn is an optional UTC timestamp column to convert to local time
+	TimestampColumn string
+	// LocalColumn receives the converted local time
+	LocalColumn string
+}
+
+// EnrichTimezone assigns an IANA timezone to each feature by point-in-polygon
+// lookup against a timezone boundaries table and returns the number of matched rows
+func EnrichTimezone(dbPath, tableName string, opts TimezoneOptions) (int, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// ICU provides the named timezone conversions
+	if _, err := db.Exec("LOAD icu;"); err != nil {
+		return 0, fmt.Errorf("failed to load icu extension: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := QuoteTableName(tableName)
+	target := QuoteIdentifier(opts.TargetColumn)
+
+	addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s VARCHAR", table, target)
+	if _, err := tx.Exec(addSQL); err != nil {
+		return 0, fmt.Errorf("failed to add %s column: %w", opts.TargetColumn, err)
+	}
+
+	// Use a point on the surface so non-point features still land in exactly one zone
+	updateSQL := fmt.Sprintf(`
+		UPDATE %s SET %s = (
+			SELECT z.%s
+			FROM %s z
+			WHERE ST_Intersects(z.geom, ST_PointOnSurface(%s.geom))
+			LIMIT 1
+		)
+	`, table, target, QuoteIdentifier(opts.ZoneColumn), QuoteTableName(opts.ZonesTable), table)
+	if _, err := tx.Exec(updateSQL); err != nil {
+		return 0, fmt.Errorf("failed to assign timezones: %w", err)
+	}
+
+	if opts.TimestampColumn != "" {
+		local := QuoteIdentifier(opts.LocalColumn)
+		addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s TIMESTAMP", table, local)
+		if _, err := tx.Exec(addSQL); err != nil {
+			return 0, fmt.Errorf("failed to add %s column: %w", opts.LocalColumn, err)
+		}
+
+		// Naive timestamps are treated as UTC before shifting into the feature's zone
+		convertSQL := fmt.Sprintf(`
+			UPDATE %s SET %s = timezone(%s, CAST(%s AS TIMESTAMP) AT TIME ZONE 'UTC')
+			WHERE %s IS NOT NULL
+		`, table, local, target, QuoteIdentifier(opts.TimestampColumn), target)
+		if _, err := tx.Exec(convertSQL); err != nil {
+			return 0, fmt.Errorf("failed to convert %s to local time: %w", opts.TimestampColumn, err)
+		}
+	}
+
+	var matched int
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NOT NULL", table, target)
+	if err := tx.QueryRow(countSQL).Scan(&matched); err != nil {
+		return 0, fmt.Errorf("failed to count matched rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return matched, nil
+}
@@ -0,0 +1,328 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExportOptions controls how a table is written to a file
+type ExportOptions struct {
+	// SRS is the CRS recorded in the output's metadata (default EPSG:4326)
+	SRS string
+	// Overwrite replaces an existing output file
+	Overwrite bool
+	// Where is a SQL condition selecting the rows to export
+	Where string
+	// Limit caps the number of rows exported; 0 exports every row
+	Limit int
+	// Measures adds a geodesic measurement column each, in Unit
+	Measures []Measure
+	Unit     Unit
+}
+
+// ExportTable writes a table to output in the given format and returns the
+// number of rows written. GDAL formats need exactly one GEOMETRY column;
+// Parquet output with GEOMETRY columns is written as GeoParquet. Parquet and
+// CSV can also be written to an s3:// URL.
+func ExportTable(dbPath, tableName, output string, format Format, opts ExportOptions) (int64, error) {
+	if opts.SRS == "" {
+		opts.SRS = "EPSG:4326"
+	}
+
+	columns, err := GetTableSchema(dbPath, tableName)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("table not found: %s", tableName)
+	}
+
+	var geomColumns []string
+	for _, col := range columns {
+		if strings.HasPrefix(col.Type, "GEOMETRY") {
+			geomColumns = append(geomColumns, col.Name)
+		}
+	}
+	if format.Driver != "" && len(geomColumns) != 1 {
+		return 0, fmt.Errorf("%s export needs exactly one GEOMETRY column, %s has %d", format.Name, tableName, len(geomColumns))
+	}
+
+	if len(opts.Measures) > 0 {
+		if len(geomColumns) != 1 {
+			return 0, fmt.Errorf("measuring needs exactly one GEOMETRY column, %s has %d", tableName, len(geomColumns))
+		}
+		if opts.Unit.Name == "" {
+			opts.Unit = units[0]
+		}
+		for _, m := range opts.Measures {
+			for _, col := range columns {
+				if strings.EqualFold(col.Name, m.Column(opts.Unit)) {
+					return 0, fmt.Errorf("%s already has a column %s", tableName, col.Name)
+				}
+			}
+		}
+	}
+
+	remote := IsS3(output)
+	if IsRemote(output) && !remote {
+		return 0, fmt.Errorf("can't write to %s; only s3:// URLs can be exported to", output)
+	}
+	if remote && format.Driver != "" {
+		return 0, fmt.Errorf("%s can't be written to %s; export Parquet or CSV, or write a local file", format.Name, output)
+	}
+	absOutput := output
+	if !remote {
+		if absOutput, err = filepath.Abs(output); err != nil {
+			return 0, fmt.Errorf("failed to resolve output path: %w", err)
+		}
+	}
+	if !remote && FileExists(absOutput) {
+		if !opts.Overwrite {
+			return 0, fmt.Errorf("output file %s already exists (use --overwrite to replace it)", output)
+		}
+		if err := os.Remove(absOutput); err != nil {
+			return 0, fmt.Errorf("failed to remove existing output: %w", err)
+		}
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if remote {
+		if err := ConfigureHTTP(db, output); err != nil {
+			return 0, err
+		}
+		if !opts.Overwrite {
+			var existing int
+			if err := db.QueryRow("SELECT COUNT(*) FROM glob(" + QuoteLiteral(output) + ")").Scan(&existing); err != nil {
+				return 0, fmt.Errorf("failed to check for an existing %s: %w", output, err)
+			}
+			if existing > 0 {
+				return 0, fmt.Errorf("output file %s already exists (use --overwrite to replace it)", output)
+			}
+		}
+	}
+
+	// Rows deleted from a soft-delete table are left out, and so is the
+	// column marking them
+	soft, err := IsSoftDelete(db, tableName)
+	if err != nil {
+		return 0, err
+	}
+	if soft {
+		var live []Column
+		for _, col := range columns {
+			if col.Name != SoftDeleteColumn {
+				live = append(live, col)
+			}
+		}
+		columns = live
+	}
+
+	// Filtered rows are selected once, so the metadata, the file and the
+	// count all describe the same rows. The temporary table only exists on
+	// the connection that created it, so stick to one.
+	source := QuoteTableName(tableName)
+	if opts.Where != "" || opts.Limit > 0 || soft {
+		db.SetMaxOpenConns(1)
+		selectSQL := "SELECT * FROM " + source
+		var conditions []string
+		if soft {
+			column := QuoteIdentifier(SoftDeleteColumn)
+			selectSQL = fmt.Sprintf("SELECT * EXCLUDE (%s) FROM %s", column, source)
+			conditions = append(conditions, column+" IS NULL")
+		}
+		if opts.Where != "" {
+			conditions = append(conditions, "("+opts.Where+")")
+		}
+		if len(conditions) > 0 {
+			selectSQL += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		if opts.Limit > 0 {
+			selectSQL += fmt.Sprintf(" LIMIT %d", opts.Limit)
+		}
+		source = QuoteIdentifier("xyzduck_export")
+		if _, err := db.Exec(fmt.Sprintf("CREATE TEMP TABLE %s AS %s", source, selectSQL)); err != nil {
+			return 0, fmt.Errorf("failed to select rows to export: %w", err)
+		}
+	}
+
+	selects := []string{"*"}
+	var options []string
+	if format.Name == "parquet" && len(geomColumns) > 0 {
+		// Write WKB with our own "geo" metadata so the file is valid GeoParquet
+		// whatever the spatial extension's defaults are
+		geo, err := geoParquetMetadata(db, source, geomColumns, opts.SRS)
+		if err != nil {
+			return 0, err
+		}
+		selects = nil
+		for _, col := range columns {
+			if strings.HasPrefix(col.Type, "GEOMETRY") {
+				selects = append(selects, fmt.Sprintf("ST_AsWKB(%s) AS %s", QuoteIdentifier(col.Name), QuoteIdentifier(col.Name)))
+			} else {
+				selects = append(selects, QuoteIdentifier(col.Name))
+			}
+		}
+		options = append(options, "KV_METADATA {geo: "+QuoteLiteral(geo)+"}")
+	}
+	if len(opts.Measures) > 0 {
+		selects = append(selects, measureSelects(geomColumns[0], opts.SRS, opts.Measures, opts.Unit)...)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), source)
+	if format.Driver != "" {
+		// Record the CRS and, when uniform, the geometry type in the layer metadata
+		// (gpkg_geometry_columns for GeoPackages) instead of a generic GEOMETRY
+		options = append(options, "SRS "+QuoteLiteral(opts.SRS))
+		geomType, err := uniformGeometryType(db, source, geomColumns[0])
+		if err != nil {
+			return 0, err
+		}
+		if geomType != "" {
+			options = append(options, "GEOMETRY_TYPE "+QuoteLiteral(geomType))
+		}
+	}
+
+	copySQL := format.CopySQL(query, absOutput, options...)
+	if _, err := db.Exec(copySQL); err != nil {
+		return 0, fmt.Errorf("failed to export %s: %w", tableName, err)
+	}
+
+	var count int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + source).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return count, nil
+}
+
+// uniformGeometryType returns the geometry type shared by every non-null
+// geometry in the column of source (a quoted table), or "" when they differ
+func uniformGeometryType(db *sql.DB, source, column string) (string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT DISTINCT ST_GeometryType(%s)::VARCHAR FROM %s WHERE %s IS NOT NULL",
+		QuoteIdentifier(column), source, QuoteIdentifier(column)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read geometry types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return "", fmt.Errorf("failed to scan geometry type: %w", err)
+		}
+		types = append(types, t)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(types) != 1 {
+		return "", nil
+	}
+	return types[0], nil
+}
+
+// geoParquetVersion is the GeoParquet specification the "geo" metadata follows
+const geoParquetVersion = "1.1.0"
+
+// geoParquetTypes maps ST_GeometryType names to GeoParquet geometry types
+var geoParquetTypes = map[string]string{
+	"POINT":              "Point",
+	"LINESTRING":         "LineString",
+	"POLYGON":            "Polygon",
+	"MULTIPOINT":         "MultiPoint",
+	"MULTILINESTRING":    "MultiLineString",
+	"MULTIPOLYGON":       "MultiPolygon",
+	"GEOMETRYCOLLECTION": "GeometryCollection",
+}
+
+// geoParquetColumn is the per-column entry of the GeoParquet "geo" metadata
+type geoParquetColumn struct {
+	Encoding      string         `json:"encoding"`
+	GeometryTypes []string       `json:"geometry_types"`
+	BBox          []float64      `json:"bbox,omitempty"`
+	CRS           map[string]any `json:"crs,omitempty"`
+}
+
+// geoParquetMetadata builds the "geo" file metadata for the geometry columns of
+// source (a quoted table): WKB encoding, the geometry types present, the
+// bounding box and the CRS. The first column is the primary one.
+func geoParquetMetadata(db *sql.DB, source string, geomColumns []string, srs string) (string, error) {
+	crs, err := geoParquetCRS(srs)
+	if err != nil {
+		return "", err
+	}
+
+	columns := make(map[string]geoParquetColumn)
+	for _, name := range geomColumns {
+		col := geoParquetColumn{Encoding: "WKB", GeometryTypes: []string{}, CRS: crs}
+
+		rows, err := db.Query(fmt.Sprintf("SELECT DISTINCT ST_GeometryType(%s)::VARCHAR FROM %s WHERE %s IS NOT NULL ORDER BY 1",
+			QuoteIdentifier(name), source, QuoteIdentifier(name)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read geometry types: %w", err)
+		}
+		for rows.Next() {
+			var t string
+			if err := rows.Scan(&t); err != nil {
+				rows.Close()
+				return "", fmt.Errorf("failed to scan geometry type: %w", err)
+			}
+			if gt, ok := geoParquetTypes[t]; ok {
+				col.GeometryTypes = append(col.GeometryTypes, gt)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return "", fmt.Errorf("error iterating rows: %w", err)
+		}
+
+		q := QuoteIdentifier(name)
+		var xmin, ymin, xmax, ymax sql.NullFloat64
+		bboxSQL := fmt.Sprintf("SELECT MIN(ST_XMin(%s)), MIN(ST_YMin(%s)), MAX(ST_XMax(%s)), MAX(ST_YMax(%s)) FROM %s", q, q, q, q, source)
+		if err := db.QueryRow(bboxSQL).Scan(&xmin, &ymin, &xmax, &ymax); err != nil {
+			return "", fmt.Errorf("failed to compute bounding box: %w", err)
+		}
+		if xmin.Valid {
+			col.BBox = []float64{xmin.Float64, ymin.Float64, xmax.Float64, ymax.Float64}
+		}
+
+		columns[name] = col
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"version":        geoParquetVersion,
+		"primary_column": geomColumns[0],
+		"columns":        columns,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode geo metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// geoParquetCRS returns the PROJJSON identifying srs, or nil for WGS84, which
+// GeoParquet assumes (as OGC:CRS84, i.e. longitude first) when crs is omitted
+func geoParquetCRS(srs string) (map[string]any, error) {
+	if strings.EqualFold(srs, "EPSG:4326") || strings.EqualFold(srs, "OGC:CRS84") {
+		return nil, nil
+	}
+	authority, code, ok := strings.Cut(srs, ":")
+	if !ok || authority == "" || code == "" {
+		return nil, fmt.Errorf("GeoParquet export needs an AUTHORITY:CODE CRS such as EPSG:27700, got %q", srs)
+	}
+	id := map[string]any{"authority": strings.ToUpper(authority), "code": code}
+	if n, err := strconv.Atoi(code); err == nil {
+		id["code"] = n
+	}
+	return map[string]any{"id": id}, nil
+}
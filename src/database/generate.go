@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// GreatCircleOptions names the coordinate columns used to build great-circle lines
+type GreatCircleOptions struct {
+	OriginLon string
+	OriginLat string
+	DestLon   string
+	DestLat   string
+	// Segments is the number of line segments per arc
+	Segments int
+}
+
+// GreatCircleResult summarizes the arcs generated
+type GreatCircleResult struct {
+	Arcs int
+	// Antipodal counts rows left out because their origin and destination
+	// are opposite points, joined by every great circle through them
+	Antipodal int
+}
+
+// GenerateGreatCircles creates outTable with the rows of srcTable plus a geom
+// column holding the great-circle arc between the origin and destination
+// coordinates. Rows without an arc, lacking a coordinate or antipodal, are
+// left out.
+func GenerateGreatCircles(dbPath, srcTable, outTable string, opts GreatCircleOptions) (GreatCircleResult, error) {
+	var result GreatCircleResult
+	if opts.Segments < 1 {
+		return result, fmt.Errorf("segments must be at least 1")
+	}
+
+	columns, err := GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return result, err
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	err = withTempTables(db, func(tx *sql.Tx) error {
+		lon1, lat1 := QuoteIdentifier(opts.OriginLon), QuoteIdentifier(opts.OriginLat)
+		lon2, lat2 := QuoteIdentifier(opts.DestLon), QuoteIdentifier(opts.DestLat)
+		selectSQL := fmt.Sprintf(`
+			SELECT rowid, %s, %s, %s, %s FROM %s
+			WHERE %s IS NOT NULL AND %s IS NOT NULL AND %s IS NOT NULL AND %s IS NOT NULL
+		`, lon1, lat1, lon2, lat2, QuoteTableName(srcTable), lon1, lat1, lon2, lat2)
+
+		rows, err := tx.Query(selectSQL)
+		if err != nil {
+			return fmt.Errorf("failed to read coordinates: %w", err)
+		}
+
+		type arc struct {
+			rowID int64
+			wkt   string
+		}
+		var arcs []arc
+		for rows.Next() {
+			var rowID int64
+			var lon1, lat1, lon2, lat2 float64
+			if err := rows.Scan(&rowID, &lon1, &lat1, &lon2, &lat2); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan coordinates: %w", err)
+			}
+			points, ok := greatCirclePoints(lon1, lat1, lon2, lat2, opts.Segments)
+			if !ok {
+				result.Antipodal++
+				continue
+			}
+			arcs = append(arcs, arc{rowID: rowID, wkt: lineWKT(points)})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating rows: %w", err)
+		}
+
+		if _, err := tx.Exec("CREATE TEMPORARY TABLE temp_arcs (rid BIGINT, wkt VARCHAR)"); err != nil {
+			return fmt.Errorf("failed to create temporary table: %w", err)
+		}
+		defer tx.Exec("DROP TABLE IF EXISTS temp_arcs")
+
+		stmt, err := tx.Prepare("INSERT INTO temp_arcs VALUES (?, ?)")
+		if err != nil {
+			return fmt.Errorf("failed to prepare insert: %w", err)
+		}
+		for _, a := range arcs {
+			if _, err := stmt.Exec(a.rowID, a.wkt); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to insert arc: %w", err)
+			}
+		}
+		stmt.Close()
+
+		if err := EnsureSchema(tx, outTable); err != nil {
+			return err
+		}
+
+		selectCols := sourceColumns("s", columns)
+		if selectCols != "" {
+			selectCols += ", "
+		}
+		createSQL := fmt.Sprintf(`
+			CREATE TABLE %s AS
+			SELECT %sST_GeomFromText(a.wkt) AS geom
+			FROM %s s JOIN temp_arcs a ON s.rowid = a.rid
+		`, QuoteTableName(outTable), selectCols, QuoteTableName(srcTable))
+		if _, err := tx.Exec(createSQL); err != nil {
+			return fmt.Errorf("failed to create %s: %w", outTable, err)
+		}
+		result.Arcs = len(arcs)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// TrackOptions configures building tracks from ordered points
+type TrackOptions struct {
+	// GroupBy identifies the moving object (vessel, flight, device)
+	GroupBy string
+	// OrderBy sorts the points within a track, usually a timestamp
+	OrderBy string
+}
+
+// GenerateTracks builds one LINESTRING per group from an ordered point table and
+// returns the number of tracks created. Groups with fewer than two points are skipped.
+func GenerateTracks(dbPath, srcTable, outTable string, opts TrackOptions) (int, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := EnsureSchema(db, outTable); err != nil {
+		return 0, err
+	}
+
+	groupBy, orderBy := QuoteIdentifier(opts.GroupBy), QuoteIdentifier(opts.OrderBy)
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE %s AS
+		SELECT
+			%s,
+			COUNT(*) AS point_count,
+			MIN(%s) AS started,
+			MAX(%s) AS ended,
+			ST_MakeLine(list(geom ORDER BY %s)) AS geom
+		FROM %s
+		WHERE geom IS NOT NULL
+		GROUP BY %s
+		HAVING COUNT(*) >= 2
+	`, QuoteTableName(outTable), groupBy, orderBy, orderBy, orderBy, QuoteTableName(srcTable), groupBy)
+	if _, err := db.Exec(createSQL); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", outTable, err)
+	}
+
+	var count int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteTableName(outTable))).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count tracks: %w", err)
+	}
+
+	return count, nil
+}
+
+// sourceColumns lists a table's columns for a SELECT, leaving out any existing geom column
+func sourceColumns(alias string, columns []Column) string {
+	var names []string
+	for _, col := range columns {
+		if strings.EqualFold(col.Name, "geom") {
+			continue
+		}
+		names = append(names, alias+"."+QuoteIdentifier(col.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// antipodalEpsilon is how close to π, in radians, an angular distance makes
+// two points antipodal; 1e-9 rad is a few millimetres on the ground
+const antipodalEpsilon = 1e-9
+
+// greatCirclePoints interpolates points along the great circle between two
+// lon/lat positions. Longitudes are unwrapped so arcs crossing the antimeridian
+// stay continuous instead of jumping across the map. It reports false for
+// antipodal points, where the arc's direction is undefined.
+func greatCirclePoints(lon1, lat1, lon2, lat2 float64, segments int) ([][2]float64, bool) {
+	toRad := math.Pi / 180
+	phi1, lam1 := lat1*toRad, lon1*toRad
+	phi2, lam2 := lat2*toRad, lon2*toRad
+
+	// Angular distance via the haversine formula
+	d := 2 * math.Asin(math.Sqrt(math.Pow(math.Sin((phi2-phi1)/2), 2)+
+		math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin((lam2-lam1)/2), 2)))
+
+	if d == 0 {
+		return [][2]float64{{lon1, lat1}, {lon2, lat2}}, true
+	}
+	// Near d = π, sin(d) vanishes and the interpolation is noise
+	if math.Pi-d < antipodalEpsilon {
+		return nil, false
+	}
+
+	points := make([][2]float64, 0, segments+1)
+	for i := 0; i <= segments; i++ {
+		f := float64(i) / float64(segments)
+		a := math.Sin((1-f)*d) / math.Sin(d)
+		b := math.Sin(f*d) / math.Sin(d)
+		x := a*math.Cos(phi1)*math.Cos(lam1) + b*math.Cos(phi2)*math.Cos(lam2)
+		y := a*math.Cos(phi1)*math.Sin(lam1) + b*math.Cos(phi2)*math.Sin(lam2)
+		z := a*math.Sin(phi1) + b*math.Sin(phi2)
+
+		lat := math.Atan2(z, math.Sqrt(x*x+y*y)) / toRad
+		lon := math.Atan2(y, x) / toRad
+
+		if len(points) > 0 {
+			prev := points[len(points)-1][0]
+			for lon-prev > 180 {
+				lon -= 360
+			}
+			for lon-prev < -180 {
+				lon += 360
+			}
+		}
+		points = append(points, [2]float64{lon, lat})
+	}
+
+	return points, true
+}
+
+// lineWKT formats points as a WKT LINESTRING
+func lineWKT(points [][2]float64) string {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.7f %.7f", p[0], p[1])
+	}
+	return "LINESTRING (" + strings.Join(coords, ", ") + ")"
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// GeometryCheck decides what a load does with geometries ST_IsValid rejects
+type GeometryCheck string
+
+const (
+	// GeometryCheckOff loads geometries without checking them
+	GeometryCheckOff GeometryCheck = ""
+	// GeometryCheckSkip drops features with invalid geometries
+	GeometryCheckSkip GeometryCheck = "skip"
+	// GeometryCheckRepair fixes invalid geometries with ST_MakeValid
+	GeometryCheckRepair GeometryCheck = "repair"
+	// GeometryCheckFail rejects the load when any geometry is invalid
+	GeometryCheckFail GeometryCheck = "fail"
+)
+
+// ParseGeometryCheck converts a --validate flag value into a GeometryCheck
+func ParseGeometryCheck(s string) (GeometryCheck, error) {
+	switch check := GeometryCheck(strings.ToLower(strings.TrimSpace(s))); check {
+	case GeometryCheckOff, GeometryCheckSkip, GeometryCheckRepair, GeometryCheckFail:
+		return check, nil
+	}
+	return GeometryCheckOff, fmt.Errorf("invalid --validate %q (must be skip, repair or fail)", s)
+}
+
+// GeometryReport counts what a geometry check did
+type GeometryReport struct {
+	Invalid  int64
+	Repaired int64
+	Dropped  int64
+}
+
+// Add adds the counts of another batch
+func (r *GeometryReport) Add(other GeometryReport) {
+	r.Invalid += other.Invalid
+	r.Repaired += other.Repaired
+	r.Dropped += other.Dropped
+}
+
+// QueryExecer runs statements in a context, like *sql.Conn and *sql.Tx
+type QueryExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Apply checks the geometries of a staging table before its rows are
+// inserted, deleting or repairing the invalid ones in place. column holds
+// GEOMETRY values, or WKB when wkb is set.
+func (c GeometryCheck) Apply(ctx context.Context, db QueryExecer, table, column string, wkb bool) (GeometryReport, error) {
+	var report GeometryReport
+	if c == GeometryCheckOff {
+		return report, nil
+	}
+
+	geom := QuoteIdentifier(column)
+	if wkb {
+		geom = fmt.Sprintf("ST_GeomFromWKB(%s)", geom)
+	}
+	invalid := fmt.Sprintf("%s IS NOT NULL AND NOT ST_IsValid(%s)", QuoteIdentifier(column), geom)
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, invalid)).Scan(&report.Invalid); err != nil {
+		return report, fmt.Errorf("failed to validate geometries: %w", err)
+	}
+	if report.Invalid == 0 {
+		return report, nil
+	}
+
+	switch c {
+	case GeometryCheckFail:
+		return report, fmt.Errorf("%d invalid geometries (use --validate skip or repair to load them)", report.Invalid)
+	case GeometryCheckSkip:
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", table, invalid)); err != nil {
+			return report, fmt.Errorf("failed to drop invalid geometries: %w", err)
+		}
+		report.Dropped = report.Invalid
+	case GeometryCheckRepair:
+		repaired := fmt.Sprintf("ST_MakeValid(%s)", geom)
+		if wkb {
+			repaired = fmt.Sprintf("ST_AsWKB(%s)", repaired)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s", table, QuoteIdentifier(column), repaired, invalid)); err != nil {
+			return report, fmt.Errorf("failed to repair invalid geometries: %w", err)
+		}
+		report.Repaired = report.Invalid
+	}
+	return report, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import "fmt"
+
+// HullMethod selects how the footprint of a group of points is drawn
+type HullMethod string
+
+const (
+	// HullConvex is the smallest convex polygon containing the points
+	HullConvex HullMethod = "convex"
+	// HullConcave follows the outline of the points more closely, by how much
+	// set by HullOptions.Alpha
+	HullConcave HullMethod = "concave"
+)
+
+// ParseHullMethod parses the --method flag
+func ParseHullMethod(s string) (HullMethod, error) {
+	switch HullMethod(s) {
+	case HullConvex, HullConcave:
+		return HullMethod(s), nil
+	}
+	return "", fmt.Errorf("invalid hull method %q (expected convex or concave)", s)
+}
+
+// HullOptions configures building footprint polygons from points
+type HullOptions struct {
+	// GroupBy builds one hull per value of this column; empty builds one
+	// hull of every point
+	GroupBy string
+	Method  HullMethod
+	// Alpha is the concave hull's edge length ratio, from 0 (tightest) to 1
+	// (the convex hull)
+	Alpha float64
+	// AllowHoles lets concave hulls keep empty areas inside them as holes
+	AllowHoles bool
+}
+
+// GenerateHulls creates outTable with one polygon per group of geometries in
+// srcTable, plus point_count, and returns the number of hulls created. Groups
+// of fewer than three points, or of points in a line, give a point or line.
+func GenerateHulls(dbPath, srcTable, outTable string, opts HullOptions) (int, error) {
+	if opts.Alpha < 0 || opts.Alpha > 1 {
+		return 0, fmt.Errorf("alpha must be between 0 and 1")
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := EnsureSchema(db, outTable); err != nil {
+		return 0, err
+	}
+
+	hull := "ST_ConvexHull(ST_Collect(list(geom)))"
+	if opts.Method == HullConcave {
+		hull = fmt.Sprintf("ST_ConcaveHull(ST_Collect(list(geom)), %g, %t)", opts.Alpha, opts.AllowHoles)
+	}
+
+	groupCol, groupBy := "", ""
+	if opts.GroupBy != "" {
+		groupCol = QuoteIdentifier(opts.GroupBy) + ", "
+		groupBy = "GROUP BY " + QuoteIdentifier(opts.GroupBy)
+	}
+
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE %s AS
+		SELECT
+			%sCOUNT(*) AS point_count,
+			%s AS geom
+		FROM %s
+		WHERE geom IS NOT NULL
+		%s
+	`, QuoteTableName(outTable), groupCol, hull, QuoteTableName(srcTable), groupBy)
+	if _, err := db.Exec(createSQL); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", outTable, err)
+	}
+
+	var count int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteTableName(outTable))).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count hulls: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// BearingOptions configures line bearing columns
+type BearingOptions struct {
+	// Column receives the azimuth from the first to the last point
+	Column string
+	// Ends also stores the azimuth of the first and last segment in
+	// <Column>_start and <Column>_end
+	Ends bool
+	// Planar measures azimuths on the plane of a projected CRS instead of
+	// along great circles between lon/lat positions
+	Planar bool
+}
+
+// LineBearings stores the azimuth of each LINESTRING in degrees clockwise
+// from north, 0 to 360, and returns the number of lines that received one.
+// Other geometries, and lines starting where they end, are left NULL.
+func LineBearings(dbPath, tableName string, opts BearingOptions) (int64, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// The macro only lives on one connection, so keep everything in a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(azimuthMacroSQL(opts.Planar)); err != nil {
+		return 0, fmt.Errorf("failed to create azimuth macro: %w", err)
+	}
+	defer tx.Exec("DROP MACRO IF EXISTS xyzduck_azimuth")
+
+	table := QuoteTableName(tableName)
+	// Each column measures from one point of the line to another
+	targets := map[string][2]string{
+		opts.Column: {"ST_StartPoint(geom)", "ST_EndPoint(geom)"},
+	}
+	columns := []string{opts.Column}
+	if opts.Ends {
+		start, end := opts.Column+"_start", opts.Column+"_end"
+		targets[start] = [2]string{"ST_PointN(geom, 1)", "ST_PointN(geom, 2)"}
+		targets[end] = [2]string{"ST_PointN(geom, ST_NPoints(geom)::INTEGER - 1)", "ST_PointN(geom, ST_NPoints(geom)::INTEGER)"}
+		columns = append(columns, start, end)
+	}
+
+	for _, col := range columns {
+		addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s DOUBLE", table, QuoteIdentifier(col))
+		if _, err := tx.Exec(addSQL); err != nil {
+			return 0, fmt.Errorf("failed to add %s column: %w", col, err)
+		}
+		from, to := targets[col][0], targets[col][1]
+		updateSQL := fmt.Sprintf(`
+			UPDATE %s SET %s = CASE WHEN ST_GeometryType(geom)::VARCHAR = 'LINESTRING'
+				THEN xyzduck_azimuth(ST_X(%s), ST_Y(%s), ST_X(%s), ST_Y(%s)) END
+		`, table, QuoteIdentifier(col), from, from, to, to)
+		if _, err := tx.Exec(updateSQL); err != nil {
+			return 0, fmt.Errorf("failed to compute %s: %w", col, err)
+		}
+	}
+
+	var count int64
+	countSQL := fmt.Sprintf("SELECT COUNT(%s) FROM %s", QuoteIdentifier(opts.Column), table)
+	if err := tx.QueryRow(countSQL).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count bearings: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return count, nil
+}
+
+// azimuthMacroSQL defines xyzduck_azimuth(x1, y1, x2, y2), the azimuth from
+// the first position to the second. The geodesic variant is the initial
+// bearing on a sphere.
+func azimuthMacroSQL(planar bool) string {
+	angle := `atan2(
+		sin(radians(x2 - x1)) * cos(radians(y2)),
+		cos(radians(y1)) * sin(radians(y2)) - sin(radians(y1)) * cos(radians(y2)) * cos(radians(x2 - x1)))`
+	if planar {
+		angle = "atan2(x2 - x1, y2 - y1)"
+	}
+	return fmt.Sprintf(`
+		CREATE OR REPLACE TEMPORARY MACRO xyzduck_azimuth(x1, y1, x2, y2) AS
+			CASE WHEN x1 = x2 AND y1 = y2 THEN NULL ELSE (degrees(%s) + 360) %% 360 END
+	`, angle)
+}
+
+// ReverseLines reverses the vertex order of the lines matching where, or of
+// every geometry when where is empty, and returns the number of rows changed
+func ReverseLines(dbPath, tableName, where string) (int64, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	updateSQL := fmt.Sprintf("UPDATE %s SET geom = ST_Reverse(geom) WHERE geom IS NOT NULL", QuoteTableName(tableName))
+	if where != "" {
+		updateSQL += fmt.Sprintf(" AND (%s)", where)
+	}
+	res, err := db.Exec(updateSQL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reverse lines: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// OrientOptions configures the digitization direction check
+type OrientOptions struct {
+	// GridSize snaps endpoints before matching them, so lines whose ends
+	// differ by numeric noise still connect
+	GridSize float64
+	// FlagColumn, when set, receives true for lines digitized against their
+	// chain and false for the other lines
+	FlagColumn string
+	// Fix reverses the lines digitized against their chain
+	Fix bool
+}
+
+// OrientResult summarizes a digitization direction check
+type OrientResult struct {
+	Lines  int
+	Chains int
+	// Inconsistent counts lines pointing against the rest of their chain
+	Inconsistent int
+}
+
+// OrientLines finds LINESTRINGs digitized against the direction of the lines
+// they connect to. Lines meeting end to end, where no third line joins, form
+// a chain that should run one way; in each chain the direction most lines
+// follow wins and the others are reported, flagged or reversed.
+func OrientLines(dbPath, tableName string, opts OrientOptions) (OrientResult, error) {
+	var result OrientResult
+	if opts.GridSize <= 0 {
+		return result, fmt.Errorf("grid size must be positive")
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	err = withTempTables(db, func(tx *sql.Tx) error {
+		table := QuoteTableName(tableName)
+		rowIDs, ends, err := readLineEnds(tx, table, opts.GridSize)
+		if err != nil {
+			return err
+		}
+
+		flip, chains := orientChains(ends)
+		result.Lines, result.Chains = len(ends), chains
+		var flipped []int64
+		for i, f := range flip {
+			if f {
+				flipped = append(flipped, rowIDs[i])
+			}
+		}
+		result.Inconsistent = len(flipped)
+
+		if opts.FlagColumn == "" && (!opts.Fix || len(flipped) == 0) {
+			return nil
+		}
+
+		if _, err := tx.Exec("CREATE TEMPORARY TABLE temp_flipped (rid BIGINT)"); err != nil {
+			return fmt.Errorf("failed to create temporary table: %w", err)
+		}
+		defer tx.Exec("DROP TABLE IF EXISTS temp_flipped")
+
+		stmt, err := tx.Prepare("INSERT INTO temp_flipped VALUES (?)")
+		if err != nil {
+			return fmt.Errorf("failed to prepare insert: %w", err)
+		}
+		for _, rid := range flipped {
+			if _, err := stmt.Exec(rid); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to record line: %w", err)
+			}
+		}
+		stmt.Close()
+
+		if opts.FlagColumn != "" {
+			flag := QuoteIdentifier(opts.FlagColumn)
+			addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s BOOLEAN", table, flag)
+			if _, err := tx.Exec(addSQL); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", opts.FlagColumn, err)
+			}
+			updateSQL := fmt.Sprintf(`
+				UPDATE %s SET %s = CASE WHEN ST_GeometryType(geom)::VARCHAR = 'LINESTRING'
+					THEN rowid IN (SELECT rid FROM temp_flipped) END
+			`, table, flag)
+			if _, err := tx.Exec(updateSQL); err != nil {
+				return fmt.Errorf("failed to flag lines: %w", err)
+			}
+		}
+
+		if opts.Fix {
+			updateSQL := fmt.Sprintf("UPDATE %s SET geom = ST_Reverse(geom) WHERE rowid IN (SELECT rid FROM temp_flipped)", table)
+			if _, err := tx.Exec(updateSQL); err != nil {
+				return fmt.Errorf("failed to reverse lines: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// lineEnds holds the snapped first and last point of a line
+type lineEnds struct {
+	start, end [2]int64
+}
+
+// readLineEnds returns the rowid and snapped endpoints of every LINESTRING
+func readLineEnds(tx *sql.Tx, table string, gridSize float64) ([]int64, []lineEnds, error) {
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT rowid, ST_X(ST_StartPoint(geom)), ST_Y(ST_StartPoint(geom)), ST_X(ST_EndPoint(geom)), ST_Y(ST_EndPoint(geom))
+		FROM %s
+		WHERE ST_GeometryType(geom)::VARCHAR = 'LINESTRING' AND NOT ST_IsEmpty(geom)
+	`, table))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read line endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	snap := func(x, y float64) [2]int64 {
+		return [2]int64{int64(math.Round(x / gridSize)), int64(math.Round(y / gridSize))}
+	}
+
+	var rowIDs []int64
+	var ends []lineEnds
+	for rows.Next() {
+		var rid int64
+		var x1, y1, x2, y2 float64
+		if err := rows.Scan(&rid, &x1, &y1, &x2, &y2); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan line endpoints: %w", err)
+		}
+		rowIDs = append(rowIDs, rid)
+		ends = append(ends, lineEnds{start: snap(x1, y1), end: snap(x2, y2)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return rowIDs, ends, nil
+}
+
+// orientChains decides which lines to reverse so that every chain runs one
+// way, and returns the decision per line and the number of chains. Only
+// nodes where exactly two line ends meet link lines into a chain; at
+// junctions and dead ends there is no direction to agree on.
+func orientChains(lines []lineEnds) ([]bool, int) {
+	type endRef struct {
+		line    int
+		atStart bool
+	}
+	nodes := make(map[[2]int64][]endRef)
+	for i, l := range lines {
+		nodes[l.start] = append(nodes[l.start], endRef{i, true})
+		nodes[l.end] = append(nodes[l.end], endRef{i, false})
+	}
+
+	flip := make([]bool, len(lines))
+	seen := make([]bool, len(lines))
+	chains := 0
+	for first := range lines {
+		if seen[first] {
+			continue
+		}
+		chains++
+		seen[first] = true
+		chain := []int{first}
+		for queue := []int{first}; len(queue) > 0; queue = queue[1:] {
+			l := queue[0]
+			for _, atStart := range []bool{true, false} {
+				node := lines[l].end
+				if atStart {
+					node = lines[l].start
+				}
+				refs := nodes[node]
+				if len(refs) != 2 {
+					continue
+				}
+				other := refs[0]
+				if other.line == l && other.atStart == atStart {
+					other = refs[1]
+				}
+				if other.line == l || seen[other.line] {
+					continue
+				}
+				// Where this line starts, as oriented, the other must end and
+				// the other way round
+				startsHere := atStart != flip[l]
+				flip[other.line] = other.atStart == startsHere
+				seen[other.line] = true
+				queue = append(queue, other.line)
+				chain = append(chain, other.line)
+			}
+		}
+
+		// Keep the direction most of the chain already has
+		reversed := 0
+		for _, l := range chain {
+			if flip[l] {
+				reversed++
+			}
+		}
+		if 2*reversed > len(chain) {
+			for _, l := range chain {
+				flip[l] = !flip[l]
+			}
+		}
+	}
+	return flip, chains
+}
@@ -0,0 +1,539 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+// RouteOptions describes the lines events are referenced to
+type RouteOptions struct {
+	// Table holds one LINESTRING per route, or per piece of a route
+	Table string
+	// IDColumn identifies the route of each line
+	IDColumn string
+	// EventIDColumn is the events' column naming their route; defaults to
+	// IDColumn
+	EventIDColumn string
+	// StartColumn and EndColumn hold the measures at the first and last point
+	// of each line, e.g. kilometer posts. Without them measures run from 0 to
+	// the line's length.
+	StartColumn string
+	EndColumn   string
+	// Planar measures lengths in CRS units instead of meters on the sphere
+	Planar bool
+}
+
+func (o RouteOptions) eventIDColumn() string {
+	if o.EventIDColumn != "" {
+		return o.EventIDColumn
+	}
+	return o.IDColumn
+}
+
+// LRSResult summarizes placing events along routes
+type LRSResult struct {
+	Rows    int64
+	Located int64
+}
+
+// route is a line with the distance from its start to every vertex
+type route struct {
+	rowID  int64
+	points [][2]float64
+	// cum[i] is the length from the first point to points[i]
+	cum []float64
+	// from and to are the measures at either end; equal when uncalibrated
+	from, to float64
+	planar   bool
+}
+
+// length returns the total length of the route
+func (r *route) length() float64 {
+	return r.cum[len(r.cum)-1]
+}
+
+// distanceAt converts a measure to a length from the start of the route, and
+// reports whether it falls on the route
+func (r *route) distanceAt(m float64) (float64, bool) {
+	d := m
+	if r.from != r.to {
+		d = (m - r.from) / (r.to - r.from) * r.length()
+	}
+	const eps = 1e-9
+	if d < -eps || d > r.length()+eps {
+		return 0, false
+	}
+	return math.Min(math.Max(d, 0), r.length()), true
+}
+
+// measureAt converts a length from the start of the route to a measure
+func (r *route) measureAt(d float64) float64 {
+	if r.from == r.to || r.length() == 0 {
+		return d
+	}
+	return r.from + d/r.length()*(r.to-r.from)
+}
+
+// pointAt returns the point at length d from the start, and the index of the
+// vertex before it
+func (r *route) pointAt(d float64) ([2]float64, int) {
+	for i := 0; i < len(r.points)-1; i++ {
+		if d > r.cum[i+1] {
+			continue
+		}
+		seg := r.cum[i+1] - r.cum[i]
+		if seg == 0 {
+			return r.points[i], i
+		}
+		f := (d - r.cum[i]) / seg
+		a, b := r.points[i], r.points[i+1]
+		return [2]float64{a[0] + f*(b[0]-a[0]), a[1] + f*(b[1]-a[1])}, i
+	}
+	last := len(r.points) - 1
+	return r.points[last], last - 1
+}
+
+// substring returns the part of the route between two lengths from its start
+func (r *route) substring(d1, d2 float64) [][2]float64 {
+	reversed := d1 > d2
+	if reversed {
+		d1, d2 = d2, d1
+	}
+	start, i := r.pointAt(d1)
+	end, j := r.pointAt(d2)
+	points := [][2]float64{start}
+	for k := i + 1; k <= j; k++ {
+		if r.cum[k] > d1 && r.cum[k] < d2 {
+			points = append(points, r.points[k])
+		}
+	}
+	points = append(points, end)
+	if reversed {
+		for a, b := 0, len(points)-1; a < b; a, b = a+1, b-1 {
+			points[a], points[b] = points[b], points[a]
+		}
+	}
+	return points
+}
+
+// project finds the point of the route closest to p and returns its length
+// from the start and its distance from p
+func (r *route) project(p [2]float64) (along, offset float64) {
+	// Compare distances on a plane scaled to the point's latitude, which is
+	// close enough to pick the nearest segment
+	scale := 1.0
+	if !r.planar {
+		scale = math.Cos(p[1] * math.Pi / 180)
+	}
+
+	best := math.Inf(1)
+	for i := 0; i < len(r.points)-1; i++ {
+		a, b := r.points[i], r.points[i+1]
+		dx, dy := (b[0]-a[0])*scale, b[1]-a[1]
+		px, py := (p[0]-a[0])*scale, p[1]-a[1]
+		t := 0.0
+		if l2 := dx*dx + dy*dy; l2 > 0 {
+			t = math.Min(math.Max((px*dx+py*dy)/l2, 0), 1)
+		}
+		ex, ey := px-t*dx, py-t*dy
+		if d2 := ex*ex + ey*ey; d2 < best {
+			best = d2
+			q := [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+			along = r.cum[i] + segmentLength(a, q, r.planar)
+			offset = segmentLength(p, q, r.planar)
+		}
+	}
+	return along, offset
+}
+
+// segmentLength measures between two positions, in meters on the sphere for
+// lon/lat or in CRS units when planar
+func segmentLength(a, b [2]float64, planar bool) float64 {
+	if planar {
+		return math.Hypot(b[0]-a[0], b[1]-a[1])
+	}
+	toRad := math.Pi / 180
+	phi1, phi2 := a[1]*toRad, b[1]*toRad
+	h := math.Pow(math.Sin((phi2-phi1)/2), 2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin((b[0]-a[0])*toRad/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// readRoutes loads the LINESTRINGs of the routes table keyed by route id. A
+// route may consist of several lines.
+func readRoutes(tx *sql.Tx, opts RouteOptions) (map[string][]*route, map[int64]*route, error) {
+	fromExpr, toExpr := "0", "0"
+	if opts.StartColumn != "" || opts.EndColumn != "" {
+		if opts.StartColumn == "" || opts.EndColumn == "" {
+			return nil, nil, fmt.Errorf("route start and end measure columns must be given together")
+		}
+		fromExpr = fmt.Sprintf("CAST(%s AS DOUBLE)", QuoteIdentifier(opts.StartColumn))
+		toExpr = fmt.Sprintf("CAST(%s AS DOUBLE)", QuoteIdentifier(opts.EndColumn))
+	}
+
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT rowid, CAST(%s AS VARCHAR), COALESCE(%s, 0), COALESCE(%s, 0)
+		FROM %s
+		WHERE geom IS NOT NULL AND ST_GeometryType(geom)::VARCHAR = 'LINESTRING' AND ST_NPoints(geom) >= 2
+	`, QuoteIdentifier(opts.IDColumn), fromExpr, toExpr, QuoteTableName(opts.Table)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+	byID := make(map[string][]*route)
+	byRow := make(map[int64]*route)
+	for rows.Next() {
+		var id sql.NullString
+		r := &route{planar: opts.Planar}
+		if err := rows.Scan(&r.rowID, &id, &r.from, &r.to); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan route: %w", err)
+		}
+		byRow[r.rowID] = r
+		if id.Valid {
+			byID[id.String] = append(byID[id.String], r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if err := readVertices(tx, opts.Table, byRow); err != nil {
+		return nil, nil, err
+	}
+	return byID, byRow, nil
+}
+
+// readVertices fills in the points of the LINESTRINGs of table, keyed by
+// rowid in byRow
+func readVertices(tx *sql.Tx, table string, byRow map[int64]*route) error {
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT rid, ST_X(ST_PointN(geom, i)), ST_Y(ST_PointN(geom, i))
+		FROM (
+			SELECT rowid AS rid, geom, unnest(generate_series(1, ST_NPoints(geom))) AS i
+			FROM %s
+			WHERE geom IS NOT NULL AND ST_GeometryType(geom)::VARCHAR = 'LINESTRING' AND ST_NPoints(geom) >= 2
+		)
+		ORDER BY rid, i
+	`, QuoteTableName(table)))
+	if err != nil {
+		return fmt.Errorf("failed to read route vertices: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rid int64
+		var p [2]float64
+		if err := rows.Scan(&rid, &p[0], &p[1]); err != nil {
+			return fmt.Errorf("failed to scan route vertex: %w", err)
+		}
+		r := byRow[rid]
+		if r == nil {
+			continue
+		}
+		if len(r.points) == 0 {
+			r.cum = append(r.cum, 0)
+		} else {
+			r.cum = append(r.cum, r.cum[len(r.cum)-1]+segmentLength(r.points[len(r.points)-1], p, r.planar))
+		}
+		r.points = append(r.points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
+
+// lrsRow is a computed result for one source row
+type lrsRow struct {
+	rowID    int64
+	wkt      string
+	routeRow sql.NullInt64
+	measure  sql.NullFloat64
+	offset   sql.NullFloat64
+}
+
+// writeLRSRows stores computed rows in the temp_lrs table
+func writeLRSRows(tx *sql.Tx, results []lrsRow) error {
+	if _, err := tx.Exec("CREATE TEMPORARY TABLE temp_lrs (rid BIGINT, wkt VARCHAR, route_rid BIGINT, measure DOUBLE, route_offset DOUBLE)"); err != nil {
+		return fmt.Errorf("failed to create temporary table: %w", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO temp_lrs VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, r := range results {
+		var wkt sql.NullString
+		if r.wkt != "" {
+			wkt = sql.NullString{String: r.wkt, Valid: true}
+		}
+		if _, err := stmt.Exec(r.rowID, wkt, r.routeRow, r.measure, r.offset); err != nil {
+			return fmt.Errorf("failed to insert result: %w", err)
+		}
+	}
+	return nil
+}
+
+// EventOptions configures placing events from a table of measures
+type EventOptions struct {
+	Routes RouteOptions
+	// Measure locates point events
+	Measure string
+	// From and To bound line events
+	From string
+	To   string
+}
+
+// LocateEvents creates outTable with every row of srcTable plus the point at
+// its measure along its route. Events whose route is missing or whose measure
+// falls outside it get a NULL geom.
+func LocateEvents(dbPath, srcTable, outTable string, opts EventOptions) (LRSResult, error) {
+	return placeEvents(dbPath, srcTable, outTable, opts, func(r *route, measures []float64) string {
+		d, ok := r.distanceAt(measures[0])
+		if !ok {
+			return ""
+		}
+		p, _ := r.pointAt(d)
+		return fmt.Sprintf("POINT (%.7f %.7f)", p[0], p[1])
+	}, opts.Measure)
+}
+
+// SegmentEvents creates outTable with every row of srcTable plus the part of
+// its route between its from and to measures. Events whose route is missing
+// or whose measures fall outside it get a NULL geom.
+func SegmentEvents(dbPath, srcTable, outTable string, opts EventOptions) (LRSResult, error) {
+	return placeEvents(dbPath, srcTable, outTable, opts, func(r *route, measures []float64) string {
+		d1, ok1 := r.distanceAt(measures[0])
+		d2, ok2 := r.distanceAt(measures[1])
+		if !ok1 || !ok2 {
+			return ""
+		}
+		return lineWKT(r.substring(d1, d2))
+	}, opts.From, opts.To)
+}
+
+// placeEvents reads the route and measures of each event and builds its
+// geometry with place, trying each line of the route until one fits
+func placeEvents(dbPath, srcTable, outTable string, opts EventOptions, place func(r *route, measures []float64) string, measureCols ...string) (LRSResult, error) {
+	var result LRSResult
+
+	columns, err := GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return result, err
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	err = withTempTables(db, func(tx *sql.Tx) error {
+		routes, _, err := readRoutes(tx, opts.Routes)
+		if err != nil {
+			return err
+		}
+
+		measureExprs := make([]string, len(measureCols))
+		for i, col := range measureCols {
+			measureExprs[i] = fmt.Sprintf("CAST(%s AS DOUBLE)", QuoteIdentifier(col))
+		}
+		rows, err := tx.Query(fmt.Sprintf("SELECT rowid, CAST(%s AS VARCHAR), %s FROM %s",
+			QuoteIdentifier(opts.Routes.eventIDColumn()), strings.Join(measureExprs, ", "), QuoteTableName(srcTable)))
+		if err != nil {
+			return fmt.Errorf("failed to read events: %w", err)
+		}
+
+		var results []lrsRow
+		for rows.Next() {
+			var rowID int64
+			var id sql.NullString
+			values := make([]sql.NullFloat64, len(measureCols))
+			dest := []any{&rowID, &id}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan event: %w", err)
+			}
+			result.Rows++
+
+			measures := make([]float64, len(values))
+			complete := id.Valid
+			for i, v := range values {
+				complete = complete && v.Valid
+				measures[i] = v.Float64
+			}
+			if !complete {
+				continue
+			}
+			for _, r := range routes[id.String] {
+				if wkt := place(r, measures); wkt != "" {
+					results = append(results, lrsRow{rowID: rowID, wkt: wkt})
+					result.Located++
+					break
+				}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating rows: %w", err)
+		}
+
+		if err := writeLRSRows(tx, results); err != nil {
+			return err
+		}
+		defer tx.Exec("DROP TABLE IF EXISTS temp_lrs")
+
+		if err := EnsureSchema(tx, outTable); err != nil {
+			return err
+		}
+
+		selectCols := sourceColumns("s", columns)
+		if selectCols != "" {
+			selectCols += ", "
+		}
+		createSQL := fmt.Sprintf(`
+			CREATE TABLE %s AS
+			SELECT %sST_GeomFromText(t.wkt) AS geom
+			FROM %s s LEFT JOIN temp_lrs t ON s.rowid = t.rid
+			ORDER BY s.rowid
+		`, QuoteTableName(outTable), selectCols, QuoteTableName(srcTable))
+		if _, err := tx.Exec(createSQL); err != nil {
+			return fmt.Errorf("failed to create %s: %w", outTable, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ProjectOptions configures measuring points along their nearest route
+type ProjectOptions struct {
+	Routes RouteOptions
+	// MatchRoute limits each point to the route its EventIDColumn names,
+	// instead of the nearest of all routes
+	MatchRoute bool
+	// MeasureColumn receives the measure; route_offset receives the distance
+	// from the point to the route
+	MeasureColumn string
+}
+
+// ProjectPoints creates outTable with every row of the point table srcTable
+// plus the measure of the closest position on the nearest route, its
+// distance from the point in route_offset and, unless MatchRoute is set,
+// the route's id.
+func ProjectPoints(dbPath, srcTable, outTable string, opts ProjectOptions) (LRSResult, error) {
+	var result LRSResult
+
+	columns, err := GetTableSchema(dbPath, srcTable)
+	if err != nil {
+		return result, err
+	}
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, routes, err := readRoutes(tx, opts.Routes)
+	if err != nil {
+		return result, err
+	}
+
+	// DuckDB picks the nearest line; the measure along it is worked out here
+	match := ""
+	if opts.MatchRoute {
+		match = fmt.Sprintf("AND r.%s = p.%s", QuoteIdentifier(opts.Routes.IDColumn), QuoteIdentifier(opts.Routes.eventIDColumn()))
+	}
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT p.rowid, ST_X(p.geom), ST_Y(p.geom), arg_min(r.rowid, ST_Distance(p.geom, r.geom))
+		FROM %s p JOIN %s r
+			ON ST_GeometryType(r.geom)::VARCHAR = 'LINESTRING' %s
+		WHERE ST_GeometryType(p.geom)::VARCHAR = 'POINT'
+		GROUP BY p.rowid, p.geom
+	`, QuoteTableName(srcTable), QuoteTableName(opts.Routes.Table), match))
+	if err != nil {
+		return result, fmt.Errorf("failed to find nearest routes: %w", err)
+	}
+
+	var results []lrsRow
+	for rows.Next() {
+		var rowID, routeRow int64
+		var p [2]float64
+		if err := rows.Scan(&rowID, &p[0], &p[1], &routeRow); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to scan point: %w", err)
+		}
+		r := routes[routeRow]
+		if r == nil {
+			continue
+		}
+		along, offset := r.project(p)
+		results = append(results, lrsRow{
+			rowID:    rowID,
+			routeRow: sql.NullInt64{Int64: routeRow, Valid: true},
+			measure:  sql.NullFloat64{Float64: r.measureAt(along), Valid: true},
+			offset:   sql.NullFloat64{Float64: offset, Valid: true},
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("error iterating rows: %w", err)
+	}
+	result.Located = int64(len(results))
+
+	if err := writeLRSRows(tx, results); err != nil {
+		return result, err
+	}
+	defer tx.Exec("DROP TABLE IF EXISTS temp_lrs")
+
+	if err := EnsureSchema(tx, outTable); err != nil {
+		return result, err
+	}
+
+	var selectCols []string
+	for _, col := range columns {
+		selectCols = append(selectCols, "s."+QuoteIdentifier(col.Name))
+	}
+	if !opts.MatchRoute {
+		selectCols = append(selectCols, "r."+QuoteIdentifier(opts.Routes.IDColumn))
+	}
+	selectCols = append(selectCols, "t.measure AS "+QuoteIdentifier(opts.MeasureColumn), "t.route_offset")
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE %s AS
+		SELECT %s
+		FROM %s s
+			LEFT JOIN temp_lrs t ON s.rowid = t.rid
+			LEFT JOIN %s r ON r.rowid = t.route_rid
+		ORDER BY s.rowid
+	`, QuoteTableName(outTable), strings.Join(selectCols, ", "), QuoteTableName(srcTable), QuoteTableName(opts.Routes.Table))
+	if _, err := tx.Exec(createSQL); err != nil {
+		return result, fmt.Errorf("failed to create %s: %w", outTable, err)
+	}
+
+	if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteTableName(outTable))).Scan(&result.Rows); err != nil {
+		return result, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
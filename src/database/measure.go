@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Measure is a geodesic measurement export can add as a column
+type Measure string
+
+const (
+	// MeasureArea is the area of polygons
+	MeasureArea Measure = "area"
+	// MeasureLength is the length of lines
+	MeasureLength Measure = "length"
+	// MeasurePerimeter is the length of the rings of polygons
+	MeasurePerimeter Measure = "perimeter"
+)
+
+// measureFunctions maps measures to the spatial extension's functions on the
+// WGS84 spheroid
+var measureFunctions = map[Measure]string{
+	MeasureArea:      "ST_Area_Spheroid",
+	MeasureLength:    "ST_Length_Spheroid",
+	MeasurePerimeter: "ST_Perimeter_Spheroid",
+}
+
+// ParseMeasures parses the --measure flag values
+func ParseMeasures(names []string) ([]Measure, error) {
+	var measures []Measure
+	seen := make(map[Measure]bool)
+	for _, name := range names {
+		m := Measure(strings.ToLower(strings.TrimSpace(name)))
+		if _, ok := measureFunctions[m]; !ok {
+			return nil, fmt.Errorf("invalid measure %q (must be area, length or perimeter)", name)
+		}
+		if !seen[m] {
+			seen[m] = true
+			measures = append(measures, m)
+		}
+	}
+	return measures, nil
+}
+
+// Unit is the unit measurements are given in. Areas are in its square.
+type Unit struct {
+	Name string
+	// Meters is the length of one unit in meters
+	Meters float64
+}
+
+// units lists the length units --units accepts
+var units = []Unit{
+	{Name: "m", Meters: 1},
+	{Name: "km", Meters: 1000},
+	{Name: "ft", Meters: 0.3048},
+	{Name: "mi", Meters: 1609.344},
+	{Name: "nmi", Meters: 1852},
+}
+
+// ParseUnit parses the --units flag; "" means meters
+func ParseUnit(s string) (Unit, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return units[0], nil
+	}
+	for _, u := range units {
+		if u.Name == name {
+			return u, nil
+		}
+	}
+	return Unit{}, fmt.Errorf("invalid unit %q (must be m, km, ft, mi or nmi)", s)
+}
+
+// Column names the column a measure is stored in, e.g. area_km2 or length_mi
+func (m Measure) Column(u Unit) string {
+	if m == MeasureArea {
+		return fmt.Sprintf("area_%s2", u.Name)
+	}
+	return fmt.Sprintf("%s_%s", m, u.Name)
+}
+
+// measureSelects returns the select expressions computing measures of the
+// geometry column, stored in srs. Geometries are brought into lat/lon order
+// on WGS84, which the spheroid functions expect, so the measurements don't
+// depend on the storage CRS.
+func measureSelects(column, srs string, measures []Measure, unit Unit) []string {
+	geom := QuoteIdentifier(column)
+	if !strings.EqualFold(srs, "EPSG:4326") && !strings.EqualFold(srs, "OGC:CRS84") {
+		geom = fmt.Sprintf("ST_Transform(%s, %s, 'EPSG:4326', always_xy := true)", geom, QuoteLiteral(srs))
+	}
+	geom = fmt.Sprintf("ST_FlipCoordinates(%s)", geom)
+
+	var selects []string
+	for _, m := range measures {
+		scale := unit.Meters
+		if m == MeasureArea {
+			scale *= unit.Meters
+		}
+		selects = append(selects, fmt.Sprintf("%s(%s) / %g AS %s", measureFunctions[m], geom, scale, QuoteIdentifier(m.Column(unit))))
+	}
+	return selects
+}
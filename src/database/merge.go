@@ -0,0 +1,139 @@
+package database
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SourceColumn is the column added by MergeTables to record where each row came from
+const SourceColumn = "source_table"
+
+// MergeOptions controls how tables are merged
+type MergeOptions struct {
+	// AddSourceColumn records each row's source table in SourceColumn
+	AddSourceColumn bool
+	// Replace overwrites the target table if it already exists
+	Replace bool
+}
+
+// MergeResult describes a completed merge
+type MergeResult struct {
+	Tables []string
+	Rows   int64
+}
+
+// ListTables returns the base tables in the database. Tables outside the main
+// schema are returned schema-qualified.
+func ListTables(dbPath string) ([]string, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT table_schema, table_name
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE' AND table_catalog = current_database()
+		ORDER BY table_schema, table_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		if schema != "main" {
+			table = schema + "." + table
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return tables, nil
+}
+
+// MergeTables unions every table matching pattern (a shell glob such as
+// "roads_*") into a new table. Columns are matched by name; columns missing
+// from some tables are NULL for their rows.
+func MergeTables(dbPath, pattern, into string, opts MergeOptions) (MergeResult, error) {
+	var result MergeResult
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return result, fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+	}
+
+	tables, err := ListTables(dbPath)
+	if err != nil {
+		return result, err
+	}
+
+	intoExists := false
+	for _, table := range tables {
+		if strings.EqualFold(table, into) {
+			intoExists = true
+			continue
+		}
+		if ok, _ := path.Match(pattern, table); ok {
+			result.Tables = append(result.Tables, table)
+		}
+	}
+
+	if len(result.Tables) == 0 {
+		return result, fmt.Errorf("no tables match %q", pattern)
+	}
+	if intoExists && !opts.Replace {
+		return result, fmt.Errorf("table '%s' already exists (use --replace to overwrite it)", into)
+	}
+
+	var selects []string
+	for _, table := range result.Tables {
+		sel := "SELECT *"
+		if opts.AddSourceColumn {
+			sel += fmt.Sprintf(", %s AS %s", QuoteLiteral(table), QuoteIdentifier(SourceColumn))
+		}
+		selects = append(selects, sel+" FROM "+QuoteTableName(table))
+	}
+
+	create := "CREATE TABLE"
+	if opts.Replace {
+		create = "CREATE OR REPLACE TABLE"
+	}
+	createSQL := fmt.Sprintf("%s %s AS\n%s", create, QuoteTableName(into), strings.Join(selects, "\nUNION ALL BY NAME\n"))
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := EnsureSchema(tx, into); err != nil {
+		return result, err
+	}
+	if _, err := tx.Exec(createSQL); err != nil {
+		return result, fmt.Errorf("failed to merge tables: %w", err)
+	}
+	if err := tx.QueryRow("SELECT COUNT(*) FROM " + QuoteTableName(into)).Scan(&result.Rows); err != nil {
+		return result, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StorageVersionError means a database file uses a storage format the bundled
+// DuckDB can't read
+type StorageVersionError struct {
+	Path string
+	// Version is the file's storage version number
+	Version uint64
+	// CreatedWith names the DuckDB release that wrote the file, as DuckDB
+	// reports it, e.g. "DuckDB version v0.9.0, v0.9.1 or v0.9.2"
+	CreatedWith string
+	// Newer is set when the file was written by a newer DuckDB
+	Newer bool
+	Err   error
+}
+
+func (e *StorageVersionError) Error() string {
+	if e.Newer {
+		return fmt.Sprintf(`%s was written by a newer DuckDB than the one bundled with xyzduck (storage version %d).
+Run 'xyzduck self-update' to get a release that can read it.`, e.Path, e.Version)
+	}
+	return fmt.Sprintf(`%s was created with %s (storage version %d), which xyzduck can no longer read.
+Run 'xyzduck migrate-storage %s' to convert it to the current format.`, e.Path, e.CreatedWith, e.Version, e.Path)
+}
+
+func (e *StorageVersionError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	storageVersionPattern = regexp.MustCompile(`database file with version number (\d+), but we can only read versions? (?:between )?(\d+)`)
+	createdWithPattern    = regexp.MustCompile(`The database file was created with (.+?)\.(?:\n|$)`)
+)
+
+// storageError turns the driver's error for a file in an unreadable storage
+// format into a StorageVersionError, leaving other errors as they are
+func storageError(path string, err error) error {
+	m := storageVersionPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	version, _ := strconv.ParseUint(m[1], 10, 64)
+	oldest, _ := strconv.ParseUint(m[2], 10, 64)
+	e := &StorageVersionError{Path: path, Version: version, Newer: version > oldest, Err: err}
+	if c := createdWithPattern.FindStringSubmatch(err.Error()); c != nil {
+		e.CreatedWith = c[1]
+	}
+	if e.CreatedWith == "" || strings.Contains(e.CreatedWith, "newer version") {
+		e.CreatedWith = "an older DuckDB"
+	}
+	return e
+}
+
+// StorageHeader is what a database file's header says about its format
+type StorageHeader struct {
+	Version uint64
+	// LibraryVersion is the DuckDB release that created the file; files from
+	// before v1.2 don't record it
+	LibraryVersion string
+}
+
+// ReadStorageHeader reads the header of a DuckDB database file
+func ReadStorageHeader(path string) (StorageHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return StorageHeader{}, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// checksum (8 bytes), magic (4), version (8), flags (4x8), library
+	// version (32), source id (32)
+	buf := make([]byte, 116)
+	if _, err := io.ReadFull(f, buf); err != nil || string(buf[8:12]) != "DUCK" {
+		return StorageHeader{}, fmt.Errorf("%s is not a DuckDB database", path)
+	}
+	return StorageHeader{
+		Version:        binary.LittleEndian.Uint64(buf[12:20]),
+		LibraryVersion: string(bytes.TrimRight(buf[52:84], "\x00")),
+	}, nil
+}
+
+// CheckStorage opens a database to find out whether the bundled DuckDB can
+// read it. It returns a *StorageVersionError when it can't.
+func CheckStorage(dbPath string) error {
+	db, err := Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		return storageError(dbPath, err)
+	}
+	return nil
+}
+
+// MigrateStorage copies every schema, table, view, sequence and macro of a
+// database the bundled DuckDB can read into a new file in its current format
+func MigrateStorage(dbPath, outPath string) error {
+	db, err := OpenInMemory()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := LoadSpatial(db); err != nil {
+		return err
+	}
+
+	statements := []string{
+		fmt.Sprintf("ATTACH %s AS migrate_source (READ_ONLY)", QuoteLiteral(dbPath)),
+		fmt.Sprintf("ATTACH %s AS migrate_target", QuoteLiteral(outPath)),
+		"COPY FROM DATABASE migrate_source TO migrate_target",
+		"DETACH migrate_target",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to copy database: %w", storageError(dbPath, err))
+		}
+	}
+	return nil
+}
+
+// ExportWithCLI exports a database with an external DuckDB command line
+// binary, e.g. an older release that can still read the file, into dir. The
+// spatial extension is loaded first when the binary has it, so geometry
+// columns export as WKT.
+func ExportWithCLI(cli, dbPath, dir string, installSpatial bool) (spatial bool, err error) {
+	load := "LOAD spatial;"
+	if installSpatial {
+		load = "INSTALL spatial; " + load
+	}
+	spatial = runCLI(cli, "", load) == nil
+
+	script := fmt.Sprintf("EXPORT DATABASE %s;", QuoteLiteral(dir))
+	if spatial {
+		script = "LOAD spatial; " + script
+	}
+	if err := runCLI(cli, dbPath, script); err != nil {
+		return spatial, fmt.Errorf("failed to export %s with %s: %w", dbPath, cli, err)
+	}
+	return spatial, nil
+}
+
+// CLIVersion reports the DuckDB release of a command line binary
+func CLIVersion(cli string) (string, error) {
+	out, err := exec.Command(cli, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", cli, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%s printed no version", cli)
+	}
+	return fields[0], nil
+}
+
+// runCLI runs a script against a database, or an in-memory one when dbPath is
+// empty, with a DuckDB command line binary, stopping at the first failing
+// statement
+func runCLI(cli, dbPath, script string) error {
+	args := []string{"-bail", "-c", script}
+	if dbPath != "" {
+		args = append([]string{"-readonly"}, append(args, dbPath)...)
+	}
+	cmd := exec.Command(cli, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Stdout = io.Discard
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// ImportExport creates outPath from a directory written by EXPORT DATABASE
+func ImportExport(dir, outPath string) error {
+	db, err := openWithSpatial(outPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("IMPORT DATABASE %s", QuoteLiteral(abs))); err != nil {
+		return fmt.Errorf("failed to import database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import "fmt"
+
+// lineFilter selects the line geometries of a table
+const lineFilter = "geom IS NOT NULL AND ST_GeometryType(geom)::VARCHAR IN ('LINESTRING', 'MULTILINESTRING')"
+
+// NodeLines creates outTable with the lines of srcTable split at every
+// intersection, so that lines only meet at their endpoints. Overlapping lines
+// are merged into one. The output has an id and a geom column; attributes are
+// not carried over. It returns the number of lines created.
+func NodeLines(dbPath, srcTable, outTable string) (int64, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := EnsureSchema(tx, outTable); err != nil {
+		return 0, err
+	}
+
+	// Unioning the linework nodes it
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE %s AS
+		SELECT row_number() OVER () AS id, part.geom AS geom
+		FROM (
+			SELECT unnest(ST_Dump(merged)) AS part
+			FROM (SELECT ST_Union_Agg(geom) AS merged FROM %s WHERE %s)
+		)
+	`, QuoteTableName(outTable), QuoteTableName(srcTable), lineFilter)
+	if _, err := tx.Exec(createSQL); err != nil {
+		return 0, fmt.Errorf("failed to node lines: %w", err)
+	}
+
+	var count int64
+	if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteTableName(outTable))).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return count, nil
+}
+
+// PolygonizeOptions configures building polygons from lines
+type PolygonizeOptions struct {
+	// Node splits the lines at their intersections first; without it the
+	// lines must already be noded, or areas they cross are missed
+	Node bool
+}
+
+// Polygonize creates outTable with one polygon for every area enclosed by the
+// lines of srcTable. The polygons share their edges, forming a coverage
+// without gaps or overlaps. The output has an id and a geom column. It
+// returns the number of polygons created.
+func Polygonize(dbPath, srcTable, outTable string, opts PolygonizeOptions) (int64, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := EnsureSchema(tx, outTable); err != nil {
+		return 0, err
+	}
+
+	linework := "list(geom)"
+	if opts.Node {
+		linework = "[ST_Union_Agg(geom)]"
+	}
+
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE %s AS
+		SELECT row_number() OVER () AS id, part.geom AS geom
+		FROM (
+			SELECT unnest(ST_Dump(polygons)) AS part
+			FROM (SELECT ST_Polygonize(%s) AS polygons FROM %s WHERE %s)
+		)
+		WHERE ST_GeometryType(part.geom)::VARCHAR = 'POLYGON'
+	`, QuoteTableName(outTable), linework, QuoteTableName(srcTable), lineFilter)
+	if _, err := tx.Exec(createSQL); err != nil {
+		return 0, fmt.Errorf("failed to polygonize lines: %w", err)
+	}
+
+	var count int64
+	if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteTableName(outTable))).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import "strings"
+
+// Names and values that end up in SQL text go through these functions; data
+// values are better passed as statement parameters or through an appender.
+
+// QuoteIdentifier quotes a single SQL identifier, doubling any embedded quotes
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteTableName quotes a possibly schema-qualified table name
+func QuoteTableName(name string) string {
+	schema, table := SplitTableName(name)
+	return QuoteIdentifier(schema) + "." + QuoteIdentifier(table)
+}
+
+// QuoteIdentifiers quotes column names into a comma-separated list
+func QuoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdentifier(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// QuoteLiteral quotes a string as a SQL string literal
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
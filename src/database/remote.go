@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/aws"
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+// IsRemote reports whether path is an HTTP(S) or s3:// URL rather than a
+// local file
+func IsRemote(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || IsS3(path)
+}
+
+// IsS3 reports whether path is an s3:// URL
+func IsS3(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), "s3://")
+}
+
+// S3Config is how s3:// URLs are accessed
+type S3Config struct {
+	// Region defaults to us-east-1
+	Region string
+	// Endpoint is the URL of an S3-compatible store, e.g.
+	// http://localhost:9000 for MinIO; objects are then addressed path-style
+	Endpoint string
+	// Creds are empty for anonymous access to public buckets
+	Creds aws.Credentials
+}
+
+var s3Config S3Config
+
+// SetS3 sets how s3:// URLs are accessed for the rest of the process
+func SetS3(c S3Config) {
+	s3Config = c
+}
+
+// ConfigureHTTP prepares a connection to read or write rawURL in place
+// through DuckDB's httpfs extension, which fetches only the byte ranges it
+// needs. s3:// URLs are accessed with the credentials given to SetS3. The
+// proxy named by HTTP_PROXY, HTTPS_PROXY and NO_PROXY is passed on, since
+// httpfs doesn't read those variables itself.
+func ConfigureHTTP(db Execer, rawURL string) error {
+	if err := offline.Check("accessing " + rawURL); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+	if IsS3(rawURL) {
+		if u, err = configureS3(db); err != nil {
+			return err
+		}
+	}
+	proxy, err := http.ProxyFromEnvironment(&http.Request{URL: u})
+	if err != nil {
+		return fmt.Errorf("invalid proxy setting: %w", err)
+	}
+	if proxy == nil {
+		return nil
+	}
+
+	settings := map[string]string{"http_proxy": proxy.Host}
+	if proxy.User != nil {
+		settings["http_proxy_username"] = proxy.User.Username()
+		if password, ok := proxy.User.Password(); ok {
+			settings["http_proxy_password"] = password
+		}
+	}
+	for name, value := range settings {
+		if _, err := db.Exec(fmt.Sprintf("SET %s = %s", name, QuoteLiteral(value))); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// configureS3 creates the secret httpfs signs S3 requests with and returns
+// the endpoint's URL, for the proxy lookup
+func configureS3(db Execer) (*url.URL, error) {
+	region := s3Config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	options := []string{"TYPE s3", "REGION " + QuoteLiteral(region)}
+	if creds := s3Config.Creds; creds.AccessKeyID != "" {
+		options = append(options, "KEY_ID "+QuoteLiteral(creds.AccessKeyID), "SECRET "+QuoteLiteral(creds.SecretAccessKey))
+		if creds.SessionToken != "" {
+			options = append(options, "SESSION_TOKEN "+QuoteLiteral(creds.SessionToken))
+		}
+	}
+
+	endpoint := &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", region)}
+	if s3Config.Endpoint != "" {
+		u, err := url.Parse(s3Config.Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %s", s3Config.Endpoint)
+		}
+		endpoint = u
+		options = append(options, "ENDPOINT "+QuoteLiteral(u.Host), "URL_STYLE 'path'",
+			fmt.Sprintf("USE_SSL %t", u.Scheme != "http"))
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE OR REPLACE SECRET xyzduck_s3 (%s)", strings.Join(options, ", "))); err != nil {
+		return nil, fmt.Errorf("failed to configure S3 access: %w", err)
+	}
+	return endpoint, nil
+}
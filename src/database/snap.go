@@ -0,0 +1,155 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Distance is a length given on the command line, either in meters or in the
+// units of the data's CRS
+type Distance struct {
+	Value float64
+	// Meters is set when the value carried a unit (m or km); the data is then
+	// taken to be lon/lat
+	Meters bool
+}
+
+// ParseDistance parses "2m", "0.5km" or a bare number in CRS units
+func ParseDistance(s string) (Distance, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	scale, meters := 1.0, false
+	switch {
+	case strings.HasSuffix(text, "km"):
+		text, scale, meters = strings.TrimSuffix(text, "km"), 1000, true
+	case strings.HasSuffix(text, "m"):
+		text, meters = strings.TrimSuffix(text, "m"), true
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || v < 0 {
+		return Distance{}, fmt.Errorf("invalid distance %q (expected e.g. 2m, 0.5km or a number in CRS units)", s)
+	}
+	return Distance{Value: v * scale, Meters: meters}, nil
+}
+
+// String formats the distance with its unit
+func (d Distance) String() string {
+	if d.Meters {
+		return fmt.Sprintf("%gm", d.Value)
+	}
+	return fmt.Sprintf("%g", d.Value)
+}
+
+// SnapOptions configures snapping features to a reference layer
+type SnapOptions struct {
+	// ReferenceTable holds the geometries features are snapped to
+	ReferenceTable string
+	// Tolerance is the farthest a feature is moved
+	Tolerance Distance
+	// ReferenceIDColumn, when set, is copied from the reference geometry a
+	// feature was snapped to into snap_ref
+	ReferenceIDColumn string
+}
+
+// SnapResult summarizes a snap
+type SnapResult struct {
+	Rows    int64
+	Snapped int64
+	// Mean, Median and Max describe the distance snapped features were from
+	// the reference, in the tolerance's unit
+	Mean   float64
+	Median float64
+	Max    float64
+}
+
+// SnapColumns are the columns SnapFeatures adds to the output table
+var SnapColumns = []string{"snap_distance", "snap_ref"}
+
+// SnapFeatures creates outTable with every row of srcTable, moving each
+// feature onto the nearest reference geometry within the tolerance: points
+// move to the closest point on it, and the vertices of lines and polygons
+// snap to its vertices and edges. snap_distance holds the distance to that
+// reference geometry, NULL for features left where they were.
+func SnapFeatures(dbPath, srcTable, outTable string, opts SnapOptions) (SnapResult, error) {
+	var result SnapResult
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := EnsureSchema(tx, outTable); err != nil {
+		return result, err
+	}
+
+	// With meters the data is lon/lat: candidates are found with the
+	// tolerance in degrees at the feature's latitude, then measured on the
+	// sphere along the shortest line between the two geometries
+	tolerance := fmt.Sprintf("%g", opts.Tolerance.Value)
+	searchTolerance := tolerance
+	distance := "ST_Distance(s.geom, r.geom)"
+	if opts.Tolerance.Meters {
+		searchTolerance = fmt.Sprintf("(%g / 111320.0 / greatest(cos(radians(ST_Y(ST_Centroid(s.geom)))), 0.01))", opts.Tolerance.Value)
+		distance = "ST_Distance_Sphere(ST_StartPoint(ST_ShortestLine(s.geom, r.geom)), ST_EndPoint(ST_ShortestLine(s.geom, r.geom)))"
+	}
+
+	refSelect, refColumn, refOutput := "", "", ""
+	if opts.ReferenceIDColumn != "" {
+		refSelect = fmt.Sprintf(", r.%s AS ref_id", QuoteIdentifier(opts.ReferenceIDColumn))
+		refColumn = ", arg_min(ref_id, d) AS ref_id"
+		refOutput = ", n.ref_id AS snap_ref"
+	}
+
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE %s AS
+		WITH src AS (
+			SELECT rowid AS xyzduck_rid, * FROM %s
+		),
+		nearest AS (
+			SELECT rid, arg_min(ref_geom, d) AS ref_geom, min(d) AS d, any_value(search) AS search%s
+			FROM (
+				SELECT s.xyzduck_rid AS rid, r.geom AS ref_geom, %s AS d, %s AS search%s
+				FROM src s JOIN %s r ON ST_DWithin(s.geom, r.geom, %s)
+			)
+			WHERE d <= %s
+			GROUP BY rid
+		)
+		SELECT s.* EXCLUDE (xyzduck_rid, geom),
+			CASE
+				WHEN n.rid IS NULL THEN s.geom
+				WHEN ST_GeometryType(s.geom)::VARCHAR = 'POINT' THEN ST_EndPoint(ST_ShortestLine(s.geom, n.ref_geom))
+				ELSE ST_Snap(s.geom, n.ref_geom, n.search)
+			END AS geom,
+			n.d AS snap_distance%s
+		FROM src s LEFT JOIN nearest n ON n.rid = s.xyzduck_rid
+		ORDER BY s.xyzduck_rid
+	`, QuoteTableName(outTable), QuoteTableName(srcTable),
+		refColumn, distance, searchTolerance, refSelect, QuoteTableName(opts.ReferenceTable), searchTolerance, tolerance,
+		refOutput)
+	if _, err := tx.Exec(createSQL); err != nil {
+		return result, fmt.Errorf("failed to create %s: %w", outTable, err)
+	}
+
+	statsSQL := fmt.Sprintf(`
+		SELECT COUNT(*), COUNT(snap_distance),
+			COALESCE(AVG(snap_distance), 0), COALESCE(MEDIAN(snap_distance), 0), COALESCE(MAX(snap_distance), 0)
+		FROM %s
+	`, QuoteTableName(outTable))
+	if err := tx.QueryRow(statsSQL).Scan(&result.Rows, &result.Snapped, &result.Mean, &result.Median, &result.Max); err != nil {
+		return result, fmt.Errorf("failed to summarize snapping: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SoftDeleteColumn records when a row of a soft-delete table was deleted;
+// it is NULL for live rows
+const SoftDeleteColumn = "deleted_at"
+
+// softDeleteTable lists the tables in soft-delete mode
+const softDeleteTable = "xyzduck_soft_delete"
+
+// Querier is satisfied by both *sql.DB and *sql.Tx
+type Querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// IsSoftDelete reports whether a table is in soft-delete mode
+func IsSoftDelete(db Querier, tableName string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT count(*) > 0 FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = 'main' AND table_name = ?", softDeleteTable).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up soft-delete tables: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	schema, table := SplitTableName(tableName)
+	var enabled bool
+	query := fmt.Sprintf("SELECT count(*) > 0 FROM %s WHERE schema_name = ? AND table_name = ?", QuoteIdentifier(softDeleteTable))
+	if err := db.QueryRow(query, schema, table).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to look up soft-delete tables: %w", err)
+	}
+	return enabled, nil
+}
+
+// HasSoftDelete reports whether a table of a database is in soft-delete mode
+func HasSoftDelete(dbPath, tableName string) (bool, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+	return IsSoftDelete(db, tableName)
+}
+
+// LiveRows returns what to select a table's rows FROM: the table itself, or
+// for a soft-delete table a subquery leaving out the deleted rows. soft tells
+// which, so callers can also leave out SoftDeleteColumn.
+func LiveRows(db Querier, tableName string) (from string, soft bool, err error) {
+	if soft, err = IsSoftDelete(db, tableName); err != nil || !soft {
+		return QuoteTableName(tableName), false, err
+	}
+	return fmt.Sprintf("(SELECT * FROM %s WHERE %s IS NULL)", QuoteTableName(tableName), QuoteIdentifier(SoftDeleteColumn)), true, nil
+}
+
+// EnableSoftDelete puts a table in soft-delete mode: it gains a deleted_at
+// column, DeleteRows stamps rows instead of removing them, and exports and
+// tile servers leave stamped rows out. It returns false when the table
+// already was.
+//
+// A deleted_at column the table already has would hide every row with a
+// value in it, and be dropped by DisableSoftDelete, so it is only taken over
+// with adopt, and only when it is a TIMESTAMP.
+func EnableSoftDelete(dbPath, tableName string, adopt bool) (bool, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	soft, err := IsSoftDelete(db, tableName)
+	if err != nil || soft {
+		return false, err
+	}
+
+	existing, err := columnType(db, tableName, SoftDeleteColumn)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case existing == "":
+	case !adopt:
+		return false, fmt.Errorf("%s already has a %s column; rename it, or adopt it with --adopt if it holds deletion times", tableName, SoftDeleteColumn)
+	case existing != "TIMESTAMP":
+		return false, fmt.Errorf("%s.%s is %s; only a TIMESTAMP column can be adopted", tableName, SoftDeleteColumn, existing)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	schema_name VARCHAR,
+	table_name VARCHAR,
+	enabled_at TIMESTAMP,
+	PRIMARY KEY (schema_name, table_name)
+)`, QuoteIdentifier(softDeleteTable))
+	if _, err := tx.Exec(createSQL); err != nil {
+		return false, fmt.Errorf("failed to create soft-delete table: %w", err)
+	}
+
+	addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s TIMESTAMP", QuoteTableName(tableName), QuoteIdentifier(SoftDeleteColumn))
+	if _, err := tx.Exec(addSQL); err != nil {
+		return false, fmt.Errorf("failed to add %s column: %w", SoftDeleteColumn, err)
+	}
+
+	schema, table := SplitTableName(tableName)
+	insertSQL := fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?)", QuoteIdentifier(softDeleteTable))
+	if _, err := tx.Exec(insertSQL, schema, table, time.Now().UTC()); err != nil {
+		return false, fmt.Errorf("failed to record soft-delete table: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit: %w", err)
+	}
+	return true, nil
+}
+
+// columnType returns the type of a table's column, or "" when it has none
+func columnType(db Querier, tableName, column string) (string, error) {
+	schema, table := SplitTableName(tableName)
+	var dataType string
+	err := db.QueryRow(`
+		SELECT data_type FROM duckdb_columns()
+		WHERE database_name = current_database() AND schema_name = ? AND table_name = ? AND lower(column_name) = lower(?)
+	`, schema, table, column).Scan(&dataType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s column: %w", column, err)
+	}
+	return dataType, nil
+}
+
+// DisableSoftDelete takes a table out of soft-delete mode, permanently
+// removing its deleted rows and the deleted_at column. It returns the number
+// of rows removed.
+func DisableSoftDelete(dbPath, tableName string) (int64, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	soft, err := IsSoftDelete(db, tableName)
+	if err != nil {
+		return 0, err
+	}
+	if !soft {
+		return 0, fmt.Errorf("%s is not in soft-delete mode", tableName)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table, column := QuoteTableName(tableName), QuoteIdentifier(SoftDeleteColumn)
+	res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s IS NOT NULL", table, column))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted rows: %w", err)
+	}
+	purged, _ := res.RowsAffected()
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)); err != nil {
+		return 0, fmt.Errorf("failed to drop %s column: %w", SoftDeleteColumn, err)
+	}
+	schema, name := SplitTableName(tableName)
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE schema_name = ? AND table_name = ?", QuoteIdentifier(softDeleteTable))
+	if _, err := tx.Exec(deleteSQL, schema, name); err != nil {
+		return 0, fmt.Errorf("failed to unrecord soft-delete table: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return purged, nil
+}
+
+// DeleteRows deletes the rows of a table matching a SQL condition. Rows of a
+// soft-delete table are stamped with the time instead, all with the same
+// one, and stay restorable; soft tells which happened. An empty condition
+// matches every row.
+func DeleteRows(dbPath, tableName, where string) (n int64, soft bool, err error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, false, err
+	}
+	defer db.Close()
+
+	if soft, err = IsSoftDelete(db, tableName); err != nil {
+		return 0, false, err
+	}
+
+	table, column := QuoteTableName(tableName), QuoteIdentifier(SoftDeleteColumn)
+	var res sql.Result
+	if soft {
+		query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s IS NULL", table, column, column)
+		if where != "" {
+			query += " AND (" + where + ")"
+		}
+		res, err = db.Exec(query, time.Now().UTC())
+	} else {
+		query := "DELETE FROM " + table
+		if where != "" {
+			query += " WHERE " + where
+		}
+		res, err = db.Exec(query)
+	}
+	if err != nil {
+		return 0, soft, fmt.Errorf("failed to delete rows: %w", err)
+	}
+	n, _ = res.RowsAffected()
+	return n, soft, nil
+}
+
+// RestoreOptions selects the deleted rows to restore; with none set, every
+// deleted row is
+type RestoreOptions struct {
+	// Where is a SQL condition on the rows
+	Where string
+	// Since restores rows deleted at or after this time
+	Since time.Time
+	// Last restores only the rows of the most recent delete
+	Last bool
+}
+
+// RestoreRows undeletes rows of a soft-delete table and returns how many
+func RestoreRows(dbPath, tableName string, opts RestoreOptions) (int64, error) {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	soft, err := IsSoftDelete(db, tableName)
+	if err != nil {
+		return 0, err
+	}
+	if !soft {
+		return 0, fmt.Errorf("%s is not in soft-delete mode, so it has no deleted rows to restore", tableName)
+	}
+
+	table, column := QuoteTableName(tableName), QuoteIdentifier(SoftDeleteColumn)
+	conditions := []string{column + " IS NOT NULL"}
+	var args []interface{}
+	if opts.Where != "" {
+		conditions = append(conditions, "("+opts.Where+")")
+	}
+	if !opts.Since.IsZero() {
+		conditions = append(conditions, column+" >= ?")
+		args = append(args, opts.Since.UTC())
+	}
+	if opts.Last {
+		conditions = append(conditions, fmt.Sprintf("%s = (SELECT max(%s) FROM %s)", column, column, table))
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s", table, column, strings.Join(conditions, " AND "))
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore rows: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
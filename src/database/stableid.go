@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StableIDOptions configures stable identifier assignment
+type StableIDOptions struct {
+	// TargetColumn receives the identifier
+	TargetColumn string
+	// KeyColumns are attributes hashed together with the geometry, or required to
+	// match the reference feature
+	KeyColumns []string
+	// GridSize snaps coordinates before hashing so tiny numeric noise between
+	// releases doesn't change the identifier
+	GridSize float64
+	// ReferenceTable switches to matching: each feature takes the id of the
+	// nearest reference feature within Tolerance, GERS-style
+	ReferenceTable string
+	// ReferenceIDColumn is the id column in ReferenceTable
+	ReferenceIDColumn string
+	// Tolerance is the maximum distance to a reference feature, in CRS units
+	Tolerance float64
+}
+
+// StableIDResult summarizes a stable identifier assignment
+type StableIDResult struct {
+	Rows int64
+	// Assigned counts rows that received an identifier
+	Assigned int64
+	// Duplicates counts rows sharing an identifier with an earlier row
+	Duplicates int64
+}
+
+// AssignStableIDs stores a content-based identifier for each feature. By
+// default the identifier is the MD5 of the normalized geometry and the key
+// attributes, so the same entity gets the same id in every release of a
+// dataset. With a reference table, ids are copied from matching reference
+// features instead and unmatched features are left NULL.
+func AssignStableIDs(dbPath, tableName string, opts StableIDOptions) (StableIDResult, error) {
+	var result StableIDResult
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := QuoteTableName(tableName)
+	target := QuoteIdentifier(opts.TargetColumn)
+
+	addSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s VARCHAR", table, target)
+	if _, err := tx.Exec(addSQL); err != nil {
+		return result, fmt.Errorf("failed to add %s column: %w", opts.TargetColumn, err)
+	}
+
+	var updateSQL string
+	if opts.ReferenceTable != "" {
+		updateSQL = matchReferenceSQL(tableName, opts)
+	} else {
+		updateSQL = fmt.Sprintf("UPDATE %s SET %s = %s", table, target, stableHashSQL(opts))
+	}
+	if _, err := tx.Exec(updateSQL); err != nil {
+		return result, fmt.Errorf("failed to assign stable ids: %w", err)
+	}
+
+	countSQL := fmt.Sprintf("SELECT COUNT(*), COUNT(%s), COUNT(%s) - COUNT(DISTINCT %s) FROM %s", target, target, target, table)
+	if err := tx.QueryRow(countSQL).Scan(&result.Rows, &result.Assigned, &result.Duplicates); err != nil {
+		return result, fmt.Errorf("failed to count assigned ids: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return result, nil
+}
+
+// stableHashSQL hashes the snapped, normalized geometry together with the key
+// attributes. json_array keeps NULLs and value boundaries unambiguous.
+func stableHashSQL(opts StableIDOptions) string {
+	parts := []string{fmt.Sprintf("hex(ST_AsWKB(ST_Normalize(ST_ReducePrecision(geom, %g))))", opts.GridSize)}
+	for _, key := range opts.KeyColumns {
+		parts = append(parts, QuoteIdentifier(key))
+	}
+	return fmt.Sprintf("md5(json_array(%s)::VARCHAR)", strings.Join(parts, ", "))
+}
+
+// matchReferenceSQL copies the id of the closest reference feature within the
+// tolerance, comparing centroids so polygons of slightly different outline
+// still pair up. Key columns must be equal on both sides.
+func matchReferenceSQL(tableName string, opts StableIDOptions) string {
+	table := QuoteTableName(tableName)
+	conditions := []string{fmt.Sprintf("ST_DWithin(r.geom, %s.geom, %g)", table, opts.Tolerance)}
+	for _, key := range opts.KeyColumns {
+		conditions = append(conditions, fmt.Sprintf("r.%s IS NOT DISTINCT FROM %s.%s", QuoteIdentifier(key), table, QuoteIdentifier(key)))
+	}
+
+	return fmt.Sprintf(`
+		UPDATE %s SET %s = (
+			SELECT r.%s::VARCHAR
+			FROM %s r
+			WHERE %s
+			ORDER BY ST_Distance(ST_Centroid(r.geom), ST_Centroid(%s.geom))
+			LIMIT 1
+		)
+	`, table, QuoteIdentifier(opts.TargetColumn), QuoteIdentifier(opts.ReferenceIDColumn),
+		QuoteTableName(opts.ReferenceTable), strings.Join(conditions, "\n\t\t\t\tAND "), table)
+}
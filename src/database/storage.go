@@ -0,0 +1,150 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ColumnStorage describes how one column is stored on disk
+type ColumnStorage struct {
+	Name        string
+	Compression string
+	Segments    int
+	// Bytes is estimated from block offsets, as DuckDB does not report segment sizes
+	Bytes int64
+}
+
+// TableStorage summarizes the on-disk footprint of a table
+type TableStorage struct {
+	Rows        int64
+	RowGroups   int
+	MaxGroupRow int64
+	MinGroupRow int64
+	Bytes       int64
+	FileBytes   int64
+	Columns     []ColumnStorage
+}
+
+// GetTableStorage reports compressed size, row-group layout and per-column storage for a table.
+// The database is checkpointed first so that all data is on disk and measurable.
+func GetTableStorage(dbPath, tableName string) (TableStorage, error) {
+	var stats TableStorage
+
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return stats, err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CHECKPOINT"); err != nil {
+		return stats, fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+
+	var blockSize int64
+	if err := db.QueryRow("SELECT block_size FROM pragma_database_size() WHERE database_name = current_database()").Scan(&blockSize); err != nil {
+		return stats, fmt.Errorf("failed to read block size: %w", err)
+	}
+
+	// Segments packed into the same block are sized by the gap to the next offset
+	segmentsSQL := fmt.Sprintf(`
+		WITH segs AS (
+			SELECT row_group_id, column_name, compression, count, block_id, block_offset,
+				len(COALESCE(additional_block_ids, [])) AS extra_blocks
+			FROM pragma_storage_info(%s)
+			WHERE persistent AND block_id >= 0
+		), sized AS (
+			SELECT *,
+				COALESCE(LEAD(block_offset) OVER (PARTITION BY block_id ORDER BY block_offset), ?) - block_offset
+					+ extra_blocks * ? AS bytes
+			FROM segs
+		)
+		SELECT column_name, string_agg(DISTINCT compression, ', ' ORDER BY compression), COUNT(*), SUM(bytes)::BIGINT
+		FROM sized
+		GROUP BY column_name
+		ORDER BY MIN(row_group_id), column_name
+	`, QuoteLiteral(tableName))
+
+	rows, err := db.Query(segmentsSQL, blockSize, blockSize)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query storage info: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var col ColumnStorage
+		if err := rows.Scan(&col.Name, &col.Compression, &col.Segments, &col.Bytes); err != nil {
+			return stats, fmt.Errorf("failed to scan storage info: %w", err)
+		}
+		stats.Bytes += col.Bytes
+		stats.Columns = append(stats.Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	// Row counts per row group come from the first column's segments
+	groupsSQL := fmt.Sprintf(`
+		SELECT COUNT(*), COALESCE(SUM(n), 0)::BIGINT, COALESCE(MIN(n), 0)::BIGINT, COALESCE(MAX(n), 0)::BIGINT
+		FROM (
+			SELECT row_group_id, SUM(count) AS n
+			FROM pragma_storage_info(%s)
+			WHERE column_id = 0 AND column_path = '[0]'
+			GROUP BY row_group_id
+		)
+	`, QuoteLiteral(tableName))
+	if err := db.QueryRow(groupsSQL).Scan(&stats.RowGroups, &stats.Rows, &stats.MinGroupRow, &stats.MaxGroupRow); err != nil {
+		return stats, fmt.Errorf("failed to query row groups: %w", err)
+	}
+
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return stats, fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	if info, err := os.Stat(absPath); err == nil {
+		stats.FileBytes = info.Size()
+	}
+
+	return stats, nil
+}
+
+// RewriteTable rebuilds a table from scratch so DuckDB can pick fresh compression
+// for every segment, then checkpoints to release the old blocks.
+// Constraints and defaults are not carried over.
+func RewriteTable(dbPath, tableName string) error {
+	db, err := openWithSpatial(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// RENAME TO takes a bare name; the table stays in its schema
+	_, bareName := SplitTableName(tableName)
+	tmpName := QuoteTableName(tableName + "__rewrite")
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s", tmpName, QuoteTableName(tableName)),
+		fmt.Sprintf("DROP TABLE %s", QuoteTableName(tableName)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmpName, QuoteIdentifier(bareName)),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rewrite table: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	if _, err := db.Exec("CHECKPOINT"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+
+	return nil
+}
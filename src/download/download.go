@@ -0,0 +1,372 @@
+package download
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+// Options controls how a URL is fetched
+type Options struct {
+	// CacheDir holds completed and partial downloads (default: DefaultCacheDir())
+	CacheDir string
+	// SHA256 is an optional expected hex digest of the content
+	SHA256 string
+	// VerifyETag checks the content against plain MD5 ETags (as served by S3)
+	VerifyETag bool
+	// Retries is the number of extra attempts after a failed transfer
+	Retries int
+	// Client overrides the HTTP client used for requests
+	Client *http.Client
+	// Extension is appended to the cached file's name, e.g. ".geojson", so
+	// its format can be told from the path
+	Extension string
+}
+
+// meta is stored next to each cached file
+type meta struct {
+	URL    string `json:"url"`
+	ETag   string `json:"etag,omitempty"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// partMeta is stored next to a partial download: the validators of the
+// response it began with, so a resume only appends bytes of the same content
+type partMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// validator returns the If-Range value a resume is sent with. Weak ETags
+// can't be used for ranges, so they fall back to Last-Modified.
+func (m partMeta) validator() string {
+	if m.ETag != "" && !strings.HasPrefix(m.ETag, "W/") {
+		return m.ETag
+	}
+	return m.LastModified
+}
+
+// matches reports whether a response carries the validators the partial
+// download began with
+func (m partMeta) matches(resp *http.Response) bool {
+	if etag := resp.Header.Get("ETag"); etag != "" || m.ETag != "" {
+		return etag == m.ETag
+	}
+	return resp.Header.Get("Last-Modified") == m.LastModified
+}
+
+// DefaultCacheDir returns the per-user download cache directory
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv("XYZDUCK_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(base, "xyzduck", "downloads"), nil
+}
+
+// Fetch downloads url into the cache and returns the path of the cached file.
+//
+// Interrupted transfers resume from where they stopped using HTTP range requests.
+// A cached copy is revalidated with its ETag and reused when unchanged (or when the
+// server can't be reached). Content is verified against opts.SHA256 when given, and
+// with opts.VerifyETag against a plain MD5 ETag as served by S3.
+func Fetch(url string, opts Options) (string, error) {
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		dir, err := DefaultCacheDir()
+		if err != nil {
+			return "", err
+		}
+		cacheDir = dir
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Minute}
+	}
+
+	key := sha256.Sum256([]byte(url))
+	base := filepath.Join(cacheDir, hex.EncodeToString(key[:16]))
+	dataPath, partPath, metaPath := base+opts.Extension, base+".part", base+".json"
+	partMetaPath := base + ".part.json"
+
+	// Reuse a cached copy if the server says it hasn't changed. Offline the
+	// cache is all there is, so it's used without asking.
+	if cached, ok := readMeta(metaPath); ok && fileExists(dataPath) {
+		fresh := offline.Enabled()
+		var err error
+		if !fresh {
+			fresh, err = revalidate(client, url, cached.ETag)
+		}
+		if err != nil || fresh {
+			if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, cached.SHA256) {
+				return "", fmt.Errorf("cached download of %s does not match expected sha256 %s", url, opts.SHA256)
+			}
+			return dataPath, nil
+		}
+	}
+
+	if offline.Enabled() {
+		return "", fmt.Errorf("%s is not in the download cache (%s) and offline mode is enabled; download it on a connected machine and copy the cache directory over", url, cacheDir)
+	}
+
+	var etag string
+	var lastErr error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 2 * time.Second)
+		}
+
+		etag, lastErr = fetchPart(client, url, partPath, partMetaPath)
+		if lastErr == nil {
+			break
+		}
+	}
+	if lastErr != nil {
+		return "", lastErr
+	}
+
+	checkETag := ""
+	if opts.VerifyETag {
+		checkETag = etag
+	}
+	sum, size, err := verify(partPath, opts.SHA256, checkETag)
+	if err != nil {
+		// Corrupt data can't be resumed, start over next time
+		os.Remove(partPath)
+		os.Remove(partMetaPath)
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+
+	if err := os.Rename(partPath, dataPath); err != nil {
+		return "", fmt.Errorf("failed to store download: %w", err)
+	}
+	os.Remove(partMetaPath)
+
+	data, _ := json.Marshal(meta{URL: url, ETag: etag, SHA256: sum, Size: size})
+	if err := os.WriteFile(metaPath, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+
+	return dataPath, nil
+}
+
+// fetchPart downloads into partPath, resuming from its current size when the
+// server supports ranges. A resume is sent with If-Range, so content changed
+// since the partial download began comes back whole and replaces it. It
+// returns the content's ETag.
+func fetchPart(client *http.Client, url, partPath, partMetaPath string) (string, error) {
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+	part, ok := readPartMeta(partMetaPath)
+	if offset > 0 && (!ok || part.URL != url || part.validator() == "") {
+		// Without a validator there's no telling whether the bytes so far are
+		// of the current content
+		if err := discardPart(partPath, partMetaPath); err != nil {
+			return "", err
+		}
+		offset = 0
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", url, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		req.Header.Set("If-Range", part.validator())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if offset == 0 {
+			return "", fmt.Errorf("failed to download %s: unexpected %s", url, resp.Status)
+		}
+		if !part.matches(resp) {
+			// A server ignoring If-Range sent a range of other content
+			resp.Body.Close()
+			if err := discardPart(partPath, partMetaPath); err != nil {
+				return "", err
+			}
+			return fetchPart(client, url, partPath, partMetaPath)
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// Nothing to resume, the server ignored the range or the content
+		// changed: start over, remembering the new content's validators
+		flags |= os.O_TRUNC
+		part = partMeta{URL: url, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		data, _ := json.Marshal(part)
+		if err := os.WriteFile(partMetaPath, data, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write download metadata: %w", err)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is complete when it is as long as the content and
+		// the content is the same; otherwise it shrank upstream
+		if offset > 0 && part.matches(resp) && contentLength(resp) == offset {
+			return part.ETag, nil
+		}
+		resp.Body.Close()
+		if err := discardPart(partPath, partMetaPath); err != nil {
+			return "", err
+		}
+		if offset == 0 {
+			return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+		}
+		return fetchPart(client, url, partPath, partMetaPath)
+	default:
+		return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open download file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", fmt.Errorf("download of %s interrupted: %w", url, err)
+	}
+
+	return part.ETag, nil
+}
+
+// contentLength returns the complete length a 416 answer gives in its
+// Content-Range ("bytes */length"), or -1
+func contentLength(resp *http.Response) int64 {
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// discardPart removes a partial download that can't be resumed
+func discardPart(partPath, partMetaPath string) error {
+	if err := os.Remove(partPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale partial download: %w", err)
+	}
+	os.Remove(partMetaPath)
+	return nil
+}
+
+// revalidate asks the server whether the cached ETag is still current
+func revalidate(client *http.Client, url, etag string) (bool, error) {
+	if etag == "" {
+		return false, nil
+	}
+
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("If-None-Match", etag)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusNotModified || resp.Header.Get("ETag") == etag, nil
+}
+
+// verify hashes the file, checking it against the expected SHA-256 and, for plain
+// MD5-style ETags, the ETag itself
+func verify(path, expectedSHA256, etag string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open download: %w", err)
+	}
+	defer f.Close()
+
+	shaHash := sha256.New()
+	var md5Hash hash.Hash
+	writers := []io.Writer{shaHash}
+
+	etagMD5 := strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	if len(etagMD5) == 32 && !strings.HasPrefix(etag, "W/") && isHex(etagMD5) {
+		md5Hash = md5.New()
+		writers = append(writers, md5Hash)
+	}
+
+	size, err := io.Copy(io.MultiWriter(writers...), f)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read download: %w", err)
+	}
+
+	sum := hex.EncodeToString(shaHash.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(sum, expectedSHA256) {
+		return "", 0, fmt.Errorf("sha256 mismatch: expected %s, got %s", expectedSHA256, sum)
+	}
+
+	// Only S3-style ETags are content MD5s; anything else is opaque and skipped
+	if md5Hash != nil && expectedSHA256 == "" {
+		if got := hex.EncodeToString(md5Hash.Sum(nil)); !strings.EqualFold(got, etagMD5) {
+			return "", 0, fmt.Errorf("ETag checksum mismatch: expected %s, got %s", etagMD5, got)
+		}
+	}
+
+	return sum, size, nil
+}
+
+func readPartMeta(path string) (partMeta, bool) {
+	var m partMeta
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, false
+	}
+	return m, json.Unmarshal(data, &m) == nil
+}
+
+func readMeta(path string) (meta, bool) {
+	var m meta
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, false
+	}
+	return m, json.Unmarshal(data, &m) == nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
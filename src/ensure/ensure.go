@@ -0,0 +1,362 @@
+package ensure
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/offline"
+	"org.xyzmaps.xyzduck/src/schema"
+)
+
+// probeView is the temporary view used to normalize view definitions
+const probeView = "xyzduck_ensure_probe"
+
+// Action is one change needed to reach the declared state
+type Action struct {
+	// Op is "+" for objects to create and "~" for objects to change
+	Op string
+	// Description says what the action does, e.g. "create schema staging"
+	Description string
+	statements  []string
+	// extension is the extension the action installs, if any
+	extension string
+}
+
+// Plan lists the changes that bring a database to a declared state
+type Plan struct {
+	Actions []Action
+	// Warnings report differences ensure doesn't fix, e.g. column types
+	Warnings []string
+	// UpToDate counts the declared objects that need no change
+	UpToDate int
+
+	extensions []string
+}
+
+// NewPlan compares a database with the declared state. A database that
+// doesn't exist yet is planned as empty.
+func NewPlan(dbPath string, state *State) (*Plan, error) {
+	p := &Plan{extensions: state.Extensions}
+	exists := database.FileExists(dbPath)
+
+	// Table columns are read before the planning connection is opened
+	tables := make([]tablePlan, len(state.Tables))
+	for i, t := range state.Tables {
+		tp, err := loadTable(t)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			tp.existing, err = database.GetTableSchema(dbPath, tp.name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		tables[i] = tp
+	}
+
+	var db *sql.DB
+	var err error
+	if exists {
+		db, err = database.Open(dbPath)
+	} else {
+		db, err = database.OpenInMemory()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// The probe view is temporary, so planning must stay on one connection
+	db.SetMaxOpenConns(1)
+
+	installed, err := queryNames(db, "SELECT extension_name FROM duckdb_extensions() WHERE installed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extensions: %w", err)
+	}
+	for _, ext := range state.Extensions {
+		if installed[strings.ToLower(ext)] {
+			// View definitions may use the extension's functions
+			db.Exec("LOAD " + database.QuoteIdentifier(ext))
+			p.UpToDate++
+			continue
+		}
+		p.add("+", "install extension "+ext, "INSTALL "+database.QuoteIdentifier(ext))
+		p.Actions[len(p.Actions)-1].extension = ext
+	}
+	if installed["spatial"] {
+		db.Exec("LOAD spatial")
+	}
+
+	schemas, err := queryNames(db, "SELECT schema_name FROM duckdb_schemas() WHERE database_name = current_database()")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	for _, s := range state.Schemas {
+		if schemas[strings.ToLower(s)] {
+			p.UpToDate++
+			continue
+		}
+		p.add("+", "create schema "+s, "CREATE SCHEMA IF NOT EXISTS "+database.QuoteIdentifier(s))
+	}
+
+	for _, t := range tables {
+		p.planTable(t)
+	}
+	for _, v := range state.Views {
+		if err := p.planView(db, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, idx := range state.Indexes {
+		if err := p.planIndex(db, idx); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *Plan) add(op, description string, statements ...string) {
+	p.Actions = append(p.Actions, Action{Op: op, Description: description, statements: statements})
+}
+
+// tablePlan is a declared table with its current columns
+type tablePlan struct {
+	name     string
+	columns  []database.Column
+	existing []database.Column
+}
+
+// loadTable reads a table's schema file
+func loadTable(t Table) (tablePlan, error) {
+	file, err := schema.Load(t.File)
+	if err != nil {
+		return tablePlan{}, err
+	}
+	tp := tablePlan{name: t.Table}
+	if tp.name == "" {
+		tp.name = file.Table
+	}
+	if tp.name == "" {
+		return tp, fmt.Errorf("schema file %s has no table name; set table in the state file", t.File)
+	}
+	for _, col := range file.Columns {
+		if col.Type == "" {
+			return tp, fmt.Errorf("schema file %s: column %s has no type", t.File, col.Name)
+		}
+		tp.columns = append(tp.columns, database.Column{Name: col.Name, Type: col.Type})
+	}
+	if len(tp.columns) == 0 {
+		return tp, fmt.Errorf("schema file %s has no columns", t.File)
+	}
+	return tp, nil
+}
+
+// planTable creates a missing table or adds its missing columns, like
+// 'schema apply'
+func (p *Plan) planTable(t tablePlan) {
+	quoted := database.QuoteTableName(t.name)
+	if len(t.existing) == 0 {
+		var defs []string
+		for _, col := range t.columns {
+			defs = append(defs, database.QuoteIdentifier(col.Name)+" "+col.Type)
+		}
+		p.add("+", fmt.Sprintf("create table %s (%d columns)", t.name, len(t.columns)),
+			append(schemaStatements(t.name), fmt.Sprintf("CREATE TABLE %s (%s)", quoted, strings.Join(defs, ", ")))...)
+		return
+	}
+
+	current := make(map[string]database.Column, len(t.existing))
+	for _, col := range t.existing {
+		current[strings.ToLower(col.Name)] = col
+	}
+	changed := false
+	for _, col := range t.columns {
+		have, ok := current[strings.ToLower(col.Name)]
+		if !ok {
+			p.add("~", fmt.Sprintf("add column %s.%s %s", t.name, col.Name, col.Type),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoted, database.QuoteIdentifier(col.Name), col.Type))
+			changed = true
+			continue
+		}
+		if !strings.EqualFold(have.Type, col.Type) {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("column %s.%s is %s but declared %s (left unchanged)", t.name, col.Name, have.Type, col.Type))
+		}
+	}
+	if !changed {
+		p.UpToDate++
+	}
+}
+
+// planView creates a missing view and replaces one whose query differs.
+// Queries are compared as DuckDB normalizes them, so formatting changes
+// alone don't replace a view.
+func (p *Plan) planView(db *sql.DB, v View) error {
+	schemaName, name := database.SplitTableName(v.Name)
+	quoted := database.QuoteTableName(v.Name)
+	query := strings.TrimSuffix(strings.TrimSpace(v.SQL), ";")
+
+	var current string
+	err := db.QueryRow(`SELECT sql FROM duckdb_views()
+		WHERE database_name = current_database() AND schema_name = ? AND view_name = ?`, schemaName, name).Scan(&current)
+	if err == sql.ErrNoRows {
+		p.add("+", "create view "+v.Name,
+			append(schemaStatements(v.Name), fmt.Sprintf("CREATE VIEW %s AS %s", quoted, query))...)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read view %s: %w", v.Name, err)
+	}
+
+	if wanted, ok := normalizeView(db, query); ok && viewBody(wanted) == viewBody(current) {
+		p.UpToDate++
+		return nil
+	}
+	p.add("~", "replace view "+v.Name, fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s", quoted, query))
+	return nil
+}
+
+// normalizeView returns DuckDB's rendering of a view query, or false when
+// the query doesn't bind yet (e.g. it uses a table the plan creates)
+func normalizeView(db *sql.DB, query string) (string, bool) {
+	if _, err := db.Exec(fmt.Sprintf("CREATE OR REPLACE TEMP VIEW %s AS %s", probeView, query)); err != nil {
+		return "", false
+	}
+	defer db.Exec("DROP VIEW IF EXISTS " + probeView)
+
+	var rendered string
+	if err := db.QueryRow("SELECT sql FROM duckdb_views() WHERE temporary AND view_name = ?", probeView).Scan(&rendered); err != nil {
+		return "", false
+	}
+	return rendered, true
+}
+
+// viewBody strips the CREATE VIEW ... AS prefix from a view's SQL
+func viewBody(viewSQL string) string {
+	if i := strings.Index(viewSQL, " AS "); i >= 0 {
+		return viewSQL[i+4:]
+	}
+	return viewSQL
+}
+
+// planIndex creates a missing index. Existing indexes are not rebuilt.
+func (p *Plan) planIndex(db *sql.DB, idx Index) error {
+	schemaName, _ := database.SplitTableName(idx.Table)
+
+	var unique bool
+	err := db.QueryRow(`SELECT is_unique FROM duckdb_indexes()
+		WHERE database_name = current_database() AND schema_name = ? AND index_name = ?`, schemaName, idx.Name).Scan(&unique)
+	if err == sql.ErrNoRows {
+		kind := "INDEX"
+		if idx.Unique {
+			kind = "UNIQUE INDEX"
+		}
+		p.add("+", fmt.Sprintf("create index %s on %s (%s)", idx.Name, idx.Table, strings.Join(idx.Columns, ", ")),
+			fmt.Sprintf("CREATE %s %s ON %s (%s)", kind, database.QuoteIdentifier(idx.Name), database.QuoteTableName(idx.Table), database.QuoteIdentifiers(idx.Columns)))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read index %s: %w", idx.Name, err)
+	}
+
+	if unique != idx.Unique {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("index %s exists with unique=%t but is declared unique=%t (left unchanged)", idx.Name, unique, idx.Unique))
+	}
+	p.UpToDate++
+	return nil
+}
+
+// Apply runs a plan's actions. Extensions are installed first; every other
+// change is made in a single transaction, so a failure leaves the database
+// as it was.
+func (p *Plan) Apply(dbPath string) error {
+	// Installing downloads the extension, so offline nothing is applied when
+	// one is missing
+	for _, a := range p.Actions {
+		if a.extension == "" {
+			continue
+		}
+		if err := offline.Check("installing extension " + a.extension); err != nil {
+			return fmt.Errorf("%w; pre-seed it to apply this plan", err)
+		}
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, a := range p.Actions {
+		if a.extension == "" {
+			continue
+		}
+		for _, stmt := range a.statements {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to %s: %w", a.Description, err)
+			}
+		}
+	}
+
+	// Column types and view queries may come from the extensions
+	for _, ext := range p.extensions {
+		if _, err := db.Exec("LOAD " + database.QuoteIdentifier(ext)); err != nil {
+			return fmt.Errorf("failed to load extension %s: %w", ext, err)
+		}
+	}
+	if err := database.LoadSpatial(db); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, a := range p.Actions {
+		if a.extension != "" {
+			continue
+		}
+		for _, stmt := range a.statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to %s: %w", a.Description, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// schemaStatements creates the schema of a qualified object name
+func schemaStatements(name string) []string {
+	schemaName, _ := database.SplitTableName(name)
+	if schemaName == "main" {
+		return nil
+	}
+	return []string{"CREATE SCHEMA IF NOT EXISTS " + database.QuoteIdentifier(schemaName)}
+}
+
+// queryNames returns the lower-cased values of a single-column query
+func queryNames(db *sql.DB, query string) (map[string]bool, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,81 @@
+package ensure
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// State is the declared layout of a database
+type State struct {
+	// DB is the database the state applies to
+	DB string `yaml:"db,omitempty"`
+	// Extensions are installed if missing
+	Extensions []string `yaml:"extensions,omitempty"`
+	// Schemas are created if missing
+	Schemas []string `yaml:"schemas,omitempty"`
+	Tables  []Table  `yaml:"tables,omitempty"`
+	Views   []View   `yaml:"views,omitempty"`
+	Indexes []Index  `yaml:"indexes,omitempty"`
+}
+
+// Table declares a table by its schema file
+type Table struct {
+	// File is a schema file as written by 'schema export', relative to the
+	// state file
+	File string `yaml:"file"`
+	// Table overrides the schema file's table name
+	Table string `yaml:"table,omitempty"`
+}
+
+// View declares a view by its query
+type View struct {
+	Name string `yaml:"name"`
+	SQL  string `yaml:"sql"`
+}
+
+// Index declares an index on table columns
+type Index struct {
+	Name    string   `yaml:"name"`
+	Table   string   `yaml:"table"`
+	Columns []string `yaml:"columns"`
+	Unique  bool     `yaml:"unique,omitempty"`
+}
+
+// LoadState reads and checks a state file. Schema file paths are resolved
+// against the state file's directory.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s State
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	for i, t := range s.Tables {
+		if strings.TrimSpace(t.File) == "" {
+			return nil, fmt.Errorf("state file: table %d has no schema file", i+1)
+		}
+		if !filepath.IsAbs(t.File) {
+			s.Tables[i].File = filepath.Join(dir, t.File)
+		}
+	}
+	for i, v := range s.Views {
+		if strings.TrimSpace(v.Name) == "" || strings.TrimSpace(v.SQL) == "" {
+			return nil, fmt.Errorf("state file: view %d needs a name and sql", i+1)
+		}
+	}
+	for i, idx := range s.Indexes {
+		if strings.TrimSpace(idx.Name) == "" || strings.TrimSpace(idx.Table) == "" || len(idx.Columns) == 0 {
+			return nil, fmt.Errorf("state file: index %d needs a name, a table and columns", i+1)
+		}
+	}
+	return &s, nil
+}
@@ -0,0 +1,408 @@
+package features
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// ErrNotFound is returned for keys no feature of the table has
+var ErrNotFound = errors.New("feature not found")
+
+// EditError is an edit refused for what it asks, such as a column the table
+// doesn't have, rather than a failure to make it
+type EditError struct {
+	Message string
+}
+
+func (e *EditError) Error() string {
+	return e.Message
+}
+
+// invalidEdit returns an *EditError
+func invalidEdit(format string, args ...any) error {
+	return &EditError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Feature is a GeoJSON feature of the table. ID holds the key column's value
+// and is also in Properties.
+type Feature struct {
+	Type       string          `json:"type"`
+	ID         any             `json:"id,omitempty"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// Edits is a batch of changes applied in one transaction
+type Edits struct {
+	// Insert adds features; one without a key gets the column's default,
+	// the next integer or a random UUID
+	Insert []Feature `json:"insert,omitempty"`
+	// Update replaces the geometry, when given, and the given properties of
+	// the features with the keys in their id
+	Update []Feature `json:"update,omitempty"`
+	// Delete lists the keys of the features to delete
+	Delete []any `json:"delete,omitempty"`
+}
+
+// Result says what a batch of edits changed
+type Result struct {
+	// Inserted lists the keys of the inserted features, in order
+	Inserted []json.RawMessage `json:"inserted"`
+	Updated  int               `json:"updated"`
+	Deleted  int               `json:"deleted"`
+}
+
+// Column is an attribute column features can be edited in
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Store reads and edits the features of one table through a connection
+// shared with the code serving it, identifying them by a key column
+type Store struct {
+	db         *sql.DB
+	table      string
+	key        Column
+	keyDefault bool
+	geomColumn string
+	columns    []Column
+	// soft is set for tables in soft-delete mode, whose deletes stamp rows
+	soft bool
+}
+
+// NewStore opens a table for editing. key names the column identifying
+// features; empty uses the table's primary key.
+func NewStore(db *sql.DB, table, key string) (*Store, error) {
+	s := &Store{db: db, table: table}
+	schema, name := database.SplitTableName(table)
+	rows, err := db.Query(`
+		SELECT column_name, data_type, column_default IS NOT NULL
+		FROM information_schema.columns
+		WHERE table_schema = ? AND table_name = ?
+		ORDER BY ordinal_position
+	`, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table schema: %w", err)
+	}
+	defaults := make(map[string]bool)
+	for rows.Next() {
+		var col Column
+		var hasDefault bool
+		if err := rows.Scan(&col.Name, &col.Type, &hasDefault); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan column info: %w", err)
+		}
+		defaults[col.Name] = hasDefault
+		if strings.HasPrefix(strings.ToUpper(col.Type), "GEOMETRY") {
+			if s.geomColumn == "" {
+				s.geomColumn = col.Name
+			}
+			continue
+		}
+		s.columns = append(s.columns, col)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	if len(defaults) == 0 {
+		return nil, fmt.Errorf("table not found: %s", table)
+	}
+	if s.geomColumn == "" {
+		return nil, fmt.Errorf("table %s has no GEOMETRY column", table)
+	}
+
+	if s.soft, err = database.IsSoftDelete(db, table); err != nil {
+		return nil, err
+	}
+	if s.soft {
+		var live []Column
+		for _, col := range s.columns {
+			if col.Name != database.SoftDeleteColumn {
+				live = append(live, col)
+			}
+		}
+		s.columns = live
+	}
+
+	if key == "" {
+		if key, err = primaryKey(db, schema, name); err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, fmt.Errorf("table %s has no single-column primary key to identify features by; name a key column", table)
+		}
+	}
+	col, ok := s.column(key)
+	if !ok {
+		return nil, fmt.Errorf("key column %s not found in %s", key, table)
+	}
+	s.key, s.keyDefault = col, defaults[col.Name]
+	return s, nil
+}
+
+// primaryKey returns the column of a table's primary key, or "" when it has
+// none or one of several columns
+func primaryKey(db *sql.DB, schema, table string) (string, error) {
+	var column string
+	err := db.QueryRow(`
+		SELECT constraint_column_names[1]
+		FROM duckdb_constraints()
+		WHERE database_name = current_database() AND schema_name = ? AND table_name = ?
+			AND constraint_type = 'PRIMARY KEY' AND len(constraint_column_names) = 1
+	`, schema, table).Scan(&column)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up primary key: %w", err)
+	}
+	return column, nil
+}
+
+// Key returns the name of the column identifying features
+func (s *Store) Key() string {
+	return s.key.Name
+}
+
+// Columns lists the attribute columns, the key included
+func (s *Store) Columns() []Column {
+	return s.columns
+}
+
+// column finds an attribute column by name, ignoring case
+func (s *Store) column(name string) (Column, bool) {
+	for _, col := range s.columns {
+		if strings.EqualFold(col.Name, name) {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// Get returns the feature with a key, with its full geometry
+func (s *Store) Get(key any) (*Feature, error) {
+	pairs := make([]string, len(s.columns))
+	for i, col := range s.columns {
+		pairs[i] = fmt.Sprintf("%s, %s", database.QuoteLiteral(col.Name), database.QuoteIdentifier(col.Name))
+	}
+	query := fmt.Sprintf("SELECT ST_AsGeoJSON(%s)::VARCHAR, json_object(%s)::VARCHAR, to_json(%s)::VARCHAR FROM %s WHERE %s",
+		database.QuoteIdentifier(s.geomColumn), strings.Join(pairs, ", "), database.QuoteIdentifier(s.key.Name),
+		database.QuoteTableName(s.table), s.match())
+
+	var geometry sql.NullString
+	var properties, id string
+	err := s.db.QueryRow(query, arg(key)).Scan(&geometry, &properties, &id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature %v: %w", key, err)
+	}
+
+	f := &Feature{Type: "Feature", ID: json.RawMessage(id), Geometry: json.RawMessage("null")}
+	if geometry.Valid {
+		f.Geometry = json.RawMessage(geometry.String)
+	}
+	if err := json.Unmarshal([]byte(properties), &f.Properties); err != nil {
+		return nil, fmt.Errorf("failed to read feature %v: %w", key, err)
+	}
+	return f, nil
+}
+
+// Apply makes a batch of edits in one transaction: either all of them are
+// made or, on the first that fails, none is
+func (s *Store) Apply(edits Edits) (Result, error) {
+	result := Result{Inserted: []json.RawMessage{}}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, f := range edits.Insert {
+		id, err := s.insert(tx, f)
+		if err != nil {
+			return Result{}, fmt.Errorf("insert %d: %w", i+1, err)
+		}
+		result.Inserted = append(result.Inserted, id)
+	}
+	for i, f := range edits.Update {
+		if err := s.update(tx, f); err != nil {
+			return Result{}, fmt.Errorf("update %d: %w", i+1, err)
+		}
+		result.Updated++
+	}
+	for i, key := range edits.Delete {
+		if err := s.delete(tx, key); err != nil {
+			return Result{}, fmt.Errorf("delete %d: %w", i+1, err)
+		}
+		result.Deleted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
+
+// insert adds a feature and returns its key as JSON
+func (s *Store) insert(tx *sql.Tx, f Feature) (json.RawMessage, error) {
+	var columns, values []string
+	var args []any
+	if !isNull(f.Geometry) {
+		columns = append(columns, database.QuoteIdentifier(s.geomColumn))
+		values = append(values, "ST_GeomFromGeoJSON(?)")
+		args = append(args, string(f.Geometry))
+	}
+
+	key := f.ID
+	hasKey := false
+	for name, value := range f.Properties {
+		col, ok := s.column(name)
+		if !ok {
+			return nil, invalidEdit("unknown column %q", name)
+		}
+		if col.Name == s.key.Name {
+			key = value
+			continue
+		}
+		columns = append(columns, database.QuoteIdentifier(col.Name))
+		values = append(values, fmt.Sprintf("CAST(? AS %s)", col.Type))
+		args = append(args, arg(value))
+	}
+
+	// A feature without a key gets the next one
+	keyType := strings.ToUpper(s.key.Type)
+	switch {
+	case key != nil:
+		values = append(values, fmt.Sprintf("CAST(? AS %s)", s.key.Type))
+		args = append(args, arg(key))
+		hasKey = true
+	case s.keyDefault:
+	case strings.Contains(keyType, "INT"):
+		values = append(values, fmt.Sprintf("(SELECT coalesce(max(%s), 0) + 1 FROM %s)", database.QuoteIdentifier(s.key.Name), database.QuoteTableName(s.table)))
+		hasKey = true
+	case keyType == "UUID":
+		values = append(values, "uuid()")
+		hasKey = true
+	default:
+		return nil, invalidEdit("a value for the key column %s is required", s.key.Name)
+	}
+	if hasKey {
+		columns = append(columns, database.QuoteIdentifier(s.key.Name))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING to_json(%s)::VARCHAR",
+		database.QuoteTableName(s.table), strings.Join(columns, ", "), strings.Join(values, ", "), database.QuoteIdentifier(s.key.Name))
+	var id string
+	if err := tx.QueryRow(query, args...).Scan(&id); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(id), nil
+}
+
+// update replaces the geometry and properties an edit gives
+func (s *Store) update(tx *sql.Tx, f Feature) error {
+	key := f.ID
+	var sets []string
+	var args []any
+	if len(f.Geometry) > 0 {
+		if isNull(f.Geometry) {
+			sets = append(sets, database.QuoteIdentifier(s.geomColumn)+" = NULL")
+		} else {
+			sets = append(sets, database.QuoteIdentifier(s.geomColumn)+" = ST_GeomFromGeoJSON(?)")
+			args = append(args, string(f.Geometry))
+		}
+	}
+	for name, value := range f.Properties {
+		col, ok := s.column(name)
+		if !ok {
+			return invalidEdit("unknown column %q", name)
+		}
+		// The key identifies the feature; it isn't changed
+		if col.Name == s.key.Name {
+			if key == nil {
+				key = value
+			}
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = CAST(? AS %s)", database.QuoteIdentifier(col.Name), col.Type))
+		args = append(args, arg(value))
+	}
+	if key == nil {
+		return invalidEdit("no id given")
+	}
+	if len(sets) == 0 {
+		return invalidEdit("nothing to change for feature %v", key)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", database.QuoteTableName(s.table), strings.Join(sets, ", "), s.match())
+	return s.exec(tx, key, query, append(args, arg(key))...)
+}
+
+// delete deletes a feature, only stamping it in soft-delete tables
+func (s *Store) delete(tx *sql.Tx, key any) error {
+	if key == nil {
+		return invalidEdit("no id given")
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", database.QuoteTableName(s.table), s.match())
+	var args []any
+	if s.soft {
+		query = fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s", database.QuoteTableName(s.table), database.QuoteIdentifier(database.SoftDeleteColumn), s.match())
+		args = append(args, time.Now().UTC())
+	}
+	return s.exec(tx, key, query, append(args, arg(key))...)
+}
+
+// exec runs an edit of one feature, failing unless it changed exactly one row
+func (s *Store) exec(tx *sql.Tx, key any, query string, args ...any) error {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	switch n, _ := res.RowsAffected(); {
+	case n == 0:
+		return fmt.Errorf("%w: %v", ErrNotFound, key)
+	case n > 1:
+		return fmt.Errorf("key %v matches %d features; the key column must be unique", key, n)
+	}
+	return nil
+}
+
+// match is the condition selecting the live feature with the key bound to it
+func (s *Store) match() string {
+	cond := fmt.Sprintf("%s = CAST(? AS %s)", database.QuoteIdentifier(s.key.Name), s.key.Type)
+	if s.soft {
+		cond += fmt.Sprintf(" AND %s IS NULL", database.QuoteIdentifier(database.SoftDeleteColumn))
+	}
+	return cond
+}
+
+// arg turns a value decoded from JSON into a query argument cast to its
+// column's type: numbers keep their text, objects and arrays become JSON
+func arg(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case float64:
+		return fmt.Sprint(v)
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return v
+}
+
+func isNull(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
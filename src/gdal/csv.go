@@ -0,0 +1,168 @@
+package gdal
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/compressed"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// CSVOptions says where the geometry of a CSV file comes from. Exactly one of
+// Lon/Lat, WKT or WKB is used; with none set, common coordinate column names
+// are looked for.
+type CSVOptions struct {
+	Lon, Lat string
+	// WKT names a column holding geometries as well-known text
+	WKT string
+	// WKB names a column holding geometries as hex-encoded well-known binary
+	WKB string
+	// SourceCRS is the CRS of the coordinates (default WGS84); they're
+	// reprojected to WGS84 unless KeepCRS is set
+	SourceCRS string
+	KeepCRS   bool
+}
+
+// IsCSV reports whether path is a CSV or TSV file, possibly .gz or .zst
+// compressed, which DuckDB's reader decompresses itself
+func IsCSV(path string) bool {
+	switch strings.ToLower(filepath.Ext(compressed.Trim(path))) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// lonNames and latNames are the coordinate columns recognized without --lon/--lat
+var (
+	lonNames = []string{"lon", "lng", "long", "longitude", "x"}
+	latNames = []string{"lat", "latitude", "y"}
+)
+
+// LoadCSV reads a CSV or TSV file with DuckDB's CSV reader and creates or
+// appends to tableName, building the geom column from coordinate, WKT or WKB
+// columns. The geometry source columns aren't stored separately.
+func LoadCSV(dbPath, srcPath, tableName string, opts CSVOptions) (Result, error) {
+	if opts.SourceCRS == "" {
+		opts.SourceCRS = WGS84
+	}
+	result := Result{Renamed: make(map[string]string), SourceCRS: opts.SourceCRS}
+
+	absPath, err := filepath.Abs(srcPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve input path: %w", err)
+	}
+
+	tableExists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		return result, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return result, err
+	}
+
+	source := "read_csv_auto(" + database.QuoteLiteral(absPath)
+	if strings.EqualFold(filepath.Ext(absPath), ".tsv") {
+		source += ", delim := '\\t'"
+	}
+	source += ")"
+
+	fields, err := csvFields(db, source)
+	if err != nil {
+		return result, fmt.Errorf("failed to read %s: %w", srcPath, err)
+	}
+	if opts.Lon == "" && opts.Lat == "" && opts.WKT == "" && opts.WKB == "" {
+		opts.Lon, opts.Lat = findField(fields, lonNames), findField(fields, latNames)
+		if opts.Lon == "" || opts.Lat == "" {
+			return result, fmt.Errorf("can't tell where the geometry is; use --lon/--lat, --wkt or --wkb (columns: %s)", strings.Join(fields, ", "))
+		}
+	}
+
+	var geomExpr string
+	skip := make(map[string]bool)
+	switch {
+	case opts.WKT != "" || opts.WKB != "":
+		if opts.Lon != "" || opts.Lat != "" || (opts.WKT != "" && opts.WKB != "") {
+			return result, fmt.Errorf("use only one of --lon/--lat, --wkt and --wkb")
+		}
+		column, fn := opts.WKT, "ST_GeomFromText"
+		if opts.WKB != "" {
+			column, fn = opts.WKB, "ST_GeomFromHEXWKB"
+		}
+		if err := requireField(fields, column); err != nil {
+			return result, err
+		}
+		geomExpr = fmt.Sprintf("%s(%s)", fn, database.QuoteIdentifier(column))
+		skip[column] = true
+	default:
+		if opts.Lon == "" || opts.Lat == "" {
+			return result, fmt.Errorf("--lon and --lat must be given together")
+		}
+		for _, column := range []string{opts.Lon, opts.Lat} {
+			if err := requireField(fields, column); err != nil {
+				return result, err
+			}
+			skip[column] = true
+		}
+		geomExpr = fmt.Sprintf("ST_Point(TRY_CAST(%s AS DOUBLE), TRY_CAST(%s AS DOUBLE))",
+			database.QuoteIdentifier(opts.Lon), database.QuoteIdentifier(opts.Lat))
+	}
+
+	if !opts.KeepCRS && !strings.EqualFold(opts.SourceCRS, WGS84) {
+		geomExpr = reprojectSQL(geomExpr, opts.SourceCRS)
+		result.Reprojected = true
+	}
+
+	err = loadQuery(db, source, tableName, tableExists, geomExpr, skip, &result)
+	return result, err
+}
+
+// csvFields lists the columns DuckDB detects in a CSV source
+func csvFields(db *sql.DB, source string) ([]string, error) {
+	rows, err := db.Query("SELECT column_name FROM (DESCRIBE SELECT * FROM " + source + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fields []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		fields = append(fields, name)
+	}
+	return fields, rows.Err()
+}
+
+// findField returns the first field matching one of names, case-insensitively
+func findField(fields, names []string) string {
+	for _, name := range names {
+		for _, f := range fields {
+			if strings.EqualFold(f, name) {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// requireField checks that a column named on the command line exists
+func requireField(fields []string, name string) error {
+	for _, f := range fields {
+		if f == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("column %q not found (columns: %s)", name, strings.Join(fields, ", "))
+}
@@ -0,0 +1,327 @@
+package gdal
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/compressed"
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/geojson"
+)
+
+// WGS84 is the CRS loaded geometries are stored in, matching GeoJSON
+const WGS84 = "EPSG:4326"
+
+// Options controls how a GDAL-readable file is loaded
+type Options struct {
+	// Layer selects the layer of a multi-layer source such as a GeoPackage
+	Layer string
+	// SourceCRS overrides the CRS detected from the file (e.g. "EPSG:27700")
+	SourceCRS string
+	// KeepCRS stores geometries in their source CRS instead of reprojecting to WGS84
+	KeepCRS bool
+}
+
+// Result summarizes a completed load
+type Result struct {
+	RowCount int64
+	// SourceCRS is the detected (or overridden) CRS, empty when unknown
+	SourceCRS string
+	// Reprojected is set when geometries were transformed to WGS84
+	Reprojected bool
+	// Renamed maps source field names to the sanitized column names they were stored as
+	Renamed map[string]string
+}
+
+// Supported reports whether path is loaded through GDAL rather than the GeoJSON loader
+func Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".shp", ".gpkg", ".fgb":
+		return true
+	}
+	return false
+}
+
+// Load reads a file (or one of its layers) with ST_Read and creates or appends
+// to tableName. Field names are sanitized like GeoJSON property keys and the
+// geometry is stored in a geom column, reprojected to WGS84 unless
+// opts.KeepCRS is set. srcPath may be an HTTP(S) URL, e.g. of a FlatGeobuf
+// file, which is read in place.
+func Load(dbPath, srcPath, tableName string, opts Options) (Result, error) {
+	result := Result{Renamed: make(map[string]string)}
+
+	absPath, err := sourcePath(srcPath)
+	if err != nil {
+		return result, err
+	}
+
+	if strings.EqualFold(filepath.Ext(absPath), ".shp") {
+		if err := checkShapefileSidecars(absPath); err != nil {
+			return result, err
+		}
+	}
+
+	tableExists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		return result, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return result, err
+	}
+	if database.IsRemote(absPath) {
+		if err := database.ConfigureHTTP(db, absPath); err != nil {
+			return result, err
+		}
+	}
+
+	layers, err := readLayers(db, absPath)
+	if err != nil {
+		return result, err
+	}
+	layer, err := pickLayer(layers, opts.Layer)
+	if err != nil {
+		return result, err
+	}
+
+	// Shapefiles keep their CRS in the .prj; other formats report it per layer
+	result.SourceCRS = opts.SourceCRS
+	if result.SourceCRS == "" {
+		if strings.EqualFold(filepath.Ext(absPath), ".shp") {
+			if result.SourceCRS, err = DetectCRS(absPath); err != nil {
+				return result, err
+			}
+		} else {
+			result.SourceCRS = layer.CRS
+		}
+	}
+
+	source := fmt.Sprintf("ST_Read(%s, layer := %s)", database.QuoteLiteral(absPath), database.QuoteLiteral(layer.Name))
+
+	geomExpr := "geom"
+	if result.SourceCRS != "" && !opts.KeepCRS && !strings.EqualFold(result.SourceCRS, WGS84) {
+		geomExpr = reprojectSQL(geomExpr, result.SourceCRS)
+		result.Reprojected = true
+	}
+
+	err = loadQuery(db, source, tableName, tableExists, geomExpr, nil, &result)
+	return result, err
+}
+
+// reprojectSQL transforms geomExpr from crs to WGS84
+func reprojectSQL(geomExpr, crs string) string {
+	return fmt.Sprintf("ST_Transform(%s, %s, %s, always_xy := true)", geomExpr, database.QuoteLiteral(crs), database.QuoteLiteral(WGS84))
+}
+
+// loadQuery creates or appends to tableName from a table expression. Field
+// names are sanitized like GeoJSON property keys; GEOMETRY fields and the
+// fields in skip are left out, and geomExpr is stored as the geom column.
+func loadQuery(db *sql.DB, source, tableName string, tableExists bool, geomExpr string, skip map[string]bool, result *Result) error {
+	rows, err := db.Query("SELECT column_name, column_type FROM (DESCRIBE SELECT * FROM " + source + ")")
+	if err != nil {
+		return fmt.Errorf("failed to read fields: %w", err)
+	}
+	var selectCols []string
+	seen := map[string]bool{"geom": true}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan field: %w", err)
+		}
+		if strings.HasPrefix(typ, "GEOMETRY") || skip[name] {
+			continue
+		}
+
+		column := geojson.SanitizeColumnName(name)
+		if seen[strings.ToLower(column)] {
+			rows.Close()
+			return fmt.Errorf("field %q maps to column %q, which is already taken", name, column)
+		}
+		seen[strings.ToLower(column)] = true
+		if column != name {
+			result.Renamed[name] = column
+		}
+		selectCols = append(selectCols, fmt.Sprintf("%s AS %s", database.QuoteIdentifier(name), database.QuoteIdentifier(column)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating fields: %w", err)
+	}
+
+	selectCols = append(selectCols, geomExpr+" AS geom")
+	selectSQL := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectCols, ", "), source)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var loadSQL string
+	if tableExists {
+		loadSQL = fmt.Sprintf("INSERT INTO %s BY NAME %s", database.QuoteTableName(tableName), selectSQL)
+	} else {
+		if err := database.EnsureSchema(tx, tableName); err != nil {
+			return err
+		}
+		loadSQL = fmt.Sprintf("CREATE TABLE %s AS %s", database.QuoteTableName(tableName), selectSQL)
+	}
+
+	res, err := tx.Exec(loadSQL)
+	if err != nil {
+		return fmt.Errorf("failed to insert features: %w", err)
+	}
+	// CREATE TABLE AS doesn't report the rows it wrote, so count them
+	if tableExists {
+		if result.RowCount, err = res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+	} else {
+		if err := tx.QueryRow("SELECT COUNT(*) FROM " + database.QuoteTableName(tableName)).Scan(&result.RowCount); err != nil {
+			return fmt.Errorf("failed to count rows: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// Layer describes one layer of a GDAL data source
+type Layer struct {
+	Name     string
+	Features int64
+	// CRS is "AUTH:CODE" when the layer's CRS has an authority, otherwise its WKT
+	CRS string
+}
+
+// readLayers lists the layers of path using ST_Read_Meta
+func readLayers(db *sql.DB, path string) ([]Layer, error) {
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT l.name, l.feature_count,
+			COALESCE(l.geometry_fields[1].crs.auth_name, ''),
+			COALESCE(l.geometry_fields[1].crs.auth_code, ''),
+			COALESCE(l.geometry_fields[1].crs.wkt, '')
+		FROM (SELECT unnest(layers) AS l FROM ST_Read_Meta(%s))
+	`, database.QuoteLiteral(path)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layers of %s: %w", filepath.Base(path), err)
+	}
+	defer rows.Close()
+
+	var layers []Layer
+	for rows.Next() {
+		var layer Layer
+		var authName, authCode, wkt string
+		if err := rows.Scan(&layer.Name, &layer.Features, &authName, &authCode, &wkt); err != nil {
+			return nil, fmt.Errorf("failed to scan layer: %w", err)
+		}
+		if authName != "" && authCode != "" {
+			layer.CRS = authName + ":" + authCode
+		} else {
+			layer.CRS = crsFromWKT(wkt)
+		}
+		layers = append(layers, layer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating layers: %w", err)
+	}
+
+	return layers, nil
+}
+
+// pickLayer returns the named layer, or the only layer when name is empty
+func pickLayer(layers []Layer, name string) (Layer, error) {
+	var names []string
+	for _, l := range layers {
+		if name != "" && l.Name == name {
+			return l, nil
+		}
+		names = append(names, l.Name)
+	}
+
+	switch {
+	case len(layers) == 0:
+		return Layer{}, fmt.Errorf("source has no layers")
+	case name != "":
+		return Layer{}, fmt.Errorf("layer %q not found (available: %s)", name, strings.Join(names, ", "))
+	case len(layers) > 1:
+		return Layer{}, fmt.Errorf("source has %d layers, choose one with --layer: %s", len(layers), strings.Join(names, ", "))
+	}
+	return layers[0], nil
+}
+
+// checkShapefileSidecars makes sure the .shx index and .dbf attributes sit next to a .shp
+func checkShapefileSidecars(shpPath string) error {
+	var missing []string
+	for _, ext := range []string{".shx", ".dbf"} {
+		if sidecar(shpPath, ext) == "" {
+			missing = append(missing, ext)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("shapefile %s is missing its %s file(s)", filepath.Base(shpPath), strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// sidecar returns the path of the file next to path with the given extension,
+// in either case, or "" if there is none
+func sidecar(path, ext string) string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, candidate := range []string{base + ext, base + strings.ToUpper(ext)} {
+		if compressed.Exists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// DetectCRS returns the CRS of a shapefile from its .prj: an EPSG code when the
+// WKT names one, otherwise the WKT itself (which PROJ understands). It returns
+// "" when there is no .prj.
+func DetectCRS(path string) (string, error) {
+	prj := sidecar(path, ".prj")
+	if prj == "" {
+		return "", nil
+	}
+
+	data, err := compressed.ReadFile(prj)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filepath.Base(prj), err)
+	}
+	return crsFromWKT(string(data)), nil
+}
+
+var (
+	// WKT1 puts the top-level AUTHORITY last; WKT2 uses ID
+	wktAuthority = regexp.MustCompile(`(?:AUTHORITY|ID)\[\s*"EPSG"\s*,\s*"?(\d+)"?\s*\]\s*\]\s*$`)
+	wktName      = regexp.MustCompile(`^\s*(?:GEOGCS|GEOGCRS)\[\s*"(?:GCS_WGS_1984|WGS 84|WGS_1984)"`)
+)
+
+// crsFromWKT reduces a WKT CRS definition to an EPSG code where possible
+func crsFromWKT(wkt string) string {
+	wkt = strings.TrimSpace(wkt)
+	if wkt == "" {
+		return ""
+	}
+	if m := wktAuthority.FindStringSubmatch(wkt); m != nil {
+		return "EPSG:" + m[1]
+	}
+	// ESRI .prj files usually carry no authority; plain WGS84 is common enough to recognize
+	if wktName.MatchString(wkt) {
+		return WGS84
+	}
+	return wkt
+}
@@ -0,0 +1,125 @@
+package gdal
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// wkbNames are the BLOB columns taken as WKB geometries in Parquet files
+// without GeoParquet metadata
+var wkbNames = []string{"geometry", "geom", "wkb_geometry"}
+
+// IsParquet reports whether path is a (Geo)Parquet file
+func IsParquet(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".parquet", ".geoparquet":
+		return true
+	}
+	return false
+}
+
+// LoadParquet reads a (Geo)Parquet file, local or an HTTP(S) URL, with
+// DuckDB's Parquet reader and creates or appends to tableName. The geometry
+// comes from the GeoParquet geometry column, or a WKB column named geometry
+// or geom. Geometries are taken as WGS84, GeoParquet's default, unless
+// opts.SourceCRS says otherwise.
+func LoadParquet(dbPath, srcPath, tableName string, opts Options) (Result, error) {
+	if opts.SourceCRS == "" {
+		opts.SourceCRS = WGS84
+	}
+	result := Result{Renamed: make(map[string]string), SourceCRS: opts.SourceCRS}
+
+	path, err := sourcePath(srcPath)
+	if err != nil {
+		return result, err
+	}
+
+	tableExists, err := database.TableExists(dbPath, tableName)
+	if err != nil {
+		return result, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return result, err
+	}
+	if database.IsRemote(path) {
+		if err := database.ConfigureHTTP(db, path); err != nil {
+			return result, err
+		}
+	}
+
+	source := "read_parquet(" + database.QuoteLiteral(path) + ")"
+	column, wkb, err := parquetGeometry(db, source)
+	if err != nil {
+		return result, err
+	}
+
+	geomExpr := database.QuoteIdentifier(column)
+	if wkb {
+		geomExpr = fmt.Sprintf("ST_GeomFromWKB(%s)", geomExpr)
+	}
+	if !opts.KeepCRS && !strings.EqualFold(opts.SourceCRS, WGS84) {
+		geomExpr = reprojectSQL(geomExpr, opts.SourceCRS)
+		result.Reprojected = true
+	}
+
+	err = loadQuery(db, source, tableName, tableExists, geomExpr, map[string]bool{column: true}, &result)
+	return result, err
+}
+
+// parquetGeometry finds the geometry column of a Parquet source: the first
+// GEOMETRY column, which DuckDB makes of GeoParquet geometries, or else a
+// BLOB column with a known name, whose WKB needs converting
+func parquetGeometry(db *sql.DB, source string) (column string, wkb bool, err error) {
+	rows, err := db.Query("SELECT column_name, column_type FROM (DESCRIBE SELECT * FROM " + source + ")")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read fields: %w", err)
+	}
+	defer rows.Close()
+
+	var blobs, fields []string
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return "", false, fmt.Errorf("failed to scan field: %w", err)
+		}
+		if strings.HasPrefix(typ, "GEOMETRY") {
+			return name, false, nil
+		}
+		if typ == "BLOB" {
+			blobs = append(blobs, name)
+		}
+		fields = append(fields, name)
+	}
+	if err := rows.Err(); err != nil {
+		return "", false, fmt.Errorf("error iterating fields: %w", err)
+	}
+
+	if name := findField(blobs, wkbNames); name != "" {
+		return name, true, nil
+	}
+	return "", false, fmt.Errorf("no geometry column found (columns: %s)", strings.Join(fields, ", "))
+}
+
+// sourcePath resolves a local input to an absolute path and leaves URLs,
+// which are read in place, as they are
+func sourcePath(srcPath string) (string, error) {
+	if database.IsRemote(srcPath) {
+		return srcPath, nil
+	}
+	absPath, err := filepath.Abs(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve input path: %w", err)
+	}
+	return absPath, nil
+}
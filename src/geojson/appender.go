@@ -0,0 +1,250 @@
+package geojson
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// appendBatchSize is the number of records appended before they're moved into the target table
+const appendBatchSize = 10000
+
+// appendStage is the temporary table records are appended to before insertion
+const appendStage = "append_stage"
+
+// appendLoader appends records through DuckDB's appender to a staging table
+// of VARCHAR properties and WKB geometries, and moves each full batch into
+// the target table, casting the properties to the column types on insert.
+// The spatial GEOMETRY type has its own storage format, so geometries can't
+// be appended to the target table directly.
+type appendLoader struct {
+	ctx       context.Context
+	conn      *sql.Conn
+	appender  *duckdb.Appender
+	columns   []database.Column
+	types     map[string]string
+	propCols  []string
+	coercions map[string]CoercionStats
+	insertSQL string
+	batch     []Record
+	appended  int
+	rows      int64
+	progress  *Progress
+	// check is applied to each batch before it's inserted, adding to report
+	check  database.GeometryCheck
+	report *database.GeometryReport
+}
+
+func newAppendLoader(ctx context.Context, conn *sql.Conn, tableName string, columns []database.Column, ids IDOptions, coercions map[string]CoercionStats) (*appendLoader, error) {
+	l := &appendLoader{ctx: ctx, conn: conn, columns: columns, types: columnTypes(columns), coercions: coercions}
+
+	var stageCols, targetCols, selectCols []string
+	for _, col := range columns {
+		if col.Name == "geom" || ids.isColumn(col.Name) {
+			continue
+		}
+		l.propCols = append(l.propCols, col.Name)
+		stageCols = append(stageCols, database.QuoteIdentifier(col.Name)+" VARCHAR")
+		targetCols = append(targetCols, database.QuoteIdentifier(col.Name))
+		selectCols = append(selectCols, database.QuoteIdentifier(col.Name))
+	}
+	stageCols = append(stageCols, "geom BLOB")
+	targetCols = append(targetCols, database.QuoteIdentifier("geom"))
+	selectCols = append(selectCols, "ST_GeomFromWKB(geom)")
+	if ids.enabled() {
+		targetCols = append(targetCols, database.QuoteIdentifier(ids.Column))
+		selectCols = append(selectCols, ids.expression(tableName, "ST_GeomFromWKB(geom)"))
+	}
+
+	createSQL := fmt.Sprintf("CREATE TEMPORARY TABLE %s (%s)", appendStage, strings.Join(stageCols, ", "))
+	if _, err := conn.ExecContext(ctx, createSQL); err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	l.insertSQL = fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+		database.QuoteTableName(tableName), strings.Join(targetCols, ", "), strings.Join(selectCols, ", "), appendStage)
+
+	err := conn.Raw(func(dc any) error {
+		var err error
+		l.appender, err = duckdb.NewAppender(dc.(driver.Conn), "temp", "main", appendStage)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create appender: %w", err)
+	}
+
+	return l, nil
+}
+
+// add appends a record, moving the batch into the target table once it's full
+func (l *appendLoader) add(record Record) error {
+	values := make(map[string]interface{}, len(record.Columns))
+	for _, prop := range record.Columns {
+		values[strings.ToLower(prop.Key)] = prop.Value
+	}
+
+	row := make([]driver.Value, 0, len(l.propCols)+1)
+	for _, name := range l.propCols {
+		row = append(row, stageValue(values[strings.ToLower(name)]))
+	}
+	if GeometryType(record.Geometry) == "null" {
+		row = append(row, nil)
+	} else {
+		wkb, err := encodeWKB(record.Geometry)
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", l.appended, err)
+		}
+		row = append(row, wkb)
+	}
+
+	if err := l.appender.AppendRow(row...); err != nil {
+		return fmt.Errorf("failed to append feature: %w", err)
+	}
+	l.appended++
+	l.progress.addWritten()
+
+	// Coercions only need the properties, so keep records small
+	l.batch = append(l.batch, Record{Columns: record.Columns})
+	if len(l.batch) >= appendBatchSize {
+		return l.flush()
+	}
+	return nil
+}
+
+// flush moves the staged rows into the target table
+func (l *appendLoader) flush() error {
+	addCoercions(l.coercions, l.batch, l.columns)
+	l.batch = l.batch[:0]
+
+	if err := l.appender.Flush(); err != nil {
+		return fmt.Errorf("failed to flush appender: %w", err)
+	}
+	if l.check != database.GeometryCheckOff {
+		report, err := l.check.Apply(l.ctx, l.conn, appendStage, "geom", true)
+		if err != nil {
+			return err
+		}
+		l.report.Add(report)
+	}
+
+	res, err := l.conn.ExecContext(l.ctx, l.insertSQL)
+	if err != nil {
+		return fmt.Errorf("failed to load data: failed to insert data: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	l.rows += rows
+
+	if _, err := l.conn.ExecContext(l.ctx, "DELETE FROM "+appendStage); err != nil {
+		return fmt.Errorf("failed to clear staging table: %w", err)
+	}
+	return nil
+}
+
+// finish flushes the last partial batch and drops the staging table
+func (l *appendLoader) finish() error {
+	if len(l.batch) > 0 {
+		if err := l.flush(); err != nil {
+			l.close()
+			return err
+		}
+	}
+
+	if err := l.appender.Close(); err != nil {
+		return fmt.Errorf("failed to close appender: %w", err)
+	}
+	if _, err := l.conn.ExecContext(l.ctx, "DROP TABLE IF EXISTS "+appendStage); err != nil {
+		return fmt.Errorf("failed to drop staging table: %w", err)
+	}
+	return nil
+}
+
+// close releases the appender after a failed load
+func (l *appendLoader) close() {
+	l.appender.Close()
+}
+
+// appendRecords runs the prepare statements, such as CREATE TABLE, appends
+// the records to the table and then runs extra, all in one transaction, and
+// returns the number of rows inserted. progress may be nil; check is applied
+// to the geometries, counting into report.
+func appendRecords(db *sql.DB, prepare []string, tableName string, columns []database.Column, ids IDOptions, records []Record, coercions map[string]CoercionStats, progress *Progress, check database.GeometryCheck, report *database.GeometryReport, extra func(ctx context.Context, conn *sql.Conn) error) (int64, error) {
+	if err := database.LoadSpatial(db); err != nil {
+		return 0, err
+	}
+
+	// The staging table and appender are tied to one connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION"); err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	for _, stmt := range prepare {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return 0, fmt.Errorf("failed to prepare table: %w", err)
+		}
+	}
+
+	loader, err := newAppendLoader(ctx, conn, tableName, columns, ids, coercions)
+	if err != nil {
+		return 0, err
+	}
+	loader.progress = progress
+	loader.check, loader.report = check, report
+	progress.start(PhaseWriting)
+	for _, r := range records {
+		if err := loader.add(r); err != nil {
+			loader.close()
+			return 0, err
+		}
+	}
+	if err := loader.finish(); err != nil {
+		return 0, err
+	}
+
+	if extra != nil {
+		if err := extra(ctx, conn); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	committed = true
+
+	return loader.rows, nil
+}
+
+// stageValue renders a property value the way properties->>'key' would
+func stageValue(v interface{}) driver.Value {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
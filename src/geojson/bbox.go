@@ -0,0 +1,148 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BBox restricts a load to the features intersecting minx, miny, maxx, maxy
+type BBox [4]float64
+
+// ParseBBox parses the --bbox flag: minx,miny,maxx,maxy in the input's
+// coordinates
+func ParseBBox(s string) (*BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid --bbox %q (must be minx,miny,maxx,maxy)", s)
+	}
+	var b BBox
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --bbox %q (must be minx,miny,maxx,maxy)", s)
+		}
+		b[i] = v
+	}
+	if b[0] > b[2] || b[1] > b[3] {
+		return nil, fmt.Errorf("invalid --bbox %q (minx and miny must not exceed maxx and maxy)", s)
+	}
+	return &b, nil
+}
+
+// filter wraps fn so features outside the box are dropped before they are
+// resolved, counting them in outside when it is set. A nil box keeps every
+// feature.
+func (b *BBox) filter(fn func(Feature) error, outside *int) func(Feature) error {
+	if b == nil {
+		return fn
+	}
+	return func(f Feature) error {
+		if !b.keeps(f.Geometry) {
+			if outside != nil {
+				*outside++
+			}
+			return nil
+		}
+		return fn(f)
+	}
+}
+
+// keeps reports whether a feature's geometry intersects the box. Features
+// without a geometry are dropped; geometries that can't be decoded are kept
+// so the load reports them.
+func (b *BBox) keeps(geometry json.RawMessage) bool {
+	if GeometryType(geometry) == "null" {
+		return false
+	}
+	g, err := decodeWKBGeometry(geometry)
+	if err != nil {
+		return true
+	}
+	return b.intersects(g)
+}
+
+// intersects reports whether a geometry touches the box: a position inside
+// it, an edge crossing it, or a polygon covering it
+func (b *BBox) intersects(g wkbGeometry) bool {
+	for _, p := range g.line {
+		if b.contains(p) {
+			return true
+		}
+	}
+	for i := 1; i < len(g.line); i++ {
+		if b.crosses(g.line[i-1], g.line[i]) {
+			return true
+		}
+	}
+	for _, ring := range g.rings {
+		for i, p := range ring {
+			if b.contains(p) || (i > 0 && b.crosses(ring[i-1], p)) {
+				return true
+			}
+		}
+	}
+	// No edge reaches the box, so it is either wholly inside the polygon or
+	// wholly outside; its corner tells which
+	if len(g.rings) > 0 && inPolygon([]float64{b[0], b[1]}, g.rings) {
+		return true
+	}
+	for _, part := range g.parts {
+		if b.intersects(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether a position lies in the box, edges included
+func (b *BBox) contains(p []float64) bool {
+	return p[0] >= b[0] && p[0] <= b[2] && p[1] >= b[1] && p[1] <= b[3]
+}
+
+// crosses reports whether the segment from p to q passes through the box,
+// clipping it against each side in turn
+func (b *BBox) crosses(p, q []float64) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := q[0]-p[0], q[1]-p[1]
+	for _, edge := range [4][2]float64{
+		{-dx, p[0] - b[0]},
+		{dx, b[2] - p[0]},
+		{-dy, p[1] - b[1]},
+		{dy, b[3] - p[1]},
+	} {
+		d, dist := edge[0], edge[1]
+		if d == 0 {
+			if dist < 0 {
+				return false
+			}
+			continue
+		}
+		t := dist / d
+		if d < 0 {
+			t0 = max(t0, t)
+		} else {
+			t1 = min(t1, t)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
+
+// inPolygon reports whether a position lies inside a polygon's outer ring
+// and outside its holes, by counting ring crossings
+func inPolygon(p []float64, rings [][][]float64) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, c := ring[i], ring[j]
+			if (a[1] > p[1]) != (c[1] > p[1]) && p[0] < (c[0]-a[0])*(p[1]-a[1])/(c[1]-a[1])+a[0] {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
@@ -0,0 +1,89 @@
+package geojson
+
+import (
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// CoercionStats counts values that had to be converted to fit a column's type
+type CoercionStats struct {
+	StringToNumber int
+	NumberToString int
+	Truncation     int
+	Other          int
+}
+
+// Total returns the number of coerced values
+func (c CoercionStats) Total() int {
+	return c.StringToNumber + c.NumberToString + c.Truncation + c.Other
+}
+
+// collectCoercions compares every property value against the column it lands in
+func collectCoercions(features []Record, columns []database.Column) map[string]CoercionStats {
+	stats := make(map[string]CoercionStats)
+	addCoercions(stats, features, columns)
+	return stats
+}
+
+// addCoercions adds the coercions needed by features to stats
+func addCoercions(stats map[string]CoercionStats, features []Record, columns []database.Column) {
+	types := columnTypes(columns)
+
+	for _, f := range features {
+		for _, prop := range f.Columns {
+			colType, ok := types[strings.ToLower(prop.Key)]
+			if !ok || prop.Value == nil {
+				continue
+			}
+
+			s := stats[prop.Key]
+			switch v := prop.Value.(type) {
+			case string:
+				if isNumericType(colType) {
+					s.StringToNumber++
+				} else if !isTextType(colType) && !(isDetectedType(colType) && stringFits(v, colType)) {
+					s.Other++
+				}
+			case float64:
+				if isTextType(colType) {
+					s.NumberToString++
+				} else if isIntegerType(colType) && v != float64(int64(v)) {
+					s.Truncation++
+				} else if !isNumericType(colType) {
+					s.Other++
+				}
+			case bool:
+				if colType != "BOOLEAN" {
+					s.Other++
+				}
+			default:
+				// Objects and arrays are flattened to their JSON text
+				if colType != "JSON" {
+					s.Other++
+				}
+			}
+
+			if s.Total() > 0 {
+				stats[prop.Key] = s
+			}
+		}
+	}
+}
+
+func isIntegerType(t string) bool {
+	switch t {
+	case "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT",
+		"UTINYINT", "USMALLINT", "UINTEGER", "UBIGINT", "UHUGEINT":
+		return true
+	}
+	return false
+}
+
+func isNumericType(t string) bool {
+	return isIntegerType(t) || t == "DOUBLE" || t == "FLOAT" || t == "REAL" || strings.HasPrefix(t, "DECIMAL")
+}
+
+func isTextType(t string) bool {
+	return t == "VARCHAR" || t == "TEXT" || t == "STRING"
+}
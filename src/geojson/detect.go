@@ -0,0 +1,68 @@
+package geojson
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	dateRe      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	timestampRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[T ](\d{2}):(\d{2})(?::(\d{2})(?:\.\d{1,9})?)?(Z|[+-]\d{2}(?::?\d{2})?)?$`)
+	uuidRe      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// detectStringType recognizes ISO-8601 dates and timestamps and UUIDs in a
+// string, returning DATE, TIMESTAMP, TIMESTAMPTZ (for timestamps with a zone
+// designator) or UUID, and VARCHAR for anything else
+func detectStringType(s string) string {
+	switch {
+	case dateRe.MatchString(s):
+		if validDate(s) {
+			return "DATE"
+		}
+	case uuidRe.MatchString(s):
+		return "UUID"
+	default:
+		m := timestampRe.FindStringSubmatch(s)
+		if m == nil || !validDate(m[1]) || !inRange(m[2], 23) || !inRange(m[3], 59) || (m[4] != "" && !inRange(m[4], 59)) {
+			break
+		}
+		if m[5] != "" {
+			return "TIMESTAMPTZ"
+		}
+		return "TIMESTAMP"
+	}
+	return "VARCHAR"
+}
+
+// validDate reports whether a YYYY-MM-DD string is a real calendar day
+func validDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// inRange reports whether a string of digits is at most max
+func inRange(digits string, max int) bool {
+	n, err := strconv.Atoi(digits)
+	return err == nil && n <= max
+}
+
+// isDetectedType reports whether a column type is one detectStringType infers
+func isDetectedType(t string) bool {
+	switch t {
+	case "DATE", "TIMESTAMP", "TIMESTAMPTZ", "TIMESTAMP WITH TIME ZONE", "UUID":
+		return true
+	}
+	return false
+}
+
+// stringFits reports whether a string is stored in a column of a detected
+// type without conversion, as when the column was inferred from it
+func stringFits(s, colType string) bool {
+	if colType == "TIMESTAMP WITH TIME ZONE" {
+		colType = "TIMESTAMPTZ"
+	}
+	detected := detectStringType(s)
+	return detected == colType || (detected == "DATE" && colType == "TIMESTAMP")
+}
@@ -0,0 +1,167 @@
+package geojson
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// DiffKind classifies a column in a SchemaDiff
+type DiffKind string
+
+const (
+	// DiffSame is a column present in both with a compatible type
+	DiffSame DiffKind = "same"
+	// DiffFileOnly is a property the table has no column for; it would be dropped
+	DiffFileOnly DiffKind = "file-only"
+	// DiffTableOnly is a column the file never sets; it would be NULL
+	DiffTableOnly DiffKind = "table-only"
+	// DiffType is a column whose values differ in type from the table's
+	DiffType DiffKind = "type"
+)
+
+// ColumnDiff compares one column of a table with the matching file property
+type ColumnDiff struct {
+	Name string
+	Kind DiffKind
+	// TableType is empty for file-only properties
+	TableType string
+	// FileType is the type inferred from the file's values, empty for
+	// table-only columns
+	FileType string
+	// Values counts the non-null values in the file
+	Values int
+	// Coerced counts values that would be converted to fit the column
+	Coerced int
+	// Unfit counts values that can't be cast to the column type and would
+	// make the load fail
+	Unfit int
+}
+
+// SchemaDiff predicts what appending a file to an existing table would do
+type SchemaDiff struct {
+	Features int
+	Columns  []ColumnDiff
+}
+
+// WouldFail reports whether any value can't be cast to its column type
+func (d SchemaDiff) WouldFail() bool {
+	for _, c := range d.Columns {
+		if c.Unfit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffSchema compares the columns of an existing table with the properties of
+// a GeoJSON file, resolved to column names exactly as a load would resolve them
+func DiffSchema(dbPath, tableName, geojsonPath string, opts LoadOptions) (SchemaDiff, error) {
+	var diff SchemaDiff
+
+	columns, err := database.GetTableSchema(dbPath, tableName)
+	if err != nil {
+		return diff, fmt.Errorf("failed to get table schema: %w", err)
+	}
+	if len(columns) == 0 {
+		return diff, fmt.Errorf("table not found: %s", tableName)
+	}
+
+	result := LoadResult{
+		Duplicates: make(map[string]int),
+		Nulled:     make(map[string]int),
+		Violations: make(map[string]map[string]int),
+	}
+	records, err := readFeatures(geojsonPath, opts, &result)
+	if err != nil {
+		return diff, err
+	}
+	diff.Features = len(records)
+
+	// Gather the inferred types and values of every property
+	inferrer := schemaInferrer{nested: opts.Nested, keepStrings: opts.KeepStrings}
+	fileTypes := make(map[string]map[string]bool)
+	var fileOrder []string
+	values := make(map[string]int)
+	for _, r := range records {
+		for _, prop := range r.Columns {
+			if _, ok := fileTypes[prop.Key]; !ok {
+				fileTypes[prop.Key] = make(map[string]bool)
+				fileOrder = append(fileOrder, prop.Key)
+			}
+			if prop.Value != nil {
+				fileTypes[prop.Key][inferrer.valueType(prop.Value)] = true
+				values[prop.Key]++
+			}
+		}
+	}
+
+	coercions := collectCoercions(records, columns)
+	// Properties are extracted by exact key, so match names case-sensitively
+	types := make(map[string]string, len(columns))
+	for _, col := range columns {
+		types[col.Name] = strings.ToUpper(col.Type)
+	}
+	unfit := make(map[string]int)
+	for _, r := range records {
+		for _, prop := range r.Columns {
+			if colType, ok := types[prop.Key]; ok && !castable(prop.Value, colType) {
+				unfit[prop.Key]++
+			}
+		}
+	}
+
+	inTable := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		inTable[col.Name] = true
+		if col.Name == "geom" {
+			continue
+		}
+
+		c := ColumnDiff{Name: col.Name, Kind: DiffSame, TableType: col.Type}
+		if _, ok := fileTypes[col.Name]; !ok {
+			c.Kind = DiffTableOnly
+		} else {
+			c.FileType = mergedType(fileTypes[col.Name])
+			c.Values = values[col.Name]
+			c.Coerced = coercions[col.Name].Total()
+			c.Unfit = unfit[col.Name]
+			if c.Coerced > 0 || c.Unfit > 0 {
+				c.Kind = DiffType
+			}
+		}
+		diff.Columns = append(diff.Columns, c)
+	}
+
+	for _, name := range fileOrder {
+		if !inTable[name] {
+			diff.Columns = append(diff.Columns, ColumnDiff{
+				Name:     name,
+				Kind:     DiffFileOnly,
+				FileType: mergedType(fileTypes[name]),
+				Values:   values[name],
+			})
+		}
+	}
+
+	return diff, nil
+}
+
+// mergedType describes the types seen in a property, widening BIGINT and
+// DOUBLE to DOUBLE
+func mergedType(seen map[string]bool) string {
+	if len(seen) == 0 {
+		return "NULL"
+	}
+	if len(seen) == 2 && seen["BIGINT"] && seen["DOUBLE"] {
+		return "DOUBLE"
+	}
+	var types []string
+	for t := range seen {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return strings.Join(types, "|")
+}
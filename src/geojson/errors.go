@@ -0,0 +1,147 @@
+package geojson
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrorPolicy decides what a load does with a feature it can't read
+type ErrorPolicy string
+
+const (
+	// OnErrorFail stops the load at the first bad feature
+	OnErrorFail ErrorPolicy = "fail"
+	// OnErrorSkip leaves bad features out and counts them
+	OnErrorSkip ErrorPolicy = "skip"
+	// OnErrorLog leaves bad features out and writes them to an ErrorLog
+	OnErrorLog ErrorPolicy = "log"
+)
+
+// ParseErrorPolicy parses the --on-error flag; "" means OnErrorFail
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch p := ErrorPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "", OnErrorFail:
+		return OnErrorFail, nil
+	case OnErrorSkip, OnErrorLog:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid --on-error %q (must be skip, fail or log)", s)
+}
+
+// tolerant reports whether bad features are stepped over
+func (p ErrorPolicy) tolerant() bool {
+	return p == OnErrorSkip || p == OnErrorLog
+}
+
+// FeatureError is a problem confined to one feature, which OnErrorSkip and
+// OnErrorLog step over
+type FeatureError struct {
+	// Feature is the feature as decoded, when it could be
+	Feature Feature
+	// Raw is the text of a feature that couldn't be decoded
+	Raw []byte
+	Err error
+}
+
+func (e *FeatureError) Error() string { return e.Err.Error() }
+
+func (e *FeatureError) Unwrap() error { return e.Err }
+
+// skipFeature steps over a feature that failed to load when opts.OnError
+// allows it, counting it and writing it to opts.ErrorLog; any other error is
+// returned to stop the load
+func skipFeature(err error, source string, opts LoadOptions, result *LoadResult) error {
+	var fe *FeatureError
+	if !opts.OnError.tolerant() || !errors.As(err, &fe) {
+		return err
+	}
+	result.Skipped++
+	if opts.OnError == OnErrorLog && opts.ErrorLog != nil {
+		return opts.ErrorLog.write(source, fe)
+	}
+	return nil
+}
+
+// ErrorLog writes the features a load skipped to a GeoJSONL sidecar file,
+// each with the reason and the file it came from. The file is created when
+// the first feature is written, replacing an earlier one.
+type ErrorLog struct {
+	Path string
+	// Count is the number of features written
+	Count int
+
+	file *os.File
+	w    *bufio.Writer
+}
+
+// errorFeature is a line of the error log. The reason and source are foreign
+// members, so the file still loads as GeoJSONL once the features are fixed.
+type errorFeature struct {
+	Type       string          `json:"type"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+	Error      string          `json:"error"`
+	Source     string          `json:"source"`
+	// Raw holds the text of a feature that couldn't be decoded
+	Raw interface{} `json:"raw,omitempty"`
+}
+
+func (l *ErrorLog) write(source string, fe *FeatureError) error {
+	if l.file == nil {
+		f, err := os.Create(l.Path)
+		if err != nil {
+			return fmt.Errorf("failed to create error log: %w", err)
+		}
+		l.file, l.w = f, bufio.NewWriter(f)
+	}
+
+	line := errorFeature{
+		Type:       "Feature",
+		Geometry:   nullIfEmpty(fe.Feature.Geometry),
+		Properties: nullIfEmpty(fe.Feature.Properties),
+		Error:      fe.Error(),
+		Source:     source,
+	}
+	if raw := strings.TrimSpace(string(fe.Raw)); raw != "" {
+		if json.Valid([]byte(raw)) {
+			line.Raw = json.RawMessage(raw)
+		} else {
+			line.Raw = raw
+		}
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to log feature: %w", err)
+	}
+	l.w.Write(data)
+	if err := l.w.WriteByte('\n'); err != nil {
+		return fmt.Errorf("failed to write error log: %w", err)
+	}
+	l.Count++
+	return nil
+}
+
+// Close flushes and closes the file, if anything was written
+func (l *ErrorLog) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	if err := l.w.Flush(); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to write error log: %w", err)
+	}
+	return l.file.Close()
+}
+
+// nullIfEmpty keeps a missing member from breaking the JSON of a line
+func nullIfEmpty(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("null")
+	}
+	return raw
+}
@@ -0,0 +1,194 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// IDMode selects how surrogate keys are generated during a load
+type IDMode string
+
+const (
+	// IDNone leaves features without a generated key
+	IDNone IDMode = ""
+	// IDSequence numbers features 1, 2, 3, ... continuing from the table's current maximum
+	IDSequence IDMode = "sequence"
+	// IDUUID gives every feature a random UUID
+	IDUUID IDMode = "uuid"
+	// IDGeometryHash derives the key from the geometry's WKB, so identical shapes share it
+	IDGeometryHash IDMode = "hash-of-geometry"
+	// IDFeature promotes the GeoJSON feature "id" member into the key column
+	IDFeature IDMode = "feature"
+	// IDProperty promotes a property, whose column is the key column
+	IDProperty IDMode = "property"
+)
+
+// ParseIDMode converts a --generate-id flag value into an IDMode
+func ParseIDMode(s string) (IDMode, error) {
+	switch mode := IDMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case IDNone, IDSequence, IDUUID, IDGeometryHash:
+		return mode, nil
+	}
+	return IDNone, fmt.Errorf("invalid --generate-id %q (must be sequence, uuid or hash-of-geometry)", s)
+}
+
+// ParseIDFlag converts an --id flag value into key options: feature promotes
+// the feature "id" member into column, uuid and sequence generate keys into
+// it, and anything else names the property to promote, also written
+// property:<name> for properties named like the others. The key becomes the
+// primary key of tables the load creates.
+func ParseIDFlag(s, column string) (IDOptions, error) {
+	s = strings.TrimSpace(s)
+	switch mode := IDMode(strings.ToLower(s)); mode {
+	case IDFeature, IDUUID, IDSequence:
+		return IDOptions{Mode: mode, Column: column, PrimaryKey: true}, nil
+	case IDGeometryHash:
+		return IDOptions{}, fmt.Errorf("--id hash-of-geometry can't be a primary key, identical geometries share it (use --generate-id)")
+	}
+
+	name := strings.TrimPrefix(s, "property:")
+	if name == "" {
+		return IDOptions{}, fmt.Errorf("invalid --id %q (must be feature, uuid, sequence or a property name)", s)
+	}
+	// The property's column, with flattened members kept apart by dots
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = SanitizeColumnName(part)
+	}
+	return IDOptions{Mode: IDProperty, Column: strings.Join(parts, "."), PrimaryKey: true}, nil
+}
+
+// IDOptions configures surrogate key generation
+type IDOptions struct {
+	Mode IDMode
+	// Column receives the generated or promoted key
+	Column string
+	// PrimaryKey makes Column the primary key of a table the load creates
+	PrimaryKey bool
+}
+
+// enabled reports whether keys should be generated
+func (o IDOptions) enabled() bool {
+	switch o.Mode {
+	case IDSequence, IDUUID, IDGeometryHash:
+		return true
+	}
+	return false
+}
+
+// promoted reports whether keys come from the features themselves
+func (o IDOptions) promoted() bool {
+	return o.Mode == IDFeature || o.Mode == IDProperty
+}
+
+// promote makes sure a feature's record carries its key: the "id" member
+// is added as the key column, and the key property must have a value.
+// Features without a key can't go into a primary key column.
+func (o IDOptions) promote(f Feature, columns []Property) ([]Property, error) {
+	switch o.Mode {
+	case IDFeature:
+		for _, col := range columns {
+			if strings.EqualFold(col.Key, o.Column) {
+				return nil, fmt.Errorf("property %q collides with the feature id column (choose another with --id-column)", col.Key)
+			}
+		}
+		var id interface{}
+		if len(f.ID) > 0 {
+			if err := json.Unmarshal(f.ID, &id); err != nil {
+				return nil, fmt.Errorf("invalid id: %w", err)
+			}
+		}
+		switch id.(type) {
+		case string, float64:
+		case nil:
+			return nil, fmt.Errorf("no id")
+		default:
+			return nil, fmt.Errorf("id must be a string or a number")
+		}
+		return append([]Property{{Key: o.Column, Value: id}}, columns...), nil
+
+	case IDProperty:
+		for _, col := range columns {
+			if strings.EqualFold(col.Key, o.Column) && col.Value != nil {
+				return columns, nil
+			}
+		}
+		return nil, fmt.Errorf("no %q property to use as the id", o.Column)
+	}
+	return columns, nil
+}
+
+// columnType returns the DuckDB type of the key column
+func (o IDOptions) columnType() string {
+	switch o.Mode {
+	case IDSequence:
+		return "BIGINT"
+	case IDUUID:
+		return "UUID"
+	default:
+		return "VARCHAR"
+	}
+}
+
+// expression returns the SQL producing a key for each row inserted into
+// tableName, where geomExpr is the row's geometry
+func (o IDOptions) expression(tableName, geomExpr string) string {
+	switch o.Mode {
+	case IDSequence:
+		return fmt.Sprintf("(SELECT COALESCE(MAX(%s), 0) FROM %s) + row_number() OVER ()",
+			database.QuoteIdentifier(o.Column), database.QuoteTableName(tableName))
+	case IDUUID:
+		return "uuid()"
+	default:
+		return fmt.Sprintf("md5(ST_AsWKB(%s))", geomExpr)
+	}
+}
+
+// isColumn reports whether name is the key column
+func (o IDOptions) isColumn(name string) bool {
+	return o.enabled() && strings.EqualFold(name, o.Column)
+}
+
+// addIDColumn puts a generated key column first in a newly inferred schema,
+// and makes the key column its primary key when asked to
+func addIDColumn(schema Schema, ids IDOptions) (Schema, error) {
+	if ids.promoted() {
+		for _, col := range schema.Columns {
+			if strings.EqualFold(col.Name, ids.Column) {
+				schema.PrimaryKey = col.Name
+				return schema, nil
+			}
+		}
+		return schema, fmt.Errorf("no %q column to use as the id", ids.Column)
+	}
+	if !ids.enabled() {
+		return schema, nil
+	}
+	for _, col := range schema.Columns {
+		if strings.EqualFold(col.Name, ids.Column) {
+			return schema, fmt.Errorf("property %q collides with the generated id column (choose another with --id-column)", col.Name)
+		}
+	}
+	columns := append([]database.Column{{Name: ids.Column, Type: ids.columnType()}}, schema.Columns...)
+	schema = Schema{Columns: columns}
+	if ids.PrimaryKey {
+		schema.PrimaryKey = ids.Column
+	}
+	return schema, nil
+}
+
+// checkIDColumn makes sure an existing table has the key column
+func checkIDColumn(columns []database.Column, ids IDOptions) error {
+	if !ids.enabled() && !ids.promoted() {
+		return nil
+	}
+	for _, col := range columns {
+		if strings.EqualFold(col.Name, ids.Column) {
+			return nil
+		}
+	}
+	return fmt.Errorf("table has no %q column to store ids in", ids.Column)
+}
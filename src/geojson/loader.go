@@ -1,6 +1,7 @@
 package geojson
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -18,106 +19,436 @@ type GeoJSON struct {
 }
 
 type Feature struct {
-	Type       string                 `json:"type"`
-	Geometry   json.RawMessage        `json:"geometry"`
-	Properties map[string]interface{} `json:"properties"`
+	Type       string          `json:"type"`
+	ID         json.RawMessage `json:"id,omitempty"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
 }
 
 // Schema represents a table schema
 type Schema struct {
 	Columns []database.Column
+	// PrimaryKey names the primary key column, if the table gets one
+	PrimaryKey string
+}
+
+// LoadOptions controls how feature properties are mapped onto columns
+type LoadOptions struct {
+	// DuplicatePolicy decides which value survives when property keys collide
+	DuplicatePolicy DuplicatePolicy
+	// NullPolicy lists property values that are stored as NULL
+	NullPolicy NullPolicy
+	// Validation checks property values against per-column rules
+	Validation ValidationPolicy
+	// EmitSQL builds the statements without touching the database
+	EmitSQL bool
+	// IDs configures surrogate keys generated for each feature
+	IDs IDOptions
+	// Format of the input file; FormatAuto detects it
+	Format Format
+	// Stream decodes and inserts features in batches instead of reading the
+	// whole file into memory
+	Stream bool
+	// Quarantine diverts features that fail validation or don't fit the
+	// column types into <table>_quarantine instead of failing the load
+	Quarantine bool
+	// Mapping routes properties onto the columns of an existing table
+	Mapping *Mapping
+	// InferSample is the number of features a new table's schema is inferred
+	// from; 0 reads every feature, which takes an extra pass when streaming
+	InferSample int
+	// Nested decides how object and array properties are stored
+	Nested NestedPolicy
+	// SchemaMode decides how features that don't match an existing table's
+	// columns are handled
+	SchemaMode SchemaMode
+	// Types overrides the inferred types of the columns a load creates
+	Types TypeOverrides
+	// KeepStrings stores date, timestamp and UUID strings as VARCHAR instead
+	// of detecting their type
+	KeepStrings bool
+	// Progress, when set, is updated as the file is read and written
+	Progress *Progress
+	// GeometryCheck decides what happens to invalid geometries
+	GeometryCheck database.GeometryCheck
+	// OnError decides what happens to features that can't be read
+	OnError ErrorPolicy
+	// ErrorLog receives the features skipped with OnErrorLog
+	ErrorLog *ErrorLog
+	// BBox, when set, leaves out the features that don't intersect it
+	BBox *BBox
+	// Properties, when set, decides which properties become columns
+	Properties *PropertySelection
+}
+
+// LoadResult summarizes a completed load
+type LoadResult struct {
+	RowCount int
+	// CreatedColumns is the number of columns of the table the load created,
+	// 0 when it loaded into an existing table
+	CreatedColumns int
+	// Duplicates counts key collisions per column name
+	Duplicates map[string]int
+	// Coercions counts values converted to fit their column's type, per column
+	Coercions map[string]CoercionStats
+	// Nulled counts values replaced with NULL by the null policy, per column
+	Nulled map[string]int
+	// Violations counts failed validation rules, per column and rule
+	Violations map[string]map[string]int
+	// Quarantined counts features written to the quarantine table
+	Quarantined int
+	// Skipped counts features left out under OnError
+	Skipped int
+	// Outside counts features left out for not intersecting BBox
+	Outside int
+	// Geometries counts the invalid geometries GeometryCheck dropped or repaired
+	Geometries database.GeometryReport
+	// SQL holds, with EmitSQL, the statements that would load the file
+	SQL []string
+	// Columns holds, with EmitSQL, the columns the table would have
+	Columns []database.Column
+	// Summary describes, with EmitSQL, the features that would be loaded
+	Summary *Summary
+}
+
+// Record is a feature whose properties have been resolved to column names
+type Record struct {
+	Geometry json.RawMessage
+	Columns  []Property
+	// Properties holds the raw properties, kept only for quarantining
+	Properties json.RawMessage
+	// Problems lists the validation rules the record broke
+	Problems []string
 }
 
 // LoadGeoJSON loads a GeoJSON file into a DuckDB database table
-func LoadGeoJSON(dbPath, geojsonPath, tableName string) (int, error) {
-	// Get absolute paths
-	absDBPath, err := filepath.Abs(dbPath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to resolve database path: %w", err)
+func LoadGeoJSON(dbPath, geojsonPath, tableName string, opts LoadOptions) (LoadResult, error) {
+	if opts.Stream {
+		return loadStreaming(dbPath, geojsonPath, tableName, opts)
+	}
+	return Load(geojsonPath, &TableSink{DBPath: dbPath, Table: tableName, EmitSQL: opts.EmitSQL, IDs: opts.IDs, Quarantine: opts.Quarantine, Mapping: opts.Mapping, Source: geojsonPath, InferSample: opts.InferSample, Nested: opts.Nested, SchemaMode: opts.SchemaMode, Types: opts.Types, KeepStrings: opts.KeepStrings, Progress: opts.Progress, GeometryCheck: opts.GeometryCheck}, opts)
+}
+
+// Load reads a GeoJSON file, resolves its properties to columns and hands the
+// records to sink
+func Load(geojsonPath string, sink Sink, opts LoadOptions) (LoadResult, error) {
+	result := LoadResult{
+		Duplicates: make(map[string]int),
+		Nulled:     make(map[string]int),
+		Coercions:  make(map[string]CoercionStats),
+		Violations: make(map[string]map[string]int),
 	}
 
 	absGeoJSONPath, err := filepath.Abs(geojsonPath)
 	if err != nil {
-		return 0, fmt.Errorf("failed to resolve GeoJSON path: %w", err)
+		return result, fmt.Errorf("failed to resolve GeoJSON path: %w", err)
 	}
 
-	// Open database
-	db, err := sql.Open("duckdb", absDBPath)
+	// Read features and resolve property keys to column names
+	records, err := readFeatures(absGeoJSONPath, opts, &result)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open database: %w", err)
+		return result, err
 	}
-	defer db.Close()
+	if len(records) == 0 && result.Outside > 0 {
+		return result, fmt.Errorf("none of the %d features intersect the bounding box", result.Outside)
+	}
+	if err := opts.Validation.enforce(result.Violations); err != nil {
+		return result, err
+	}
+
+	if err := sink.Write(records, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
 
-	// Ensure spatial extension is loaded
-	if err := loadSpatialExtension(db); err != nil {
-		return 0, err
+// TableSink writes records into a DuckDB table, creating it when it doesn't exist
+type TableSink struct {
+	DBPath string
+	Table  string
+	// EmitSQL builds the statements into LoadResult.SQL without touching the database
+	EmitSQL bool
+	// IDs configures surrogate keys generated for each feature
+	IDs IDOptions
+	// Quarantine diverts records that can't be inserted into the quarantine table
+	Quarantine bool
+	// Source names the input file in the quarantine table
+	Source string
+	// Mapping adds the columns it maps properties to when the table lacks them
+	Mapping *Mapping
+	// InferSample is the number of records a new table's schema is inferred
+	// from; 0 uses every record
+	InferSample int
+	// Nested decides how object and array properties are stored
+	Nested NestedPolicy
+	// SchemaMode decides how records that don't match an existing table's
+	// columns are handled
+	SchemaMode SchemaMode
+	// Types overrides the inferred types of the columns the sink creates
+	Types TypeOverrides
+	// KeepStrings stores date, timestamp and UUID strings as VARCHAR
+	KeepStrings bool
+	// Progress, when set, counts the records appended
+	Progress *Progress
+	// GeometryCheck decides what happens to invalid geometries
+	GeometryCheck database.GeometryCheck
+}
+
+// Write implements Sink
+func (s *TableSink) Write(records []Record, result *LoadResult) error {
+	absDBPath, err := filepath.Abs(s.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve database path: %w", err)
 	}
 
 	// Check if table exists
-	tableExists, err := database.TableExists(absDBPath, tableName)
+	tableExists, err := database.TableExists(absDBPath, s.Table)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check if table exists: %w", err)
+		return fmt.Errorf("failed to check if table exists: %w", err)
 	}
 
-	if !tableExists {
+	// Work out the target columns: the existing table's, or inferred for a new one
+	var createStatements []string
+	var columns []database.Column
+	if tableExists {
+		columns, err = database.GetTableSchema(absDBPath, s.Table)
+		if err != nil {
+			return fmt.Errorf("failed to get table schema: %w", err)
+		}
+		if err := checkIDColumn(columns, s.IDs); err != nil {
+			return err
+		}
+		added := s.Mapping.newColumns(columns)
+		columns = append(columns, added...)
+
+		inferrer := schemaInferrer{nested: s.Nested, keepStrings: s.KeepStrings}
+		for _, r := range records {
+			inferrer.add(r)
+		}
+		merged, err := reconcileSchema(s.Table, columns, &inferrer, s.SchemaMode)
+		if err != nil {
+			return err
+		}
+		merged = s.Types.apply(merged)
+		added = append(added, merged...)
+		columns = append(columns, merged...)
+		createStatements = addColumnStatements(s.Table, added)
+	} else {
 		// Infer schema from GeoJSON
-		schema, err := inferSchemaFromGeoJSON(absGeoJSONPath)
+		schema, err := inferSchema(records, s.InferSample, schemaInferrer{nested: s.Nested, keepStrings: s.KeepStrings})
 		if err != nil {
-			return 0, fmt.Errorf("failed to infer schema: %w", err)
+			return fmt.Errorf("failed to infer schema: %w", err)
 		}
+		schema.Columns = s.Types.apply(schema.Columns)
+		if schema, err = addIDColumn(schema, s.IDs); err != nil {
+			return err
+		}
+		columns = schema.Columns
+		createStatements = createTableStatements(s.Table, schema)
+	}
+
+	var rejected []rejectedRecord
+	if s.Quarantine {
+		records, rejected = splitRejected(records, columns)
+		result.Quarantined = len(rejected)
+	}
+
+	if s.EmitSQL {
+		// Record values that will need converting to the table's column types
+		result.Coercions = collectCoercions(records, columns)
 
-		// Create table
-		if err := createTableFromSchema(db, tableName, schema); err != nil {
-			return 0, fmt.Errorf("failed to create table: %w", err)
+		// The printed SQL reads the features from a normalized file, kept so
+		// the statements can be run later
+		normalizedPath, err := writeNormalizedFeatures(records)
+		if err != nil {
+			return err
 		}
+		stageSQL, insertSQL, dropSQL := insertStatements(s.Table, columns, normalizedPath, s.IDs)
+		result.SQL = append([]string{"LOAD spatial"}, createStatements...)
+		result.SQL = append(result.SQL, stageSQL, insertSQL, dropSQL)
+		result.Columns = columns
+		result.Summary = summarize(records)
+		return nil
+	}
+
+	// Open database
+	db, err := database.Open(absDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-		fmt.Printf("✓ Table '%s' created with %d columns\n", tableName, len(schema.Columns))
+	result.Coercions = make(map[string]CoercionStats)
+	rowsAffected, err := appendRecords(db, createStatements, s.Table, columns, s.IDs, records, result.Coercions, s.Progress, s.GeometryCheck, &result.Geometries, func(ctx context.Context, conn *sql.Conn) error {
+		return writeQuarantine(ctx, conn, s.Table, s.Source, rejected)
+	})
+	if err != nil {
+		return err
 	}
 
-	// Load data into table
-	rowCount, err := loadDataIntoTable(db, absDBPath, tableName, absGeoJSONPath)
+	if !tableExists {
+		result.CreatedColumns = len(columns)
+	}
+
+	result.RowCount = int(rowsAffected)
+	return nil
+}
+
+// readFeatures parses the GeoJSON file and resolves each feature's properties to columns
+func readFeatures(geojsonPath string, opts LoadOptions, result *LoadResult) ([]Record, error) {
+	var records []Record
+	opts.Progress.start(PhaseReading)
+	err := streamFile(geojsonPath, opts.Format, opts.Progress, opts.BBox.filter(func(f Feature) error {
+		record, err := resolveRecord(f, len(records), opts, result)
+		if err != nil {
+			return skipFeature(err, geojsonPath, opts, result)
+		}
+		records = append(records, record)
+		opts.Progress.addFeature()
+		return nil
+	}, &result.Outside), func(fe *FeatureError) error {
+		return skipFeature(fe, geojsonPath, opts, result)
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to load data: %w", err)
+		return nil, err
 	}
 
-	return rowCount, nil
+	return records, nil
 }
 
-// loadSpatialExtension ensures the spatial extension is loaded
-func loadSpatialExtension(db *sql.DB) error {
-	_, err := db.Exec("LOAD spatial;")
+// resolveRecord resolves the properties of the i-th feature to columns. The
+// errors it returns are *FeatureError, confined to the feature.
+func resolveRecord(f Feature, i int, opts LoadOptions, result *LoadResult) (Record, error) {
+	props, err := decodeProperties(f.Properties)
 	if err != nil {
-		return fmt.Errorf("failed to load spatial extension: %w", err)
+		return Record{}, &FeatureError{Feature: f, Err: fmt.Errorf("feature %d: %w", i, err)}
 	}
-	return nil
+
+	columns, err := resolveColumns(props, opts.DuplicatePolicy, result.Duplicates)
+	if err != nil {
+		return Record{}, &FeatureError{Feature: f, Err: fmt.Errorf("feature %d: %w", i, err)}
+	}
+	if opts.Nested == NestedFlatten {
+		if columns, err = flattenColumns(columns, opts.DuplicatePolicy, result.Duplicates); err != nil {
+			return Record{}, &FeatureError{Feature: f, Err: fmt.Errorf("feature %d: %w", i, err)}
+		}
+	}
+
+	// A geometry that can't be converted to WKB would otherwise fail the load
+	// once it's appended. Unknown types are left for quarantine to catch.
+	if t := GeometryType(f.Geometry); opts.OnError.tolerant() && t != "null" && (validGeometryTypes[t] || !opts.Quarantine) {
+		if _, err := decodeWKBGeometry(f.Geometry); err != nil {
+			return Record{}, &FeatureError{Feature: f, Err: fmt.Errorf("feature %d: %w", i, err)}
+		}
+	}
+	columns = opts.Properties.apply(columns)
+	if columns, err = opts.IDs.promote(f, columns); err != nil {
+		return Record{}, &FeatureError{Feature: f, Err: fmt.Errorf("feature %d: %w", i, err)}
+	}
+	columns = opts.Mapping.apply(columns)
+	applyNullPolicy(columns, opts.NullPolicy, result.Nulled)
+	record := Record{Geometry: f.Geometry, Columns: columns}
+	record.Problems = opts.Validation.validate(columns, result.Violations)
+	if opts.Quarantine {
+		record.Properties = f.Properties
+	}
+
+	return record, nil
 }
 
-// inferSchemaFromGeoJSON reads the first feature to infer the table schema
-func inferSchemaFromGeoJSON(geojsonPath string) (Schema, error) {
-	data, err := os.ReadFile(geojsonPath)
+// writeNormalizedFeatures writes records back out as a FeatureCollection keyed by
+// column name, returning the path of the temporary file
+func writeNormalizedFeatures(records []Record) (string, error) {
+	out := struct {
+		Type     string          `json:"type"`
+		Features []recordFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: []recordFeature{}}
+
+	for _, r := range records {
+		out.Features = append(out.Features, r.feature())
+	}
+
+	tmp, err := os.CreateTemp("", "xyzduck-*.geojson")
 	if err != nil {
-		return Schema{}, fmt.Errorf("failed to read GeoJSON file: %w", err)
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
 	}
+	defer tmp.Close()
 
-	var gj GeoJSON
-	if err := json.Unmarshal(data, &gj); err != nil {
-		return Schema{}, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	if err := json.NewEncoder(tmp).Encode(out); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write normalized features: %w", err)
 	}
 
-	if len(gj.Features) == 0 {
+	return tmp.Name(), nil
+}
+
+// inferSchema infers the table schema from the first sample records, or from
+// all of them when sample is 0, using an empty inferrer
+func inferSchema(records []Record, sample int, inferrer schemaInferrer) (Schema, error) {
+	if len(records) == 0 {
 		return Schema{}, fmt.Errorf("GeoJSON file contains no features")
 	}
+	if sample > 0 && sample < len(records) {
+		records = records[:sample]
+	}
 
-	// Infer types from first feature
-	firstFeature := gj.Features[0]
-	var columns []database.Column
+	for _, r := range records {
+		inferrer.add(r)
+	}
+	return inferrer.schema(), nil
+}
+
+// schemaInferrer collects the property keys of records in the order they
+// first appear, widening each column's type to fit every value seen
+type schemaInferrer struct {
+	names  []string
+	types  map[string]string
+	nested NestedPolicy
+	// keepStrings skips detecting dates, timestamps and UUIDs in strings
+	keepStrings bool
+}
+
+// add widens the schema to fit a record. NULLs fit any type.
+func (s *schemaInferrer) add(r Record) {
+	if s.types == nil {
+		s.types = make(map[string]string)
+	}
+	for _, prop := range r.Columns {
+		seen, ok := s.types[prop.Key]
+		if !ok {
+			s.names = append(s.names, prop.Key)
+		}
+		if prop.Value == nil {
+			if !ok {
+				s.types[prop.Key] = ""
+			}
+			continue
+		}
+		s.types[prop.Key] = widenType(seen, s.valueType(prop.Value))
+	}
+}
+
+// valueType infers the column type of a single non-null value
+func (s *schemaInferrer) valueType(value interface{}) string {
+	if s.nested == NestedJSON && isNested(value) {
+		return "JSON"
+	}
+	if text, ok := value.(string); ok && !s.keepStrings {
+		return detectStringType(text)
+	}
+	return inferType(value)
+}
 
-	for key, value := range firstFeature.Properties {
-		colType := inferType(value)
-		columns = append(columns, database.Column{
-			Name: key,
-			Type: colType,
-		})
+// schema returns the columns seen so far plus the geometry column. Columns
+// that only ever held NULL become VARCHAR.
+func (s *schemaInferrer) schema() Schema {
+	var columns []database.Column
+	for _, name := range s.names {
+		colType := s.types[name]
+		if colType == "" {
+			colType = "VARCHAR"
+		}
+		columns = append(columns, database.Column{Name: name, Type: colType})
 	}
 
 	// Always add geometry column
@@ -126,7 +457,25 @@ func inferSchemaFromGeoJSON(geojsonPath string) (Schema, error) {
 		Type: "GEOMETRY",
 	})
 
-	return Schema{Columns: columns}, nil
+	return Schema{Columns: columns}
+}
+
+// widenType returns the narrowest type holding values of both types, along
+// BIGINT -> DOUBLE -> VARCHAR and DATE -> TIMESTAMP -> VARCHAR. An empty type
+// means no values yet.
+func widenType(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	case (a == "BIGINT" && b == "DOUBLE") || (a == "DOUBLE" && b == "BIGINT"):
+		return "DOUBLE"
+	case (a == "DATE" && b == "TIMESTAMP") || (a == "TIMESTAMP" && b == "DATE"):
+		return "TIMESTAMP"
+	default:
+		return "VARCHAR"
+	}
 }
 
 // inferType infers DuckDB type from Go value
@@ -149,81 +498,65 @@ func inferType(value interface{}) string {
 	}
 }
 
-// createTableFromSchema creates a table with the inferred schema
-func createTableFromSchema(db *sql.DB, tableName string, schema Schema) error {
+// createTableStatements returns the SQL that creates the schema (if qualified) and table
+func createTableStatements(tableName string, schema Schema) []string {
+	var statements []string
+
+	// Make sure the target schema exists for qualified names like staging.roads
+	if dbSchema, _ := database.SplitTableName(tableName); dbSchema != "main" {
+		statements = append(statements, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", database.QuoteIdentifier(dbSchema)))
+	}
+
 	var colDefs []string
 	for _, col := range schema.Columns {
-		colDefs = append(colDefs, fmt.Sprintf("%s %s", col.Name, col.Type))
+		colDefs = append(colDefs, fmt.Sprintf("%s %s", database.QuoteIdentifier(col.Name), col.Type))
 	}
-
-	createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", tableName, strings.Join(colDefs, ", "))
-	_, err := db.Exec(createSQL)
-	if err != nil {
-		return fmt.Errorf("failed to execute CREATE TABLE: %w", err)
+	if schema.PrimaryKey != "" {
+		colDefs = append(colDefs, fmt.Sprintf("PRIMARY KEY (%s)", database.QuoteIdentifier(schema.PrimaryKey)))
 	}
 
-	return nil
+	statements = append(statements, fmt.Sprintf("CREATE TABLE %s (%s)", database.QuoteTableName(tableName), strings.Join(colDefs, ", ")))
+	return statements
 }
 
-// loadDataIntoTable loads GeoJSON features into the specified table
-func loadDataIntoTable(db *sql.DB, dbPath, tableName, geojsonPath string) (int, error) {
-	// First, create a temporary view of the GeoJSON file
-	createTempSQL := fmt.Sprintf(`
-		CREATE TEMPORARY TABLE temp_geojson AS
-		SELECT * FROM read_json_auto('%s')
-	`, geojsonPath)
-
-	_, err := db.Exec(createTempSQL)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read GeoJSON file: %w", err)
-	}
-	defer db.Exec("DROP TABLE IF EXISTS temp_geojson")
-
-	// Get the column names from the target table (excluding geom)
-	schema, err := database.GetTableSchema(dbPath, tableName)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get table schema: %w", err)
-	}
-
-	// Build column list (excluding geometry)
-	var propCols []string
-	for _, col := range schema {
-		if col.Name != "geom" {
-			propCols = append(propCols, col.Name)
+// insertStatements returns the SQL that stages the normalized GeoJSON file in a
+// temporary table, inserts its features into the target table, and drops the
+// stage. Loads append the records instead; this is the SQL --emit-sql prints.
+func insertStatements(tableName string, columns []database.Column, geojsonPath string, ids IDOptions) (stage, insert, drop string) {
+	// Keep features as JSON: inferring a STRUCT would give every nested object
+	// the members of all the others
+	stage = fmt.Sprintf(`CREATE TEMPORARY TABLE temp_geojson AS
+SELECT * FROM read_json(%s, columns = {features: 'JSON[]'})`, database.QuoteLiteral(geojsonPath))
+
+	// Build the column lists, extracting properties by their (normalized) key
+	var targetCols, selectCols []string
+	for _, col := range columns {
+		if col.Name == "geom" || ids.isColumn(col.Name) {
+			continue
 		}
+		targetCols = append(targetCols, database.QuoteIdentifier(col.Name))
+		selectCols = append(selectCols, fmt.Sprintf("properties->>%s AS %s", database.QuoteLiteral(col.Name), database.QuoteIdentifier(col.Name)))
 	}
-
-	// Build the SELECT part for properties
-	var selectCols []string
-	for _, colName := range propCols {
-		selectCols = append(selectCols, fmt.Sprintf("properties->>'%s' as %s", colName, colName))
-	}
-	selectCols = append(selectCols, "ST_GeomFromGeoJSON(json(geometry)) as geom")
-
-	// Build and execute INSERT statement
-	insertSQL := fmt.Sprintf(`
-		INSERT INTO %s
-		SELECT %s
-		FROM (
-			SELECT unnest(features) as feature
-			FROM temp_geojson
-		) sub,
-		LATERAL (
-			SELECT
-				feature->'properties' as properties,
-				feature->'geometry' as geometry
-		) extracted
-	`, tableName, strings.Join(selectCols, ", "))
-
-	result, err := db.Exec(insertSQL)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert data: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	targetCols = append(targetCols, database.QuoteIdentifier("geom"))
+	selectCols = append(selectCols, "ST_GeomFromGeoJSON(json(geometry)) AS geom")
+	if ids.enabled() {
+		targetCols = append(targetCols, database.QuoteIdentifier(ids.Column))
+		selectCols = append(selectCols, ids.expression(tableName, "ST_GeomFromGeoJSON(json(geometry))"))
 	}
 
-	return int(rowsAffected), nil
+	insert = fmt.Sprintf(`INSERT INTO %s (%s)
+SELECT %s
+FROM (
+	SELECT unnest(features) AS feature
+	FROM temp_geojson
+) sub,
+LATERAL (
+	SELECT
+		feature->'properties' AS properties,
+		-- A JSON null geometry must become SQL NULL
+		CASE WHEN json_type(feature->'geometry') <> 'NULL' THEN feature->'geometry' END AS geometry
+) extracted`, database.QuoteTableName(tableName), strings.Join(targetCols, ", "), strings.Join(selectCols, ", "))
+
+	drop = "DROP TABLE IF EXISTS temp_geojson"
+	return stage, insert, drop
 }
@@ -0,0 +1,167 @@
+package geojson
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// MappingEntry says where one incoming property goes when appending
+type MappingEntry struct {
+	// Property is the property's column name as a load resolves it
+	Property string `yaml:"property"`
+	// Column is the target column; it is added to the table with Type when
+	// the table doesn't have it yet
+	Column string `yaml:"column,omitempty"`
+	Type   string `yaml:"type,omitempty"`
+	// Skip drops the property
+	Skip bool `yaml:"skip,omitempty"`
+}
+
+// Mapping routes the properties of a file onto the columns of an existing
+// table. Properties it doesn't mention go to the column of the same name.
+type Mapping struct {
+	Properties []MappingEntry `yaml:"properties"`
+}
+
+// LoadMapping reads and checks a mapping file
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+
+	var m Mapping
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+
+	targets := make(map[string]string)
+	for i, e := range m.Properties {
+		if strings.TrimSpace(e.Property) == "" {
+			return nil, fmt.Errorf("mapping file entry %d has no property", i+1)
+		}
+		if e.Skip {
+			continue
+		}
+		if strings.TrimSpace(e.Column) == "" {
+			return nil, fmt.Errorf("mapping for property %s needs a column or skip: true", e.Property)
+		}
+		if other, ok := targets[e.Column]; ok {
+			return nil, fmt.Errorf("properties %s and %s are both mapped to column %s", other, e.Property, e.Column)
+		}
+		targets[e.Column] = e.Property
+	}
+	return &m, nil
+}
+
+// Save writes the mapping as YAML
+func (m *Mapping) Save(path string) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("failed to encode mapping file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode mapping file: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write mapping file: %w", err)
+	}
+	return nil
+}
+
+// SuggestMapping maps every property the table has no column for to a new
+// column of the same name, typed from the file's values
+func SuggestMapping(diff SchemaDiff) *Mapping {
+	m := &Mapping{}
+	for _, c := range diff.Columns {
+		if c.Kind != DiffFileOnly {
+			continue
+		}
+		m.Properties = append(m.Properties, MappingEntry{Property: c.Name, Column: c.Name, Type: NewColumnType(c.FileType)})
+	}
+	return m
+}
+
+// NewColumnType picks the column type for values of a file type reported
+// by DiffSchema; mixed and all-NULL properties become VARCHAR
+func NewColumnType(fileType string) string {
+	if fileType == "" || fileType == "NULL" || strings.Contains(fileType, "|") {
+		return "VARCHAR"
+	}
+	return fileType
+}
+
+// apply renames and drops the properties of a record. A mapped property
+// takes precedence over an unmapped one already named like its column.
+func (m *Mapping) apply(props []Property) []Property {
+	if m == nil {
+		return props
+	}
+	entries := make(map[string]MappingEntry, len(m.Properties))
+	targets := make(map[string]bool, len(m.Properties))
+	for _, e := range m.Properties {
+		entries[e.Property] = e
+		if !e.Skip {
+			targets[e.Column] = true
+		}
+	}
+
+	out := make([]Property, 0, len(props))
+	for _, p := range props {
+		e, ok := entries[p.Key]
+		switch {
+		case !ok:
+			if targets[p.Key] {
+				continue
+			}
+		case e.Skip:
+			continue
+		default:
+			p.Key = e.Column
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// newColumns returns the mapped columns the table doesn't have yet
+func (m *Mapping) newColumns(existing []database.Column) []database.Column {
+	if m == nil {
+		return nil
+	}
+	have := make(map[string]bool, len(existing))
+	for _, col := range existing {
+		have[col.Name] = true
+	}
+
+	var added []database.Column
+	for _, e := range m.Properties {
+		if e.Skip || have[e.Column] {
+			continue
+		}
+		colType := e.Type
+		if colType == "" {
+			colType = "VARCHAR"
+		}
+		added = append(added, database.Column{Name: e.Column, Type: colType})
+		have[e.Column] = true
+	}
+	return added
+}
+
+// addColumnStatements returns the SQL that adds columns to a table
+func addColumnStatements(tableName string, columns []database.Column) []string {
+	var statements []string
+	for _, col := range columns {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+			database.QuoteTableName(tableName), database.QuoteIdentifier(col.Name), col.Type))
+	}
+	return statements
+}
@@ -0,0 +1,69 @@
+package geojson
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NullRule lists the property values that should be stored as NULL
+type NullRule struct {
+	EmptyAsNull bool
+	Values      []string
+}
+
+// NullPolicy applies a default NullRule with optional per-column overrides
+type NullPolicy struct {
+	Default NullRule
+	// Columns holds overrides keyed by lower-case column name
+	Columns map[string]NullRule
+}
+
+// ruleFor returns the rule that applies to a column
+func (p NullPolicy) ruleFor(column string) NullRule {
+	if rule, ok := p.Columns[strings.ToLower(column)]; ok {
+		return rule
+	}
+	return p.Default
+}
+
+// matches reports whether a value should become NULL under this rule
+func (r NullRule) matches(value interface{}) bool {
+	var text string
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return r.EmptyAsNull
+		}
+		text = v
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return false
+	}
+
+	for _, candidate := range r.Values {
+		if candidate == text {
+			return true
+		}
+		// Let "-9999" match -9999.0 and similar spellings of the same number
+		if n, err := strconv.ParseFloat(candidate, 64); err == nil {
+			if f, ok := value.(float64); ok && f == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyNullPolicy replaces matching values with nil, counting replacements per column
+func applyNullPolicy(columns []Property, policy NullPolicy, nulled map[string]int) {
+	for i, col := range columns {
+		if col.Value == nil {
+			continue
+		}
+		if policy.ruleFor(col.Key).matches(col.Value) {
+			columns[i].Value = nil
+			nulled[col.Key]++
+		}
+	}
+}
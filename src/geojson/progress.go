@@ -0,0 +1,121 @@
+package geojson
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Phase is the stage a load is in
+type Phase string
+
+const (
+	// PhaseReading parses the file into memory
+	PhaseReading Phase = "Reading"
+	// PhaseWriting appends the parsed features to the table
+	PhaseWriting Phase = "Writing"
+	// PhaseLoading reads and appends features at once, when streaming
+	PhaseLoading Phase = "Loading"
+)
+
+// Progress tracks a running load so it can be reported from another
+// goroutine. A nil *Progress tracks nothing.
+type Progress struct {
+	bytesRead  atomic.Int64
+	totalBytes atomic.Int64
+	features   atomic.Int64
+	written    atomic.Int64
+
+	mu      sync.Mutex
+	phase   Phase
+	started time.Time
+}
+
+// ProgressSnapshot is the state of a load at one moment
+type ProgressSnapshot struct {
+	Phase Phase
+	// Started is when the phase started
+	Started time.Time
+	// BytesRead and TotalBytes measure the input file read so far
+	BytesRead  int64
+	TotalBytes int64
+	// Features is the number of features parsed, Written the number appended
+	// to the table
+	Features int64
+	Written  int64
+}
+
+// Snapshot returns the current state of the load
+func (p *Progress) Snapshot() ProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ProgressSnapshot{
+		Phase:      p.phase,
+		Started:    p.started,
+		BytesRead:  p.bytesRead.Load(),
+		TotalBytes: p.totalBytes.Load(),
+		Features:   p.features.Load(),
+		Written:    p.written.Load(),
+	}
+}
+
+// Fraction is how far the phase has got, from 0 to 1: the share of the file
+// read, or while writing the share of the parsed features appended
+func (s ProgressSnapshot) Fraction() float64 {
+	if s.Phase == PhaseWriting {
+		if s.Features == 0 {
+			return 0
+		}
+		return min(float64(s.Written)/float64(s.Features), 1)
+	}
+	if s.TotalBytes == 0 {
+		return 0
+	}
+	return min(float64(s.BytesRead)/float64(s.TotalBytes), 1)
+}
+
+// start begins a phase
+func (p *Progress) start(phase Phase) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase = phase
+	p.started = time.Now()
+}
+
+// reader counts the bytes read through r as the file's progress
+func (p *Progress) reader(r io.Reader, size int64) io.Reader {
+	if p == nil {
+		return r
+	}
+	p.bytesRead.Store(0)
+	p.totalBytes.Store(size)
+	return progressReader{r: r, n: &p.bytesRead}
+}
+
+func (p *Progress) addFeature() {
+	if p != nil {
+		p.features.Add(1)
+	}
+}
+
+func (p *Progress) addWritten() {
+	if p != nil {
+		p.written.Add(1)
+	}
+}
+
+// progressReader adds the bytes read through it to n
+type progressReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (r progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,210 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DuplicatePolicy controls how property keys that map to the same column are resolved
+type DuplicatePolicy string
+
+const (
+	// DuplicateFirstWins keeps the first value seen for a column
+	DuplicateFirstWins DuplicatePolicy = "first"
+	// DuplicateLastWins keeps the last value seen for a column
+	DuplicateLastWins DuplicatePolicy = "last"
+	// DuplicateSuffix keeps every value, adding _2, _3, ... to later columns
+	DuplicateSuffix DuplicatePolicy = "suffix"
+	// DuplicateError aborts the load on the first collision
+	DuplicateError DuplicatePolicy = "error"
+)
+
+// ParseDuplicatePolicy validates a policy name given on the command line
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case DuplicateFirstWins, DuplicateLastWins, DuplicateSuffix, DuplicateError:
+		return p, nil
+	case "":
+		return DuplicateFirstWins, nil
+	default:
+		return "", fmt.Errorf("invalid duplicate key policy %q (expected first, last, suffix or error)", s)
+	}
+}
+
+// NestedPolicy controls how object and array property values are stored
+type NestedPolicy string
+
+const (
+	// NestedText stores nested values as their JSON text in VARCHAR columns
+	NestedText NestedPolicy = ""
+	// NestedFlatten spreads the members of objects over dotted columns such
+	// as address.city; arrays are kept as text
+	NestedFlatten NestedPolicy = "flatten"
+	// NestedJSON stores nested values in JSON columns
+	NestedJSON NestedPolicy = "json"
+)
+
+// ParseNestedPolicy validates a policy name given on the command line
+func ParseNestedPolicy(s string) (NestedPolicy, error) {
+	switch p := NestedPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case NestedText, NestedFlatten, NestedJSON:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid nested property policy %q (expected flatten or json)", s)
+	}
+}
+
+// Property is a single key/value pair from a feature's properties object
+type Property struct {
+	Key   string
+	Value interface{}
+}
+
+// decodeProperties decodes a properties object keeping key order and literal duplicates,
+// which encoding/json would otherwise silently collapse into a map
+func decodeProperties(raw json.RawMessage) ([]Property, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read properties: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("properties must be a JSON object")
+	}
+
+	var props []Property
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read property key: %w", err)
+		}
+		key, _ := tok.(string)
+
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to read property %q: %w", key, err)
+		}
+		props = append(props, Property{Key: key, Value: value})
+	}
+
+	return props, nil
+}
+
+// SanitizeColumnName turns a property key into a usable column name
+func SanitizeColumnName(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		return "_"
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// resolveColumns maps a feature's properties onto column names, applying the
+// duplicate policy whenever two keys end up on the same (case-insensitive) column.
+// Every collision is recorded against the column name in duplicates.
+func resolveColumns(props []Property, policy DuplicatePolicy, duplicates map[string]int) ([]Property, error) {
+	var columns []Property
+	index := make(map[string]int)
+
+	for _, prop := range props {
+		name := SanitizeColumnName(prop.Key)
+		lower := strings.ToLower(name)
+
+		pos, seen := index[lower]
+		if !seen {
+			index[lower] = len(columns)
+			columns = append(columns, Property{Key: name, Value: prop.Value})
+			continue
+		}
+
+		existing := columns[pos].Key
+		duplicates[existing]++
+
+		switch policy {
+		case DuplicateError:
+			return nil, fmt.Errorf("property %q collides with column %q", prop.Key, existing)
+		case DuplicateLastWins:
+			columns[pos].Value = prop.Value
+		case DuplicateSuffix:
+			for n := 2; ; n++ {
+				candidate := fmt.Sprintf("%s_%d", name, n)
+				if _, taken := index[strings.ToLower(candidate)]; !taken {
+					index[strings.ToLower(candidate)] = len(columns)
+					columns = append(columns, Property{Key: candidate, Value: prop.Value})
+					break
+				}
+			}
+		default:
+			// First value wins, nothing to do
+		}
+	}
+
+	return columns, nil
+}
+
+// flattenColumns replaces object values with one column per member, named
+// <column>.<member> and nested as deep as the objects are. Members are
+// resolved like top-level keys, in key order, so colliding keys follow the
+// duplicate policy too.
+func flattenColumns(columns []Property, policy DuplicatePolicy, duplicates map[string]int) ([]Property, error) {
+	var flat []Property
+	for _, col := range columns {
+		obj, ok := col.Value.(map[string]interface{})
+		if !ok {
+			flat = append(flat, col)
+			continue
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		members := make([]Property, len(keys))
+		for i, k := range keys {
+			members[i] = Property{Key: k, Value: obj[k]}
+		}
+
+		members, err := resolveColumns(members, policy, duplicates)
+		if err != nil {
+			return nil, err
+		}
+		if members, err = flattenColumns(members, policy, duplicates); err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			flat = append(flat, Property{Key: col.Key + "." + m.Key, Value: m.Value})
+		}
+	}
+	return flat, nil
+}
+
+// isNested reports whether a property value is an object or array
+func isNested(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
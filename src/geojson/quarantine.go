@@ -0,0 +1,187 @@
+package geojson
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// QuarantineSuffix is appended to a table's name to name its quarantine table
+const QuarantineSuffix = "_quarantine"
+
+// QuarantineTable returns the quarantine table for tableName, in the same schema
+func QuarantineTable(tableName string) string {
+	return tableName + QuarantineSuffix
+}
+
+// rejectedRecord is a record kept out of the target table, with the reason
+type rejectedRecord struct {
+	Record
+	Reason string
+}
+
+// contextExecer is satisfied by *sql.Tx and *sql.Conn
+type contextExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// splitRejected separates the records that would fail validation or the cast
+// into their column's type from those that can be inserted
+func splitRejected(records []Record, columns []database.Column) (accepted []Record, rejected []rejectedRecord) {
+	types := columnTypes(columns)
+	for _, r := range records {
+		if reason := rejectReason(r, types); reason != "" {
+			rejected = append(rejected, rejectedRecord{Record: r, Reason: reason})
+		} else {
+			accepted = append(accepted, r)
+		}
+	}
+	return accepted, rejected
+}
+
+// columnTypes maps lower-case column names to upper-case types
+func columnTypes(columns []database.Column) map[string]string {
+	types := make(map[string]string, len(columns))
+	for _, col := range columns {
+		types[strings.ToLower(col.Name)] = strings.ToUpper(col.Type)
+	}
+	return types
+}
+
+// rejectReason describes everything wrong with a record, or returns "" when it
+// can be inserted
+func rejectReason(r Record, types map[string]string) string {
+	problems := append([]string(nil), r.Problems...)
+
+	if t := GeometryType(r.Geometry); t != "null" && !validGeometryTypes[t] {
+		problems = append(problems, fmt.Sprintf("geometry: unknown type %q", t))
+	}
+	for _, prop := range r.Columns {
+		colType, ok := types[strings.ToLower(prop.Key)]
+		if ok && !castable(prop.Value, colType) {
+			problems = append(problems, fmt.Sprintf("%s: can't store %q as %s", prop.Key, valueText(prop.Value), colType))
+		}
+	}
+
+	return strings.Join(problems, "; ")
+}
+
+var validGeometryTypes = map[string]bool{
+	"Point": true, "LineString": true, "Polygon": true,
+	"MultiPoint": true, "MultiLineString": true, "MultiPolygon": true,
+	"GeometryCollection": true,
+}
+
+// integerRanges bounds the values each integer type can hold
+var integerRanges = map[string][2]float64{
+	"TINYINT":   {math.MinInt8, math.MaxInt8},
+	"SMALLINT":  {math.MinInt16, math.MaxInt16},
+	"INTEGER":   {math.MinInt32, math.MaxInt32},
+	"BIGINT":    {math.MinInt64, math.MaxInt64},
+	"UTINYINT":  {0, math.MaxUint8},
+	"USMALLINT": {0, math.MaxUint16},
+	"UINTEGER":  {0, math.MaxUint32},
+	"UBIGINT":   {0, math.MaxUint64},
+}
+
+// castable reports whether DuckDB can cast the text of a property value (as
+// extracted with ->>) to colType. Types other than text, numbers and booleans
+// aren't checked.
+func castable(value interface{}, colType string) bool {
+	if value == nil || isTextType(colType) {
+		return true
+	}
+
+	switch value.(type) {
+	case string, float64, bool:
+	default:
+		// Objects and arrays only fit text columns
+		return !isNumericType(colType) && colType != "BOOLEAN"
+	}
+	text := strings.TrimSpace(valueText(value))
+
+	switch {
+	case colType == "JSON":
+		// Strings are stored unquoted, so only those spelling out JSON fit
+		return json.Valid([]byte(text))
+	case colType == "BOOLEAN":
+		switch strings.ToLower(text) {
+		case "true", "false", "t", "f", "1", "0", "yes", "no", "y", "n":
+			return true
+		}
+		return false
+	case isIntegerType(colType):
+		n, ok := parseNumber(text)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return false
+		}
+		if r, ok := integerRanges[colType]; ok {
+			return math.Round(n) >= r[0] && math.Round(n) <= r[1]
+		}
+		return true
+	case isNumericType(colType):
+		_, ok := parseNumber(text)
+		return ok
+	}
+	return true
+}
+
+// parseNumber parses integer literals (including 0x and _ separators) and decimals
+func parseNumber(text string) (float64, bool) {
+	if n, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return float64(n), true
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil && !math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+// quarantineStatements returns the SQL creating the quarantine table and the
+// parameterized insert for one rejected feature
+func quarantineStatements(tableName string) (create, insert string) {
+	table := database.QuoteTableName(QuarantineTable(tableName))
+	create = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	properties JSON,
+	geometry JSON,
+	reason VARCHAR,
+	source VARCHAR,
+	quarantined_at TIMESTAMP DEFAULT current_timestamp
+)`, table)
+	insert = fmt.Sprintf("INSERT INTO %s (properties, geometry, reason, source) VALUES (?, ?, ?, ?)", table)
+	return create, insert
+}
+
+// writeQuarantine stores rejected records with their raw properties and geometry
+func writeQuarantine(ctx context.Context, db contextExecer, tableName, source string, rejected []rejectedRecord) error {
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	createSQL, insertSQL := quarantineStatements(tableName)
+	if _, err := db.ExecContext(ctx, createSQL); err != nil {
+		return fmt.Errorf("failed to create quarantine table: %w", err)
+	}
+	for _, r := range rejected {
+		if _, err := db.ExecContext(ctx, insertSQL, rawJSON(r.Properties), rawJSON(r.Geometry), r.Reason, source); err != nil {
+			return fmt.Errorf("failed to quarantine feature: %w", err)
+		}
+	}
+	return nil
+}
+
+// rawJSON returns raw JSON as text, or nil when it's missing or null
+func rawJSON(raw []byte) interface{} {
+	text := strings.TrimSpace(string(raw))
+	if text == "" || text == "null" {
+		return nil
+	}
+	return text
+}
@@ -0,0 +1,88 @@
+package geojson
+
+import (
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// SchemaMode decides what happens when features don't match the columns of
+// the existing table they are appended to
+type SchemaMode string
+
+const (
+	// SchemaCast drops properties the table has no column for and casts
+	// values to the column types
+	SchemaCast SchemaMode = "cast"
+	// SchemaMerge adds a column for every property the table lacks, then
+	// casts like SchemaCast
+	SchemaMerge SchemaMode = "merge"
+	// SchemaStrict fails unless every property has a column of a type its
+	// values fit without conversion
+	SchemaStrict SchemaMode = "strict"
+)
+
+// ParseSchemaMode parses the --schema-mode flag; "" means SchemaCast
+func ParseSchemaMode(s string) (SchemaMode, error) {
+	switch SchemaMode(s) {
+	case "", SchemaCast:
+		return SchemaCast, nil
+	case SchemaMerge, SchemaStrict:
+		return SchemaMode(s), nil
+	}
+	return "", fmt.Errorf("invalid schema mode %q (expected merge, strict or cast)", s)
+}
+
+// reconcileSchema compares the columns of an existing table with the schema
+// inferred from the incoming features. With SchemaMerge it returns the
+// columns to add; with SchemaStrict it fails with the differences.
+func reconcileSchema(tableName string, columns []database.Column, file *schemaInferrer, mode SchemaMode) ([]database.Column, error) {
+	if mode == SchemaCast {
+		return nil, nil
+	}
+
+	// DuckDB column names are case-insensitive
+	types := columnTypes(columns)
+
+	var added []database.Column
+	var problems []string
+	for _, name := range file.names {
+		fileType := file.types[name]
+		tableType, ok := types[strings.ToLower(name)]
+		switch {
+		case !ok:
+			colType := fileType
+			if colType == "" {
+				colType = "VARCHAR"
+			}
+			added = append(added, database.Column{Name: name, Type: colType})
+			problems = append(problems, fmt.Sprintf("  + %s %s (not in table)", name, colType))
+		case !typeFits(fileType, tableType):
+			problems = append(problems, fmt.Sprintf("  ~ %s: file has %s, table has %s", name, fileType, tableType))
+		}
+	}
+
+	if mode == SchemaStrict && len(problems) > 0 {
+		return nil, fmt.Errorf("features don't match the schema of table '%s':\n%s", tableName, strings.Join(problems, "\n"))
+	}
+	return added, nil
+}
+
+// typeFits reports whether values inferred as fileType can be stored in a
+// tableType column without conversion. An empty fileType (only NULLs) fits
+// anything.
+func typeFits(fileType, tableType string) bool {
+	switch {
+	case fileType == "" || fileType == tableType:
+		return true
+	case fileType == "BIGINT":
+		return isNumericType(tableType)
+	case fileType == "DOUBLE":
+		return isNumericType(tableType) && !isIntegerType(tableType)
+	case fileType == "VARCHAR" || isDetectedType(fileType):
+		// Dates, timestamps and UUIDs were strings in the file
+		return isTextType(tableType) || (fileType == "DATE" && tableType == "TIMESTAMP")
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package geojson
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PropertySelection decides which properties become columns. Patterns are
+// matched case-insensitively against column names and may use * and ?
+// globs; a flattened column such as address.city is also matched by the
+// pattern of its parent, address.
+type PropertySelection struct {
+	// Include keeps only the properties matching one of its patterns
+	Include []string
+	// Exclude drops the properties matching one of its patterns, after
+	// Include has been applied
+	Exclude []string
+}
+
+// ParsePropertySelection validates the --include-props and --exclude-props
+// patterns, returning nil when neither has any
+func ParsePropertySelection(include, exclude []string) (*PropertySelection, error) {
+	include, err := normalizePatterns("--include-props", include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err = normalizePatterns("--exclude-props", exclude)
+	if err != nil {
+		return nil, err
+	}
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	return &PropertySelection{Include: include, Exclude: exclude}, nil
+}
+
+// normalizePatterns lower-cases patterns and, when they are plain property
+// keys, sanitizes them the way their columns' names are
+func normalizePatterns(flag string, patterns []string) ([]string, error) {
+	var out []string
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", flag, p, err)
+		}
+		if !strings.ContainsAny(p, `*?[\`) {
+			parts := strings.Split(p, ".")
+			for i, part := range parts {
+				parts[i] = SanitizeColumnName(part)
+			}
+			p = strings.Join(parts, ".")
+		}
+		out = append(out, strings.ToLower(p))
+	}
+	return out, nil
+}
+
+// apply returns the selected columns. A nil selection keeps every column.
+func (s *PropertySelection) apply(columns []Property) []Property {
+	if s == nil {
+		return columns
+	}
+	kept := columns[:0:0]
+	for _, col := range columns {
+		name := strings.ToLower(col.Key)
+		if len(s.Include) > 0 && !matchesAny(name, s.Include) {
+			continue
+		}
+		if matchesAny(name, s.Exclude) {
+			continue
+		}
+		kept = append(kept, col)
+	}
+	return kept
+}
+
+// matchesAny reports whether a lower-case column name, or one of the parents
+// of a flattened one, matches a pattern
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		for candidate := name; ; {
+			if ok, _ := path.Match(p, candidate); ok {
+				return true
+			}
+			i := strings.LastIndexByte(candidate, '.')
+			if i < 0 {
+				break
+			}
+			candidate = candidate[:i]
+		}
+	}
+	return false
+}
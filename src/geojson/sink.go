@@ -0,0 +1,106 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// Sink receives the records produced by Load. Implementations fill in the
+// parts of result they know about, at least RowCount.
+type Sink interface {
+	Write(records []Record, result *LoadResult) error
+}
+
+// recordFeature is the GeoJSON form of a Record
+type recordFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   json.RawMessage        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// feature converts a record back to a GeoJSON feature keyed by column name
+func (r Record) feature() recordFeature {
+	props := make(map[string]interface{}, len(r.Columns))
+	for _, col := range r.Columns {
+		props[col.Key] = col.Value
+	}
+	geometry := r.Geometry
+	if len(geometry) == 0 {
+		geometry = json.RawMessage("null")
+	}
+	return recordFeature{Type: "Feature", Geometry: geometry, Properties: props}
+}
+
+// GeoJSONLSink writes one GeoJSON feature per line (GeoJSONL)
+type GeoJSONLSink struct {
+	W io.Writer
+}
+
+// Write implements Sink
+func (s *GeoJSONLSink) Write(records []Record, result *LoadResult) error {
+	enc := json.NewEncoder(s.W)
+	for i, r := range records {
+		if err := enc.Encode(r.feature()); err != nil {
+			return fmt.Errorf("failed to write feature %d: %w", i, err)
+		}
+	}
+	result.RowCount = len(records)
+	return nil
+}
+
+// ParquetSink writes records to a (Geo)Parquet file through an in-memory DuckDB
+type ParquetSink struct {
+	Path string
+}
+
+// Write implements Sink
+func (s *ParquetSink) Write(records []Record, result *LoadResult) error {
+	absPath, err := filepath.Abs(s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output path: %w", err)
+	}
+
+	schema, err := inferSchema(records, 0, schemaInferrer{})
+	if err != nil {
+		return fmt.Errorf("failed to infer schema: %w", err)
+	}
+
+	db, err := database.OpenInMemory()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	const table = "features"
+	result.Coercions = make(map[string]CoercionStats)
+	rows, err := appendRecords(db, createTableStatements(table, schema), table, schema.Columns, IDOptions{}, records, result.Coercions, nil, database.GeometryCheckOff, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	copySQL := fmt.Sprintf("COPY %s TO %s (FORMAT parquet)", database.QuoteTableName(table), database.QuoteLiteral(absPath))
+	if _, err := db.Exec(copySQL); err != nil {
+		return fmt.Errorf("failed to write Parquet file: %w", err)
+	}
+
+	result.RowCount = int(rows)
+	return nil
+}
+
+// MultiSink fans records out to several sinks in order, e.g. a table and a
+// Parquet copy. The result reflects the last sink.
+type MultiSink []Sink
+
+// Write implements Sink
+func (m MultiSink) Write(records []Record, result *LoadResult) error {
+	for _, sink := range m {
+		if err := sink.Write(records, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package geojson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/compressed"
+)
+
+// Format identifies how features are laid out in an input file
+type Format string
+
+const (
+	// FormatAuto detects the format from the file extension and contents
+	FormatAuto Format = ""
+	// FormatGeoJSON is a FeatureCollection (or a single Feature)
+	FormatGeoJSON Format = "geojson"
+	// FormatGeoJSONL has one Feature per line (also known as NDJSON or GeoJSONSeq)
+	FormatGeoJSONL Format = "geojsonl"
+)
+
+// ParseFormat converts a --format flag value into a Format
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return FormatAuto, nil
+	case "geojson":
+		return FormatGeoJSON, nil
+	case "geojsonl", "geojsonseq", "ndjson":
+		return FormatGeoJSONL, nil
+	default:
+		return FormatAuto, fmt.Errorf("invalid format %q (must be geojson or geojsonl)", s)
+	}
+}
+
+// DetectFormat works out a file's format from its extension, falling back to
+// looking at the first JSON value: a Feature followed by more data means one
+// feature per line
+func DetectFormat(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(compressed.Trim(path))) {
+	case ".geojsonl", ".geojsons", ".ndjson", ".jsonl":
+		return FormatGeoJSONL, nil
+	}
+
+	f, err := compressed.Open(path, nil)
+	if err != nil {
+		return FormatAuto, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(newRecordSeparatorReader(f))
+	var first struct {
+		Type string `json:"type"`
+	}
+	if err := dec.Decode(&first); err != nil {
+		// Let the GeoJSON parser report the problem
+		return FormatGeoJSON, nil
+	}
+	if first.Type == "Feature" && dec.More() {
+		return FormatGeoJSONL, nil
+	}
+	return FormatGeoJSON, nil
+}
+
+// StreamFile streams the features of a file in the given format (detected when
+// FormatAuto) to fn. .gz and .zst files and members of zip archives are
+// decompressed as they are read.
+func StreamFile(path string, format Format, fn func(Feature) error) error {
+	return streamFile(path, format, nil, fn, nil)
+}
+
+// streamFile is StreamFile counting the bytes read in progress. Features that
+// can't be decoded go to onError, when given, instead of ending the stream.
+func streamFile(path string, format Format, progress *Progress, fn func(Feature) error, onError func(*FeatureError) error) error {
+	if format == FormatAuto {
+		detected, err := DetectFormat(path)
+		if err != nil {
+			return err
+		}
+		format = detected
+	}
+
+	// Progress counts the compressed bytes of .gz, .zst and zip input
+	var wrap func(io.Reader, int64) io.Reader
+	if progress != nil {
+		wrap = progress.reader
+	}
+	r, err := compressed.Open(path, wrap)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer r.Close()
+
+	if format == FormatGeoJSONL {
+		if onError != nil {
+			return streamTolerantLines(r, fn, onError)
+		}
+		return StreamFeatureLines(r, fn)
+	}
+	return streamFeatures(r, fn, onError)
+}
+
+// StreamFeatureLines decodes newline-delimited features (GeoJSONL / NDJSON, and
+// RFC 8142 sequences with record separators) one at a time, calling fn for each
+func StreamFeatureLines(r io.Reader, fn func(Feature) error) error {
+	dec := json.NewDecoder(newRecordSeparatorReader(r))
+
+	for n := 1; ; n++ {
+		var f Feature
+		err := dec.Decode(&f)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse feature %d: %w", n, err)
+		}
+		if f.Type != "Feature" {
+			return fmt.Errorf("feature %d is not a GeoJSON Feature", n)
+		}
+		if err := fn(f); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// streamTolerantLines is StreamFeatureLines handing the lines that aren't
+// features to onError. Getting past broken JSON takes one feature per line,
+// so the lines are split before they're decoded.
+func streamTolerantLines(r io.Reader, fn func(Feature) error, onError func(*FeatureError) error) error {
+	br := bufio.NewReader(newRecordSeparatorReader(r))
+
+	n := 0
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			n++
+			var f Feature
+			var err error
+			if jsonErr := json.Unmarshal(line, &f); jsonErr != nil {
+				err = onError(&FeatureError{Raw: line, Err: fmt.Errorf("failed to parse feature %d: %w", n, jsonErr)})
+			} else if f.Type != "Feature" {
+				err = onError(&FeatureError{Raw: line, Err: fmt.Errorf("feature %d is not a GeoJSON Feature", n)})
+			} else {
+				err = fn(f)
+			}
+			if err != nil {
+				if errors.Is(err, ErrStop) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read features: %w", readErr)
+		}
+	}
+}
+
+// recordSeparatorReader turns RFC 8142 record separators into whitespace
+type recordSeparatorReader struct {
+	r io.Reader
+}
+
+func newRecordSeparatorReader(r io.Reader) io.Reader {
+	return recordSeparatorReader{bufio.NewReader(r)}
+}
+
+func (r recordSeparatorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == 0x1e {
+			p[i] = '\n'
+		}
+	}
+	return n, err
+}
+
+// ErrStop can be returned from a StreamFeatures callback to end the stream early
+var ErrStop = errors.New("stop streaming")
+
+// StreamFeatures decodes a FeatureCollection (or a single Feature) one feature at a
+// time, calling fn for each, so arbitrarily large files never sit in memory whole
+func StreamFeatures(r io.Reader, fn func(Feature) error) error {
+	return streamFeatures(r, fn, nil)
+}
+
+// streamFeatures is StreamFeatures handing the members of the features array
+// that aren't features to onError, when given. Broken JSON still ends the
+// stream, as there's no telling where the next feature starts.
+func streamFeatures(r io.Reader, fn func(Feature) error, onError func(*FeatureError) error) error {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("failed to parse GeoJSON: expected a JSON object")
+	}
+
+	// Members other than "features" are kept in case this is a bare Feature
+	members := make(map[string]json.RawMessage)
+	sawFeatures := false
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to parse GeoJSON: %w", err)
+		}
+		key, _ := tok.(string)
+
+		if key != "features" {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("failed to parse GeoJSON member %q: %w", key, err)
+			}
+			members[key] = raw
+			continue
+		}
+
+		sawFeatures = true
+		tok, err = dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to parse features: %w", err)
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			return fmt.Errorf("failed to parse features: expected an array")
+		}
+
+		for dec.More() {
+			var f Feature
+			if onError == nil {
+				if err := dec.Decode(&f); err != nil {
+					return fmt.Errorf("failed to parse feature: %w", err)
+				}
+				err = fn(f)
+			} else {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return fmt.Errorf("failed to parse feature: %w", err)
+				}
+				if jsonErr := json.Unmarshal(raw, &f); jsonErr != nil {
+					err = onError(&FeatureError{Raw: raw, Err: fmt.Errorf("failed to parse feature: %w", jsonErr)})
+				} else {
+					err = fn(f)
+				}
+			}
+			if err != nil {
+				if errors.Is(err, ErrStop) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		// Consume the closing bracket
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("failed to parse features: %w", err)
+		}
+	}
+
+	if sawFeatures {
+		return nil
+	}
+
+	// A lone Feature object rather than a collection
+	var typ string
+	json.Unmarshal(members["type"], &typ)
+	if typ != "Feature" {
+		return fmt.Errorf("GeoJSON is neither a FeatureCollection nor a Feature")
+	}
+
+	err = fn(Feature{Type: typ, ID: members["id"], Geometry: members["geometry"], Properties: members["properties"]})
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// GeometryType returns the "type" member of a raw GeoJSON geometry, or "null"
+func GeometryType(raw json.RawMessage) string {
+	var g struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &g); err != nil || g.Type == "" {
+		return "null"
+	}
+	return g.Type
+}
+
+// DecodeProperties returns a feature's properties in file order, including duplicate keys
+func (f Feature) DecodeProperties() ([]Property, error) {
+	return decodeProperties(f.Properties)
+}
@@ -0,0 +1,234 @@
+package geojson
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// loadStreaming decodes features one at a time and appends them in batches, so
+// only one batch is ever held in memory. The schema of a new table is inferred
+// in a first pass over the file, reading opts.InferSample features or all.
+func loadStreaming(dbPath, geojsonPath, tableName string, opts LoadOptions) (LoadResult, error) {
+	result := LoadResult{
+		Duplicates: make(map[string]int),
+		Nulled:     make(map[string]int),
+		Coercions:  make(map[string]CoercionStats),
+		Violations: make(map[string]map[string]int),
+	}
+
+	if opts.EmitSQL {
+		return result, fmt.Errorf("emitting SQL is not supported when streaming")
+	}
+
+	absDBPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve database path: %w", err)
+	}
+
+	tableExists, err := database.TableExists(absDBPath, tableName)
+	if err != nil {
+		return result, fmt.Errorf("failed to check if table exists: %w", err)
+	}
+
+	var columns, added []database.Column
+	var schema Schema
+	if tableExists {
+		columns, err = database.GetTableSchema(absDBPath, tableName)
+		if err != nil {
+			return result, fmt.Errorf("failed to get table schema: %w", err)
+		}
+		if err := checkIDColumn(columns, opts.IDs); err != nil {
+			return result, err
+		}
+		added = opts.Mapping.newColumns(columns)
+		columns = append(columns, added...)
+		// Comparing the features with the table takes a first pass
+		if opts.SchemaMode != "" && opts.SchemaMode != SchemaCast {
+			inferrer, _, err := inferStream(geojsonPath, opts)
+			if err != nil {
+				return result, fmt.Errorf("failed to infer schema: %w", err)
+			}
+			merged, err := reconcileSchema(tableName, columns, &inferrer, opts.SchemaMode)
+			if err != nil {
+				return result, err
+			}
+			merged = opts.Types.apply(merged)
+			added = append(added, merged...)
+			columns = append(columns, merged...)
+		}
+	} else {
+		if schema, err = inferStreamSchema(geojsonPath, opts); err != nil {
+			return result, fmt.Errorf("failed to infer schema: %w", err)
+		}
+		schema.Columns = opts.Types.apply(schema.Columns)
+		if schema, err = addIDColumn(schema, opts.IDs); err != nil {
+			return result, err
+		}
+	}
+
+	db, err := database.Open(absDBPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return result, err
+	}
+
+	// The staging table and appender are tied to one connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION"); err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	// Columns the mapping and schema mode add are part of the same transaction
+	for _, stmt := range addColumnStatements(tableName, added) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return result, fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
+	var loader *appendLoader
+	n := 0
+	opts.Progress.start(PhaseLoading)
+	err = streamFile(geojsonPath, opts.Format, opts.Progress, opts.BBox.filter(func(feat Feature) error {
+		record, err := resolveRecord(feat, n, opts, &result)
+		if err != nil {
+			return skipFeature(err, geojsonPath, opts, &result)
+		}
+		n++
+		opts.Progress.addFeature()
+
+		if loader == nil {
+			if !tableExists {
+				columns = schema.Columns
+				for _, stmt := range createTableStatements(tableName, schema) {
+					if _, err := conn.ExecContext(ctx, stmt); err != nil {
+						return fmt.Errorf("failed to create table: %w", err)
+					}
+				}
+			}
+			if loader, err = newAppendLoader(ctx, conn, tableName, columns, opts.IDs, result.Coercions); err != nil {
+				return err
+			}
+			loader.progress = opts.Progress
+			loader.check, loader.report = opts.GeometryCheck, &result.Geometries
+		}
+
+		if opts.Quarantine {
+			if reason := rejectReason(record, loader.types); reason != "" {
+				result.Quarantined++
+				return writeQuarantine(ctx, conn, tableName, geojsonPath, []rejectedRecord{{Record: record, Reason: reason}})
+			}
+		}
+
+		return loader.add(record)
+	}, &result.Outside), func(fe *FeatureError) error {
+		return skipFeature(fe, geojsonPath, opts, &result)
+	})
+	if err != nil {
+		if loader != nil {
+			loader.close()
+		}
+		return result, err
+	}
+
+	if loader == nil {
+		if result.Outside > 0 {
+			return result, fmt.Errorf("none of the %d features intersect the bounding box", result.Outside)
+		}
+		if !tableExists {
+			return result, fmt.Errorf("failed to infer schema: GeoJSON file contains no features")
+		}
+		return result, nil
+	}
+
+	if err := loader.finish(); err != nil {
+		return result, err
+	}
+
+	// Rows are already in the table, but nothing is visible until COMMIT
+	if err := opts.Validation.enforce(result.Violations); err != nil {
+		return result, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	committed = true
+
+	if !tableExists {
+		result.CreatedColumns = len(columns)
+	}
+
+	result.RowCount = int(loader.rows)
+	return result, nil
+}
+
+// inferStreamSchema infers a table schema from the first opts.InferSample
+// features of a file, or from all of them when it is 0
+func inferStreamSchema(geojsonPath string, opts LoadOptions) (Schema, error) {
+	inferrer, n, err := inferStream(geojsonPath, opts)
+	if err != nil {
+		return Schema{}, err
+	}
+	if n == 0 && opts.BBox != nil {
+		return Schema{}, fmt.Errorf("no features intersect the bounding box")
+	}
+	if n == 0 {
+		return Schema{}, fmt.Errorf("GeoJSON file contains no features")
+	}
+	return inferrer.schema(), nil
+}
+
+// inferStream feeds the first opts.InferSample features of a file, or all of
+// them when it is 0, to a schemaInferrer and returns how many it read
+func inferStream(geojsonPath string, opts LoadOptions) (schemaInferrer, int, error) {
+	// Counts were already gathered in the pass that loads the features
+	scratch := LoadResult{
+		Duplicates: make(map[string]int),
+		Nulled:     make(map[string]int),
+		Violations: make(map[string]map[string]int),
+	}
+
+	inferrer := schemaInferrer{nested: opts.Nested, keepStrings: opts.KeepStrings}
+	n := 0
+	// Bad features are skipped here and counted in the loading pass
+	skip := func(err error) error {
+		if _, ok := err.(*FeatureError); ok && opts.OnError.tolerant() {
+			return nil
+		}
+		return err
+	}
+	err := streamFile(geojsonPath, opts.Format, nil, opts.BBox.filter(func(feat Feature) error {
+		record, err := resolveRecord(feat, n, opts, &scratch)
+		if err != nil {
+			return skip(err)
+		}
+		inferrer.add(record)
+		n++
+		if opts.InferSample > 0 && n >= opts.InferSample {
+			return ErrStop
+		}
+		return nil
+	}, nil), func(fe *FeatureError) error {
+		return skip(fe)
+	})
+	return inferrer, n, err
+}
@@ -0,0 +1,40 @@
+package geojson
+
+import "math"
+
+// Summary describes the features a load read
+type Summary struct {
+	Features int
+	// GeometryTypes counts features per geometry type, "null" for none
+	GeometryTypes map[string]int
+	// BBox is minx, miny, maxx, maxy over every geometry, or nil when there
+	// are none
+	BBox []float64
+}
+
+// summarize counts the records' geometry types and works out their bounding
+// box. Geometries that can't be decoded only count towards their type.
+func summarize(records []Record) *Summary {
+	s := &Summary{Features: len(records), GeometryTypes: make(map[string]int)}
+	bbox := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, r := range records {
+		t := GeometryType(r.Geometry)
+		s.GeometryTypes[t]++
+		if t == "null" {
+			continue
+		}
+		g, err := decodeWKBGeometry(r.Geometry)
+		if err != nil {
+			continue
+		}
+		g.positions(func(p []float64) bool {
+			bbox[0], bbox[1] = math.Min(bbox[0], p[0]), math.Min(bbox[1], p[1])
+			bbox[2], bbox[3] = math.Max(bbox[2], p[0]), math.Max(bbox[3], p[1])
+			return true
+		})
+	}
+	if bbox[0] <= bbox[2] {
+		s.BBox = bbox
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package geojson
+
+import (
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// TypeOverrides maps column names to the DuckDB types used instead of the
+// inferred ones
+type TypeOverrides map[string]string
+
+// ParseTypeOverrides parses "population:BIGINT,updated_at:TIMESTAMP". Commas
+// inside parentheses belong to the type, as in DECIMAL(10,2).
+func ParseTypeOverrides(s string) (TypeOverrides, error) {
+	overrides := make(TypeOverrides)
+	for _, entry := range splitTopLevel(s) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, colType, ok := strings.Cut(entry, ":")
+		name, colType = strings.TrimSpace(name), strings.TrimSpace(colType)
+		if !ok || name == "" || colType == "" {
+			return nil, fmt.Errorf("invalid type override %q (expected column:TYPE)", entry)
+		}
+		overrides[name] = strings.ToUpper(colType)
+	}
+	return overrides, nil
+}
+
+// splitTopLevel splits s on commas outside parentheses
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Lookup returns the override for a column, matching names case-insensitively
+// like DuckDB does
+func (t TypeOverrides) Lookup(name string) (string, bool) {
+	if colType, ok := t[name]; ok {
+		return colType, true
+	}
+	for key, colType := range t {
+		if strings.EqualFold(key, name) {
+			return colType, true
+		}
+	}
+	return "", false
+}
+
+// apply replaces the types of overridden columns. The geometry column keeps
+// its type.
+func (t TypeOverrides) apply(columns []database.Column) []database.Column {
+	if len(t) == 0 {
+		return columns
+	}
+	out := make([]database.Column, len(columns))
+	for i, col := range columns {
+		if colType, ok := t.Lookup(col.Name); ok && col.Name != "geom" {
+			col.Type = colType
+		}
+		out[i] = col
+	}
+	return out
+}
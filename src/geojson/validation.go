@@ -0,0 +1,140 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Validation rule names, as reported in LoadResult.Violations
+const (
+	RuleRequired = "required"
+	RulePattern  = "pattern"
+	RuleRange    = "range"
+	RuleEnum     = "enum"
+)
+
+// ValidationRule lists the checks applied to one column's values
+type ValidationRule struct {
+	Required bool
+	Pattern  *regexp.Regexp
+	Min, Max *float64
+	Enum     []string
+	// MaxViolations is the number of violations per rule tolerated in strict mode
+	MaxViolations int
+}
+
+// ValidationPolicy holds per-column validation rules
+type ValidationPolicy struct {
+	// Columns holds rules keyed by lower-case column name
+	Columns map[string]ValidationRule
+	// Strict rejects the load when a rule has more than MaxViolations violations
+	Strict bool
+}
+
+// validate counts the rule violations of one record, per column and rule, and
+// returns them as "column: rule" descriptions
+func (p ValidationPolicy) validate(columns []Property, violations map[string]map[string]int) []string {
+	if len(p.Columns) == 0 {
+		return nil
+	}
+
+	values := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		values[strings.ToLower(col.Key)] = col.Value
+	}
+
+	var problems []string
+	for name, rule := range p.Columns {
+		for _, failed := range rule.check(values[name]) {
+			if violations[name] == nil {
+				violations[name] = make(map[string]int)
+			}
+			violations[name][failed]++
+			problems = append(problems, name+": "+failed)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// check returns the rules a value violates. Only required applies to NULL.
+func (r ValidationRule) check(value interface{}) []string {
+	if value == nil {
+		if r.Required {
+			return []string{RuleRequired}
+		}
+		return nil
+	}
+
+	var failed []string
+	text := valueText(value)
+	if r.Pattern != nil && !r.Pattern.MatchString(text) {
+		failed = append(failed, RulePattern)
+	}
+	if r.Min != nil || r.Max != nil {
+		n, ok := value.(float64)
+		if !ok {
+			var err error
+			n, err = strconv.ParseFloat(text, 64)
+			ok = err == nil
+		}
+		if !ok || (r.Min != nil && n < *r.Min) || (r.Max != nil && n > *r.Max) {
+			failed = append(failed, RuleRange)
+		}
+	}
+	if len(r.Enum) > 0 {
+		allowed := false
+		for _, e := range r.Enum {
+			if e == text {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			failed = append(failed, RuleEnum)
+		}
+	}
+	return failed
+}
+
+// valueText renders a property value the way it's written in the schema file
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// enforce returns an error listing the rules whose violations exceed their
+// threshold when the policy is strict
+func (p ValidationPolicy) enforce(violations map[string]map[string]int) error {
+	if !p.Strict {
+		return nil
+	}
+
+	var problems []string
+	for name, rules := range violations {
+		limit := p.Columns[name].MaxViolations
+		for rule, count := range rules {
+			if count > limit {
+				problems = append(problems, fmt.Sprintf("%s %s: %d (max %d)", name, rule, count, limit))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("validation failed:\n  %s", strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,189 @@
+package geojson
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// wkbTypes maps GeoJSON geometry types to their well-known binary codes
+var wkbTypes = map[string]uint32{
+	"Point":              1,
+	"LineString":         2,
+	"Polygon":            3,
+	"MultiPoint":         4,
+	"MultiLineString":    5,
+	"MultiPolygon":       6,
+	"GeometryCollection": 7,
+}
+
+// wkbGeometry is a decoded GeoJSON geometry. Multi geometries and collections
+// hold their members in parts.
+type wkbGeometry struct {
+	code  uint32
+	line  [][]float64
+	rings [][][]float64
+	parts []wkbGeometry
+}
+
+// encodeWKB converts a GeoJSON geometry to little-endian ISO well-known
+// binary. When any position has a third value the whole geometry is written
+// with Z, positions without one getting 0.
+func encodeWKB(geometry json.RawMessage) ([]byte, error) {
+	g, err := decodeWKBGeometry(geometry)
+	if err != nil {
+		return nil, err
+	}
+	return g.append(nil, g.hasZ()), nil
+}
+
+func decodeWKBGeometry(raw json.RawMessage) (wkbGeometry, error) {
+	var g struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometries  []json.RawMessage `json:"geometries"`
+	}
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return wkbGeometry{}, fmt.Errorf("invalid GeoJSON geometry: %w", err)
+	}
+	code, ok := wkbTypes[g.Type]
+	if !ok {
+		return wkbGeometry{}, fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+
+	out := wkbGeometry{code: code}
+	var err error
+	switch g.Type {
+	case "Point":
+		var p []float64
+		err = json.Unmarshal(g.Coordinates, &p)
+		if len(p) > 0 {
+			out.line = [][]float64{p}
+		}
+	case "LineString":
+		err = json.Unmarshal(g.Coordinates, &out.line)
+	case "Polygon":
+		err = json.Unmarshal(g.Coordinates, &out.rings)
+	case "MultiPoint":
+		var points [][]float64
+		err = json.Unmarshal(g.Coordinates, &points)
+		for _, p := range points {
+			out.parts = append(out.parts, wkbGeometry{code: wkbTypes["Point"], line: [][]float64{p}})
+		}
+	case "MultiLineString":
+		var lines [][][]float64
+		err = json.Unmarshal(g.Coordinates, &lines)
+		for _, line := range lines {
+			out.parts = append(out.parts, wkbGeometry{code: wkbTypes["LineString"], line: line})
+		}
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		err = json.Unmarshal(g.Coordinates, &polygons)
+		for _, rings := range polygons {
+			out.parts = append(out.parts, wkbGeometry{code: wkbTypes["Polygon"], rings: rings})
+		}
+	case "GeometryCollection":
+		for _, member := range g.Geometries {
+			part, err := decodeWKBGeometry(member)
+			if err != nil {
+				return wkbGeometry{}, err
+			}
+			out.parts = append(out.parts, part)
+		}
+	}
+	if err != nil {
+		return wkbGeometry{}, fmt.Errorf("invalid %s coordinates: %w", g.Type, err)
+	}
+	if err := out.checkPositions(); err != nil {
+		return wkbGeometry{}, fmt.Errorf("invalid %s: %w", g.Type, err)
+	}
+	return out, nil
+}
+
+// positions calls fn for every position of the geometry
+func (g wkbGeometry) positions(fn func(p []float64) bool) bool {
+	for _, p := range g.line {
+		if !fn(p) {
+			return false
+		}
+	}
+	for _, ring := range g.rings {
+		for _, p := range ring {
+			if !fn(p) {
+				return false
+			}
+		}
+	}
+	for _, part := range g.parts {
+		if !part.positions(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g wkbGeometry) checkPositions() error {
+	if !g.positions(func(p []float64) bool { return len(p) >= 2 }) {
+		return fmt.Errorf("position with fewer than two coordinates")
+	}
+	return nil
+}
+
+func (g wkbGeometry) hasZ() bool {
+	return !g.positions(func(p []float64) bool { return len(p) < 3 })
+}
+
+// append writes the geometry to buf
+func (g wkbGeometry) append(buf []byte, z bool) []byte {
+	code := g.code
+	if z {
+		code += 1000
+	}
+	buf = append(buf, 1) // little endian
+	buf = binary.LittleEndian.AppendUint32(buf, code)
+
+	switch {
+	case g.code == wkbTypes["Point"]:
+		// An empty point is written with NaN coordinates
+		p := []float64{math.NaN(), math.NaN(), math.NaN()}
+		if len(g.line) > 0 {
+			p = g.line[0]
+		}
+		buf = appendPosition(buf, p, z)
+	case g.code == wkbTypes["LineString"]:
+		buf = appendPositions(buf, g.line, z)
+	case g.code == wkbTypes["Polygon"]:
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(g.rings)))
+		for _, ring := range g.rings {
+			buf = appendPositions(buf, ring, z)
+		}
+	default:
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(g.parts)))
+		for _, part := range g.parts {
+			buf = part.append(buf, z)
+		}
+	}
+	return buf
+}
+
+func appendPositions(buf []byte, positions [][]float64, z bool) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(positions)))
+	for _, p := range positions {
+		buf = appendPosition(buf, p, z)
+	}
+	return buf
+}
+
+func appendPosition(buf []byte, p []float64, z bool) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p[0]))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p[1]))
+	if z {
+		var zv float64
+		if len(p) > 2 {
+			zv = p[2]
+		}
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(zv))
+	}
+	return buf
+}
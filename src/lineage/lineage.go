@@ -0,0 +1,230 @@
+package lineage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// Table records which source columns fed each column of derived tables
+const Table = "xyzduck_lineage"
+
+// Source is a column of a table a derived column was computed from
+type Source struct {
+	Table  string
+	Column string
+}
+
+// String returns the source as table.column
+func (s Source) String() string {
+	return s.Table + "." + s.Column
+}
+
+// Column is a column of a derived table and the columns it came from. A
+// column computed from constants alone has no sources.
+type Column struct {
+	Name    string
+	Sources []Source
+}
+
+// Entry is the recorded lineage of a derived table
+type Entry struct {
+	Table      string
+	Columns    []Column
+	Statement  string
+	RecordedAt time.Time
+}
+
+// Tables lists the distinct tables the entry's columns came from
+func (e *Entry) Tables() []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, col := range e.Columns {
+		for _, s := range col.Sources {
+			if key := strings.ToLower(s.Table); !seen[key] {
+				seen[key] = true
+				tables = append(tables, s.Table)
+			}
+		}
+	}
+	return tables
+}
+
+// Record records the lineage of the table or view a statement created, once
+// it has run. Statements other than CREATE TABLE/VIEW ... AS SELECT return a
+// nil entry; re-creating a table replaces its lineage.
+func Record(db *sql.DB, stmt string) (*Entry, error) {
+	schema, name, query, ok := derivedTable(stmt)
+	if !ok {
+		return nil, nil
+	}
+	table := name
+	if schema != "main" {
+		table = schema + "." + name
+	}
+
+	r := &resolver{db: db}
+	parsed, err := r.parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := r.outputColumns(schema, name, parsed)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{Table: table, Columns: columns, Statement: strings.TrimSpace(stmt), RecordedAt: time.Now().UTC()}
+	if err := save(db, schema, name, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// RecordFile records the lineage of a statement run against a database file
+func RecordFile(dbPath, stmt string) (*Entry, error) {
+	if _, _, _, ok := derivedTable(stmt); !ok {
+		return nil, nil
+	}
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return Record(db, stmt)
+}
+
+// outputColumns pairs the parsed columns with those the table ended up with.
+// They match by position; a column count that differs means the parse went
+// astray, so they match by name and unmatched columns get no sources.
+func (r *resolver) outputColumns(schema, table string, parsed []Column) ([]Column, error) {
+	rels, err := r.baseRelation(table, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	actual := rels[0].columns
+	if len(actual) == 0 {
+		return nil, fmt.Errorf("table not found: %s.%s", schema, table)
+	}
+
+	byName := make(map[string][]Source, len(parsed))
+	for _, col := range parsed {
+		byName[strings.ToLower(col.Name)] = col.Sources
+	}
+	columns := make([]Column, len(actual))
+	for i, name := range actual {
+		columns[i].Name = name
+		if len(parsed) == len(actual) {
+			columns[i].Sources = parsed[i].Sources
+		} else {
+			columns[i].Sources = byName[strings.ToLower(name)]
+		}
+	}
+	return columns, nil
+}
+
+// exists reports whether the lineage table has been created
+func exists(db *sql.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT count(*) > 0 FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = 'main' AND table_name = ?", Table).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up lineage table: %w", err)
+	}
+	return exists, nil
+}
+
+// save replaces the recorded lineage of the entry's table, schema.name
+func save(db *sql.DB, schema, name string, e *Entry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := database.QuoteIdentifier(Table)
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	schema_name VARCHAR,
+	table_name VARCHAR,
+	column_name VARCHAR,
+	position INTEGER,
+	source_table VARCHAR,
+	source_column VARCHAR,
+	statement VARCHAR,
+	recorded_at TIMESTAMP
+)`, table)
+	if _, err := tx.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create lineage table: %w", err)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE schema_name = ? AND table_name = ?", table), schema, name); err != nil {
+		return fmt.Errorf("failed to clear lineage of %s: %w", e.Table, err)
+	}
+
+	// A column without sources is still recorded, with NULL ones, so the
+	// lineage lists every column
+	insertSQL := fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?, ?, ?, ?, ?)", table)
+	for i, col := range e.Columns {
+		sources := col.Sources
+		if len(sources) == 0 {
+			sources = []Source{{}}
+		}
+		for _, s := range sources {
+			if _, err := tx.Exec(insertSQL, schema, name, col.Name, i+1, nullString(s.Table), nullString(s.Column), e.Statement, e.RecordedAt); err != nil {
+				return fmt.Errorf("failed to record lineage of %s: %w", e.Table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the recorded lineage of a table, nil when there is none
+func Lookup(db *sql.DB, tableName string) (*Entry, error) {
+	ok, err := exists(db)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	schema, name := database.SplitTableName(tableName)
+	query := fmt.Sprintf(`SELECT column_name, source_table, source_column, statement, recorded_at
+FROM %s WHERE schema_name = ? AND table_name = ?
+ORDER BY position, source_table, source_column`, database.QuoteIdentifier(Table))
+	rows, err := db.Query(query, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lineage: %w", err)
+	}
+	defer rows.Close()
+
+	var entry *Entry
+	for rows.Next() {
+		var column string
+		var sourceTable, sourceColumn sql.NullString
+		var stmt string
+		var recordedAt time.Time
+		if err := rows.Scan(&column, &sourceTable, &sourceColumn, &stmt, &recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lineage: %w", err)
+		}
+		if entry == nil {
+			entry = &Entry{Table: tableName, Statement: stmt, RecordedAt: recordedAt}
+		}
+		if n := len(entry.Columns); n == 0 || entry.Columns[n-1].Name != column {
+			entry.Columns = append(entry.Columns, Column{Name: column})
+		}
+		if sourceTable.Valid {
+			last := &entry.Columns[len(entry.Columns)-1]
+			last.Sources = append(last.Sources, Source{Table: sourceTable.String, Column: sourceColumn.String})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return entry, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,378 @@
+package lineage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identifier matches a plain or double-quoted SQL identifier
+const identifier = `(?:"(?:[^"]|"")+"|[A-Za-z_][\w$]*)`
+
+// createAsPattern matches CREATE TABLE/VIEW ... AS, capturing TEMP, the
+// qualified name and the query
+var createAsPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(TEMP\s+|TEMPORARY\s+)?(?:TABLE|VIEW)\s+(?:IF\s+NOT\s+EXISTS\s+)?(` +
+	identifier + `(?:\s*\.\s*` + identifier + `)?)\s*(?:\([^)]*\)\s*)?AS\s+(.+)$`)
+
+// derivedTable returns the schema and name of the table or view a CREATE ...
+// AS statement creates and the query filling it. Temporary tables aren't
+// tracked.
+func derivedTable(stmt string) (schema, name, query string, ok bool) {
+	m := createAsPattern.FindStringSubmatch(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	if m == nil || m[1] != "" {
+		return "", "", "", false
+	}
+	parts := splitQualifiedName(m[2])
+	if len(parts) == 2 {
+		return parts[0], parts[1], m[3], true
+	}
+	return "main", parts[0], m[3], true
+}
+
+// splitQualifiedName splits a name as createAsPattern matched it at the dots
+// outside double quotes, unquoting the parts
+func splitQualifiedName(s string) []string {
+	var parts []string
+	var part strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' && quoted && i+1 < len(s) && s[i+1] == '"':
+			part.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		case !quoted && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			// Whitespace around the dot
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// node is a part of the syntax tree json_serialize_sql returns
+type node = map[string]any
+
+// relation is something a query selects from, with where each of its
+// columns comes from
+type relation struct {
+	name    string
+	columns []string
+	// sources maps lower-case column names to the base columns feeding them
+	sources map[string][]Source
+}
+
+// resolver works out column lineage from DuckDB's syntax tree of a query
+type resolver struct {
+	db *sql.DB
+}
+
+// parseQuery returns the lineage of the columns a query produces, in order.
+// Output columns are named as the query names them, or "" when it doesn't.
+func (r *resolver) parseQuery(query string) ([]Column, error) {
+	var tree string
+	if err := r.db.QueryRow("SELECT json_serialize_sql(?::VARCHAR)::VARCHAR", query).Scan(&tree); err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	var parsed struct {
+		Error        bool   `json:"error"`
+		ErrorMessage string `json:"error_message"`
+		Statements   []struct {
+			Node node `json:"node"`
+		} `json:"statements"`
+	}
+	if err := json.Unmarshal([]byte(tree), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to read syntax tree: %w", err)
+	}
+	if parsed.Error {
+		return nil, fmt.Errorf("failed to parse query: %s", parsed.ErrorMessage)
+	}
+	if len(parsed.Statements) != 1 {
+		return nil, fmt.Errorf("expected one query, got %d", len(parsed.Statements))
+	}
+	return r.resolveNode(parsed.Statements[0].Node, nil)
+}
+
+// resolveNode resolves a query node; ctes maps the lower-case names of the
+// common table expressions in scope to their columns
+func (r *resolver) resolveNode(n node, ctes map[string][]Column) ([]Column, error) {
+	switch n["type"] {
+	case "SELECT_NODE":
+		ctes, err := r.withCTEs(n, ctes)
+		if err != nil {
+			return nil, err
+		}
+		relations, err := r.relations(asNode(n["from_table"]), ctes)
+		if err != nil {
+			return nil, err
+		}
+		var columns []Column
+		for _, e := range asList(n["select_list"]) {
+			expr := asNode(e)
+			if expr["class"] == "STAR" {
+				columns = append(columns, expandStar(expr, relations)...)
+				continue
+			}
+			col := Column{Name: asString(expr["alias"])}
+			for _, ref := range columnRefs(expr) {
+				if col.Name == "" && expr["class"] == "COLUMN_REF" {
+					col.Name = ref[len(ref)-1]
+				}
+				col.Sources = addSources(col.Sources, resolveRef(ref, relations))
+			}
+			columns = append(columns, col)
+		}
+		return columns, nil
+
+	case "SET_OPERATION_NODE":
+		// The first branch names the columns; every branch feeds them
+		children := asList(n["children"])
+		if len(children) == 0 {
+			children = []any{n["left"], n["right"]}
+		}
+		var columns []Column
+		for i, child := range children {
+			branch, err := r.resolveNode(asNode(child), ctes)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				columns = branch
+				continue
+			}
+			for j := range columns {
+				if j < len(branch) {
+					columns[j].Sources = addSources(columns[j].Sources, branch[j].Sources)
+				}
+			}
+		}
+		return columns, nil
+	}
+	return nil, fmt.Errorf("unsupported query node %v", n["type"])
+}
+
+// withCTEs adds a node's common table expressions to those in scope
+func (r *resolver) withCTEs(n node, ctes map[string][]Column) (map[string][]Column, error) {
+	entries := asList(asNode(n["cte_map"])["map"])
+	if len(entries) == 0 {
+		return ctes, nil
+	}
+	scoped := make(map[string][]Column, len(ctes)+len(entries))
+	for k, v := range ctes {
+		scoped[k] = v
+	}
+	for _, e := range entries {
+		entry := asNode(e)
+		query := asNode(asNode(asNode(entry["value"])["query"])["node"])
+		columns, err := r.resolveNode(query, scoped)
+		if err != nil {
+			return nil, err
+		}
+		scoped[strings.ToLower(asString(entry["key"]))] = columns
+	}
+	return scoped, nil
+}
+
+// relations lists what a FROM clause selects from. Table functions and other
+// sources without table columns are left out.
+func (r *resolver) relations(ref node, ctes map[string][]Column) ([]relation, error) {
+	switch ref["type"] {
+	case "BASE_TABLE":
+		schema, table := asString(ref["schema_name"]), asString(ref["table_name"])
+		name := asString(ref["alias"])
+		if name == "" {
+			name = table
+		}
+		if columns, ok := ctes[strings.ToLower(table)]; ok && schema == "" {
+			return []relation{derivedRelation(name, columns)}, nil
+		}
+		return r.baseRelation(name, schema, table)
+
+	case "SUBQUERY":
+		columns, err := r.resolveNode(asNode(asNode(ref["subquery"])["node"]), ctes)
+		if err != nil {
+			return nil, err
+		}
+		return []relation{derivedRelation(asString(ref["alias"]), columns)}, nil
+
+	case "JOIN":
+		left, err := r.relations(asNode(ref["left"]), ctes)
+		if err != nil {
+			return nil, err
+		}
+		right, err := r.relations(asNode(ref["right"]), ctes)
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	}
+	return nil, nil
+}
+
+// baseRelation reads the columns of a table or view of the database
+func (r *resolver) baseRelation(name, schema, table string) ([]relation, error) {
+	if schema == "" {
+		schema = "main"
+	}
+	rows, err := r.db.Query("SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ? ORDER BY ordinal_position", schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	display := table
+	if schema != "main" {
+		display = schema + "." + table
+	}
+	rel := relation{name: name, sources: make(map[string][]Source)}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		rel.columns = append(rel.columns, column)
+		rel.sources[strings.ToLower(column)] = []Source{{Table: display, Column: column}}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	return []relation{rel}, nil
+}
+
+// derivedRelation is a subquery or common table expression selected from
+func derivedRelation(name string, columns []Column) relation {
+	rel := relation{name: name, sources: make(map[string][]Source)}
+	for _, col := range columns {
+		rel.columns = append(rel.columns, col.Name)
+		rel.sources[strings.ToLower(col.Name)] = col.Sources
+	}
+	return rel
+}
+
+// expandStar lists the columns * or rel.* stands for, less EXCLUDE ones
+func expandStar(expr node, relations []relation) []Column {
+	only := strings.ToLower(asString(expr["relation_name"]))
+	exclude := make(map[string]bool)
+	for _, e := range asList(expr["exclude_list"]) {
+		if names := strings.Fields(strings.Join(stringsIn(e), " ")); len(names) > 0 {
+			exclude[strings.ToLower(names[len(names)-1])] = true
+		}
+	}
+
+	var columns []Column
+	for _, rel := range relations {
+		if only != "" && strings.ToLower(rel.name) != only {
+			continue
+		}
+		for _, name := range rel.columns {
+			if !exclude[strings.ToLower(name)] {
+				columns = append(columns, Column{Name: name, Sources: rel.sources[strings.ToLower(name)]})
+			}
+		}
+	}
+	return columns
+}
+
+// resolveRef finds the base columns a column reference stands for
+func resolveRef(ref []string, relations []relation) []Source {
+	column := strings.ToLower(ref[len(ref)-1])
+	qualifier := ""
+	if len(ref) > 1 {
+		qualifier = strings.ToLower(ref[len(ref)-2])
+	}
+	for _, rel := range relations {
+		if qualifier != "" && strings.ToLower(rel.name) != qualifier {
+			continue
+		}
+		if sources, ok := rel.sources[column]; ok {
+			return sources
+		}
+	}
+	return nil
+}
+
+// columnRefs collects the column references of an expression, leaving out
+// those of subqueries, which select from relations of their own
+func columnRefs(v any) [][]string {
+	var refs [][]string
+	switch v := v.(type) {
+	case node:
+		switch v["class"] {
+		case "COLUMN_REF":
+			if names := stringsIn(v["column_names"]); len(names) > 0 {
+				refs = append(refs, names)
+			}
+			return refs
+		case "SUBQUERY":
+			return refs
+		}
+		for _, child := range v {
+			refs = append(refs, columnRefs(child)...)
+		}
+	case []any:
+		for _, child := range v {
+			refs = append(refs, columnRefs(child)...)
+		}
+	}
+	return refs
+}
+
+// addSources appends the sources not already in list
+func addSources(list, sources []Source) []Source {
+	for _, s := range sources {
+		seen := false
+		for _, t := range list {
+			if strings.EqualFold(s.Table, t.Table) && strings.EqualFold(s.Column, t.Column) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func asNode(v any) node {
+	n, _ := v.(node)
+	return n
+}
+
+func asList(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// stringsIn collects the strings of a value, however it nests them
+func stringsIn(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, e := range v {
+			out = append(out, stringsIn(e)...)
+		}
+		return out
+	case node:
+		for _, key := range []string{"column_names", "name", "exclude_name"} {
+			if names := stringsIn(v[key]); len(names) > 0 {
+				return names
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package lineage
+
+import "testing"
+
+func TestDerivedTable(t *testing.T) {
+	tests := []struct {
+		stmt         string
+		schema, name string
+		query        string
+		ok           bool
+	}{
+		{stmt: "CREATE TABLE stats AS SELECT 1", schema: "main", name: "stats", query: "SELECT 1", ok: true},
+		{stmt: "create or replace view staging.stats as select 1;", schema: "staging", name: "stats", query: "select 1", ok: true},
+		{stmt: `CREATE TABLE "staging" . "My Stats" AS SELECT 1`, schema: "staging", name: "My Stats", query: "SELECT 1", ok: true},
+		{stmt: `CREATE TABLE "a." AS SELECT 1`, schema: "main", name: "a.", query: "SELECT 1", ok: true},
+		{stmt: `CREATE TABLE "v1.2" AS SELECT 1`, schema: "main", name: "v1.2", query: "SELECT 1", ok: true},
+		{stmt: `CREATE TABLE "s.1"."t.2" AS SELECT 1`, schema: "s.1", name: "t.2", query: "SELECT 1", ok: true},
+		{stmt: `CREATE TABLE ".".x AS SELECT 1`, schema: ".", name: "x", query: "SELECT 1", ok: true},
+		{stmt: `CREATE TABLE "say ""hi""" AS SELECT 1`, schema: "main", name: `say "hi"`, query: "SELECT 1", ok: true},
+		{stmt: "CREATE TEMP TABLE scratch AS SELECT 1"},
+		{stmt: "CREATE TABLE roads (id INTEGER)"},
+		{stmt: "SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			schema, name, query, ok := derivedTable(tt.stmt)
+			if ok != tt.ok || schema != tt.schema || name != tt.name || query != tt.query {
+				t.Errorf("derivedTable() = %q, %q, %q, %t; want %q, %q, %q, %t",
+					schema, name, query, ok, tt.schema, tt.name, tt.query, tt.ok)
+			}
+		})
+	}
+}
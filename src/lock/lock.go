@@ -0,0 +1,203 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// heartbeat is how often a holder or waiter touches its files
+	heartbeat = 2 * time.Second
+	// staleAfter is how long an untouched file survives before it's taken to
+	// belong to a process that died
+	staleAfter = 15 * time.Second
+	// pollInterval is how often waiters check whether it's their turn
+	pollInterval = 200 * time.Millisecond
+)
+
+// Lock is a held write lock on a database. DuckDB allows a single process to
+// open a database file, so xyzduck invocations take turns: each joins a queue
+// of ticket files next to the database and, once first in line, creates the
+// lock file.
+type Lock struct {
+	queueDir   string
+	lockPath   string
+	ticketPath string
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// LockPath returns the lock file of a database
+func LockPath(dbPath string) string {
+	return dbPath + ".lock"
+}
+
+// queueDir returns the directory holding the waiters' tickets
+func queueDir(dbPath string) string {
+	return dbPath + ".queue"
+}
+
+// Acquire waits up to timeout for the database to be free, in arrival order
+// with other xyzduck processes, and locks it. waiting is called once, with a
+// description of the current holder, if the lock isn't free straight away.
+func Acquire(dbPath string, timeout time.Duration, waiting func(holder string)) (*Lock, error) {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve database path: %w", err)
+	}
+
+	dir := queueDir(absPath)
+	l := &Lock{
+		queueDir:   dir,
+		lockPath:   LockPath(absPath),
+		ticketPath: filepath.Join(dir, fmt.Sprintf("%020d-%d", time.Now().UnixNano(), os.Getpid())),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	// The last process out removes the queue directory, possibly just after
+	// we created it, so try again if it vanishes
+	for attempt := 0; ; attempt++ {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create lock queue: %w", err)
+		}
+		err := os.WriteFile(l.ticketPath, nil, 0644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || attempt == 2 {
+			return nil, fmt.Errorf("failed to join lock queue: %w", err)
+		}
+	}
+	go l.keepAlive()
+
+	deadline := time.Now().Add(timeout)
+	notified := false
+	for {
+		first, err := l.firstInLine()
+		if err != nil {
+			l.Release()
+			return nil, err
+		}
+		if first {
+			acquired, err := l.tryLock()
+			if err != nil {
+				l.Release()
+				return nil, err
+			}
+			if acquired {
+				return l, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			l.Release()
+			return nil, fmt.Errorf("timed out waiting for %s, which is in use by %s", filepath.Base(absPath), holder(l.lockPath))
+		}
+		if !notified && waiting != nil {
+			waiting(holder(l.lockPath))
+			notified = true
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release unlocks the database, or leaves the queue if it was never locked
+func (l *Lock) Release() {
+	close(l.stop)
+	<-l.done
+	// Only remove the lock file if it's ours
+	if holder(l.lockPath) == describe(os.Getpid()) {
+		os.Remove(l.lockPath)
+	}
+	os.Remove(l.ticketPath)
+	// Fails while others are still queued, which is what we want
+	os.Remove(l.queueDir)
+}
+
+// keepAlive touches the ticket and, once held, the lock file so other
+// processes don't mistake them for stale
+func (l *Lock) keepAlive() {
+	defer close(l.done)
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			os.Chtimes(l.ticketPath, now, now)
+			if holder(l.lockPath) == describe(os.Getpid()) {
+				os.Chtimes(l.lockPath, now, now)
+			}
+		}
+	}
+}
+
+// firstInLine clears stale tickets and reports whether ours is the oldest
+func (l *Lock) firstInLine() (bool, error) {
+	entries, err := os.ReadDir(l.queueDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read lock queue: %w", err)
+	}
+
+	var tickets []string
+	for _, e := range entries {
+		path := filepath.Join(l.queueDir, e.Name())
+		if path != l.ticketPath && isStale(path) {
+			os.Remove(path)
+			continue
+		}
+		tickets = append(tickets, e.Name())
+	}
+	sort.Strings(tickets)
+	return len(tickets) > 0 && tickets[0] == filepath.Base(l.ticketPath), nil
+}
+
+// tryLock creates the lock file, replacing it if its holder stopped updating it
+func (l *Lock) tryLock() (bool, error) {
+	f, err := os.OpenFile(l.lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		if isStale(l.lockPath) {
+			os.Remove(l.lockPath)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(describe(os.Getpid())); err != nil {
+		os.Remove(l.lockPath)
+		return false, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return true, nil
+}
+
+// isStale reports whether a file hasn't been touched for staleAfter
+func isStale(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > staleAfter
+}
+
+// holder returns the contents of a lock file, describing the process holding it
+func holder(lockPath string) string {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "another xyzduck process"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// describe identifies a process in a lock file
+func describe(pid int) string {
+	return "xyzduck (pid " + strconv.Itoa(pid) + ")"
+}
@@ -0,0 +1,250 @@
+package macros
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// Version is the version of the macro library. Bump it whenever a macro is
+// added, changed or removed, so installed databases get upgraded.
+const Version = 1
+
+// Table records the macros installed in a database and the library version
+// they came from
+const Table = "xyzduck_macros"
+
+// Macro is a SQL macro of the library
+type Macro struct {
+	Name        string
+	Signature   string
+	Description string
+	// Body is the expression the macro expands to
+	Body string
+}
+
+// SQL returns the statement creating or replacing the macro
+func (m Macro) SQL() string {
+	return fmt.Sprintf("CREATE OR REPLACE MACRO %s(%s) AS %s", database.QuoteIdentifier(m.Name), m.Signature, m.Body)
+}
+
+// Library lists the macros install creates. Spatial ones need the spatial
+// extension loaded when they are called.
+var Library = []Macro{
+	// Bounding boxes
+	{
+		Name:        "xyz_bbox",
+		Signature:   "minx, miny, maxx, maxy",
+		Description: "Polygon of a bounding box",
+		Body:        "ST_MakeEnvelope(minx, miny, maxx, maxy)",
+	},
+	{
+		Name:        "xyz_bbox_of",
+		Signature:   "geom",
+		Description: "Bounding box of a geometry as [minx, miny, maxx, maxy]",
+		Body:        "[ST_XMin(geom), ST_YMin(geom), ST_XMax(geom), ST_YMax(geom)]",
+	},
+	{
+		Name:        "xyz_bbox_around",
+		Signature:   "lon, lat, meters",
+		Description: "Polygon of the box reaching a distance in meters around a lon/lat position",
+		Body:        "ST_MakeEnvelope(lon - meters / (111320 * cos(radians(lat))), lat - meters / 110540, lon + meters / (111320 * cos(radians(lat))), lat + meters / 110540)",
+	},
+
+	// Web Mercator tiles
+	{
+		Name:        "xyz_lon_to_tile_x",
+		Signature:   "lon, z",
+		Description: "Column of the zoom z tile holding a longitude",
+		Body:        "least(greatest(floor((lon + 180) / 360 * pow(2, z)), 0), pow(2, z) - 1)::INTEGER",
+	},
+	{
+		Name:        "xyz_lat_to_tile_y",
+		Signature:   "lat, z",
+		Description: "Row of the zoom z tile holding a latitude",
+		Body:        "least(greatest(floor((1 - ln(tan(radians(lat)) + 1 / cos(radians(lat))) / pi()) / 2 * pow(2, z)), 0), pow(2, z) - 1)::INTEGER",
+	},
+	{
+		Name:        "xyz_tile_x_to_lon",
+		Signature:   "x, z",
+		Description: "Longitude of the west edge of tile column x at zoom z",
+		Body:        "x / pow(2, z) * 360 - 180",
+	},
+	{
+		Name:        "xyz_tile_y_to_lat",
+		Signature:   "y, z",
+		Description: "Latitude of the north edge of tile row y at zoom z",
+		Body:        "degrees(atan(sinh(pi() * (1 - 2 * y / pow(2, z)))))",
+	},
+	{
+		Name:        "xyz_tile_envelope",
+		Signature:   "z, x, y",
+		Description: "Polygon of a tile in lon/lat",
+		Body:        "ST_MakeEnvelope(xyz_tile_x_to_lon(x, z), xyz_tile_y_to_lat(y + 1, z), xyz_tile_x_to_lon(x + 1, z), xyz_tile_y_to_lat(y, z))",
+	},
+
+	// Quadkeys
+	{
+		Name:        "xyz_quadkey",
+		Signature:   "z, x, y",
+		Description: "Bing Maps quadkey of a tile",
+		Body:        "(SELECT COALESCE(string_agg((((x >> (i - 1)) & 1) + 2 * ((y >> (i - 1)) & 1))::VARCHAR, '' ORDER BY i DESC), '') FROM range(1, z + 1) AS r(i))",
+	},
+	{
+		Name:        "xyz_quadkey_to_tile",
+		Signature:   "quadkey",
+		Description: "Tile of a quadkey as {z, x, y}",
+		Body: "{'z': length(quadkey), " +
+			"'x': (SELECT COALESCE(sum((substr(quadkey, i::INTEGER, 1)::INTEGER & 1) << (length(quadkey) - i)), 0) FROM range(1, length(quadkey) + 1) AS r(i))::INTEGER, " +
+			"'y': (SELECT COALESCE(sum((substr(quadkey, i::INTEGER, 1)::INTEGER >> 1) << (length(quadkey) - i)), 0) FROM range(1, length(quadkey) + 1) AS r(i))::INTEGER}",
+	},
+	{
+		Name:        "xyz_point_quadkey",
+		Signature:   "lon, lat, z",
+		Description: "Quadkey of the zoom z tile holding a lon/lat position",
+		Body:        "xyz_quadkey(z, xyz_lon_to_tile_x(lon, z), xyz_lat_to_tile_y(lat, z))",
+	},
+
+	// Safe casts: NULL instead of an error for values that don't convert
+	{
+		Name:        "xyz_try_int",
+		Signature:   "v",
+		Description: "BIGINT of a value, or NULL when it isn't one",
+		Body:        "TRY_CAST(NULLIF(trim(v::VARCHAR), '') AS BIGINT)",
+	},
+	{
+		Name:        "xyz_try_double",
+		Signature:   "v",
+		Description: "DOUBLE of a value, or NULL when it isn't one",
+		Body:        "TRY_CAST(NULLIF(trim(replace(v::VARCHAR, ',', '')), '') AS DOUBLE)",
+	},
+	{
+		Name:        "xyz_try_date",
+		Signature:   "v",
+		Description: "DATE of a value, or NULL when it isn't one",
+		Body:        "TRY_CAST(NULLIF(trim(v::VARCHAR), '') AS DATE)",
+	},
+	{
+		Name:        "xyz_try_bool",
+		Signature:   "v",
+		Description: "BOOLEAN of true/false, yes/no, y/n, t/f or 1/0, or NULL",
+		Body:        "CASE WHEN lower(trim(v::VARCHAR)) IN ('true', 'yes', 'y', 't', '1') THEN true WHEN lower(trim(v::VARCHAR)) IN ('false', 'no', 'n', 'f', '0') THEN false END",
+	},
+	{
+		Name:        "xyz_try_geom",
+		Signature:   "wkt",
+		Description: "Geometry of WKT text, or NULL when it doesn't parse",
+		Body:        "TRY(ST_GeomFromText(wkt))",
+	},
+}
+
+// Installed returns the macro library version installed in a database, 0
+// when there is none
+func Installed(db *sql.DB) (int, error) {
+	var exists bool
+	err := db.QueryRow("SELECT count(*) > 0 FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = 'main' AND table_name = ?", Table).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up macros table: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRow(fmt.Sprintf("SELECT max(version) FROM %s", database.QuoteIdentifier(Table))).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read installed macros: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// InstallResult says what an install changed
+type InstallResult struct {
+	// Previous is the version that was installed before, 0 for none
+	Previous int
+	// Installed counts the macros created or replaced
+	Installed int
+	// Dropped lists macros of an older version the library no longer has
+	Dropped []string
+}
+
+// Install creates or replaces every macro of the library in one transaction
+// and records them at Version. Macros an older version installed that the
+// library dropped are removed. A database with a newer library is left alone
+// unless force is set.
+func Install(db *sql.DB, force bool) (InstallResult, error) {
+	previous, err := Installed(db)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	result := InstallResult{Previous: previous}
+	if previous > Version && !force {
+		return result, fmt.Errorf("macros version %d are installed, newer than this xyzduck's %d (run 'xyzduck self-update', or use --force to downgrade)", previous, Version)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := database.QuoteIdentifier(Table)
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	name VARCHAR PRIMARY KEY,
+	version INTEGER,
+	installed_at TIMESTAMP
+)`, table)
+	if _, err := tx.Exec(createSQL); err != nil {
+		return result, fmt.Errorf("failed to create macros table: %w", err)
+	}
+
+	// Macros an earlier library installed but this one doesn't have
+	current := make(map[string]bool, len(Library))
+	for _, m := range Library {
+		current[m.Name] = true
+	}
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM %s ORDER BY name", table))
+	if err != nil {
+		return result, fmt.Errorf("failed to read installed macros: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to scan macro name: %w", err)
+		}
+		if !current[name] {
+			result.Dropped = append(result.Dropped, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("error iterating rows: %w", err)
+	}
+	for _, name := range result.Dropped {
+		if _, err := tx.Exec("DROP MACRO IF EXISTS " + database.QuoteIdentifier(name)); err != nil {
+			return result, fmt.Errorf("failed to drop macro %s: %w", name, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE name = ?", table), name); err != nil {
+			return result, fmt.Errorf("failed to unrecord macro %s: %w", name, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	insertSQL := fmt.Sprintf("INSERT OR REPLACE INTO %s VALUES (?, ?, ?)", table)
+	for _, m := range Library {
+		if _, err := tx.Exec(m.SQL()); err != nil {
+			return result, fmt.Errorf("failed to create macro %s: %w", m.Name, err)
+		}
+		if _, err := tx.Exec(insertSQL, m.Name, Version, now); err != nil {
+			return result, fmt.Errorf("failed to record macro %s: %w", m.Name, err)
+		}
+		result.Installed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
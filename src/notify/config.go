@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config lists where change events are published
+type Config struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// Target is one broker and the tables whose changes are published to it
+type Target struct {
+	// URL is nats://[user:pass@]host:port, or kafka+http(s)://host:port for a
+	// Kafka REST proxy
+	URL string `yaml:"url"`
+	// Subject is the NATS subject or Kafka topic; {table} is replaced by the
+	// table name. Defaults to xyzduck.{table}
+	Subject string `yaml:"subject,omitempty"`
+	// Tables are glob patterns (roads, staging.*) selecting the tables to
+	// publish; empty means every table
+	Tables []string `yaml:"tables,omitempty"`
+}
+
+// LoadConfig reads and checks a notification config file
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notify config: %w", err)
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse notify config: %w", err)
+	}
+
+	for i, t := range c.Targets {
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			return nil, fmt.Errorf("notify config: target %d: invalid url: %w", i+1, err)
+		}
+		switch u.Scheme {
+		case "nats", "kafka+http", "kafka+https":
+		default:
+			return nil, fmt.Errorf("notify config: target %d: unsupported url %q (expected nats:// or kafka+http(s)://)", i+1, t.URL)
+		}
+		for _, pattern := range t.Tables {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("notify config: target %d: invalid table pattern %q", i+1, pattern)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// For returns the targets that publish changes to table
+func (c *Config) For(table string) []Target {
+	var targets []Target
+	for _, t := range c.Targets {
+		if t.matches(table) {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// matches reports whether a table is selected by the target's patterns
+func (t Target) matches(table string) bool {
+	if len(t.Tables) == 0 {
+		return true
+	}
+	for _, pattern := range t.Tables {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(table)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Name identifies the target in messages, without its credentials
+func (t Target) Name() string {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return t.URL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// subject returns the subject or topic events about table are published on.
+// Whitespace and control characters become _: NATS would read text after a
+// space as a reply subject, and a line break as another protocol command.
+func (t Target) subject(table string) string {
+	subject := t.Subject
+	if subject == "" {
+		subject = "xyzduck.{table}"
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, strings.ReplaceAll(subject, "{table}", table))
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// publishKafkaREST produces a record through a Kafka REST proxy (the
+// Confluent v2 API), which keeps xyzduck free of a native Kafka client
+func publishKafkaREST(u *url.URL, topic string, payload []byte) error {
+	endpoint := *u
+	endpoint.Scheme = strings.TrimPrefix(u.Scheme, "kafka+")
+	endpoint.User = nil
+	endpoint.Path = strings.TrimSuffix(u.Path, "/") + "/topics/" + url.PathEscape(topic)
+
+	body, err := json.Marshal(map[string]any{
+		"records": []map[string]json.RawMessage{{"value": payload}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Kafka record: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), pass)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Kafka REST proxy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Kafka REST proxy returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// publishNATS publishes a message with the NATS client protocol: read the
+// server's INFO, CONNECT, PUB, then PING and wait for the PONG, which the
+// server only sends once it has processed the PUB
+func publishNATS(u *url.URL, subject string, payload []byte) error {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read NATS server info: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected NATS greeting: %s", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err == nil && info.TLSRequired {
+		return fmt.Errorf("NATS server requires TLS, which isn't supported")
+	}
+
+	connect := map[string]any{"verbose": false, "pedantic": false, "name": "xyzduck"}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"] = u.User.Username()
+			connect["pass"] = pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+	options, err := json.Marshal(connect)
+	if err != nil {
+		return fmt.Errorf("failed to encode NATS connect options: %w", err)
+	}
+
+	msg := fmt.Sprintf("CONNECT %s\r\nPUB %s %d\r\n%s\r\nPING\r\n", options, subject, len(payload), payload)
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read NATS reply: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/offline"
+)
+
+// timeout bounds each publish so an unreachable broker doesn't hold up a load
+const timeout = 10 * time.Second
+
+// Event describes a change to a table
+type Event struct {
+	// Event is the command that made the change: load, append, merge, apply,
+	// delete or restore
+	Event    string `json:"event"`
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	// Rows is the number of rows written
+	Rows int64 `json:"rows"`
+	// BBox is the extent of the table after the change, as minx, miny, maxx,
+	// maxy; omitted when the table has no geometries
+	BBox []float64 `json:"bbox,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Publish sends an event to a target
+func Publish(t Target, e Event) error {
+	if err := offline.Check("publishing change events"); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	subject := t.subject(e.Table)
+	switch u.Scheme {
+	case "nats":
+		return publishNATS(u, subject, payload)
+	case "kafka+http", "kafka+https":
+		return publishKafkaREST(u, subject, payload)
+	default:
+		return fmt.Errorf("unsupported url %q", t.URL)
+	}
+}
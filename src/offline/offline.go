@@ -0,0 +1,32 @@
+package offline
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+var enabled bool
+
+// Set turns offline mode on or off for the rest of the process
+func Set(on bool) {
+	enabled = on
+}
+
+// Enabled reports whether network access is disabled, either by --offline or
+// by setting XYZDUCK_OFFLINE
+func Enabled() bool {
+	if enabled {
+		return true
+	}
+	on, _ := strconv.ParseBool(os.Getenv("XYZDUCK_OFFLINE"))
+	return on
+}
+
+// Check returns an error describing what needed the network when offline mode is on
+func Check(what string) error {
+	if !Enabled() {
+		return nil
+	}
+	return fmt.Errorf("%s requires network access, which is disabled in offline mode", what)
+}
@@ -0,0 +1,226 @@
+package osm
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/geojson"
+)
+
+// areaKeys are the tags that make a closed way a polygon rather than a ring
+var areaKeys = []string{"building", "landuse", "natural", "leisure", "amenity", "place", "boundary", "waterway", "water", "man_made", "aeroway"}
+
+// areaRelations are the relation types assembled into (multi)polygons
+var areaRelations = []string{"multipolygon", "boundary"}
+
+// TagColumn maps an OSM tag key to the column it's stored in
+type TagColumn struct {
+	Key    string
+	Column string
+}
+
+// Options controls how an OSM extract is loaded
+type Options struct {
+	// Tags are copied into columns of their own; every tag is also kept in
+	// the tags JSON column
+	Tags []TagColumn
+}
+
+// Result summarizes a completed load
+type Result struct {
+	Nodes     int64
+	Ways      int64
+	Relations int64
+}
+
+// IsPBF reports whether path is an OpenStreetMap PBF extract
+func IsPBF(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pbf")
+}
+
+// BaseName derives a table name from an extract's filename, dropping the
+// .osm.pbf (or .pbf) extension
+func BaseName(path string) string {
+	base := filepath.Base(path)
+	base = base[:len(base)-len(filepath.Ext(base))]
+	if strings.EqualFold(filepath.Ext(base), ".osm") {
+		base = base[:len(base)-len(".osm")]
+	}
+	return base
+}
+
+// Tables returns the node, way and relation tables of a base table name
+func Tables(base string) (nodes, ways, relations string) {
+	return base + "_nodes", base + "_ways", base + "_relations"
+}
+
+// ParseTagColumns parses key[=column] mappings. Columns default to the key,
+// sanitized like a GeoJSON property key.
+func ParseTagColumns(specs []string) ([]TagColumn, error) {
+	reserved := map[string]bool{"osm_id": true, "tags": true, "geom": true}
+	seen := make(map[string]bool)
+
+	var tags []TagColumn
+	for _, spec := range specs {
+		key, column, _ := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		column = strings.TrimSpace(column)
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag mapping %q (expected key or key=column)", spec)
+		}
+		if column == "" {
+			column = geojson.SanitizeColumnName(key)
+		}
+
+		lower := strings.ToLower(column)
+		if reserved[lower] {
+			return nil, fmt.Errorf("tag %q can't be stored in column %q, which is reserved", key, column)
+		}
+		if seen[lower] {
+			return nil, fmt.Errorf("tag %q maps to column %q, which is already taken", key, column)
+		}
+		seen[lower] = true
+		tags = append(tags, TagColumn{Key: key, Column: column})
+	}
+	return tags, nil
+}
+
+// Load reads an OSM PBF extract with ST_ReadOSM and creates three tables:
+// tagged nodes as points, tagged ways as lines (or polygons when closed and
+// tagged as an area) and multipolygon/boundary relations assembled from their
+// member ways. The tables must not exist yet.
+func Load(dbPath, srcPath, baseTable string, opts Options) (Result, error) {
+	var result Result
+
+	absPath, err := filepath.Abs(srcPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve input path: %w", err)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return result, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Read the extract once; ways and relations are resolved against it
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE TEMP TABLE osm_elements AS
+		SELECT kind, id, tags, refs, lat, lon, ref_roles, ref_types FROM ST_ReadOSM(%s)`, database.QuoteLiteral(absPath))); err != nil {
+		return result, fmt.Errorf("failed to read OSM extract: %w", err)
+	}
+
+	// Every way's nodes in order; ways clipped by the extract boundary lose
+	// their missing nodes
+	if _, err := tx.Exec(`CREATE TEMP TABLE osm_way_points AS
+		WITH members AS (
+			SELECT id, unnest(refs) AS ref, unnest(generate_series(1, len(refs))) AS pos
+			FROM osm_elements WHERE kind = 'way'
+		)
+		SELECT m.id, list(ST_Point(n.lon, n.lat) ORDER BY m.pos) AS points
+		FROM members m JOIN osm_elements n ON n.kind = 'node' AND n.id = m.ref
+		GROUP BY m.id
+		HAVING count(*) >= 2`); err != nil {
+		return result, fmt.Errorf("failed to assemble ways: %w", err)
+	}
+
+	nodesTable, waysTable, relationsTable := Tables(baseTable)
+	columns := tagColumnsSQL(opts.Tags, "e.tags")
+
+	nodesSQL := fmt.Sprintf(`SELECT e.id AS osm_id, %s, ST_Point(e.lon, e.lat) AS geom
+		FROM osm_elements e
+		WHERE e.kind = 'node' AND cardinality(e.tags) > 0`, columns)
+
+	closed := "len(w.points) >= 4 AND ST_Equals(w.points[1], w.points[-1])"
+	waysSQL := fmt.Sprintf(`SELECT e.id AS osm_id, %s,
+			CASE WHEN %s AND %s THEN ST_MakePolygon(ST_MakeLine(w.points)) ELSE ST_MakeLine(w.points) END AS geom
+		FROM osm_elements e JOIN osm_way_points w ON e.kind = 'way' AND w.id = e.id
+		WHERE cardinality(e.tags) > 0`, columns, closed, isAreaSQL("e.tags"))
+
+	relationsSQL := fmt.Sprintf(`WITH members AS (
+			SELECT id, unnest(refs) AS ref, unnest(ref_types)::VARCHAR AS ref_type
+			FROM osm_elements WHERE kind = 'relation' AND tags['type'] IN (%s)
+		), areas AS (
+			SELECT m.id, ST_BuildArea(ST_Collect(list(ST_MakeLine(w.points)))) AS geom
+			FROM members m JOIN osm_way_points w ON m.ref_type = 'way' AND w.id = m.ref
+			GROUP BY m.id
+		)
+		SELECT e.id AS osm_id, %s, a.geom
+		FROM osm_elements e JOIN areas a ON e.kind = 'relation' AND a.id = e.id
+		WHERE a.geom IS NOT NULL AND NOT ST_IsEmpty(a.geom)`, literalList(areaRelations), columns)
+
+	for _, t := range []struct {
+		table string
+		sql   string
+		count *int64
+	}{
+		{nodesTable, nodesSQL, &result.Nodes},
+		{waysTable, waysSQL, &result.Ways},
+		{relationsTable, relationsSQL, &result.Relations},
+	} {
+		if err := createTable(tx, t.table, t.sql, t.count); err != nil {
+			return result, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
+
+// createTable creates table from a query and counts its rows
+func createTable(tx *sql.Tx, table, query string, count *int64) error {
+	if err := database.EnsureSchema(tx, table); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s AS %s", database.QuoteTableName(table), query)); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	// CREATE TABLE AS doesn't report the rows it wrote, so count them
+	if err := tx.QueryRow("SELECT COUNT(*) FROM " + database.QuoteTableName(table)).Scan(count); err != nil {
+		return fmt.Errorf("failed to count rows: %w", err)
+	}
+	return nil
+}
+
+// tagColumnsSQL selects the mapped tags and the full tag map as JSON
+func tagColumnsSQL(tags []TagColumn, tagsExpr string) string {
+	var cols []string
+	for _, t := range tags {
+		cols = append(cols, fmt.Sprintf("%s[%s] AS %s", tagsExpr, database.QuoteLiteral(t.Key), database.QuoteIdentifier(t.Column)))
+	}
+	cols = append(cols, fmt.Sprintf("to_json(%s) AS tags", tagsExpr))
+	return strings.Join(cols, ", ")
+}
+
+// isAreaSQL tests whether a way's tags describe an area: area=yes, or any
+// area key unless area=no
+func isAreaSQL(tagsExpr string) string {
+	var keys []string
+	for _, k := range areaKeys {
+		keys = append(keys, fmt.Sprintf("%s[%s] IS NOT NULL", tagsExpr, database.QuoteLiteral(k)))
+	}
+	return fmt.Sprintf("(%[1]s['area'] = 'yes' OR (coalesce(%[1]s['area'], '') <> 'no' AND (%[2]s)))", tagsExpr, strings.Join(keys, " OR "))
+}
+
+// literalList quotes values for an IN list
+func literalList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = database.QuoteLiteral(v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,69 @@
+package query
+
+import (
+	"database/sql"
+	"fmt"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// TableInfo describes a table for browsing
+type TableInfo struct {
+	// Name is schema-qualified outside the main schema
+	Name    string
+	Rows    int64
+	Columns []database.Column
+}
+
+// Tables lists the base tables of the open database with their row counts
+// and columns
+func Tables(db *sql.DB) ([]TableInfo, error) {
+	rows, err := db.Query(`
+		SELECT table_schema, table_name, column_name, data_type
+		FROM information_schema.columns
+		WHERE table_catalog = current_database()
+		  AND (table_schema, table_name) IN (
+			SELECT (table_schema, table_name) FROM information_schema.tables
+			WHERE table_type = 'BASE TABLE' AND table_catalog = current_database())
+		ORDER BY table_schema, table_name, ordinal_position
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []TableInfo
+	for rows.Next() {
+		var schema, table string
+		var col database.Column
+		if err := rows.Scan(&schema, &table, &col.Name, &col.Type); err != nil {
+			return nil, fmt.Errorf("failed to scan column info: %w", err)
+		}
+		if schema != "main" {
+			table = schema + "." + table
+		}
+		if len(tables) == 0 || tables[len(tables)-1].Name != table {
+			tables = append(tables, TableInfo{Name: table})
+		}
+		t := &tables[len(tables)-1]
+		t.Columns = append(t.Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close()
+
+	for i := range tables {
+		countSQL := fmt.Sprintf("SELECT count(*) FROM %s", database.QuoteTableName(tables[i].Name))
+		if err := db.QueryRow(countSQL).Scan(&tables[i].Rows); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", tables[i].Name, err)
+		}
+	}
+	return tables, nil
+}
+
+// Page returns up to limit rows of a table starting at offset, in storage
+// order, with geometries as WKT
+func Page(db *sql.DB, table string, limit, offset int64) (*Result, error) {
+	return RunOn(db, fmt.Sprintf("SELECT * FROM %s LIMIT %d OFFSET %d", database.QuoteTableName(table), limit, offset), GeometryWKT)
+}
@@ -0,0 +1,267 @@
+package query
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// Format is an output format for query results
+type Format string
+
+const (
+	FormatTable   Format = "table"
+	FormatCSV     Format = "csv"
+	FormatJSON    Format = "json"
+	FormatGeoJSON Format = "geojson"
+)
+
+// Formats lists the supported output formats
+var Formats = []Format{FormatTable, FormatCSV, FormatJSON, FormatGeoJSON}
+
+// ParseFormat looks up an output format by name
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q (use table, csv, json or geojson)", name)
+}
+
+// FormatForPath picks the output format from a file extension, falling back
+// to an aligned table
+func FormatForPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".geojson":
+		return FormatGeoJSON
+	default:
+		return FormatTable
+	}
+}
+
+// GeometryEncoding returns the geometry encoding a format needs
+func (f Format) GeometryEncoding() GeometryEncoding {
+	if f == FormatJSON || f == FormatGeoJSON {
+		return GeometryGeoJSON
+	}
+	return GeometryWKT
+}
+
+// Write renders a result in a format
+func Write(w io.Writer, r *Result, f Format) error {
+	switch f {
+	case FormatCSV:
+		return writeCSV(w, r)
+	case FormatJSON:
+		return writeJSON(w, r)
+	case FormatGeoJSON:
+		return writeGeoJSON(w, r)
+	default:
+		return WriteTable(w, r)
+	}
+}
+
+// WriteTable writes rows as aligned columns. Values are kept on one line.
+func WriteTable(w io.Writer, r *Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(r.Columns, "\t"))
+	underline := make([]string, len(r.Columns))
+	for i, c := range r.Columns {
+		underline[i] = strings.Repeat("-", max(len(c), 1))
+	}
+	fmt.Fprintln(tw, strings.Join(underline, "\t"))
+
+	cells := make([]string, len(r.Columns))
+	for _, row := range r.Rows {
+		for i, v := range row {
+			if v == nil {
+				cells[i] = "NULL"
+				continue
+			}
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(Text(v, r.Types[i]))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, r *Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(r.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(r.Columns))
+	for _, row := range r.Rows {
+		for i, v := range row {
+			record[i] = ""
+			if v != nil {
+				record[i] = Text(v, r.Types[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes an array with one object per row
+func writeJSON(w io.Writer, r *Result) error {
+	objects := make([]json.RawMessage, len(r.Rows))
+	for n, row := range r.Rows {
+		obj, err := r.object(row, -1)
+		if err != nil {
+			return err
+		}
+		objects[n] = obj
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objects)
+}
+
+// writeGeoJSON writes a FeatureCollection. The first geometry column
+// becomes the feature geometry and every other column a property.
+func writeGeoJSON(w io.Writer, r *Result) error {
+	geomIndex := -1
+	for i, g := range r.Geometry {
+		if g {
+			geomIndex = i
+			break
+		}
+	}
+	if geomIndex < 0 {
+		return fmt.Errorf("GeoJSON output needs a GEOMETRY column in the result")
+	}
+
+	features := make([]map[string]any, len(r.Rows))
+	for n, row := range r.Rows {
+		props, err := r.object(row, geomIndex)
+		if err != nil {
+			return err
+		}
+		features[n] = map[string]any{
+			"type":       "Feature",
+			"geometry":   r.jsonValue(geomIndex, row[geomIndex]),
+			"properties": props,
+		}
+	}
+	enc := json.NewEncoder(w)
+	return enc.Encode(map[string]any{"type": "FeatureCollection", "features": features})
+}
+
+// object encodes a row as a JSON object with the keys in column order,
+// leaving out column skip
+func (r *Result) object(row []any, skip int) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range row {
+		if i == skip {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(r.Columns[i])
+		value, err := json.Marshal(r.jsonValue(i, v))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode column %s: %w", r.Columns[i], err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts a column value for encoding/json; geometries are
+// embedded as GeoJSON objects
+func (r *Result) jsonValue(i int, v any) any {
+	if s, ok := v.(string); ok && r.Geometry[i] {
+		return json.RawMessage(s)
+	}
+	return Value(v, r.Types[i])
+}
+
+// Value converts a value returned by the driver to one encoding/json can
+// write faithfully
+func Value(v any, dbType string) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return Text(v, dbType)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = Value(e, "")
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = Value(e, "")
+		}
+		return out
+	case duckdb.Map:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = Value(e, "")
+		}
+		return out
+	case duckdb.Decimal:
+		// Keep every digit rather than rounding through float64
+		return json.Number(v.String())
+	case fmt.Stringer:
+		if _, ok := v.(json.Marshaler); ok {
+			return v
+		}
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// Text formats a value for text output
+func Text(v any, dbType string) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		if dbType == "UUID" && len(v) == 16 {
+			h := hex.EncodeToString(v)
+			return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+		}
+		return "\\x" + hex.EncodeToString(v)
+	case []any, map[string]any, duckdb.Map:
+		data, err := json.Marshal(Value(v, dbType))
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,108 @@
+package query
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyLimit is how many entries a history keeps
+const historyLimit = 1000
+
+// History is a list of statements run interactively, oldest first, kept in
+// a file with one JSON string per line so statements can span lines
+type History struct {
+	path    string
+	entries []string
+}
+
+// LoadHistory reads the history kept at path. A missing file is an empty
+// history; an empty path keeps the history in memory only.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		h.entries = append(h.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(h.entries) > historyLimit {
+		h.entries = h.entries[len(h.entries)-historyLimit:]
+	}
+	return h, nil
+}
+
+// Len returns the number of entries
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Entry returns entry i, counting from the oldest
+func (h *History) Entry(i int) string {
+	return h.entries[i]
+}
+
+// Add appends a statement, skipping a repeat of the latest entry, and saves
+// it to the history file
+func (h *History) Add(stmt string) error {
+	if stmt == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == stmt) {
+		return nil
+	}
+	h.entries = append(h.entries, stmt)
+	if h.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(stmt)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Search returns the newest entry before index before that contains term,
+// ignoring case, or -1 when there is none
+func (h *History) Search(term string, before int) int {
+	term = strings.ToLower(term)
+	for i := min(before, len(h.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(h.entries[i]), term) {
+			return i
+		}
+	}
+	return -1
+}
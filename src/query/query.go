@@ -0,0 +1,173 @@
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// GeometryEncoding is how GEOMETRY columns are returned
+type GeometryEncoding int
+
+const (
+	// GeometryWKT returns geometries as WKT text
+	GeometryWKT GeometryEncoding = iota
+	// GeometryGeoJSON returns geometries as GeoJSON geometry objects
+	GeometryGeoJSON
+)
+
+// Result holds the rows returned by a statement
+type Result struct {
+	Columns []string
+	// Types are the DuckDB types of the columns as returned
+	Types []string
+	// Geometry marks the columns that held GEOMETRY values
+	Geometry []bool
+	Rows     [][]any
+	// RowsAffected is set instead for statements that return no rows
+	RowsAffected int64
+	// Statement is true when the SQL returned no result set
+	Statement bool
+}
+
+// Run executes a SQL statement against a database. GEOMETRY columns of
+// queries are converted with enc; other statements report the number of
+// rows they changed.
+func Run(dbPath, query string, enc GeometryEncoding) (*Result, error) {
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err := database.LoadSpatial(db); err != nil {
+		return nil, err
+	}
+	return RunOn(db, query, enc)
+}
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// RunOn executes a statement on an open connection, or in a transaction,
+// with the spatial extension loaded
+func RunOn(db Queryer, query string, enc GeometryEncoding) (*Result, error) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	if query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	// DESCRIBE only succeeds for queries; anything else (DDL, INSERT, SHOW,
+	// PRAGMA, ...) runs as is
+	columns, err := describe(db, query)
+	if err != nil {
+		result, err := collect(db, query, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Statements that change data return just the number of rows changed
+		if len(result.Columns) == 1 && result.Columns[0] == "Count" {
+			result.Statement = true
+			if len(result.Rows) == 1 {
+				if n, ok := result.Rows[0][0].(int64); ok {
+					result.RowsAffected = n
+				}
+			}
+		}
+		return result, nil
+	}
+
+	var geometry []bool
+	var replace []string
+	for _, col := range columns {
+		isGeom := strings.HasPrefix(strings.ToUpper(col.Type), "GEOMETRY")
+		geometry = append(geometry, isGeom)
+		if !isGeom {
+			continue
+		}
+		fn := "ST_AsText"
+		if enc == GeometryGeoJSON {
+			fn = "ST_AsGeoJSON"
+		}
+		quoted := database.QuoteIdentifier(col.Name)
+		replace = append(replace, fmt.Sprintf("%s(%s)::VARCHAR AS %s", fn, quoted, quoted))
+	}
+
+	wrapped := query
+	if len(replace) > 0 {
+		wrapped = fmt.Sprintf("SELECT * REPLACE (%s) FROM (%s)", strings.Join(replace, ", "), query)
+	}
+	return collect(db, wrapped, geometry)
+}
+
+// collect runs a query and reads all of its rows
+func collect(db Queryer, query string, geometry []bool) (*Result, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result columns: %w", err)
+	}
+	result := &Result{Geometry: geometry}
+	for _, ct := range columnTypes {
+		result.Columns = append(result.Columns, ct.Name())
+		result.Types = append(result.Types, ct.DatabaseTypeName())
+	}
+	if result.Geometry == nil {
+		result.Geometry = make([]bool, len(columnTypes))
+	}
+
+	for rows.Next() {
+		values := make([]any, len(columnTypes))
+		ptrs := make([]any, len(columnTypes))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	return result, nil
+}
+
+// describe returns the columns a query produces
+func describe(db Queryer, query string) ([]database.Column, error) {
+	rows, err := db.Query("DESCRIBE " + query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []database.Column
+	for rows.Next() {
+		var col database.Column
+		var null, key, def, extra sql.NullString
+		if err := rows.Scan(&col.Name, &col.Type, &null, &key, &def, &extra); err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// HasGeometry reports whether any column held geometries
+func (r *Result) HasGeometry() bool {
+	for _, g := range r.Geometry {
+		if g {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a report: the queries it runs, when, and where the result
+// is written
+type Config struct {
+	// Title heads the report; defaults to the config file's name
+	Title string `yaml:"title,omitempty"`
+	// DB is the database the queries run against
+	DB string `yaml:"db"`
+	// Schedule is once, hourly, daily, weekly or a Go duration like 30m
+	Schedule string `yaml:"schedule,omitempty"`
+	// Format is html or markdown; defaults to the output's extension
+	Format Format `yaml:"format,omitempty"`
+	// Output is the file or s3:// URL written; {date} and {time} are
+	// replaced by when the report ran
+	Output string `yaml:"output"`
+	// MapSize is the longer side of the maps in pixels
+	MapSize int `yaml:"map_size,omitempty"`
+	// Queries are the report's sections, in order
+	Queries []Query `yaml:"queries"`
+}
+
+// Query is a section of a report
+type Query struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	SQL         string `yaml:"sql"`
+	// Limit caps the rows listed in the table; the map shows them all
+	Limit int `yaml:"limit,omitempty"`
+	// Map draws the result's geometries; on by default when there are any
+	Map *bool `yaml:"map,omitempty"`
+}
+
+const (
+	defaultLimit   = 50
+	defaultMapSize = 640
+)
+
+// LoadConfig reads and checks a report config file
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report config: %w", err)
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse report config: %w", err)
+	}
+
+	if c.Title == "" {
+		base := filepath.Base(configPath)
+		c.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if c.MapSize == 0 {
+		c.MapSize = defaultMapSize
+	}
+	if c.MapSize < 64 || c.MapSize > 4096 {
+		return nil, fmt.Errorf("report config: map_size must be between 64 and 4096")
+	}
+	if _, err := ParseSchedule(c.Schedule); err != nil {
+		return nil, fmt.Errorf("report config: %w", err)
+	}
+	if c.Format != "" {
+		if c.Format, err = ParseFormat(string(c.Format)); err != nil {
+			return nil, fmt.Errorf("report config: %w", err)
+		}
+	}
+
+	if len(c.Queries) == 0 {
+		return nil, fmt.Errorf("report config has no queries")
+	}
+	names := make(map[string]bool)
+	for i := range c.Queries {
+		q := &c.Queries[i]
+		if strings.TrimSpace(q.Name) == "" {
+			return nil, fmt.Errorf("report config: query %d has no name", i+1)
+		}
+		if names[q.Name] {
+			return nil, fmt.Errorf("report config: query %q is listed twice", q.Name)
+		}
+		names[q.Name] = true
+		if strings.TrimSpace(q.SQL) == "" {
+			return nil, fmt.Errorf("report config: query %q has no sql", q.Name)
+		}
+		if q.Limit < 0 {
+			return nil, fmt.Errorf("report config: query %q: limit must be positive", q.Name)
+		}
+		if q.Limit == 0 {
+			q.Limit = defaultLimit
+		}
+	}
+	return &c, nil
+}
+
+// ParseSchedule returns the interval between reports, 0 for a single run
+func ParseSchedule(s string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "once":
+		return 0, nil
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("invalid schedule %q (must be once, hourly, daily, weekly or a duration of at least 1m)", s)
+	}
+	return d, nil
+}
+
+// OutputFormat is the configured format, or the one the output's extension
+// implies
+func (c *Config) OutputFormat() Format {
+	if c.Format != "" {
+		return c.Format
+	}
+	return FormatForPath(c.Output)
+}
+
+// OutputPath fills in the {date} and {time} placeholders of the output
+func (c *Config) OutputPath(now time.Time) string {
+	now = now.UTC()
+	return strings.NewReplacer("{date}", now.Format("2006-01-02"), "{time}", now.Format("150405")).Replace(c.Output)
+}
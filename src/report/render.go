@@ -0,0 +1,167 @@
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+// Format is how a report is rendered
+type Format string
+
+const (
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+)
+
+// ParseFormat parses a report format; md is short for markdown
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "html":
+		return FormatHTML, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown report format %q (use html or markdown)", name)
+}
+
+// FormatForPath picks the format from a file extension, falling back to HTML
+func FormatForPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	default:
+		return FormatHTML
+	}
+}
+
+// ContentType is the MIME type of documents in the format
+func (f Format) ContentType() string {
+	if f == FormatMarkdown {
+		return "text/markdown; charset=utf-8"
+	}
+	return "text/html; charset=utf-8"
+}
+
+// Output is a rendered report
+type Output struct {
+	Document []byte
+	// Assets are files the document links to by name, to be written next
+	// to it: the maps of a Markdown report. HTML reports embed theirs.
+	Assets map[string][]byte
+}
+
+// Render renders a report. name is the document's file name, which the
+// names of its assets are derived from.
+func Render(r *Report, format Format, name string) (*Output, error) {
+	var buf bytes.Buffer
+	if format == FormatMarkdown {
+		out := &Output{Assets: make(map[string][]byte)}
+		base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+		writeMarkdown(&buf, r, func(i int, png []byte) string {
+			asset := fmt.Sprintf("%s-map%d.png", base, i+1)
+			out.Assets[asset] = png
+			return asset
+		})
+		out.Document = buf.Bytes()
+		return out, nil
+	}
+
+	if err := reportPage.Execute(&buf, r); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return &Output{Document: buf.Bytes()}, nil
+}
+
+// writeMarkdown writes the report as Markdown, saving each map through asset,
+// which returns the name to link it by
+func writeMarkdown(buf *bytes.Buffer, r *Report, asset func(i int, png []byte) string) {
+	fmt.Fprintf(buf, "# %s\n\n", r.Title)
+	fmt.Fprintf(buf, "_%s · %s_\n", r.Database, r.GeneratedAt.Format("2006-01-02 15:04 UTC"))
+
+	for i, s := range r.Sections {
+		fmt.Fprintf(buf, "\n## %s\n\n", s.Name)
+		if s.Description != "" {
+			fmt.Fprintf(buf, "%s\n\n", s.Description)
+		}
+		if s.Map != nil {
+			fmt.Fprintf(buf, "![Map of %s](%s)\n\n", s.Name, asset(i, s.Map))
+		}
+
+		switch {
+		case s.Total == 0:
+			buf.WriteString("No rows.\n")
+			continue
+		case len(s.Columns) == 0:
+			fmt.Fprintf(buf, "%d rows.\n", s.Total)
+			continue
+		}
+		buf.WriteString("|")
+		for _, col := range s.Columns {
+			fmt.Fprintf(buf, " %s |", markdownCell(col))
+		}
+		buf.WriteString("\n|")
+		for range s.Columns {
+			buf.WriteString(" --- |")
+		}
+		buf.WriteString("\n")
+		for _, row := range s.Rows {
+			buf.WriteString("|")
+			for _, v := range row {
+				fmt.Fprintf(buf, " %s |", markdownCell(v))
+			}
+			buf.WriteString("\n")
+		}
+		if s.Truncated() {
+			fmt.Fprintf(buf, "\nFirst %d of %d rows.\n", len(s.Rows), s.Total)
+		}
+	}
+}
+
+// markdownCell keeps a value on one line and from closing its cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ").Replace(s)
+}
+
+// dataURI embeds a PNG in the page
+func dataURI(png []byte) template.URL {
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+}
+
+// reportPage is the report as a standalone HTML page
+var reportPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"dataURI": dataURI,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} · xyzduck</title>
+<style>
+  body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 56em; color: #1e293b; }
+  table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+  th, td { padding: 0.35em 0.75em; border-bottom: 1px solid #e2e8f0; text-align: left; }
+  img { display: block; max-width: 100%; margin: 1em 0; border: 1px solid #e2e8f0; background: #f1f5f9; }
+  .muted { color: #64748b; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">{{.Database}} · {{.GeneratedAt.Format "2006-01-02 15:04 UTC"}}</p>
+{{range .Sections}}
+<h2>{{.Name}}</h2>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{with .Map}}<img src="{{dataURI .}}" alt="Map">{{end}}
+{{if not .Total}}<p class="muted">No rows.</p>
+{{else if not .Columns}}<p class="muted">{{.Total}} rows.</p>
+{{else}}<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Truncated}}<p class="muted">First {{len .Rows}} of {{.Total}} rows.</p>{{end}}
+{{end}}{{end}}
+</body>
+</html>
+`))
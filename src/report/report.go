@@ -0,0 +1,142 @@
+package report
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+	"org.xyzmaps.xyzduck/src/query"
+	"org.xyzmaps.xyzduck/src/thumbnail"
+)
+
+// resultTable holds a query's result while its map is drawn
+const resultTable = "xyzduck_report_result"
+
+// Report is the result of running a config's queries
+type Report struct {
+	Title       string
+	Database    string
+	GeneratedAt time.Time
+	Sections    []Section
+}
+
+// Section is the result of one query
+type Section struct {
+	Name        string
+	Description string
+	SQL         string
+	// Columns and Rows are the result as text, without geometry columns
+	Columns []string
+	Rows    [][]string
+	// Total is the number of rows the query returned, of which the first
+	// Limit are in Rows
+	Total int
+	// Map is a PNG of the result's geometries, nil when there is none
+	Map []byte
+}
+
+// Truncated reports whether rows were left out of the table
+func (s Section) Truncated() bool {
+	return s.Total > len(s.Rows)
+}
+
+// Run runs the queries of a config. db must have the spatial extension
+// loaded.
+func Run(db *sql.DB, c *Config, dbPath string) (*Report, error) {
+	r := &Report{Title: c.Title, Database: dbPath, GeneratedAt: time.Now().UTC()}
+	for _, q := range c.Queries {
+		section, err := runQuery(db, q, c.MapSize)
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %w", q.Name, err)
+		}
+		r.Sections = append(r.Sections, section)
+	}
+	return r, nil
+}
+
+// runQuery stores a query's result in a temporary table, so its geometries'
+// extent can be worked out without running it twice. The table only lives
+// on the connection that created it, so all of it happens in a transaction,
+// which is rolled back to drop the table.
+func runQuery(db *sql.DB, q Query, mapSize int) (Section, error) {
+	section := Section{Name: q.Name, Description: q.Description, SQL: q.SQL}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return section, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := database.QuoteIdentifier(resultTable)
+	createSQL := fmt.Sprintf("CREATE OR REPLACE TEMP TABLE %s AS %s", table, trimStatement(q.SQL))
+	if _, err := tx.Exec(createSQL); err != nil {
+		return section, fmt.Errorf("query failed: %w", err)
+	}
+
+	result, err := query.RunOn(tx, "SELECT * FROM "+table, query.GeometryGeoJSON)
+	if err != nil {
+		return section, err
+	}
+
+	geomColumn := -1
+	for i, col := range result.Columns {
+		if result.Geometry[i] {
+			if geomColumn < 0 {
+				geomColumn = i
+			}
+			continue
+		}
+		section.Columns = append(section.Columns, col)
+	}
+	section.Total = len(result.Rows)
+	for i, row := range result.Rows {
+		if i == q.Limit {
+			break
+		}
+		var cells []string
+		for j, v := range row {
+			if !result.Geometry[j] {
+				cells = append(cells, query.Text(v, result.Types[j]))
+			}
+		}
+		section.Rows = append(section.Rows, cells)
+	}
+
+	if geomColumn < 0 || (q.Map != nil && !*q.Map) {
+		return section, nil
+	}
+	section.Map, err = drawMap(tx, result, geomColumn, mapSize)
+	return section, err
+}
+
+// drawMap draws the geometries of a result column, fitted to their extent
+func drawMap(tx *sql.Tx, result *query.Result, column, size int) ([]byte, error) {
+	q := database.QuoteIdentifier(result.Columns[column])
+	var xmin, ymin, xmax, ymax sql.NullFloat64
+	extentSQL := fmt.Sprintf("SELECT MIN(ST_XMin(%s)), MIN(ST_YMin(%s)), MAX(ST_XMax(%s)), MAX(ST_YMax(%s)) FROM %s", q, q, q, q, database.QuoteIdentifier(resultTable))
+	if err := tx.QueryRow(extentSQL).Scan(&xmin, &ymin, &xmax, &ymax); err != nil {
+		return nil, fmt.Errorf("failed to read extent: %w", err)
+	}
+	if !xmin.Valid {
+		return nil, nil
+	}
+
+	canvas := thumbnail.NewCanvas([4]float64{xmin.Float64, ymin.Float64, xmax.Float64, ymax.Float64}, size)
+	for _, row := range result.Rows {
+		geometry, ok := row[column].(string)
+		if !ok {
+			continue
+		}
+		if err := canvas.DrawGeoJSON([]byte(geometry)); err != nil {
+			return nil, fmt.Errorf("failed to draw map: %w", err)
+		}
+	}
+	return canvas.PNG()
+}
+
+// trimStatement drops the trailing semicolon a query may end with
+func trimStatement(s string) string {
+	return strings.TrimSuffix(strings.TrimSpace(s), ";")
+}
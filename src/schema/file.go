@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a schema file describing a table's columns and per-column load settings
+type File struct {
+	// Table is the table the file describes, used by 'schema apply'
+	Table string `yaml:"table,omitempty"`
+	// SoftDelete puts the table in soft-delete mode, used by 'schema apply'
+	SoftDelete bool         `yaml:"soft_delete,omitempty"`
+	Columns    []ColumnSpec `yaml:"columns"`
+}
+
+// ColumnSpec holds the settings for a single column
+type ColumnSpec struct {
+	Name string `yaml:"name"`
+	// Type is the DuckDB column type, used by 'schema apply' and by load to
+	// override the inferred type
+	Type string `yaml:"type,omitempty"`
+	// NullValues replaces the global --null-values list for this column
+	NullValues []string `yaml:"null_values,omitempty"`
+	// EmptyAsNull overrides --empty-as-null for this column
+	EmptyAsNull *bool `yaml:"empty_as_null,omitempty"`
+	// Validate lists rules every loaded value must satisfy
+	Validate *ValidationSpec `yaml:"validate,omitempty"`
+}
+
+// ValidationSpec holds the validation rules for a column
+type ValidationSpec struct {
+	// Required rejects missing and NULL values
+	Required bool `yaml:"required,omitempty"`
+	// Pattern is a regular expression values must match
+	Pattern string `yaml:"pattern,omitempty"`
+	// Min and Max bound numeric values
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+	// Enum lists the allowed values
+	Enum []string `yaml:"enum,omitempty"`
+	// MaxViolations is the number of violations per rule tolerated by --strict
+	MaxViolations int `yaml:"max_violations,omitempty"`
+}
+
+// Load reads and parses a schema file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file: %w", err)
+	}
+
+	for i, col := range f.Columns {
+		if strings.TrimSpace(col.Name) == "" {
+			return nil, fmt.Errorf("schema file column %d has no name", i+1)
+		}
+	}
+
+	return &f, nil
+}
+
+// Column returns the spec for a column, matching names case-insensitively
+func (f *File) Column(name string) (ColumnSpec, bool) {
+	if f == nil {
+		return ColumnSpec{}, false
+	}
+	for _, col := range f.Columns {
+		if strings.EqualFold(col.Name, name) {
+			return col, true
+		}
+	}
+	return ColumnSpec{}, false
+}
+
+// Marshal renders the schema file as YAML
+func (f *File) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode schema file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode schema file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"org.xyzmaps.xyzduck/src/auth"
+	"org.xyzmaps.xyzduck/src/features"
+	"org.xyzmaps.xyzduck/src/telemetry"
+)
+
+// maxEditsSize limits the body of an edits request
+const maxEditsSize = 32 << 20
+
+// handleFeature serves /features/{id}, a feature with its full geometry for
+// editing; tiles only carry geometries clipped to them
+func (s *Server) handleFeature(w http.ResponseWriter, r *http.Request) {
+	f, err := s.opts.Features.Get(r.PathValue("id"))
+	if errors.Is(err, features.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(f)
+}
+
+// handleEdits serves POST /features/edits, applying a batch of inserts,
+// updates and deletes in one transaction. A batch with an edit that fails
+// changes nothing and is answered with the reason.
+//
+// Other sites' pages mustn't be able to edit through a visitor's browser, as
+// the server usually runs on localhost without auth: cross-site requests are
+// refused, and so are bodies other than JSON, which a page can only send
+// after a CORS preflight the server doesn't answer.
+func (s *Server) handleEdits(w http.ResponseWriter, r *http.Request) {
+	if err := http.NewCrossOriginProtection().Check(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "edits must be sent as application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if s.opts.Auth != nil {
+		id, _ := r.Context().Value(identityKey{}).(auth.Identity)
+		if !s.opts.Auth.CanEdit(id) {
+			http.Error(w, fmt.Sprintf("%s may not edit", id.Subject), http.StatusForbidden)
+			return
+		}
+	}
+
+	var edits features.Edits
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEditsSize))
+	dec.UseNumber()
+	if err := dec.Decode(&edits); err != nil {
+		http.Error(w, fmt.Sprintf("invalid edits: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	_, span := telemetry.Start(r.Context(), "apply edits", telemetry.KindInternal,
+		telemetry.Int64("inserts", int64(len(edits.Insert))),
+		telemetry.Int64("updates", int64(len(edits.Update))),
+		telemetry.Int64("deletes", int64(len(edits.Delete))))
+	result, err := s.opts.Features.Apply(edits)
+	span.RecordError(err)
+	span.Finish()
+	var invalid *features.EditError
+	switch {
+	case errors.Is(err, features.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.opts.OnEdit != nil {
+		s.opts.OnEdit(result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
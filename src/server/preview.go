@@ -0,0 +1,270 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// previewPage shows the tiles over an OpenStreetMap basemap with MapLibre GL
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} · xyzduck</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<link rel="stylesheet" href="https://unpkg.com/maplibre-gl@4.7.1/dist/maplibre-gl.css">
+<script src="https://unpkg.com/maplibre-gl@4.7.1/dist/maplibre-gl.js"></script>
+{{if .Edit}}<link rel="stylesheet" href="https://unpkg.com/@mapbox/mapbox-gl-draw@1.4.3/dist/mapbox-gl-draw.css">
+<script src="https://unpkg.com/@mapbox/mapbox-gl-draw@1.4.3/dist/mapbox-gl-draw.js"></script>
+{{end}}<style>
+  body { margin: 0; }
+  #map { position: absolute; top: 0; bottom: 0; width: 100%; }
+  #panel { display: none; position: absolute; top: 10px; right: 50px; max-height: 80%; overflow: auto;
+    padding: 10px; background: #fff; border-radius: 4px; box-shadow: 0 1px 4px rgba(0, 0, 0, 0.3); font: 13px sans-serif; }
+  #panel h3 { margin: 0 0 8px; font-size: 14px; }
+  #panel button { margin: 8px 4px 0 0; }
+  #panel .error { color: #c92a2a; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<div id="map"></div>
+<div id="panel"></div>
+<script>
+const layer = {{.Layer}};
+const color = {{.Color}};
+// A server requiring authentication is opened as /?access_token=..., which
+// is passed on to the tile requests
+const token = new URLSearchParams(location.search).get("access_token");
+const map = new maplibregl.Map({
+  container: "map",
+  transformRequest: (url) => token && url.startsWith(location.origin)
+    ? { url, headers: { Authorization: "Bearer " + token } }
+    : { url },
+  style: {
+    version: 8,
+    sources: {
+      osm: {
+        type: "raster",
+        tiles: ["https://tile.openstreetmap.org/{z}/{x}/{y}.png"],
+        tileSize: 256,
+        attribution: "&copy; OpenStreetMap contributors"
+      },
+      data: { type: "vector", url: location.origin + "/tiles.json" }
+    },
+    layers: [
+      { id: "osm", type: "raster", source: "osm" },
+      { id: "fill", type: "fill", source: "data", "source-layer": layer,
+        filter: ["==", "$type", "Polygon"],
+        paint: { "fill-color": color, "fill-opacity": 0.3 } },
+      { id: "line", type: "line", source: "data", "source-layer": layer,
+        filter: ["!=", "$type", "Point"],
+        paint: { "line-color": color, "line-width": 1.5 } },
+      { id: "point", type: "circle", source: "data", "source-layer": layer,
+        filter: ["==", "$type", "Point"],
+        paint: { "circle-color": color, "circle-radius": 4, "circle-stroke-color": "#fff", "circle-stroke-width": 1 } }
+    ]
+  }
+});
+map.addControl(new maplibregl.NavigationControl());
+{{with .Bounds}}map.fitBounds([[{{index . 0}}, {{index . 1}}], [{{index . 2}}, {{index . 3}}]], { padding: 20, animate: false });{{end}}
+
+{{if .Edit}}
+// Editing: new features are drawn with the tools on the left, existing ones
+// are clicked to edit their attributes, reshape or delete them. Changes are
+// sent to the features API, then the tiles are reloaded.
+const edit = {{.Edit}};
+const headers = token ? { Authorization: "Bearer " + token } : {};
+// mapbox-gl-draw styles its controls with Mapbox class names
+MapboxDraw.constants.classes.CONTROL_BASE = "maplibregl-ctrl";
+MapboxDraw.constants.classes.CONTROL_PREFIX = "maplibregl-ctrl-";
+MapboxDraw.constants.classes.CONTROL_GROUP = "maplibregl-ctrl-group";
+const draw = new MapboxDraw({
+  displayControlsDefault: false,
+  controls: { point: true, line_string: true, polygon: true }
+});
+map.addControl(draw, "top-left");
+
+const panel = document.getElementById("panel");
+// editing is the feature in the panel: id is null for a drawn one, drawnId
+// the draw feature holding its geometry while it is edited
+let editing = null;
+
+const text = (value) => value == null ? "" : typeof value === "object" ? JSON.stringify(value) : String(value);
+
+function button(label, onclick) {
+  const b = document.createElement("button");
+  b.textContent = label;
+  b.onclick = onclick;
+  panel.append(b);
+}
+
+function openPanel(feature, drawnId) {
+  editing = {
+    id: feature ? feature.id : null,
+    properties: feature ? feature.properties : {},
+    geometry: feature ? feature.geometry : null,
+    drawnId: drawnId,
+    geometryChanged: !feature
+  };
+  panel.replaceChildren();
+  const title = document.createElement("h3");
+  title.textContent = feature ? edit.key + " " + text(feature.id) : "New feature";
+  panel.append(title);
+  const form = document.createElement("table");
+  for (const col of edit.columns) {
+    const row = form.insertRow();
+    row.insertCell().textContent = col.name;
+    const input = document.createElement("input");
+    input.name = col.name;
+    input.placeholder = col.type;
+    input.value = text(editing.properties[col.name]);
+    input.disabled = feature && col.name === edit.key;
+    row.insertCell().append(input);
+  }
+  panel.append(form);
+  button("Save", save);
+  if (feature && feature.geometry) button("Edit geometry", editGeometry);
+  if (feature) button("Delete", remove);
+  button("Cancel", closePanel);
+  const error = document.createElement("div");
+  error.className = "error";
+  panel.append(error);
+  panel.style.display = "block";
+}
+
+function closePanel() {
+  editing = null;
+  draw.deleteAll();
+  panel.style.display = "none";
+}
+
+function editGeometry() {
+  draw.deleteAll();
+  const [id] = draw.add({ type: "Feature", properties: {}, geometry: editing.geometry });
+  editing.drawnId = id;
+  if (editing.geometry.type === "Point") {
+    draw.changeMode("simple_select", { featureIds: [id] });
+  } else {
+    draw.changeMode("direct_select", { featureId: id });
+  }
+}
+
+// send posts a batch of edits, closing the panel once they are made
+async function send(edits) {
+  const res = await fetch(location.origin + "/features/edits", {
+    method: "POST",
+    headers: { ...headers, "Content-Type": "application/json" },
+    body: JSON.stringify(edits)
+  });
+  if (!res.ok) {
+    panel.querySelector(".error").textContent = await res.text();
+    return;
+  }
+  closePanel();
+  map.getSource("data").setTiles([location.origin + "/tiles/{z}/{x}/{y}.mvt?v=" + Date.now()]);
+}
+
+// save inserts a drawn feature, or updates the changed attributes and
+// geometry of an existing one. Values are sent as text and cast to their
+// column's type; an emptied field is NULL.
+function save() {
+  const properties = {};
+  for (const input of panel.querySelectorAll("input")) {
+    if (input.disabled || input.value === text(editing.properties[input.name])) continue;
+    properties[input.name] = input.value === "" ? null : input.value;
+  }
+  const feature = { type: "Feature", properties };
+  const drawn = editing.drawnId && draw.get(editing.drawnId);
+  if (drawn && editing.geometryChanged) feature.geometry = drawn.geometry;
+  if (editing.id === null) {
+    send({ insert: [feature] });
+    return;
+  }
+  if (!Object.keys(properties).length && !feature.geometry) {
+    closePanel();
+    return;
+  }
+  feature.id = editing.id;
+  send({ update: [feature] });
+}
+
+function remove() {
+  if (confirm("Delete this feature?")) send({ delete: [editing.id] });
+}
+
+map.on("draw.create", (e) => {
+  const id = e.features[0].id;
+  for (const f of draw.getAll().features) {
+    if (f.id !== id) draw.delete(f.id);
+  }
+  openPanel(null, id);
+});
+map.on("draw.update", (e) => {
+  if (editing && e.features.some((f) => f.id === editing.drawnId)) editing.geometryChanged = true;
+});
+
+// Clicking a feature opens it with its full geometry; tiles only hold the
+// part in them
+map.on("click", async (e) => {
+  if (editing || draw.getMode() !== "simple_select") return;
+  const features = map.queryRenderedFeatures(e.point, { layers: ["point", "line", "fill"] });
+  if (!features.length || features[0].properties[edit.key] == null) return;
+  const res = await fetch(location.origin + "/features/" + encodeURIComponent(features[0].properties[edit.key]), { headers });
+  if (!res.ok) {
+    alert(await res.text());
+    return;
+  }
+  openPanel(await res.json());
+});
+{{else}}
+// Show a feature's properties on click
+map.on("click", (e) => {
+  const features = map.queryRenderedFeatures(e.point, { layers: ["point", "line", "fill"] });
+  if (!features.length) return;
+  const table = document.createElement("table");
+  for (const [key, value] of Object.entries(features[0].properties)) {
+    const row = table.insertRow();
+    row.insertCell().textContent = key;
+    row.insertCell().textContent = value;
+  }
+  new maplibregl.Popup().setLngLat(e.lngLat).setDOMContent(table).addTo(map);
+});
+{{end}}
+for (const id of ["point", "line", "fill"]) {
+  map.on("mouseenter", id, () => map.getCanvas().style.cursor = "pointer");
+  map.on("mouseleave", id, () => map.getCanvas().style.cursor = "");
+}
+</script>
+</body>
+</html>
+`))
+
+// handlePreview serves the preview map
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	meta := s.source.Metadata
+	var bounds []float64
+	if !s.source.Empty() {
+		bounds = meta.Bounds[:]
+	}
+
+	// A choropleth colours features with a MapLibre expression
+	var color any = "#d6336c"
+	if s.opts.Choropleth != nil {
+		color = s.opts.Choropleth.ColorExpression()
+	}
+
+	// Editing passes the key and the columns to the editing tools
+	var edit any
+	if s.opts.Features != nil {
+		edit = map[string]any{"key": s.opts.Features.Key(), "columns": s.opts.Features.Columns()}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	previewPage.Execute(w, map[string]any{
+		"Name":   meta.Name,
+		"Layer":  meta.Layer,
+		"Bounds": bounds,
+		"Color":  color,
+		"Edit":   edit,
+	})
+}
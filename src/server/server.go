@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/auth"
+	"org.xyzmaps.xyzduck/src/classify"
+	"org.xyzmaps.xyzduck/src/features"
+	"org.xyzmaps.xyzduck/src/telemetry"
+	"org.xyzmaps.xyzduck/src/thumbnail"
+	"org.xyzmaps.xyzduck/src/tiles"
+)
+
+// Options configures the tile server
+type Options struct {
+	MinZoom int
+	MaxZoom int
+	// RasterSize is the width and height of raster tiles in pixels
+	RasterSize int
+	// RasterStyle sets the colours raster tiles are drawn in
+	RasterStyle thumbnail.Style
+	// Choropleth, when set, colours features by the class of their value in
+	// the preview map and raster tiles
+	Choropleth *classify.Classification
+	// Auth, when set, turns away requests it doesn't authenticate
+	Auth *auth.Authenticator
+	// Features, when set, turns on editing: the features API and the
+	// preview map's editing tools
+	Features *features.Store
+	// OnEdit, when set, is called after each batch of edits is committed
+	OnEdit func(features.Result)
+}
+
+// Server serves vector and raster tiles generated on the fly from one table
+type Server struct {
+	source *tiles.Source
+	opts   Options
+	mux    *http.ServeMux
+	// classColors are the choropleth's class colours for raster tiles
+	classColors []color.RGBA
+}
+
+// New creates a server for a tile source
+func New(source *tiles.Source, opts Options) (*Server, error) {
+	s := &Server{source: source, opts: opts, mux: http.NewServeMux()}
+	if opts.Choropleth != nil {
+		for _, hex := range opts.Choropleth.Colors {
+			c, err := thumbnail.ParseColor(hex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid class colour: %w", err)
+			}
+			s.classColors = append(s.classColors, c)
+		}
+	}
+	s.mux.HandleFunc("GET /{$}", s.handlePreview)
+	s.mux.HandleFunc("GET /tiles.json", s.handleTileJSON)
+	s.mux.HandleFunc("GET /tiles/{z}/{x}/{file}", s.handleTile)
+	s.mux.HandleFunc("GET /raster/{z}/{x}/{file}", s.handleRaster)
+	if opts.Features != nil {
+		s.mux.HandleFunc("GET /features/{id}", s.handleFeature)
+		s.mux.HandleFunc("POST /features/edits", s.handleEdits)
+	}
+	return s, nil
+}
+
+// ServeHTTP serves a request, in a span of its own while tracing is on. A
+// traceparent header from the client makes it part of the client's trace.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, pattern := s.mux.Handler(r)
+	name := pattern
+	if name == "" {
+		name = r.Method
+	}
+	ctx := telemetry.WithTraceparent(r.Context(), r.Header.Get("traceparent"))
+	ctx, span := telemetry.Start(ctx, name, telemetry.KindServer,
+		telemetry.String("http.request.method", r.Method),
+		telemetry.String("url.path", r.URL.Path))
+	if span == nil {
+		s.serve(w, r)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.serve(rec, r.WithContext(ctx))
+	span.SetAttributes(telemetry.Int64("http.response.status_code", int64(rec.status)))
+	if rec.status >= 500 {
+		span.RecordError(fmt.Errorf("%d %s", rec.status, http.StatusText(rec.status)))
+	}
+	span.Finish()
+}
+
+// serve authenticates a request when the server requires it and hands it to
+// its handler
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Auth != nil {
+		id, err := s.opts.Auth.Check(r)
+		var authErr *auth.Error
+		if errors.As(err, &authErr) {
+			auth.Challenge(w, authErr)
+			http.Error(w, authErr.Message, authErr.Status)
+			return
+		}
+		telemetry.FromContext(r.Context()).SetAttributes(telemetry.String("enduser.id", id.Subject))
+		r = r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// identityKey keys the caller's auth.Identity in request contexts
+type identityKey struct{}
+
+// statusRecorder remembers the status code a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// handleTile serves /tiles/{z}/{x}/{y}.mvt. Tiles without features are 204
+// No Content, which map clients treat as empty.
+func (s *Server) handleTile(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.requestTile(w, r, ".mvt")
+	if !ok {
+		return
+	}
+
+	_, span := telemetry.Start(r.Context(), "generate tile", telemetry.KindInternal)
+	data, _, err := s.source.Tile(t)
+	span.RecordError(err)
+	span.Finish()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if data == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if compressed, err := tiles.Gzip(data); err == nil {
+			w.Header().Set("Content-Encoding", "gzip")
+			data = compressed
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// handleRaster serves /raster/{z}/{x}/{y}.png, the tile drawn as an image for
+// clients that can't render vector tiles. Tiles without features are
+// transparent. With a choropleth, features are drawn in their class colour,
+// keeping the opacity of the fill colour, and features without a value in
+// the plain style.
+func (s *Server) handleRaster(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.requestTile(w, r, ".png")
+	if !ok {
+		return
+	}
+
+	var valueColumn string
+	if s.opts.Choropleth != nil {
+		valueColumn = s.opts.Choropleth.Column
+	}
+	_, span := telemetry.Start(r.Context(), "query geometries", telemetry.KindInternal)
+	geometries, err := s.source.Geometries(t, valueColumn)
+	span.SetAttributes(telemetry.Int64("features", int64(len(geometries))))
+	span.RecordError(err)
+	span.Finish()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	canvas := thumbnail.NewTileCanvas(s.opts.RasterSize, s.opts.RasterStyle, t.Position)
+	for _, g := range geometries {
+		if s.opts.Choropleth != nil {
+			canvas.SetStyle(s.rasterStyle(g.Value))
+		}
+		if err := canvas.DrawGeoJSON(g.GeoJSON); err != nil {
+			http.Error(w, fmt.Sprintf("tile %s: %v", t, err), http.StatusInternalServerError)
+			return
+		}
+	}
+	data, err := canvas.PNG()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// rasterStyle is the style a choropleth feature with the value is drawn in
+func (s *Server) rasterStyle(value sql.NullFloat64) thumbnail.Style {
+	if !value.Valid {
+		return s.opts.RasterStyle
+	}
+	c := s.classColors[s.opts.Choropleth.Class(value.Float64)]
+	fill := c
+	fill.A = s.opts.RasterStyle.Fill.A
+	return thumbnail.Style{Fill: fill, Stroke: c}
+}
+
+// requestTile reads the tile a request asks for, answering with an error
+// when the path is not a tile with the suffix or the zoom is not served
+func (s *Server) requestTile(w http.ResponseWriter, r *http.Request, suffix string) (tiles.Tile, bool) {
+	t, ok := parseTile(r.PathValue("z"), r.PathValue("x"), r.PathValue("file"), suffix)
+	if !ok {
+		http.NotFound(w, r)
+		return t, false
+	}
+	if t.Z < s.opts.MinZoom || t.Z > s.opts.MaxZoom {
+		http.Error(w, fmt.Sprintf("zoom must be between %d and %d", s.opts.MinZoom, s.opts.MaxZoom), http.StatusNotFound)
+		return t, false
+	}
+	telemetry.FromContext(r.Context()).SetAttributes(telemetry.String("tile", t.String()))
+	return t, true
+}
+
+// parseTile reads a tile address from the path segments of a tile URL ending
+// in suffix
+func parseTile(zs, xs, file, suffix string) (tiles.Tile, bool) {
+	ys, ok := strings.CutSuffix(file, suffix)
+	if !ok {
+		return tiles.Tile{}, false
+	}
+	z, errZ := strconv.Atoi(zs)
+	x, errX := strconv.Atoi(xs)
+	y, errY := strconv.Atoi(ys)
+	if errZ != nil || errX != nil || errY != nil {
+		return tiles.Tile{}, false
+	}
+	t := tiles.Tile{Z: z, X: x, Y: y}
+	return t, t.Valid()
+}
+
+// handleTileJSON describes the tileset, with tile URLs on the host the
+// client used
+func (s *Server) handleTileJSON(w http.ResponseWriter, r *http.Request) {
+	meta := s.source.Metadata
+	meta.MinZoom, meta.MaxZoom = s.opts.MinZoom, s.opts.MaxZoom
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tiles.TileJSON(meta, []string{baseURL(r) + "/tiles/{z}/{x}/{y}.mvt"}))
+}
+
+// baseURL is the scheme and host the request was made to
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
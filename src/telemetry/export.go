@@ -0,0 +1,330 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/offline"
+	"org.xyzmaps.xyzduck/src/version"
+)
+
+const (
+	// exportInterval is how often finished spans are sent while a command runs
+	exportInterval = 5 * time.Second
+	// exportBatch sends spans early once this many are waiting
+	exportBatch = 512
+	// maxQueued drops spans beyond this many, e.g. while the collector is down
+	maxQueued = 10000
+)
+
+// tracer collects finished spans for export and the timings report
+type tracer struct {
+	config Config
+	client *http.Client
+
+	mu     sync.Mutex
+	queue  []*Span
+	kept   []*Span
+	warned bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// current is the process's tracer; tracing is off until Init
+var current = &tracer{}
+
+// Init turns tracing on as configured: exporting spans to an OTLP endpoint
+// in the background, keeping them for WriteTimings, or both. Offline mode
+// turns the export off.
+func Init(config Config) error {
+	var err error
+	if config.Endpoint != "" {
+		if err = offline.Check("exporting traces"); err != nil {
+			config.Endpoint = ""
+		}
+	}
+	t := &tracer{config: config}
+	if config.Endpoint != "" {
+		t.client = &http.Client{Timeout: 10 * time.Second}
+		t.wake = make(chan struct{}, 1)
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.run()
+	}
+	current = t
+	return err
+}
+
+// Shutdown sends the spans still waiting, giving up when ctx is done
+func Shutdown(ctx context.Context) {
+	t := current
+	if t.stop == nil {
+		return
+	}
+	close(t.stop)
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+	}
+}
+
+func (t *tracer) enabled() bool {
+	return t.config.Endpoint != "" || t.config.Timings
+}
+
+// finished queues a span that has ended
+func (t *tracer) finished(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.config.Timings && s.Kind == KindInternal {
+		t.kept = append(t.kept, s)
+	}
+	if t.stop == nil || len(t.queue) >= maxQueued {
+		return
+	}
+	t.queue = append(t.queue, s)
+	if len(t.queue) >= exportBatch {
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run sends the queued spans every exportInterval, when a batch is full and
+// once more when stopped
+func (t *tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			t.flush()
+			return
+		case <-ticker.C:
+		case <-t.wake:
+		}
+		t.flush()
+	}
+}
+
+// flush sends the queued spans. A failed export is reported once, on stderr
+// so it doesn't mix with a command's output, and the spans are dropped.
+func (t *tracer) flush() {
+	t.mu.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	if err := t.export(spans); err != nil {
+		t.mu.Lock()
+		warned := t.warned
+		t.warned = true
+		t.mu.Unlock()
+		if !warned {
+			fmt.Fprintf(os.Stderr, "! Failed to export traces: %v\n", err)
+		}
+	}
+}
+
+// export posts spans to the endpoint as OTLP/HTTP JSON
+func (t *tracer) export(spans []*Span) error {
+	body, err := json.Marshal(encodeSpans(t.config.Resource, spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", t.config.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", t.config.Endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// The OTLP JSON encoding of ExportTraceServiceRequest. IDs are hex and 64-bit
+// integers are strings, as the protocol's JSON mapping requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		// Code 2 is STATUS_CODE_ERROR
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// encodeSpans builds the export request for spans
+func encodeSpans(resource []Attr, spans []*Span) otlpRequest {
+	// Later resource attributes override earlier ones with the same key
+	byKey := make(map[string]Attr)
+	var keys []string
+	for _, a := range resource {
+		if _, seen := byKey[a.Key]; !seen {
+			keys = append(keys, a.Key)
+		}
+		byKey[a.Key] = a
+	}
+	var resourceAttrs []Attr
+	for _, key := range keys {
+		resourceAttrs = append(resourceAttrs, byKey[key])
+	}
+
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "org.xyzmaps.xyzduck", Version: version.GetVersion()}}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        encodeAttrs(s.Attrs),
+		}
+		if s.ParentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Err != nil {
+			span.Status = &otlpStatus{Code: 2, Message: s.Err.Error()}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttrs(resourceAttrs)},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+// encodeAttrs converts attributes to OTLP key-values
+func encodeAttrs(attrs []Attr) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for _, a := range attrs {
+		var value map[string]any
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: a.Key, Value: value})
+	}
+	return kvs
+}
+
+// WriteTimings writes the command spans kept with Config.Timings as a tree,
+// each with its duration
+func WriteTimings(w io.Writer) error {
+	t := current
+	t.mu.Lock()
+	spans := append([]*Span(nil), t.kept...)
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	ids := make(map[[8]byte]bool)
+	children := make(map[[8]byte][]*Span)
+	for _, s := range spans {
+		ids[s.SpanID] = true
+	}
+	var roots []*Span
+	for _, s := range spans {
+		if ids[s.ParentID] {
+			children[s.ParentID] = append(children[s.ParentID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Timings:\n")
+	var write func(s *Span, depth int)
+	write = func(s *Span, depth int) {
+		label := strings.Repeat("  ", depth) + s.Name
+		status := ""
+		if s.Err != nil {
+			status = "  (failed)"
+		}
+		fmt.Fprintf(&sb, "  %-48s %10s%s\n", label, s.Duration().Round(time.Millisecond), status)
+		kids := children[s.SpanID]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].Start.Before(kids[j].Start) })
+		for _, child := range kids {
+			write(child, depth+1)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Start.Before(roots[j].Start) })
+	for _, root := range roots {
+		write(root, 0)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,249 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind says what a span stands for
+type Kind int
+
+const (
+	// KindInternal is a step of a command
+	KindInternal Kind = 1
+	// KindServer is a request served over HTTP
+	KindServer Kind = 2
+)
+
+// Attr is a key and a string, int64, float64 or bool value recorded on a span
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String, Int64, Float64 and Bool make attributes of their type
+func String(key, value string) Attr          { return Attr{key, value} }
+func Int64(key string, value int64) Attr     { return Attr{key, value} }
+func Float64(key string, value float64) Attr { return Attr{key, value} }
+func Bool(key string, value bool) Attr       { return Attr{key, value} }
+
+// Span times one operation. A nil *Span, returned while tracing is off,
+// records nothing.
+type Span struct {
+	Name     string
+	Kind     Kind
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	// Err is set when the operation failed
+	Err error
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// SetName renames the span, e.g. once the command being run is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Name = name
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attrs = append(s.Attrs, attrs...)
+}
+
+// RecordError marks the span as failed when err is not nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Err = err
+}
+
+// Finish ends the span and hands it to the exporter and the timings report.
+// Later calls do nothing.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+	current.finished(s)
+}
+
+// Duration is how long the span took
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// spanKey keys the current span in a context
+type spanKey struct{}
+
+// parentKey keys a remote parent in a context, e.g. from a traceparent header
+type parentKey struct{}
+
+// parent is a span in another process
+type parent struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// Start starts a span, a child of the span in ctx if there is one, and
+// returns a context carrying it. It returns a nil span while tracing is off.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	if !current.enabled() {
+		return ctx, nil
+	}
+	s := &Span{Name: name, Kind: kind, Start: time.Now(), Attrs: attrs}
+	switch p := ctx.Value(spanKey{}).(type) {
+	case *Span:
+		s.TraceID, s.ParentID = p.TraceID, p.SpanID
+	default:
+		if remote, ok := ctx.Value(parentKey{}).(parent); ok {
+			s.TraceID, s.ParentID = remote.traceID, remote.spanID
+		} else {
+			rand.Read(s.TraceID[:])
+		}
+	}
+	rand.Read(s.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span in ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// WithTraceparent makes spans started from the returned context children of
+// the span a W3C traceparent header names, e.g. from a calling pipeline. An
+// empty or malformed header leaves ctx as it is.
+func WithTraceparent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var p parent
+	if _, err := hex.Decode(p.traceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(p.spanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	if p.traceID == [16]byte{} || p.spanID == [8]byte{} {
+		return ctx
+	}
+	return context.WithValue(ctx, parentKey{}, p)
+}
+
+// Traceparent formats the span as a W3C traceparent header, to pass the trace
+// on to another process
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]))
+}
+
+// Config says where finished spans go
+type Config struct {
+	// Endpoint is the OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces;
+	// empty means spans aren't exported
+	Endpoint string
+	// Headers are sent with every export, e.g. for authentication
+	Headers map[string]string
+	// Resource describes this process: service.name, service.version, ...
+	Resource []Attr
+	// Timings keeps the spans of commands, but not of requests, for
+	// WriteTimings
+	Timings bool
+}
+
+// ConfigFromEnv reads the standard OpenTelemetry environment variables:
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT, the
+// matching _HEADERS, OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_SDK_DISABLED. Only the http/json protocol is supported.
+func ConfigFromEnv(serviceVersion string) (Config, error) {
+	c := Config{
+		Resource: []Attr{String("service.name", "xyzduck"), String("service.version", serviceVersion)},
+	}
+	if disabled := os.Getenv("OTEL_SDK_DISABLED"); strings.EqualFold(disabled, "true") {
+		return c, nil
+	}
+
+	for key, value := range parseList(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		c.Resource = append(c.Resource, String(key, value))
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		c.Resource = append(c.Resource, String("service.name", name))
+	}
+
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		c.Endpoint = endpoint
+	} else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		c.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	if c.Endpoint == "" {
+		return c, nil
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/json" {
+		return Config{Resource: c.Resource}, fmt.Errorf("unsupported OTLP protocol %q (only http/json is supported)", protocol)
+	}
+
+	c.Headers = parseList(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for key, value := range parseList(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")) {
+		c.Headers[key] = value
+	}
+	return c, nil
+}
+
+// parseList parses a key1=value1,key2=value2 list as used by the OTEL_*
+// variables, with percent-encoded values
+func parseList(s string) map[string]string {
+	values := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		key, value, found := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		values[key] = value
+	}
+	return values
+}
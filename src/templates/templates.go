@@ -0,0 +1,148 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/download"
+)
+
+const naturalEarthBase = "https://raw.githubusercontent.com/nvkelso/natural-earth-vector/master/geojson/"
+
+// Layer is a single seed table created by a template
+type Layer struct {
+	Table       string
+	Description string
+	// URL points at a remote GeoJSON file; leave empty for generated layers
+	URL string
+	// Generate builds the layer locally when no URL is set
+	Generate func() ([]byte, error)
+}
+
+// Template is a named set of seed layers loaded by `init --template`
+type Template struct {
+	Name        string
+	Description string
+	Layers      []Layer
+}
+
+var graticuleLayer = Layer{
+	Table:       "graticule",
+	Description: "10° graticule lines",
+	Generate:    func() ([]byte, error) { return generateGraticule(10) },
+}
+
+var registry = map[string]Template{
+	"world-admin": {
+		Name:        "world-admin",
+		Description: "Country and first-level admin boundaries, time zones and a graticule (Natural Earth)",
+		Layers: []Layer{
+			{Table: "countries", Description: "Admin-0 countries (1:110m)", URL: naturalEarthBase + "ne_110m_admin_0_countries.geojson"},
+			{Table: "admin1", Description: "Admin-1 states and provinces (1:50m)", URL: naturalEarthBase + "ne_50m_admin_1_states_provinces.geojson"},
+			{Table: "timezones", Description: "Time zones (1:10m)", URL: naturalEarthBase + "ne_10m_time_zones.geojson"},
+			graticuleLayer,
+		},
+	},
+	"graticule": {
+		Name:        "graticule",
+		Description: "A 10° graticule generated locally (no download needed)",
+		Layers:      []Layer{graticuleLayer},
+	},
+}
+
+// Get returns the template with the given name
+func Get(name string) (Template, error) {
+	t, ok := registry[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Template{}, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return t, nil
+}
+
+// Names returns the available template names in sorted order
+func Names() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Remote reports whether the layer has to be downloaded
+func (l Layer) Remote() bool {
+	return l.URL != ""
+}
+
+// Fetch returns the path of a GeoJSON file for the layer. Remote layers are
+// downloaded into the shared download cache; generated layers are written to a
+// temporary file. Call cleanup once the file has been loaded.
+func (l Layer) Fetch() (path string, cleanup func(), err error) {
+	if l.Remote() {
+		path, err := download.Fetch(l.URL, download.Options{Retries: 3})
+		if err != nil {
+			return "", nil, err
+		}
+		return path, func() {}, nil
+	}
+
+	data, err := l.Generate()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate %s: %w", l.Table, err)
+	}
+
+	tmp, err := os.CreateTemp("", "xyzduck-"+l.Table+"-*.geojson")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		os.Remove(tmp.Name())
+		return "", nil, fmt.Errorf("failed to write %s: %w", l.Table, err)
+	}
+
+	return tmp.Name(), func() { os.Remove(tmp.Name()) }, nil
+}
+
+// generateGraticule builds meridians and parallels every step degrees as GeoJSON
+func generateGraticule(step int) ([]byte, error) {
+	type feature struct {
+		Type       string                 `json:"type"`
+		Geometry   map[string]interface{} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	var features []feature
+	line := func(kind string, degrees int, coords [][2]float64) {
+		features = append(features, feature{
+			Type:       "Feature",
+			Geometry:   map[string]interface{}{"type": "LineString", "coordinates": coords},
+			Properties: map[string]interface{}{"kind": kind, "degrees": degrees},
+		})
+	}
+
+	for lon := -180; lon <= 180; lon += step {
+		var coords [][2]float64
+		for lat := -90; lat <= 90; lat++ {
+			coords = append(coords, [2]float64{float64(lon), float64(lat)})
+		}
+		line("meridian", lon, coords)
+	}
+
+	for lat := -90 + step; lat < 90; lat += step {
+		var coords [][2]float64
+		for lon := -180; lon <= 180; lon++ {
+			coords = append(coords, [2]float64{float64(lon), float64(lat)})
+		}
+		line("parallel", lat, coords)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
@@ -0,0 +1,224 @@
+package thumbnail
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// CatalogTable holds the metadata and thumbnails of each table
+const CatalogTable = "xyzduck_catalog"
+
+// Options controls how thumbnails are drawn
+type Options struct {
+	// Size is the longer side of a thumbnail in pixels
+	Size int
+	// MaxFeatures caps the features drawn; larger tables are sampled
+	MaxFeatures int
+}
+
+// Entry is the catalog record of one table
+type Entry struct {
+	Table          string     `json:"table"`
+	GeometryColumn string     `json:"geometry_column"`
+	Rows           int64      `json:"rows"`
+	GeometryTypes  []string   `json:"geometry_types"`
+	BBox           [4]float64 `json:"bbox"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	// Thumbnail and BBoxPreview are PNG images, empty when the table has no
+	// geometries
+	Thumbnail   []byte `json:"-"`
+	BBoxPreview []byte `json:"-"`
+	// ThumbnailFile and BBoxPreviewFile name the images written by Export
+	ThumbnailFile   string `json:"thumbnail,omitempty"`
+	BBoxPreviewFile string `json:"bbox_preview,omitempty"`
+}
+
+// Build gathers the metadata of a table and draws its thumbnail and bbox
+// preview. db must have the spatial extension loaded.
+func Build(db *sql.DB, table, geomColumn string, opts Options) (Entry, error) {
+	entry := Entry{Table: table, GeometryColumn: geomColumn, UpdatedAt: time.Now().UTC()}
+	q := database.QuoteIdentifier(geomColumn)
+	from := database.QuoteTableName(table)
+
+	var xmin, ymin, xmax, ymax sql.NullFloat64
+	statsSQL := fmt.Sprintf("SELECT count(*), MIN(ST_XMin(%s)), MIN(ST_YMin(%s)), MAX(ST_XMax(%s)), MAX(ST_YMax(%s)) FROM %s", q, q, q, q, from)
+	if err := db.QueryRow(statsSQL).Scan(&entry.Rows, &xmin, &ymin, &xmax, &ymax); err != nil {
+		return entry, fmt.Errorf("failed to read extent of %s: %w", table, err)
+	}
+	if !xmin.Valid {
+		return entry, nil
+	}
+	entry.BBox = [4]float64{xmin.Float64, ymin.Float64, xmax.Float64, ymax.Float64}
+
+	typesSQL := fmt.Sprintf("SELECT DISTINCT ST_GeometryType(%s)::VARCHAR FROM %s WHERE %s IS NOT NULL ORDER BY 1", q, from, q)
+	rows, err := db.Query(typesSQL)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read geometry types of %s: %w", table, err)
+	}
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return entry, fmt.Errorf("failed to read geometry types of %s: %w", table, err)
+		}
+		entry.GeometryTypes = append(entry.GeometryTypes, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return entry, fmt.Errorf("failed to read geometry types of %s: %w", table, err)
+	}
+
+	canvas := NewCanvas(entry.BBox, opts.Size)
+
+	// Detail finer than a pixel is invisible, so simplify to about half of one.
+	// Cast to text: the driver would decode the JSON value into a map.
+	tolerance := math.Max(entry.BBox[2]-entry.BBox[0], entry.BBox[3]-entry.BBox[1]) / float64(opts.Size) / 2
+	drawSQL := fmt.Sprintf(`SELECT ST_AsGeoJSON(ST_Simplify(g, %g))::VARCHAR
+FROM (SELECT %s AS g FROM %s WHERE %s IS NOT NULL)
+USING SAMPLE reservoir(%d ROWS) REPEATABLE (42)`, tolerance, q, from, q, opts.MaxFeatures)
+	rows, err = db.Query(drawSQL)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read geometries of %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var geometry sql.NullString
+		if err := rows.Scan(&geometry); err != nil {
+			return entry, fmt.Errorf("failed to read geometries of %s: %w", table, err)
+		}
+		if !geometry.Valid {
+			continue
+		}
+		if err := canvas.DrawGeoJSON([]byte(geometry.String)); err != nil {
+			return entry, fmt.Errorf("failed to draw %s: %w", table, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return entry, fmt.Errorf("failed to read geometries of %s: %w", table, err)
+	}
+
+	if entry.Thumbnail, err = canvas.PNG(); err != nil {
+		return entry, err
+	}
+	if entry.BBoxPreview, err = BBoxPreview(entry.BBox, opts.Size); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// Store writes entries to the catalog table, replacing earlier entries for
+// the same tables
+func Store(db *sql.DB, entries []Entry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	table_name VARCHAR PRIMARY KEY,
+	geometry_column VARCHAR,
+	row_count BIGINT,
+	geometry_types VARCHAR[],
+	xmin DOUBLE,
+	ymin DOUBLE,
+	xmax DOUBLE,
+	ymax DOUBLE,
+	thumbnail BLOB,
+	bbox_preview BLOB,
+	updated_at TIMESTAMP
+)`, database.QuoteIdentifier(CatalogTable))
+	if _, err := tx.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create catalog table: %w", err)
+	}
+
+	insertSQL := fmt.Sprintf("INSERT OR REPLACE INTO %s VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", database.QuoteIdentifier(CatalogTable))
+	for _, e := range entries {
+		var bbox [4]any
+		if e.Thumbnail != nil {
+			for i, v := range e.BBox {
+				bbox[i] = v
+			}
+		}
+		if _, err := tx.Exec(insertSQL, e.Table, e.GeometryColumn, e.Rows, e.GeometryTypes,
+			bbox[0], bbox[1], bbox[2], bbox[3], e.Thumbnail, e.BBoxPreview, e.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to store catalog entry for %s: %w", e.Table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit catalog: %w", err)
+	}
+	return nil
+}
+
+// Prune removes catalog entries for tables that are not in keep
+func Prune(db *sql.DB, keep []string) (int64, error) {
+	exists, err := catalogExists(db)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	deleteSQL := "DELETE FROM " + database.QuoteIdentifier(CatalogTable)
+	args := make([]any, len(keep))
+	if len(keep) > 0 {
+		for i, t := range keep {
+			args[i] = t
+		}
+		deleteSQL += " WHERE table_name NOT IN (" + strings.Repeat("?, ", len(keep)-1) + "?)"
+	}
+	res, err := db.Exec(deleteSQL, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune catalog: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func catalogExists(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT count(*) FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = 'main' AND table_name = ?", CatalogTable).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up catalog table: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Export writes each entry's images to dir as <table>.png and
+// <table>.bbox.png, and the metadata of all entries to catalog.json
+func Export(dir string, entries []Entry) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		if e.Thumbnail == nil {
+			continue
+		}
+		e.ThumbnailFile = e.Table + ".png"
+		e.BBoxPreviewFile = e.Table + ".bbox.png"
+		if err := os.WriteFile(filepath.Join(dir, e.ThumbnailFile), e.Thumbnail, 0o644); err != nil {
+			return fmt.Errorf("failed to write thumbnail: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.BBoxPreviewFile), e.BBoxPreview, 0o644); err != nil {
+			return fmt.Errorf("failed to write bbox preview: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"tables": entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "catalog.json"), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	fillColor      = color.RGBA{R: 0x3b, G: 0x82, B: 0xf6, A: 0x60}
+	strokeColor    = color.RGBA{R: 0x1d, G: 0x4e, B: 0xd8, A: 0xff}
+	landColor      = color.RGBA{R: 0xf1, G: 0xf5, B: 0xf9, A: 0xff}
+	graticuleColor = color.RGBA{R: 0xcb, G: 0xd5, B: 0xe1, A: 0xff}
+)
+
+// Style sets the colours geometries are drawn in
+type Style struct {
+	// Fill is the colour inside polygons, usually translucent
+	Fill color.RGBA
+	// Stroke is the colour of lines, outlines and points
+	Stroke color.RGBA
+}
+
+// DefaultStyle draws in translucent blue with a darker outline
+var DefaultStyle = Style{Fill: fillColor, Stroke: strokeColor}
+
+// ParseColor parses a hex colour: #rrggbb, or #rrggbbaa with an alpha
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q (expected #rrggbb or #rrggbbaa)", s)
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// Canvas draws geometries into an image, scaled to fit a bounding box
+type Canvas struct {
+	img    *image.RGBA
+	style  Style
+	bbox   [4]float64
+	scaleX float64
+	scaleY float64
+	offX   float64
+	offY   float64
+	// position, when set, replaces the bounding box: it maps lon/lat to
+	// image space scaled to [0, 1]
+	position func(lon, lat float64) (float64, float64)
+}
+
+// NewCanvas returns a transparent canvas whose longer side is size pixels,
+// shaped like bbox (minx, miny, maxx, maxy) in an equirectangular projection
+// corrected for latitude
+func NewCanvas(bbox [4]float64, size int) *Canvas {
+	// Pad degenerate extents, e.g. a single point, so there is something to scale
+	w, h := bbox[2]-bbox[0], bbox[3]-bbox[1]
+	pad := math.Max(math.Max(w, h)*0.05, 1e-6)
+	bbox = [4]float64{bbox[0] - pad, bbox[1] - pad, bbox[2] + pad, bbox[3] + pad}
+	w, h = bbox[2]-bbox[0], bbox[3]-bbox[1]
+
+	// Shrink longitudes by the cosine of the middle latitude so shapes keep
+	// their proportions away from the equator
+	kx := math.Max(math.Cos((bbox[1]+bbox[3])/2*math.Pi/180), 0.1)
+	scale := float64(size) / math.Max(w*kx, h)
+	width := max(int(math.Round(w*kx*scale)), 1)
+	height := max(int(math.Round(h*scale)), 1)
+
+	return &Canvas{
+		img:    image.NewRGBA(image.Rect(0, 0, width, height)),
+		style:  DefaultStyle,
+		bbox:   bbox,
+		scaleX: scale * kx,
+		scaleY: scale,
+		offX:   (float64(width) - w*kx*scale) / 2,
+		offY:   (float64(height) - h*scale) / 2,
+	}
+}
+
+// NewTileCanvas returns a transparent size by size canvas for a map tile.
+// position maps lon/lat to where it falls in the tile, from (0, 0) at the
+// top-left corner to (1, 1) at the bottom-right.
+func NewTileCanvas(size int, style Style, position func(lon, lat float64) (float64, float64)) *Canvas {
+	return &Canvas{
+		img:      image.NewRGBA(image.Rect(0, 0, size, size)),
+		style:    style,
+		position: position,
+	}
+}
+
+// SetStyle changes the colours of the geometries drawn next
+func (c *Canvas) SetStyle(style Style) {
+	c.style = style
+}
+
+// pixel converts a coordinate to image space, y pointing down
+func (c *Canvas) pixel(p []float64) (float64, float64) {
+	if c.position != nil {
+		x, y := c.position(p[0], p[1])
+		size := c.img.Bounds().Size()
+		return x * float64(size.X), y * float64(size.Y)
+	}
+	return c.offX + (p[0]-c.bbox[0])*c.scaleX, c.offY + (c.bbox[3]-p[1])*c.scaleY
+}
+
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []geoJSONGeometry `json:"geometries"`
+}
+
+// DrawGeoJSON draws a GeoJSON geometry: polygons filled and outlined, lines
+// stroked and points as dots
+func (c *Canvas) DrawGeoJSON(geometry []byte) error {
+	var g geoJSONGeometry
+	if err := json.Unmarshal(geometry, &g); err != nil {
+		return fmt.Errorf("invalid GeoJSON geometry: %w", err)
+	}
+	return c.draw(g)
+}
+
+func (c *Canvas) draw(g geoJSONGeometry) error {
+	switch g.Type {
+	case "GeometryCollection":
+		for _, member := range g.Geometries {
+			if err := c.draw(member); err != nil {
+				return err
+			}
+		}
+	case "Point":
+		var p []float64
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return fmt.Errorf("invalid Point: %w", err)
+		}
+		c.dot(p)
+	case "MultiPoint":
+		var points [][]float64
+		if err := json.Unmarshal(g.Coordinates, &points); err != nil {
+			return fmt.Errorf("invalid MultiPoint: %w", err)
+		}
+		for _, p := range points {
+			c.dot(p)
+		}
+	case "LineString":
+		var line [][]float64
+		if err := json.Unmarshal(g.Coordinates, &line); err != nil {
+			return fmt.Errorf("invalid LineString: %w", err)
+		}
+		c.stroke(line)
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+			return fmt.Errorf("invalid MultiLineString: %w", err)
+		}
+		for _, line := range lines {
+			c.stroke(line)
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return fmt.Errorf("invalid Polygon: %w", err)
+		}
+		c.polygon(rings)
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return fmt.Errorf("invalid MultiPolygon: %w", err)
+		}
+		for _, rings := range polygons {
+			c.polygon(rings)
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+	return nil
+}
+
+// dot draws a point as a small square
+func (c *Canvas) dot(p []float64) {
+	if len(p) < 2 {
+		return
+	}
+	x, y := c.pixel(p)
+	px, py := int(x), int(y)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			c.blend(px+dx, py+dy, c.style.Stroke)
+		}
+	}
+}
+
+// stroke draws a line through the points
+func (c *Canvas) stroke(line [][]float64) {
+	for i := 1; i < len(line); i++ {
+		if len(line[i-1]) < 2 || len(line[i]) < 2 {
+			continue
+		}
+		x0, y0 := c.pixel(line[i-1])
+		x1, y1 := c.pixel(line[i])
+		c.line(x0, y0, x1, y1, c.style.Stroke)
+	}
+}
+
+// polygon fills the rings with the even-odd rule, so holes stay empty, and
+// outlines them
+func (c *Canvas) polygon(rings [][][]float64) {
+	type edge struct{ x0, y0, x1, y1 float64 }
+	var edges []edge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, ring := range rings {
+		for i := 1; i < len(ring); i++ {
+			if len(ring[i-1]) < 2 || len(ring[i]) < 2 {
+				continue
+			}
+			x0, y0 := c.pixel(ring[i-1])
+			x1, y1 := c.pixel(ring[i])
+			edges = append(edges, edge{x0, y0, x1, y1})
+			minY, maxY = math.Min(minY, math.Min(y0, y1)), math.Max(maxY, math.Max(y0, y1))
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+
+	// Sample each pixel row at its centre
+	bounds := c.img.Bounds()
+	var xs []float64
+	for py := max(int(minY), bounds.Min.Y); py <= min(int(maxY), bounds.Max.Y-1); py++ {
+		y := float64(py) + 0.5
+		xs = xs[:0]
+		for _, e := range edges {
+			if (e.y0 <= y) != (e.y1 <= y) {
+				xs = append(xs, e.x0+(y-e.y0)/(e.y1-e.y0)*(e.x1-e.x0))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			for px := max(int(math.Round(xs[i])), bounds.Min.X); px < min(int(math.Round(xs[i+1])), bounds.Max.X); px++ {
+				c.blend(px, py, c.style.Fill)
+			}
+		}
+	}
+
+	for _, e := range edges {
+		c.line(e.x0, e.y0, e.x1, e.y1, c.style.Stroke)
+	}
+}
+
+// line draws a one pixel wide line with Bresenham's algorithm
+func (c *Canvas) line(fx0, fy0, fx1, fy1 float64, col color.RGBA) {
+	x0, y0, x1, y1 := int(fx0), int(fy0), int(fx1), int(fy1)
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		c.set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// set paints a pixel, ignoring coordinates outside the image
+func (c *Canvas) set(x, y int, col color.RGBA) {
+	if image.Pt(x, y).In(c.img.Bounds()) {
+		c.img.SetRGBA(x, y, col)
+	}
+}
+
+// blend paints a translucent colour over a pixel
+func (c *Canvas) blend(x, y int, col color.RGBA) {
+	if !image.Pt(x, y).In(c.img.Bounds()) {
+		return
+	}
+	dst := c.img.RGBAAt(x, y)
+	a := uint32(col.A)
+	mix := func(s, d uint8) uint8 {
+		return uint8((uint32(s)*a + uint32(d)*(255-a)) / 255)
+	}
+	c.img.SetRGBA(x, y, color.RGBA{
+		R: mix(col.R, dst.R),
+		G: mix(col.G, dst.G),
+		B: mix(col.B, dst.B),
+		A: uint8(a + uint32(dst.A)*(255-a)/255),
+	})
+}
+
+// PNG encodes the image
+func (c *Canvas) PNG() ([]byte, error) {
+	return encodePNG(c.img)
+}
+
+// BBoxPreview renders the whole world, width pixels wide, with a 30° graticule
+// and bbox marked on it, so the location of a dataset is visible at a glance
+func BBoxPreview(bbox [4]float64, width int) ([]byte, error) {
+	height := max(width/2, 1)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	c := &Canvas{
+		img:    img,
+		bbox:   [4]float64{-180, -90, 180, 90},
+		scaleX: float64(width) / 360,
+		scaleY: float64(height) / 180,
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, landColor)
+		}
+	}
+	for lon := -150.0; lon < 180; lon += 30 {
+		x, _ := c.pixel([]float64{lon, 0})
+		c.line(x, 0, x, float64(height-1), graticuleColor)
+	}
+	for lat := -60.0; lat < 90; lat += 30 {
+		_, y := c.pixel([]float64{0, lat})
+		c.line(0, y, float64(width-1), y, graticuleColor)
+	}
+
+	// Keep tiny extents at least a few pixels across
+	x0, y0 := c.pixel([]float64{math.Max(bbox[0], -180), math.Min(bbox[3], 90)})
+	x1, y1 := c.pixel([]float64{math.Min(bbox[2], 180), math.Max(bbox[1], -90)})
+	if x1-x0 < 4 {
+		mid := (x0 + x1) / 2
+		x0, x1 = mid-2, mid+2
+	}
+	if y1-y0 < 4 {
+		mid := (y0 + y1) / 2
+		y0, y1 = mid-2, mid+2
+	}
+	for py := int(y0); py <= int(y1); py++ {
+		for px := int(x0); px <= int(x1); px++ {
+			c.blend(px, py, fillColor)
+		}
+	}
+	c.line(x0, y0, x1, y0, strokeColor)
+	c.line(x1, y0, x1, y1, strokeColor)
+	c.line(x1, y1, x0, y1, strokeColor)
+	c.line(x0, y1, x0, y0, strokeColor)
+
+	return encodePNG(img)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
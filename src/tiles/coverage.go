@@ -0,0 +1,165 @@
+package tiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// earthRadius is the WGS84 semi-major axis in kilometres, for tile areas
+const earthRadius = 6378.137
+
+// TileCount is a tile holding data and how much
+type TileCount struct {
+	Tile
+	Features int64
+}
+
+// Area returns the tile's area on the sphere in square kilometres
+func (t Tile) Area() float64 {
+	west, south, east, north := t.Bounds()
+	rad := math.Pi / 180
+	return earthRadius * earthRadius * (east - west) * rad * (math.Sin(north*rad) - math.Sin(south*rad))
+}
+
+// Density returns the tile's features per square kilometre
+func (c TileCount) Density() float64 {
+	return float64(c.Features) / c.Area()
+}
+
+// Coverage is which tiles of one zoom level hold data
+type Coverage struct {
+	Zoom int
+	// Tiles are the tiles with at least one feature, ordered by x then y
+	Tiles []TileCount
+	// Extent is the number of tiles covering the data's bounding box; those
+	// not in Tiles are gaps
+	Extent int64
+	// Bounds is the lon/lat extent of the data: west, south, east, north
+	Bounds [4]float64
+}
+
+// Coverage counts the features in each tile at zoom z. Features spanning
+// several tiles count in each tile their geometry intersects.
+func (s *Source) Coverage(z int) (Coverage, error) {
+	cov := Coverage{Zoom: z, Bounds: s.Metadata.Bounds}
+	if z < 0 || z > 24 {
+		return cov, fmt.Errorf("invalid zoom %d (zoom levels go from 0 to 24)", z)
+	}
+	if s.Empty() {
+		return cov, nil
+	}
+	minX, maxY := tileXY(cov.Bounds[0], cov.Bounds[1], z)
+	maxX, minY := tileXY(cov.Bounds[2], cov.Bounds[3], z)
+	cov.Extent = int64(maxX-minX+1) * int64(maxY-minY+1)
+
+	// Each feature's bounding box gives the range of tiles it may touch;
+	// only features reaching past one tile are checked against the tiles
+	q := database.QuoteIdentifier(s.geomColumn)
+	n := 1 << z
+	tileX := func(lon string) string {
+		return fmt.Sprintf("LEAST(GREATEST(FLOOR((%s + 180) / 360 * %d)::INTEGER, 0), %d)", lon, n, n-1)
+	}
+	tileY := func(lat string) string {
+		rad := fmt.Sprintf("RADIANS(LEAST(GREATEST(%s, %v), %v))", lat, -maxLat, maxLat)
+		return fmt.Sprintf("LEAST(GREATEST(FLOOR((1 - LN(TAN(%s) + 1 / COS(%s)) / PI()) / 2 * %d)::INTEGER, 0), %d)", rad, rad, n, n-1)
+	}
+	tileLat := func(y string) string {
+		a := fmt.Sprintf("PI() * (1 - 2 * %s / %d)", y, n)
+		return fmt.Sprintf("DEGREES(ATAN((EXP(%s) - EXP(-%s)) / 2))", a, a)
+	}
+	query := fmt.Sprintf(`
+		WITH ranges AS (
+			SELECT %[1]s AS geom, %[2]s AS x0, %[3]s AS x1, %[4]s AS y0, %[5]s AS y1
+			FROM %[6]s WHERE %[1]s IS NOT NULL AND NOT ST_IsEmpty(%[1]s)
+		), cells AS (
+			SELECT geom, x, y, x0 <> x1 OR y0 <> y1 AS spans
+			FROM ranges, range(x0, x1 + 1) AS rx(x), range(y0, y1 + 1) AS ry(y)
+		)
+		SELECT x, y, COUNT(*) FROM cells
+		WHERE NOT spans OR ST_Intersects(geom, ST_MakeEnvelope(x / %[7]d * 360 - 180, %[8]s, (x + 1) / %[7]d * 360 - 180, %[9]s))
+		GROUP BY x, y ORDER BY x, y`,
+		q, tileX("ST_XMin("+q+")"), tileX("ST_XMax("+q+")"), tileY("ST_YMax("+q+")"), tileY("ST_YMin("+q+")"),
+		s.from, n, tileLat("(y + 1)"), tileLat("y"))
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return cov, fmt.Errorf("failed to compute coverage: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := TileCount{Tile: Tile{Z: z}}
+		if err := rows.Scan(&c.X, &c.Y, &c.Features); err != nil {
+			return cov, fmt.Errorf("failed to scan tile: %w", err)
+		}
+		cov.Tiles = append(cov.Tiles, c)
+	}
+	if err := rows.Err(); err != nil {
+		return cov, fmt.Errorf("error iterating tiles: %w", err)
+	}
+	return cov, nil
+}
+
+// Densest returns the n tiles with the most features, densest first
+func (c Coverage) Densest(n int) []TileCount {
+	sorted := append([]TileCount(nil), c.Tiles...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Features > sorted[j].Features })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// Gaps returns the tiles within the data's extent that hold no data
+func (c Coverage) Gaps() []Tile {
+	if c.Extent == 0 {
+		return nil
+	}
+	filled := make(map[Tile]bool, len(c.Tiles))
+	for _, t := range c.Tiles {
+		filled[t.Tile] = true
+	}
+	var gaps []Tile
+	for _, t := range Covering(c.Bounds, c.Zoom) {
+		if !filled[t] {
+			gaps = append(gaps, t)
+		}
+	}
+	return gaps
+}
+
+// WriteGeoJSON writes the tiles as a FeatureCollection of tile polygons with
+// their z/x/y, feature count and density; gaps, when given, are added with a
+// count of 0
+func (c Coverage) WriteGeoJSON(w io.Writer, gaps []Tile) error {
+	features := make([]map[string]any, 0, len(c.Tiles)+len(gaps))
+	add := func(t TileCount) {
+		west, south, east, north := t.Bounds()
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"geometry": map[string]any{
+				"type":        "Polygon",
+				"coordinates": [][][2]float64{{{west, south}, {east, south}, {east, north}, {west, north}, {west, south}}},
+			},
+			"properties": map[string]any{
+				"tile":     t.String(),
+				"z":        t.Z,
+				"x":        t.X,
+				"y":        t.Y,
+				"features": t.Features,
+				"density":  math.Round(t.Density()*1000) / 1000,
+			},
+		})
+	}
+	for _, t := range c.Tiles {
+		add(t)
+	}
+	for _, t := range gaps {
+		add(TileCount{Tile: t})
+	}
+	return json.NewEncoder(w).Encode(map[string]any{"type": "FeatureCollection", "features": features})
+}
@@ -0,0 +1,307 @@
+package tiles
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// buffer is how far, in tile coordinates, features are kept beyond the tile
+// edge so lines and polygon outlines don't show seams between tiles
+const buffer = 64
+
+// Options controls tile generation
+type Options struct {
+	MinZoom int
+	MaxZoom int
+	// Layer names the layer in each tile (default: the table name)
+	Layer string
+}
+
+// Metadata describes a generated tileset
+type Metadata struct {
+	Name    string
+	Layer   string
+	MinZoom int
+	MaxZoom int
+	// Bounds is the lon/lat extent of the data: west, south, east, north
+	Bounds [4]float64
+	// Fields maps attribute names to "String", "Number" or "Boolean"
+	Fields map[string]string
+}
+
+// Stats summarizes a generation run
+type Stats struct {
+	Tiles    int
+	Features int
+	Bytes    int64
+}
+
+// Source reads the features of one table for tiles
+type Source struct {
+	db    *sql.DB
+	table string
+	// from is what features are selected from: the table, or its live rows
+	// when it is in soft-delete mode
+	from       string
+	geomColumn string
+	columns    []database.Column
+	Metadata   Metadata
+}
+
+// NewSource opens a table for tiling: it finds the geometry column, the
+// attribute columns and the extent of the data. Close the source when done.
+func NewSource(dbPath, table, layer string) (*Source, error) {
+	columns, err := database.GetTableSchema(dbPath, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table not found: %s", table)
+	}
+
+	s := &Source{table: table}
+	fields := make(map[string]string)
+	for _, col := range columns {
+		upper := strings.ToUpper(col.Type)
+		switch {
+		case strings.HasPrefix(upper, "GEOMETRY"):
+			if s.geomColumn == "" {
+				s.geomColumn = col.Name
+			}
+		default:
+			s.columns = append(s.columns, col)
+			fields[col.Name] = fieldType(upper)
+		}
+	}
+	if s.geomColumn == "" {
+		return nil, fmt.Errorf("table %s has no GEOMETRY column", table)
+	}
+
+	if layer == "" {
+		_, layer = database.SplitTableName(table)
+	}
+	s.Metadata = Metadata{Name: table, Layer: layer, Fields: fields}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := database.LoadSpatial(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.db = db
+
+	// Rows deleted from a soft-delete table aren't tiled
+	from, soft, err := database.LiveRows(db, table)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.from = from
+	if soft {
+		var live []database.Column
+		for _, col := range s.columns {
+			if col.Name != database.SoftDeleteColumn {
+				live = append(live, col)
+			}
+		}
+		s.columns = live
+		delete(fields, database.SoftDeleteColumn)
+	}
+
+	q := database.QuoteIdentifier(s.geomColumn)
+	var w, south, e, n sql.NullFloat64
+	bboxSQL := fmt.Sprintf("SELECT MIN(ST_XMin(%s)), MIN(ST_YMin(%s)), MAX(ST_XMax(%s)), MAX(ST_YMax(%s)) FROM %s", q, q, q, q, s.from)
+	if err := db.QueryRow(bboxSQL).Scan(&w, &south, &e, &n); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to compute bounding box: %w", err)
+	}
+	if w.Valid {
+		s.Metadata.Bounds = [4]float64{w.Float64, south.Float64, e.Float64, n.Float64}
+	}
+	return s, nil
+}
+
+// Close closes the database connection
+func (s *Source) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the source's connection, so the table can be written to while
+// it is served without opening the database twice
+func (s *Source) DB() *sql.DB {
+	return s.db
+}
+
+// Empty reports whether the table has no geometries to tile
+func (s *Source) Empty() bool {
+	return s.Metadata.Bounds == [4]float64{}
+}
+
+// Tile encodes one tile as an MVT with a single layer. It returns nil when
+// no feature falls in the tile.
+func (s *Source) Tile(t Tile) ([]byte, int, error) {
+	selects := []string{s.clippedGeometry(t)}
+	for _, col := range s.columns {
+		selects = append(selects, database.QuoteIdentifier(col.Name))
+	}
+	query := s.tileQuery(t, selects)
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query tile %s: %w", t, err)
+	}
+	defer rows.Close()
+
+	layer := NewLayer(s.Metadata.Layer, t)
+	values := make([]any, len(selects))
+	ptrs := make([]any, len(selects))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan feature: %w", err)
+		}
+		geometry, ok := values[0].(string)
+		if !ok {
+			continue
+		}
+		props := make([]Property, len(s.columns))
+		for i, col := range s.columns {
+			props[i] = Property{Key: col.Name, Value: values[i+1]}
+		}
+		if err := layer.AddGeoJSON([]byte(geometry), props); err != nil {
+			return nil, 0, fmt.Errorf("tile %s: %w", t, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating features: %w", err)
+	}
+
+	return layer.Encode(), layer.Len(), nil
+}
+
+// TileGeometry is a geometry clipped to a tile with the feature's value for
+// colouring it
+type TileGeometry struct {
+	// GeoJSON is the geometry as GeoJSON
+	GeoJSON []byte
+	// Value is the feature's value column as a number, NULL when the value is
+	// missing or not numeric
+	Value sql.NullFloat64
+}
+
+// Geometries returns the geometries falling in a tile as GeoJSON, clipped to
+// the tile and its buffer, for drawing the tile as an image. valueColumn, if
+// not empty, is read as each geometry's Value.
+func (s *Source) Geometries(t Tile, valueColumn string) ([]TileGeometry, error) {
+	selects := []string{s.clippedGeometry(t), "NULL::DOUBLE"}
+	if valueColumn != "" {
+		selects[1] = fmt.Sprintf("TRY_CAST(%s AS DOUBLE)", database.QuoteIdentifier(valueColumn))
+	}
+	rows, err := s.db.Query(s.tileQuery(t, selects))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tile %s: %w", t, err)
+	}
+	defer rows.Close()
+
+	var geometries []TileGeometry
+	for rows.Next() {
+		var geometry sql.NullString
+		var value sql.NullFloat64
+		if err := rows.Scan(&geometry, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan feature: %w", err)
+		}
+		if geometry.Valid {
+			geometries = append(geometries, TileGeometry{GeoJSON: []byte(geometry.String), Value: value})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating features: %w", err)
+	}
+	return geometries, nil
+}
+
+// envelope is the tile's extent grown by the buffer, as SQL
+func envelope(t Tile) string {
+	west, south, east, north := t.Bounds()
+	bx := (east - west) * buffer / Extent
+	by := (north - south) * buffer / Extent
+	return fmt.Sprintf("ST_MakeEnvelope(%v, %v, %v, %v)", west-bx, south-by, east+bx, north+by)
+}
+
+// clippedGeometry selects the geometry cut to the tile's envelope and
+// simplified to the tile's resolution, as GeoJSON
+func (s *Source) clippedGeometry(t Tile) string {
+	west, _, east, _ := t.Bounds()
+	// One pixel; detail below it is invisible
+	tolerance := (east - west) / Extent
+	// Cast to text: the driver would decode the JSON value into a map
+	return fmt.Sprintf("ST_AsGeoJSON(ST_SimplifyPreserveTopology(ST_Intersection(%s, %s), %v))::VARCHAR",
+		database.QuoteIdentifier(s.geomColumn), envelope(t), tolerance)
+}
+
+// tileQuery selects from the features intersecting the tile's envelope
+func (s *Source) tileQuery(t Tile, selects []string) string {
+	return fmt.Sprintf("SELECT %s FROM %s WHERE ST_Intersects(%s, %s)",
+		strings.Join(selects, ", "), s.from, database.QuoteIdentifier(s.geomColumn), envelope(t))
+}
+
+// Generate writes every non-empty tile covering the table's data between the
+// zoom levels to sink
+func Generate(dbPath, table string, opts Options, sink Sink) (Stats, error) {
+	var stats Stats
+	if opts.MinZoom < 0 || opts.MaxZoom > 24 || opts.MinZoom > opts.MaxZoom {
+		return stats, fmt.Errorf("invalid zoom range %d-%d (zoom levels go from 0 to 24)", opts.MinZoom, opts.MaxZoom)
+	}
+
+	source, err := NewSource(dbPath, table, opts.Layer)
+	if err != nil {
+		return stats, err
+	}
+	defer source.Close()
+	meta := source.Metadata
+	meta.MinZoom, meta.MaxZoom = opts.MinZoom, opts.MaxZoom
+
+	if !source.Empty() {
+		for z := opts.MinZoom; z <= opts.MaxZoom; z++ {
+			for _, t := range Covering(meta.Bounds, z) {
+				data, features, err := source.Tile(t)
+				if err != nil {
+					return stats, err
+				}
+				if data == nil {
+					continue
+				}
+				if err := sink.Put(t, data); err != nil {
+					return stats, fmt.Errorf("failed to write tile %s: %w", t, err)
+				}
+				stats.Tiles++
+				stats.Features += features
+				stats.Bytes += int64(len(data))
+			}
+		}
+	}
+
+	if err := sink.Close(meta); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// fieldType maps a DuckDB column type to a TileJSON field type
+func fieldType(colType string) string {
+	switch {
+	case colType == "BOOLEAN":
+		return "Boolean"
+	case strings.Contains(colType, "INT"), colType == "DOUBLE", colType == "FLOAT", colType == "REAL", strings.HasPrefix(colType, "DECIMAL"):
+		return "Number"
+	default:
+		return "String"
+	}
+}
@@ -0,0 +1,396 @@
+package tiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Extent is the size of a tile in MVT integer coordinates
+const Extent = 4096
+
+// MVT geometry types and commands, from the Mapbox Vector Tile spec 2.1
+const (
+	mvtPoint      = 1
+	mvtLineString = 2
+	mvtPolygon    = 3
+
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// Layer collects the features of one MVT layer
+type Layer struct {
+	Name     string
+	tile     Tile
+	features [][]byte
+	keys     []string
+	keyIndex map[string]int
+	values   [][]byte
+	valIndex map[string]int
+}
+
+// NewLayer starts an empty layer for a tile
+func NewLayer(name string, t Tile) *Layer {
+	return &Layer{Name: name, tile: t, keyIndex: make(map[string]int), valIndex: make(map[string]int)}
+}
+
+// Len returns the number of features in the layer
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddGeoJSON adds a feature from a GeoJSON geometry in lon/lat and its
+// properties. Geometries that vanish at the tile's resolution are skipped; a
+// GeometryCollection adds one feature per member.
+func (l *Layer) AddGeoJSON(geometry []byte, props []Property) error {
+	var g geoJSONGeometry
+	if err := json.Unmarshal(geometry, &g); err != nil {
+		return fmt.Errorf("invalid geometry: %w", err)
+	}
+	return l.add(g, props)
+}
+
+// Property is a feature attribute
+type Property struct {
+	Key   string
+	Value any
+}
+
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []geoJSONGeometry `json:"geometries"`
+}
+
+func (l *Layer) add(g geoJSONGeometry, props []Property) error {
+	var geomType int
+	var commands []uint32
+	switch g.Type {
+	case "GeometryCollection":
+		for _, member := range g.Geometries {
+			if err := l.add(member, props); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Point", "MultiPoint":
+		var points [][]float64
+		if g.Type == "Point" {
+			var p []float64
+			if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+				return fmt.Errorf("invalid Point: %w", err)
+			}
+			points = [][]float64{p}
+		} else if err := json.Unmarshal(g.Coordinates, &points); err != nil {
+			return fmt.Errorf("invalid MultiPoint: %w", err)
+		}
+		geomType = mvtPoint
+		commands = l.encodePoints(points)
+	case "LineString", "MultiLineString":
+		var lines [][][]float64
+		if g.Type == "LineString" {
+			var line [][]float64
+			if err := json.Unmarshal(g.Coordinates, &line); err != nil {
+				return fmt.Errorf("invalid LineString: %w", err)
+			}
+			lines = [][][]float64{line}
+		} else if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+			return fmt.Errorf("invalid MultiLineString: %w", err)
+		}
+		geomType = mvtLineString
+		commands = l.encodeLines(lines)
+	case "Polygon", "MultiPolygon":
+		var polygons [][][][]float64
+		if g.Type == "Polygon" {
+			var rings [][][]float64
+			if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+				return fmt.Errorf("invalid Polygon: %w", err)
+			}
+			polygons = [][][][]float64{rings}
+		} else if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return fmt.Errorf("invalid MultiPolygon: %w", err)
+		}
+		geomType = mvtPolygon
+		commands = l.encodePolygons(polygons)
+	default:
+		return fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	var tags []uint32
+	for _, p := range props {
+		value, ok := encodeValue(p.Value)
+		if !ok {
+			continue
+		}
+		tags = append(tags, uint32(l.key(p.Key)), uint32(l.value(value)))
+	}
+
+	var f []byte
+	if len(tags) > 0 {
+		f = appendPacked(f, 2, tags)
+	}
+	f = appendVarintField(f, 3, uint64(geomType))
+	f = appendPacked(f, 4, commands)
+	l.features = append(l.features, f)
+	return nil
+}
+
+// Encode returns the layer as a vector tile holding just this layer, or nil
+// when it has no features
+func (l *Layer) Encode() []byte {
+	if len(l.features) == 0 {
+		return nil
+	}
+
+	var layer []byte
+	layer = appendVarintField(layer, 15, 2)
+	layer = appendBytesField(layer, 1, []byte(l.Name))
+	for _, f := range l.features {
+		layer = appendBytesField(layer, 2, f)
+	}
+	for _, k := range l.keys {
+		layer = appendBytesField(layer, 3, []byte(k))
+	}
+	for _, v := range l.values {
+		layer = appendBytesField(layer, 4, v)
+	}
+	layer = appendVarintField(layer, 5, Extent)
+
+	return appendBytesField(nil, 3, layer)
+}
+
+func (l *Layer) key(k string) int {
+	if i, ok := l.keyIndex[k]; ok {
+		return i
+	}
+	l.keys = append(l.keys, k)
+	l.keyIndex[k] = len(l.keys) - 1
+	return len(l.keys) - 1
+}
+
+func (l *Layer) value(v []byte) int {
+	if i, ok := l.valIndex[string(v)]; ok {
+		return i
+	}
+	l.values = append(l.values, v)
+	l.valIndex[string(v)] = len(l.values) - 1
+	return len(l.values) - 1
+}
+
+// encodeValue encodes a property as an MVT Value message
+func encodeValue(v any) ([]byte, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return appendBytesField(nil, 1, []byte(v)), true
+	case []byte:
+		return appendBytesField(nil, 1, v), true
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		return appendVarintField(nil, 7, b), true
+	case float32:
+		return appendDoubleField(nil, 3, float64(v)), true
+	case float64:
+		return appendDoubleField(nil, 3, v), true
+	case int8:
+		return appendVarintField(nil, 6, zigzag(int64(v))), true
+	case int16:
+		return appendVarintField(nil, 6, zigzag(int64(v))), true
+	case int32:
+		return appendVarintField(nil, 6, zigzag(int64(v))), true
+	case int64:
+		return appendVarintField(nil, 6, zigzag(v)), true
+	case int:
+		return appendVarintField(nil, 6, zigzag(int64(v))), true
+	case uint8:
+		return appendVarintField(nil, 5, uint64(v)), true
+	case uint16:
+		return appendVarintField(nil, 5, uint64(v)), true
+	case uint32:
+		return appendVarintField(nil, 5, uint64(v)), true
+	case uint64:
+		return appendVarintField(nil, 5, v), true
+	case time.Time:
+		return appendBytesField(nil, 1, []byte(v.Format(time.RFC3339))), true
+	default:
+		return appendBytesField(nil, 1, []byte(fmt.Sprint(v))), true
+	}
+}
+
+// point converts lon/lat to tile coordinates
+func (l *Layer) point(c []float64) [2]int64 {
+	x, y := l.tile.Position(c[0], c[1])
+	return [2]int64{int64(math.Round(x * Extent)), int64(math.Round(y * Extent))}
+}
+
+// path converts a line to tile coordinates, dropping repeated points
+func (l *Layer) path(coords [][]float64) [][2]int64 {
+	var out [][2]int64
+	for _, c := range coords {
+		if len(c) < 2 {
+			continue
+		}
+		p := l.point(c)
+		if len(out) > 0 && out[len(out)-1] == p {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (l *Layer) encodePoints(points [][]float64) []uint32 {
+	var pts [][2]int64
+	for _, c := range points {
+		if len(c) >= 2 {
+			pts = append(pts, l.point(c))
+		}
+	}
+	if len(pts) == 0 {
+		return nil
+	}
+	var cur [2]int64
+	commands := []uint32{command(cmdMoveTo, len(pts))}
+	for _, p := range pts {
+		commands = append(commands, delta(&cur, p)...)
+	}
+	return commands
+}
+
+func (l *Layer) encodeLines(lines [][][]float64) []uint32 {
+	var cur [2]int64
+	var commands []uint32
+	for _, line := range lines {
+		pts := l.path(line)
+		if len(pts) < 2 {
+			continue
+		}
+		commands = append(commands, command(cmdMoveTo, 1))
+		commands = append(commands, delta(&cur, pts[0])...)
+		commands = append(commands, command(cmdLineTo, len(pts)-1))
+		for _, p := range pts[1:] {
+			commands = append(commands, delta(&cur, p)...)
+		}
+	}
+	return commands
+}
+
+// encodePolygons writes exterior rings with positive area and interior rings
+// with negative area in tile coordinates (y down), as the spec requires
+func (l *Layer) encodePolygons(polygons [][][][]float64) []uint32 {
+	var cur [2]int64
+	var commands []uint32
+	for _, rings := range polygons {
+		for i, ring := range rings {
+			pts := l.path(ring)
+			if len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+				pts = pts[:len(pts)-1]
+			}
+			if len(pts) < 3 {
+				if i == 0 {
+					// The exterior collapsed, so the holes go with it
+					break
+				}
+				continue
+			}
+			area := ringArea(pts)
+			if area == 0 {
+				if i == 0 {
+					break
+				}
+				continue
+			}
+			if (i == 0) != (area > 0) {
+				reverse(pts)
+			}
+
+			commands = append(commands, command(cmdMoveTo, 1))
+			commands = append(commands, delta(&cur, pts[0])...)
+			commands = append(commands, command(cmdLineTo, len(pts)-1))
+			for _, p := range pts[1:] {
+				commands = append(commands, delta(&cur, p)...)
+			}
+			commands = append(commands, command(cmdClosePath, 1))
+		}
+	}
+	return commands
+}
+
+// ringArea returns twice the signed area of a ring
+func ringArea(pts [][2]int64) int64 {
+	var sum int64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		sum += pts[i][0]*pts[j][1] - pts[j][0]*pts[i][1]
+	}
+	return sum
+}
+
+func reverse(pts [][2]int64) {
+	for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+		pts[i], pts[j] = pts[j], pts[i]
+	}
+}
+
+func command(id, count int) uint32 {
+	return uint32(id&7) | uint32(count)<<3
+}
+
+// delta encodes p relative to the cursor and moves the cursor
+func delta(cur *[2]int64, p [2]int64) []uint32 {
+	dx, dy := p[0]-cur[0], p[1]-cur[1]
+	*cur = p
+	return []uint32{uint32(zigzag(dx)), uint32(zigzag(dy))}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+// Protocol buffer encoding helpers
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendVarint(b, uint64(field)<<3)
+	return appendVarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|2)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendDoubleField(b []byte, field int, v float64) []byte {
+	b = appendVarint(b, uint64(field)<<3|1)
+	bits := math.Float64bits(v)
+	for i := 0; i < 8; i++ {
+		b = append(b, byte(bits>>(8*i)))
+	}
+	return b
+}
+
+func appendPacked(b []byte, field int, values []uint32) []byte {
+	var packed []byte
+	for _, v := range values {
+		packed = appendVarint(packed, uint64(v))
+	}
+	return appendBytesField(b, field, packed)
+}
@@ -0,0 +1,302 @@
+package tiles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// PMTiles v3 layout constants, from the PMTiles specification
+const (
+	pmHeaderSize = 127
+	// The header and root directory must fit in the first 16 KiB so readers
+	// can fetch both with one request
+	pmRootLimit = 16384 - pmHeaderSize
+
+	pmCompressionGzip = 2
+	pmTileTypeMVT     = 1
+)
+
+// PMTilesSink writes tiles into a single PMTiles v3 archive. Tiles are
+// buffered in a temporary file and written in tile ID order on Close, with
+// identical tiles stored once.
+type PMTilesSink struct {
+	path    string
+	tmp     *os.File
+	size    int64
+	entries []pmEntry
+}
+
+// pmEntry is a directory entry. While tiles are buffered Offset points into
+// the temporary file; in the archive it is relative to the tile data (or,
+// for leaf directory entries, to the leaf directories).
+type pmEntry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint32
+	RunLength uint32
+	hash      [sha256.Size]byte
+}
+
+// NewPMTilesSink starts a PMTiles archive at path
+func NewPMTilesSink(path string) (*PMTilesSink, error) {
+	tmp, err := os.CreateTemp("", "xyzduck-pmtiles-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	return &PMTilesSink{path: path, tmp: tmp}, nil
+}
+
+// Put buffers a gzipped tile
+func (s *PMTilesSink) Put(t Tile, data []byte) error {
+	compressed, err := Gzip(data)
+	if err != nil {
+		return err
+	}
+	if _, err := s.tmp.Write(compressed); err != nil {
+		return fmt.Errorf("failed to buffer tile: %w", err)
+	}
+	s.entries = append(s.entries, pmEntry{
+		TileID: TileID(t),
+		Offset: uint64(s.size),
+		Length: uint32(len(compressed)),
+		hash:   sha256.Sum256(compressed),
+	})
+	s.size += int64(len(compressed))
+	return nil
+}
+
+// Close writes the archive: header, root directory, metadata, leaf
+// directories and the tile data
+func (s *PMTilesSink) Close(meta Metadata) error {
+	defer os.Remove(s.tmp.Name())
+	defer s.tmp.Close()
+
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].TileID < s.entries[j].TileID })
+
+	// Lay out the tile data in tile ID order, storing repeated tiles once and
+	// merging consecutive repeats into runs
+	var entries []pmEntry
+	var order []pmEntry
+	offsets := make(map[[sha256.Size]byte]uint64)
+	var dataLength uint64
+	for _, e := range s.entries {
+		offset, seen := offsets[e.hash]
+		if !seen {
+			offset = dataLength
+			offsets[e.hash] = offset
+			order = append(order, e)
+			dataLength += uint64(e.Length)
+		}
+		if n := len(entries); n > 0 {
+			last := &entries[n-1]
+			if last.Offset == offset && last.TileID+uint64(last.RunLength) == e.TileID {
+				last.RunLength++
+				continue
+			}
+		}
+		entries = append(entries, pmEntry{TileID: e.TileID, Offset: offset, Length: e.Length, RunLength: 1})
+	}
+
+	root, leaves, err := buildDirectories(entries)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.Marshal(map[string]any{
+		"name":          meta.Name,
+		"format":        "pbf",
+		"type":          "overlay",
+		"vector_layers": vectorLayers(meta),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	metadata, err = Gzip(metadata)
+	if err != nil {
+		return err
+	}
+
+	b := meta.Bounds
+	c := center(meta)
+	h := pmHeader{
+		RootOffset:     pmHeaderSize,
+		RootLength:     uint64(len(root)),
+		MetadataLength: uint64(len(metadata)),
+		LeafLength:     uint64(len(leaves)),
+		DataLength:     dataLength,
+		AddressedTiles: uint64(len(s.entries)),
+		TileEntries:    uint64(len(entries)),
+		TileContents:   uint64(len(order)),
+		MinZoom:        uint8(meta.MinZoom),
+		MaxZoom:        uint8(meta.MaxZoom),
+		Bounds:         [4]int32{e7(b[0]), e7(b[1]), e7(b[2]), e7(b[3])},
+		CenterZoom:     uint8(meta.MinZoom),
+		Center:         [2]int32{e7(c[0]), e7(c[1])},
+	}
+	h.MetadataOffset = h.RootOffset + h.RootLength
+	h.LeafOffset = h.MetadataOffset + h.MetadataLength
+	h.DataOffset = h.LeafOffset + h.LeafLength
+
+	out, err := os.Create(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+
+	for _, part := range [][]byte{h.encode(), root, metadata, leaves} {
+		if _, err := out.Write(part); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	var buf []byte
+	for _, e := range order {
+		if cap(buf) < int(e.Length) {
+			buf = make([]byte, e.Length)
+		}
+		buf = buf[:e.Length]
+		if _, err := s.tmp.ReadAt(buf, int64(e.Offset)); err != nil {
+			return fmt.Errorf("failed to read buffered tile: %w", err)
+		}
+		if _, err := out.Write(buf); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	return out.Close()
+}
+
+// buildDirectories encodes the root directory, splitting the entries into
+// leaf directories when they don't fit in the root
+func buildDirectories(entries []pmEntry) (root, leaves []byte, err error) {
+	root, err = encodeDirectory(entries)
+	if err != nil || len(root) <= pmRootLimit {
+		return root, nil, err
+	}
+
+	for leafSize := 4096; ; leafSize += leafSize / 5 {
+		var rootEntries []pmEntry
+		leaves = nil
+		for start := 0; start < len(entries); start += leafSize {
+			end := min(start+leafSize, len(entries))
+			leaf, err := encodeDirectory(entries[start:end])
+			if err != nil {
+				return nil, nil, err
+			}
+			rootEntries = append(rootEntries, pmEntry{
+				TileID: entries[start].TileID,
+				Offset: uint64(len(leaves)),
+				Length: uint32(len(leaf)),
+			})
+			leaves = append(leaves, leaf...)
+		}
+		root, err = encodeDirectory(rootEntries)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(root) <= pmRootLimit {
+			return root, leaves, nil
+		}
+	}
+}
+
+// encodeDirectory serializes directory entries column by column and gzips
+// them: tile ID deltas, run lengths, lengths, then offsets, where 0 means
+// "right after the previous entry"
+func encodeDirectory(entries []pmEntry) ([]byte, error) {
+	b := appendVarint(nil, uint64(len(entries)))
+	var lastID uint64
+	for _, e := range entries {
+		b = appendVarint(b, e.TileID-lastID)
+		lastID = e.TileID
+	}
+	for _, e := range entries {
+		b = appendVarint(b, uint64(e.RunLength))
+	}
+	for _, e := range entries {
+		b = appendVarint(b, uint64(e.Length))
+	}
+	for i, e := range entries {
+		if i > 0 && e.Offset == entries[i-1].Offset+uint64(entries[i-1].Length) {
+			b = appendVarint(b, 0)
+		} else {
+			b = appendVarint(b, e.Offset+1)
+		}
+	}
+	return Gzip(b)
+}
+
+// pmHeader is the fixed-size PMTiles v3 header
+type pmHeader struct {
+	RootOffset, RootLength         uint64
+	MetadataOffset, MetadataLength uint64
+	LeafOffset, LeafLength         uint64
+	DataOffset, DataLength         uint64
+	AddressedTiles                 uint64
+	TileEntries                    uint64
+	TileContents                   uint64
+	MinZoom, MaxZoom               uint8
+	// Bounds and Center are in degrees × 10^7
+	Bounds     [4]int32
+	CenterZoom uint8
+	Center     [2]int32
+}
+
+func (h pmHeader) encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("PMTiles")
+	buf.WriteByte(3)
+	for _, v := range []uint64{
+		h.RootOffset, h.RootLength, h.MetadataOffset, h.MetadataLength,
+		h.LeafOffset, h.LeafLength, h.DataOffset, h.DataLength,
+		h.AddressedTiles, h.TileEntries, h.TileContents,
+	} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	// Clustered, internal compression, tile compression, tile type
+	buf.Write([]byte{1, pmCompressionGzip, pmCompressionGzip, pmTileTypeMVT})
+	buf.Write([]byte{h.MinZoom, h.MaxZoom})
+	binary.Write(&buf, binary.LittleEndian, h.Bounds)
+	buf.WriteByte(h.CenterZoom)
+	binary.Write(&buf, binary.LittleEndian, h.Center)
+	return buf.Bytes()
+}
+
+// TileID numbers tiles along a Hilbert curve per zoom level, after all the
+// tiles of lower zooms, as PMTiles does
+func TileID(t Tile) uint64 {
+	var id uint64
+	for z := 0; z < t.Z; z++ {
+		id += 1 << (2 * z)
+	}
+	n := int64(1) << t.Z
+	x, y := int64(t.X), int64(t.Y)
+	var d uint64
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry int64
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		d += uint64(s * s * ((3 * rx) ^ ry))
+		if ry == 0 {
+			if rx == 1 {
+				x = n - 1 - x
+				y = n - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+	return id + d
+}
+
+// e7 converts degrees to the fixed-point integers PMTiles stores
+func e7(deg float64) int32 {
+	return int32(math.Round(deg * 1e7))
+}
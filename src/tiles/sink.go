@@ -0,0 +1,201 @@
+package tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/database"
+)
+
+// Sink receives generated tiles
+type Sink interface {
+	// Put stores one encoded, uncompressed tile
+	Put(t Tile, data []byte) error
+	// Close finishes the output once every tile has been written
+	Close(meta Metadata) error
+}
+
+// DirSink writes tiles to a z/x/y.mvt directory tree with a metadata.json
+// in TileJSON form
+type DirSink struct {
+	Dir string
+}
+
+// Put writes a tile to <dir>/<z>/<x>/<y>.mvt
+func (s *DirSink) Put(t Tile, data []byte) error {
+	dir := filepath.Join(s.Dir, strconv.Itoa(t.Z), strconv.Itoa(t.X))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create tile directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, strconv.Itoa(t.Y)+".mvt"), data, 0644)
+}
+
+// Close writes metadata.json
+func (s *DirSink) Close(meta Metadata) error {
+	data, err := json.MarshalIndent(TileJSON(meta, []string{"{z}/{x}/{y}.mvt"}), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create tile directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(s.Dir, "metadata.json"), data, 0644)
+}
+
+// TileJSON describes a tileset in TileJSON 3.0 form
+func TileJSON(meta Metadata, tileURLs []string) map[string]any {
+	return map[string]any{
+		"tilejson":      "3.0.0",
+		"name":          meta.Name,
+		"tiles":         tileURLs,
+		"minzoom":       meta.MinZoom,
+		"maxzoom":       meta.MaxZoom,
+		"bounds":        meta.Bounds[:],
+		"center":        center(meta),
+		"vector_layers": vectorLayers(meta),
+	}
+}
+
+// vectorLayers lists the tileset's layer and its fields, as TileJSON and the
+// MBTiles json metadata expect
+func vectorLayers(meta Metadata) []map[string]any {
+	return []map[string]any{{
+		"id":      meta.Layer,
+		"fields":  meta.Fields,
+		"minzoom": meta.MinZoom,
+		"maxzoom": meta.MaxZoom,
+	}}
+}
+
+// center is the middle of the bounds at the minimum zoom
+func center(meta Metadata) []float64 {
+	b := meta.Bounds
+	return []float64{(b[0] + b[2]) / 2, (b[1] + b[3]) / 2, float64(meta.MinZoom)}
+}
+
+// MBTilesSink writes tiles into an MBTiles 1.3 file through DuckDB's sqlite
+// extension. Tiles are gzip-compressed, as MBTiles readers expect for pbf.
+type MBTilesSink struct {
+	db   *sql.DB
+	stmt *sql.Stmt
+}
+
+// mbtilesAlias is the catalog name the MBTiles file is attached as
+const mbtilesAlias = "mbtiles"
+
+// NewMBTilesSink creates an MBTiles file at path
+func NewMBTilesSink(path string) (*MBTilesSink, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve output path: %w", err)
+	}
+
+	db, err := database.OpenInMemory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite takes one writer at a time, so keep the file's writes on one
+	// connection
+	db.SetMaxOpenConns(1)
+	if err := database.LoadExtension(db, "sqlite"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &MBTilesSink{db: db}
+	alias := database.QuoteIdentifier(mbtilesAlias)
+	statements := []string{
+		fmt.Sprintf("ATTACH %s AS %s (TYPE sqlite)", database.QuoteLiteral(absPath), alias),
+		fmt.Sprintf("CREATE TABLE %s.metadata (name TEXT, value TEXT)", alias),
+		fmt.Sprintf("CREATE TABLE %s.tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)", alias),
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create MBTiles file: %w", err)
+		}
+	}
+
+	s.stmt, err = db.Prepare(fmt.Sprintf("INSERT INTO %s.tiles VALUES (?, ?, ?, ?)", alias))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare tile insert: %w", err)
+	}
+	return s, nil
+}
+
+// Put stores a gzipped tile; MBTiles numbers rows from the bottom (TMS)
+func (s *MBTilesSink) Put(t Tile, data []byte) error {
+	compressed, err := Gzip(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.stmt.Exec(t.Z, t.X, (1<<t.Z)-1-t.Y, compressed)
+	return err
+}
+
+// Close writes the metadata table, indexes the tiles and closes the file
+func (s *MBTilesSink) Close(meta Metadata) error {
+	defer s.db.Close()
+	s.stmt.Close()
+	alias := database.QuoteIdentifier(mbtilesAlias)
+
+	layers, err := json.Marshal(map[string]any{"vector_layers": vectorLayers(meta)})
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	b := meta.Bounds
+	c := center(meta)
+	entries := [][2]string{
+		{"name", meta.Name},
+		{"format", "pbf"},
+		{"type", "overlay"},
+		{"version", "1"},
+		{"minzoom", strconv.Itoa(meta.MinZoom)},
+		{"maxzoom", strconv.Itoa(meta.MaxZoom)},
+		{"bounds", joinFloats(b[:])},
+		{"center", joinFloats(c)},
+		{"json", string(layers)},
+	}
+	for _, e := range entries {
+		if _, err := s.db.Exec(fmt.Sprintf("INSERT INTO %s.metadata VALUES (?, ?)", alias), e[0], e[1]); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX tile_index ON %s.tiles (zoom_level, tile_column, tile_row)", alias)); err != nil {
+		return fmt.Errorf("failed to index tiles: %w", err)
+	}
+	if _, err := s.db.Exec("DETACH " + alias); err != nil {
+		return fmt.Errorf("failed to close MBTiles file: %w", err)
+	}
+	return nil
+}
+
+// Gzip compresses a tile
+func Gzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress tile: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress tile: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func joinFloats(values []float64) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
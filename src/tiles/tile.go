@@ -0,0 +1,91 @@
+package tiles
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxLat is the latitude limit of the Web Mercator tile grid
+const maxLat = 85.0511287798066
+
+// Tile addresses a tile of the XYZ (slippy map) grid
+type Tile struct {
+	Z, X, Y int
+}
+
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Bounds returns the tile's extent in longitude and latitude
+func (t Tile) Bounds() (west, south, east, north float64) {
+	n := math.Exp2(float64(t.Z))
+	west = float64(t.X)/n*360 - 180
+	east = float64(t.X+1)/n*360 - 180
+	north = tileLat(float64(t.Y), n)
+	south = tileLat(float64(t.Y+1), n)
+	return west, south, east, north
+}
+
+// Valid reports whether the tile exists at its zoom level
+func (t Tile) Valid() bool {
+	n := 1 << t.Z
+	return t.Z >= 0 && t.Z <= 30 && t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// Position returns where a lon/lat point falls in the tile, from (0, 0) at
+// the top-left corner to (1, 1) at the bottom-right
+func (t Tile) Position(lon, lat float64) (float64, float64) {
+	n := math.Exp2(float64(t.Z))
+	x, y := project(lon, lat)
+	return x*n - float64(t.X), y*n - float64(t.Y)
+}
+
+// Covering returns the tiles at zoom z intersecting a lon/lat bounding box
+func Covering(bbox [4]float64, z int) []Tile {
+	n := 1 << z
+	minX, maxY := tileXY(bbox[0], bbox[1], z)
+	maxX, minY := tileXY(bbox[2], bbox[3], z)
+
+	var tiles []Tile
+	for x := minX; x <= maxX && x < n; x++ {
+		for y := minY; y <= maxY && y < n; y++ {
+			tiles = append(tiles, Tile{Z: z, X: x, Y: y})
+		}
+	}
+	return tiles
+}
+
+// tileXY returns the tile containing a point at zoom z
+func tileXY(lon, lat float64, z int) (int, int) {
+	n := math.Exp2(float64(z))
+	px, py := project(lon, lat)
+	x := int(math.Floor(px * n))
+	y := int(math.Floor(py * n))
+	limit := int(n) - 1
+	return clamp(x, 0, limit), clamp(y, 0, limit)
+}
+
+// project maps lon/lat to Web Mercator coordinates in [0, 1], y pointing down
+func project(lon, lat float64) (float64, float64) {
+	lat = math.Max(-maxLat, math.Min(maxLat, lat))
+	x := (lon + 180) / 360
+	rad := lat * math.Pi / 180
+	y := (1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2
+	return x, y
+}
+
+// tileLat returns the latitude of the top edge of tile row y of n rows
+func tileLat(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,327 @@
+package topojson
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"org.xyzmaps.xyzduck/src/compressed"
+)
+
+// Topology is a decoded TopoJSON file
+type Topology struct {
+	Type      string                     `json:"type"`
+	Transform *Transform                 `json:"transform"`
+	Arcs      [][][]float64              `json:"arcs"`
+	Objects   map[string]json.RawMessage `json:"objects"`
+}
+
+// Transform dequantizes the positions of a quantized topology
+type Transform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+// object is a TopoJSON geometry object
+type object struct {
+	Type        string          `json:"type"`
+	ID          json.RawMessage `json:"id,omitempty"`
+	Properties  json.RawMessage `json:"properties,omitempty"`
+	Arcs        json.RawMessage `json:"arcs,omitempty"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Geometries  []object        `json:"geometries,omitempty"`
+}
+
+// feature is the GeoJSON form of a TopoJSON object
+type feature struct {
+	Type       string          `json:"type"`
+	ID         json.RawMessage `json:"id,omitempty"`
+	Geometry   interface{}     `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+// geometry is a GeoJSON geometry
+type geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates,omitempty"`
+	Geometries  []geometry  `json:"geometries,omitempty"`
+}
+
+// IsTopoJSON reports whether path is a TopoJSON file: a .topojson extension,
+// or a .json file whose top-level type is Topology
+func IsTopoJSON(path string) bool {
+	switch strings.ToLower(filepath.Ext(compressed.Trim(path))) {
+	case ".topojson":
+		return true
+	case ".json":
+	default:
+		return false
+	}
+
+	f, err := compressed.Open(path, nil)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// The type member usually comes first; only look at the top-level keys
+	dec := json.NewDecoder(bufio.NewReader(f))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if key == "type" {
+			var t string
+			return dec.Decode(&t) == nil && t == "Topology"
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// Read decodes a TopoJSON file
+func Read(path string) (*Topology, error) {
+	data, err := compressed.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TopoJSON file: %w", err)
+	}
+
+	var topo Topology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		return nil, fmt.Errorf("failed to parse TopoJSON: %w", err)
+	}
+	if topo.Type != "Topology" {
+		return nil, fmt.Errorf("not a TopoJSON topology (type %q)", topo.Type)
+	}
+
+	// Quantized arcs are delta-encoded; decode them to absolute positions once
+	for _, arc := range topo.Arcs {
+		var x, y float64
+		for _, p := range arc {
+			if len(p) < 2 {
+				return nil, fmt.Errorf("invalid arc position %v", p)
+			}
+			if topo.Transform != nil {
+				x += p[0]
+				y += p[1]
+				p[0], p[1] = topo.dequantize(x, y)
+			}
+		}
+	}
+
+	return &topo, nil
+}
+
+// ObjectNames returns the names of the topology's objects, sorted
+func (t *Topology) ObjectNames() []string {
+	var names []string
+	for name := range t.Objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteFeatureCollection writes one object as a GeoJSON FeatureCollection. A
+// GeometryCollection object contributes one feature per member geometry.
+func (t *Topology) WriteFeatureCollection(name string, w io.Writer) error {
+	raw, ok := t.Objects[name]
+	if !ok {
+		return fmt.Errorf("object %q not found (available: %s)", name, strings.Join(t.ObjectNames(), ", "))
+	}
+
+	var obj object
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return fmt.Errorf("failed to parse object %q: %w", name, err)
+	}
+
+	members := []object{obj}
+	if obj.Type == "GeometryCollection" {
+		members = obj.Geometries
+	}
+
+	features := make([]feature, 0, len(members))
+	for i, m := range members {
+		geom, err := t.geometry(m)
+		if err != nil {
+			return fmt.Errorf("object %q, geometry %d: %w", name, i, err)
+		}
+		props := m.Properties
+		if len(props) == 0 {
+			props = json.RawMessage("{}")
+		}
+		f := feature{Type: "Feature", ID: m.ID, Properties: props}
+		if geom != nil {
+			f.Geometry = geom
+		}
+		features = append(features, f)
+	}
+
+	out := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: features}
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		return fmt.Errorf("failed to write object %q: %w", name, err)
+	}
+	return nil
+}
+
+// geometry expands a TopoJSON geometry object into GeoJSON, or nil for a null geometry
+func (t *Topology) geometry(o object) (*geometry, error) {
+	g := &geometry{Type: o.Type}
+	switch o.Type {
+	case "", "null":
+		return nil, nil
+	case "Point":
+		var p []float64
+		if err := json.Unmarshal(o.Coordinates, &p); err != nil {
+			return nil, fmt.Errorf("invalid Point coordinates: %w", err)
+		}
+		g.Coordinates = t.position(p)
+	case "MultiPoint":
+		var ps [][]float64
+		if err := json.Unmarshal(o.Coordinates, &ps); err != nil {
+			return nil, fmt.Errorf("invalid MultiPoint coordinates: %w", err)
+		}
+		coords := make([][]float64, len(ps))
+		for i, p := range ps {
+			coords[i] = t.position(p)
+		}
+		g.Coordinates = coords
+	case "LineString":
+		var arcs []int
+		if err := json.Unmarshal(o.Arcs, &arcs); err != nil {
+			return nil, fmt.Errorf("invalid LineString arcs: %w", err)
+		}
+		line, err := t.line(arcs)
+		if err != nil {
+			return nil, err
+		}
+		g.Coordinates = line
+	case "MultiLineString", "Polygon":
+		var arcs [][]int
+		if err := json.Unmarshal(o.Arcs, &arcs); err != nil {
+			return nil, fmt.Errorf("invalid %s arcs: %w", o.Type, err)
+		}
+		lines, err := t.lines(arcs)
+		if err != nil {
+			return nil, err
+		}
+		g.Coordinates = lines
+	case "MultiPolygon":
+		var arcs [][][]int
+		if err := json.Unmarshal(o.Arcs, &arcs); err != nil {
+			return nil, fmt.Errorf("invalid MultiPolygon arcs: %w", err)
+		}
+		polygons := make([][][][]float64, len(arcs))
+		for i, rings := range arcs {
+			lines, err := t.lines(rings)
+			if err != nil {
+				return nil, err
+			}
+			polygons[i] = lines
+		}
+		g.Coordinates = polygons
+	case "GeometryCollection":
+		for _, member := range o.Geometries {
+			sub, err := t.geometry(member)
+			if err != nil {
+				return nil, err
+			}
+			if sub != nil {
+				g.Geometries = append(g.Geometries, *sub)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown geometry type %q", o.Type)
+	}
+	return g, nil
+}
+
+// lines stitches several arc lists, e.g. the rings of a polygon
+func (t *Topology) lines(arcLists [][]int) ([][][]float64, error) {
+	lines := make([][][]float64, len(arcLists))
+	for i, arcs := range arcLists {
+		line, err := t.line(arcs)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = line
+	}
+	return lines, nil
+}
+
+// line stitches arcs into one line. A negative index ~i means arc i reversed;
+// each arc after the first starts where the previous one ended, so its first
+// position is dropped.
+func (t *Topology) line(arcs []int) ([][]float64, error) {
+	var line [][]float64
+	for k, index := range arcs {
+		reversed := index < 0
+		if reversed {
+			index = ^index
+		}
+		if index >= len(t.Arcs) {
+			return nil, fmt.Errorf("arc %d out of range (topology has %d arcs)", index, len(t.Arcs))
+		}
+
+		arc := t.Arcs[index]
+		points := make([][]float64, len(arc))
+		for i, p := range arc {
+			if reversed {
+				points[len(arc)-1-i] = p[:2]
+			} else {
+				points[i] = p[:2]
+			}
+		}
+		if k > 0 && len(points) > 0 {
+			points = points[1:]
+		}
+		line = append(line, points...)
+	}
+	return line, nil
+}
+
+// position dequantizes a Point or MultiPoint position, which isn't delta-encoded
+func (t *Topology) position(p []float64) []float64 {
+	if t.Transform == nil || len(p) < 2 {
+		return p
+	}
+	x, y := t.dequantize(p[0], p[1])
+	return []float64{x, y}
+}
+
+// dequantize applies the transform to a quantized position
+func (t *Topology) dequantize(x, y float64) (float64, float64) {
+	return x*t.Transform.Scale[0] + t.Transform.Translate[0], y*t.Transform.Scale[1] + t.Transform.Translate[1]
+}
+
+// ExtractObject writes one object to a temporary GeoJSON file, returning its
+// path and a function that removes the file
+func (t *Topology) ExtractObject(name string) (string, func(), error) {
+	tmp, err := os.CreateTemp("", "xyzduck-topojson-*.geojson")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer tmp.Close()
+
+	if err := t.WriteFeatureCollection(name, tmp); err != nil {
+		os.Remove(tmp.Name())
+		return "", nil, err
+	}
+
+	return tmp.Name(), func() { os.Remove(tmp.Name()) }, nil
+}
@@ -0,0 +1,221 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Record is one run of a command. Records never leave the machine.
+type Record struct {
+	Time     time.Time     `json:"time"`
+	Command  string        `json:"command"`
+	Duration time.Duration `json:"duration"`
+	Failed   bool          `json:"failed,omitempty"`
+	// Database, Table and Rows describe the data a command changed
+	Database string `json:"database,omitempty"`
+	Table    string `json:"table,omitempty"`
+	Rows     int64  `json:"rows,omitempty"`
+	// Query is the SQL run by the query command
+	Query string `json:"query,omitempty"`
+}
+
+// ConfigDir returns the per-user configuration directory
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("XYZDUCK_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(base, "xyzduck"), nil
+}
+
+// HistoryPath returns the file command history is kept in. History is only
+// recorded while the file exists.
+func HistoryPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "usage.jsonl"), nil
+}
+
+// Enabled reports whether command history is being recorded
+func Enabled() bool {
+	path, err := HistoryPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Enable starts recording command history
+func Enable() error {
+	path, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create usage history: %w", err)
+	}
+	return f.Close()
+}
+
+// Disable stops recording and deletes the recorded history
+func Disable() error {
+	path, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete usage history: %w", err)
+	}
+	return nil
+}
+
+// Append adds a record to the history. It does nothing unless recording
+// has been enabled.
+func Append(r Record) error {
+	path, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open usage history: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage history: %w", err)
+	}
+	return nil
+}
+
+// Read returns the recorded history, oldest first. Lines that can't be
+// parsed, e.g. from an interrupted write, are skipped.
+func Read() ([]Record, error) {
+	path, err := HistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage history: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage history: %w", err)
+	}
+	return records, nil
+}
+
+// CommandStats summarizes the runs of one command
+type CommandStats struct {
+	Command  string
+	Runs     int
+	Failures int
+	Total    time.Duration
+}
+
+// Summary is the report over a stretch of history
+type Summary struct {
+	Runs  int
+	First time.Time
+	// Commands are ordered by number of runs
+	Commands []CommandStats
+	// Loads are the runs that changed the most rows
+	Loads []Record
+	// Queries are the slowest successful query runs
+	Queries []Record
+}
+
+// Summarize reports on the records since a time, keeping the top n loads
+// and queries
+func Summarize(records []Record, since time.Time, n int) Summary {
+	var s Summary
+	byCommand := map[string]*CommandStats{}
+	for _, r := range records {
+		if r.Time.Before(since) {
+			continue
+		}
+		if s.Runs == 0 || r.Time.Before(s.First) {
+			s.First = r.Time
+		}
+		s.Runs++
+
+		stats := byCommand[r.Command]
+		if stats == nil {
+			stats = &CommandStats{Command: r.Command}
+			byCommand[r.Command] = stats
+		}
+		stats.Runs++
+		stats.Total += r.Duration
+		if r.Failed {
+			stats.Failures++
+			continue
+		}
+		if r.Rows > 0 {
+			s.Loads = append(s.Loads, r)
+		}
+		if r.Query != "" {
+			s.Queries = append(s.Queries, r)
+		}
+	}
+
+	for _, stats := range byCommand {
+		s.Commands = append(s.Commands, *stats)
+	}
+	sort.Slice(s.Commands, func(i, j int) bool {
+		if s.Commands[i].Runs != s.Commands[j].Runs {
+			return s.Commands[i].Runs > s.Commands[j].Runs
+		}
+		return s.Commands[i].Command < s.Commands[j].Command
+	})
+	sort.SliceStable(s.Loads, func(i, j int) bool { return s.Loads[i].Rows > s.Loads[j].Rows })
+	sort.SliceStable(s.Queries, func(i, j int) bool { return s.Queries[i].Duration > s.Queries[j].Duration })
+
+	if len(s.Commands) > n {
+		s.Commands = s.Commands[:n]
+	}
+	if len(s.Loads) > n {
+		s.Loads = s.Loads[:n]
+	}
+	if len(s.Queries) > n {
+		s.Queries = s.Queries[:n]
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes where an ingestion worker reads from and loads into
+type Config struct {
+	// DB is the database objects are loaded into
+	DB string `yaml:"db"`
+	// Queue is the SQS queue receiving S3 notifications
+	Queue string `yaml:"queue,omitempty"`
+	// Region defaults to the queue URL's region
+	Region string `yaml:"region,omitempty"`
+	// Endpoint overrides the S3 endpoint, e.g. for MinIO
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Mappings route objects to tables; the first match wins
+	Mappings []Mapping `yaml:"mappings"`
+}
+
+// Mapping routes the objects under a key prefix to a table
+type Mapping struct {
+	// Bucket restricts the mapping to one bucket; empty matches any
+	Bucket string `yaml:"bucket,omitempty"`
+	// Prefix is the key prefix, e.g. incoming/roads/
+	Prefix string `yaml:"prefix"`
+	// Pattern is an optional glob on the object's file name, e.g. *.geojson
+	Pattern string `yaml:"pattern,omitempty"`
+	// Table receives the objects; {name} is replaced by the file name
+	// without its extension
+	Table string `yaml:"table"`
+}
+
+// LoadConfig reads and checks a worker config file
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worker config: %w", err)
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse worker config: %w", err)
+	}
+
+	if len(c.Mappings) == 0 {
+		return nil, fmt.Errorf("worker config has no mappings")
+	}
+	for i, m := range c.Mappings {
+		if strings.TrimSpace(m.Table) == "" {
+			return nil, fmt.Errorf("worker config: mapping %d has no table", i+1)
+		}
+		if m.Pattern != "" {
+			if _, err := path.Match(m.Pattern, ""); err != nil {
+				return nil, fmt.Errorf("worker config: mapping %d: invalid pattern %q", i+1, m.Pattern)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Route returns the table an object is loaded into, or false when no
+// mapping matches it
+func (c *Config) Route(bucket, key string) (string, bool) {
+	name := path.Base(key)
+	for _, m := range c.Mappings {
+		if m.Bucket != "" && m.Bucket != bucket {
+			continue
+		}
+		if !strings.HasPrefix(key, m.Prefix) {
+			continue
+		}
+		if m.Pattern != "" {
+			if ok, _ := path.Match(m.Pattern, name); !ok {
+				continue
+			}
+		}
+		return strings.ReplaceAll(m.Table, "{name}", tableName(name)), true
+	}
+	return "", false
+}
+
+// tableName derives a table name from a file name
+func tableName(name string) string {
+	name = name[:len(name)-len(path.Ext(name))]
+	name = strings.NewReplacer("-", "_", " ", "_", ".", "_").Replace(name)
+	return name
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Object is an S3 object reported as created
+type Object struct {
+	Bucket string
+	Key    string
+	Size   int64
+	// Region is the bucket's region as the event reports it, or ""
+	Region string
+}
+
+// s3Notification is the body S3 sends to SQS (or SNS) for bucket events
+type s3Notification struct {
+	Event   string `json:"Event"`
+	Records []struct {
+		EventName string `json:"eventName"`
+		AWSRegion string `json:"awsRegion"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// envelope covers SNS notifications and EventBridge events
+type envelope struct {
+	Type       string `json:"Type"`
+	Message    string `json:"Message"`
+	DetailType string `json:"detail-type"`
+	Region     string `json:"region"`
+	Detail     struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+		} `json:"object"`
+	} `json:"detail"`
+}
+
+// CreatedObjects extracts the created objects from a queue message. S3
+// notifications may arrive directly, wrapped in an SNS notification or as
+// EventBridge events. Other events, such as the s3:TestEvent S3 sends when
+// notifications are configured, yield no objects.
+func CreatedObjects(body string) ([]Object, error) {
+	var env envelope
+	if err := json.Unmarshal([]byte(body), &env); err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	switch {
+	case env.Type == "Notification" && env.Message != "":
+		return CreatedObjects(env.Message)
+	case env.DetailType != "":
+		// EventBridge keys aren't URL-encoded
+		if env.DetailType != "Object Created" {
+			return nil, nil
+		}
+		return []Object{{Bucket: env.Detail.Bucket.Name, Key: env.Detail.Object.Key, Size: env.Detail.Object.Size, Region: env.Region}}, nil
+	}
+
+	var n s3Notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil, fmt.Errorf("failed to parse S3 notification: %w", err)
+	}
+
+	var objects []Object
+	for _, r := range n.Records {
+		if !strings.HasPrefix(r.EventName, "ObjectCreated:") {
+			continue
+		}
+		// Notification keys are URL-encoded, with spaces as '+'
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key %q: %w", r.S3.Object.Key, err)
+		}
+		objects = append(objects, Object{Bucket: r.S3.Bucket.Name, Key: key, Size: r.S3.Object.Size, Region: r.AWSRegion})
+	}
+	return objects, nil
+}